@@ -0,0 +1,349 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+
+	proto "github.com/dimitarvdimitrov/sporkfs/api/pb"
+	"github.com/dimitarvdimitrov/sporkfs/log"
+	"github.com/dimitarvdimitrov/sporkfs/raft"
+	"github.com/dimitarvdimitrov/sporkfs/store"
+	"github.com/dimitarvdimitrov/sporkfs/store/history"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Filesystem is what the client API serves. Its methods check the permissions of the user of the context.
+type Filesystem interface {
+	Root() *store.File
+	Linearize(ctx context.Context) (context.Context, error)
+	Lookup(ctx context.Context, f *store.File, name string) (*store.File, error)
+	ACL(ctx context.Context, dir *store.File) (acl store.ACL, inherited bool, err error)
+	SetACL(ctx context.Context, dir *store.File, acl store.ACL) error
+	Quota(ctx context.Context, dir *store.File) (*store.Quota, error)
+	SetQuota(ctx context.Context, dir *store.File, maxBytes, maxInodes int64) error
+	Snapshots(ctx context.Context, dir *store.File) ([]*store.Snapshot, error)
+	CreateSnapshot(ctx context.Context, dir *store.File, name string) error
+	DeleteSnapshot(ctx context.Context, dir *store.File, name string) error
+	Versions(ctx context.Context, dir *store.File, name string) ([]history.Revision, error)
+	RestoreVersion(ctx context.Context, dir *store.File, name string, version uint64) error
+	Copy(ctx context.Context, file, parent *store.File, name string) (*store.File, error)
+	Batch(ctx context.Context, ops []store.Op) error
+	Delete(ctx context.Context, file *store.File) error
+	DeleteAll(ctx context.Context, file *store.File) error
+}
+
+type clientServer struct {
+	fs Filesystem
+}
+
+func NewClientServer(fs Filesystem) *clientServer {
+	return &clientServer{fs: fs}
+}
+
+func (server *clientServer) GetACL(ctx context.Context, req *proto.GetACLRequest) (_ *proto.GetACLReply, err error) {
+	ctx, span := trace.Start(ctx, "api.clientServer.GetACL")
+	defer trace.End(span, &err)
+
+	dir, err := server.resolve(ctx, req.Path)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	acl, inherited, err := server.fs.ACL(ctx, dir)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	reply := &proto.GetACLReply{
+		Acl:       make(map[string]uint32, len(acl)),
+		Inherited: inherited,
+	}
+	for user, perm := range acl {
+		reply.Acl[user] = uint32(perm)
+	}
+	return reply, nil
+}
+
+func (server *clientServer) SetACL(ctx context.Context, req *proto.SetACLRequest) (_ *proto.SetACLReply, err error) {
+	ctx, span := trace.Start(ctx, "api.clientServer.SetACL")
+	defer trace.End(span, &err)
+
+	dir, err := server.resolve(ctx, req.Path)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	var acl store.ACL
+	if len(req.Acl) > 0 {
+		acl = make(store.ACL, len(req.Acl))
+		for user, perm := range req.Acl {
+			acl[user] = store.Permission(perm)
+		}
+	}
+	log.Info("[client_api] setting acl", log.Id(dir.Id), zap.String("path", req.Path), zap.Any("acl", acl))
+
+	if err = server.fs.SetACL(ctx, dir, acl); err != nil {
+		return nil, toStatus(err)
+	}
+	return &proto.SetACLReply{}, nil
+}
+
+func (server *clientServer) GetQuota(ctx context.Context, req *proto.GetQuotaRequest) (_ *proto.GetQuotaReply, err error) {
+	ctx, span := trace.Start(ctx, "api.clientServer.GetQuota")
+	defer trace.End(span, &err)
+
+	dir, err := server.resolve(ctx, req.Path)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	quota, err := server.fs.Quota(ctx, dir)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if quota == nil {
+		return &proto.GetQuotaReply{}, nil
+	}
+
+	maxBytes, maxInodes, used := quota.Usage()
+	return &proto.GetQuotaReply{
+		MaxBytes:   maxBytes,
+		MaxInodes:  maxInodes,
+		UsedBytes:  used.Bytes,
+		UsedInodes: used.Inodes,
+	}, nil
+}
+
+func (server *clientServer) SetQuota(ctx context.Context, req *proto.SetQuotaRequest) (_ *proto.SetQuotaReply, err error) {
+	ctx, span := trace.Start(ctx, "api.clientServer.SetQuota")
+	defer trace.End(span, &err)
+
+	dir, err := server.resolve(ctx, req.Path)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	log.Info("[client_api] setting quota", log.Id(dir.Id), zap.String("path", req.Path), zap.Int64("max_bytes", req.MaxBytes), zap.Int64("max_inodes", req.MaxInodes))
+
+	if err = server.fs.SetQuota(ctx, dir, req.MaxBytes, req.MaxInodes); err != nil {
+		return nil, toStatus(err)
+	}
+	return &proto.SetQuotaReply{}, nil
+}
+
+func (server *clientServer) ListSnapshots(ctx context.Context, req *proto.ListSnapshotsRequest) (_ *proto.ListSnapshotsReply, err error) {
+	ctx, span := trace.Start(ctx, "api.clientServer.ListSnapshots")
+	defer trace.End(span, &err)
+
+	dir, err := server.resolve(ctx, req.Path)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	snapshots, err := server.fs.Snapshots(ctx, dir)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	reply := &proto.ListSnapshotsReply{}
+	for _, snap := range snapshots {
+		reply.Snapshots = append(reply.Snapshots, &proto.ListSnapshotsReply_Snapshot{
+			Name:    snap.Name,
+			Created: snap.Created.UnixNano(),
+		})
+	}
+	return reply, nil
+}
+
+func (server *clientServer) CreateSnapshot(ctx context.Context, req *proto.CreateSnapshotRequest) (_ *proto.CreateSnapshotReply, err error) {
+	ctx, span := trace.Start(ctx, "api.clientServer.CreateSnapshot")
+	defer trace.End(span, &err)
+
+	dir, err := server.resolve(ctx, req.Path)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	log.Info("[client_api] creating snapshot", log.Id(dir.Id), zap.String("path", req.Path), log.Name(req.Name))
+
+	if err = server.fs.CreateSnapshot(ctx, dir, req.Name); err != nil {
+		return nil, toStatus(err)
+	}
+	return &proto.CreateSnapshotReply{}, nil
+}
+
+func (server *clientServer) DeleteSnapshot(ctx context.Context, req *proto.DeleteSnapshotRequest) (_ *proto.DeleteSnapshotReply, err error) {
+	ctx, span := trace.Start(ctx, "api.clientServer.DeleteSnapshot")
+	defer trace.End(span, &err)
+
+	dir, err := server.resolve(ctx, req.Path)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	log.Info("[client_api] deleting snapshot", log.Id(dir.Id), zap.String("path", req.Path), log.Name(req.Name))
+
+	if err = server.fs.DeleteSnapshot(ctx, dir, req.Name); err != nil {
+		return nil, toStatus(err)
+	}
+	return &proto.DeleteSnapshotReply{}, nil
+}
+
+func (server *clientServer) ListVersions(ctx context.Context, req *proto.ListVersionsRequest) (_ *proto.ListVersionsReply, err error) {
+	ctx, span := trace.Start(ctx, "api.clientServer.ListVersions")
+	defer trace.End(span, &err)
+
+	dir, err := server.resolve(ctx, path.Dir(req.Path))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	revisions, err := server.fs.Versions(ctx, dir, path.Base(req.Path))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	reply := &proto.ListVersionsReply{}
+	for _, r := range revisions {
+		reply.Versions = append(reply.Versions, &proto.ListVersionsReply_Version{
+			Version:  r.Version,
+			Size:     r.Size,
+			Modified: r.Mtime.UnixNano(),
+			Retired:  r.Retired.UnixNano(),
+		})
+	}
+	return reply, nil
+}
+
+func (server *clientServer) RestoreVersion(ctx context.Context, req *proto.RestoreVersionRequest) (_ *proto.RestoreVersionReply, err error) {
+	ctx, span := trace.Start(ctx, "api.clientServer.RestoreVersion")
+	defer trace.End(span, &err)
+
+	dir, err := server.resolve(ctx, path.Dir(req.Path))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	log.Info("[client_api] restoring version", log.Id(dir.Id), zap.String("path", req.Path), log.Ver(req.Version))
+
+	if err = server.fs.RestoreVersion(ctx, dir, path.Base(req.Path), req.Version); err != nil {
+		return nil, toStatus(err)
+	}
+	return &proto.RestoreVersionReply{}, nil
+}
+
+func (server *clientServer) CopyFile(ctx context.Context, req *proto.CopyFileRequest) (_ *proto.CopyFileReply, err error) {
+	ctx, span := trace.Start(ctx, "api.clientServer.CopyFile")
+	defer trace.End(span, &err)
+
+	file, err := server.resolve(ctx, req.SourcePath)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	parent, err := server.resolve(ctx, path.Dir(req.DestinationPath))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	log.Info("[client_api] copying file", log.Id(file.Id), zap.String("source", req.SourcePath), zap.String("destination", req.DestinationPath))
+
+	if _, err = server.fs.Copy(ctx, file, parent, path.Base(req.DestinationPath)); err != nil {
+		return nil, toStatus(err)
+	}
+	return &proto.CopyFileReply{}, nil
+}
+
+func (server *clientServer) Batch(ctx context.Context, req *proto.BatchRequest) (_ *proto.BatchReply, err error) {
+	ctx, span := trace.Start(ctx, "api.clientServer.Batch")
+	defer trace.End(span, &err)
+
+	ops := make([]store.Op, 0, len(req.Operations))
+	for i, op := range req.Operations {
+		switch o := op.Operation.(type) {
+		case *proto.BatchRequest_Operation_Create:
+			mode := store.ModeRegularFile
+			if o.Create.Directory {
+				mode = store.ModeDirectory | 0777
+			}
+			ops = append(ops, store.Op{Kind: store.OpCreate, Path: o.Create.Path, Mode: mode})
+		case *proto.BatchRequest_Operation_Write:
+			ops = append(ops, store.Op{Kind: store.OpWrite, Path: o.Write.Path, Data: o.Write.Data})
+		case *proto.BatchRequest_Operation_Rename:
+			ops = append(ops, store.Op{Kind: store.OpRename, Path: o.Rename.Path, NewPath: o.Rename.NewPath})
+		case *proto.BatchRequest_Operation_Delete:
+			ops = append(ops, store.Op{Kind: store.OpDelete, Path: o.Delete.Path})
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "operation %d is empty", i)
+		}
+	}
+	log.Info("[client_api] applying batch", zap.Int("operations", len(ops)))
+
+	if err = server.fs.Batch(ctx, ops); err != nil {
+		return nil, toStatus(err)
+	}
+	return &proto.BatchReply{}, nil
+}
+
+// DeleteFile deletes the file at the path. Directories which aren't empty are only deleted with everything in them
+// if the request is recursive.
+func (server *clientServer) DeleteFile(ctx context.Context, req *proto.DeleteFileRequest) (_ *proto.DeleteFileReply, err error) {
+	ctx, span := trace.Start(ctx, "api.clientServer.DeleteFile")
+	defer trace.End(span, &err)
+
+	file, err := server.resolve(ctx, req.Path)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if file.Parent == nil {
+		return nil, toStatus(store.ErrReadOnly)
+	}
+	log.Info("[client_api] deleting file", log.Id(file.Id), zap.String("path", req.Path), zap.Bool("recursive", req.Recursive))
+
+	if req.Recursive {
+		err = server.fs.DeleteAll(ctx, file)
+	} else {
+		err = server.fs.Delete(ctx, file)
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &proto.DeleteFileReply{}, nil
+}
+
+// resolve looks up the file at the absolute path.
+func (server *clientServer) resolve(ctx context.Context, path string) (*store.File, error) {
+	ctx, err := server.fs.Linearize(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	f := server.fs.Root()
+	for _, name := range strings.Split(path, "/") {
+		if name == "" || name == "." {
+			continue
+		}
+		if f, err = server.fs.Lookup(ctx, f, name); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, store.ErrNoSuchFile):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, store.ErrFileAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, store.ErrDirectoryNotEmpty), errors.Is(err, store.ErrNotDirectory), errors.Is(err, store.ErrIsDirectory):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, store.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, store.ErrQuotaExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, store.ErrReadOnly):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, raft.ErrNoLeader):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, raft.ErrTimedOut):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return err
+	}
+}
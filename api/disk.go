@@ -0,0 +1,23 @@
+package api
+
+import (
+	"context"
+	"syscall"
+
+	proto "github.com/dimitarvdimitrov/sporkfs/api/pb"
+)
+
+// DiskUsage reports the capacity of the file system on which the data dir is.
+func (server *fileServer) DiskUsage(ctx context.Context, req *proto.DiskUsageRequest) (*proto.DiskUsageReply, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(server.dataDir, &st); err != nil {
+		return nil, err
+	}
+
+	blockSize := uint64(st.Bsize)
+	return &proto.DiskUsageReply{
+		TotalBytes:     uint64(st.Blocks) * blockSize,
+		FreeBytes:      uint64(st.Bfree) * blockSize,
+		AvailableBytes: uint64(st.Bavail) * blockSize,
+	}, nil
+}
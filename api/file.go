@@ -6,8 +6,11 @@ import (
 
 	proto "github.com/dimitarvdimitrov/sporkfs/api/pb"
 	"github.com/dimitarvdimitrov/sporkfs/log"
+	"github.com/dimitarvdimitrov/sporkfs/qos"
 	"github.com/dimitarvdimitrov/sporkfs/store"
 	"github.com/dimitarvdimitrov/sporkfs/store/data"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
+	"go.uber.org/zap"
 )
 
 // ChunkSize is the size of the chunk of file
@@ -16,17 +19,24 @@ const ChunkSize = 1 << 16
 
 type fileServer struct {
 	data, cache data.Driver
+	limiter     *qos.Limiter
+	dataDir     string
 }
 
-func NewFileServer(s, c data.Driver) *fileServer {
+func NewFileServer(s, c data.Driver, limiter *qos.Limiter, dataDir string) *fileServer {
 	return &fileServer{
-		data:  s,
-		cache: c,
+		data:    s,
+		cache:   c,
+		limiter: limiter,
+		dataDir: dataDir,
 	}
 }
 
-func (server *fileServer) Read(req *proto.ReadRequest, stream proto.File_ReadServer) error {
-	log.Debug("[file_api] received read grpc request", log.Id(req.Id), log.Ver(req.Version))
+func (server *fileServer) Read(req *proto.ReadRequest, stream proto.File_ReadServer) (err error) {
+	ctx, span := trace.Start(stream.Context(), "api.fileServer.Read", trace.Id(req.Id), trace.Ver(req.Version))
+	defer trace.End(span, &err)
+
+	log.Debug("[file_api] received read grpc request", log.Id(req.Id), log.Ver(req.Version), zap.Int64("offset", req.Offset), zap.Int64("length", req.Length))
 	defer log.Debug("[file_api] returned read grpc request", log.Id(req.Id), log.Ver(req.Version))
 
 	var src data.Driver
@@ -43,16 +53,28 @@ func (server *fileServer) Read(req *proto.ReadRequest, stream proto.File_ReadSer
 	if err != nil {
 		return err
 	}
+	defer reader.Close()
 
 	// send an empty reply to confirm we have the file
 	if err = stream.Send(&proto.ReadReply{}); err != nil {
 		return err
 	}
 
-	off := int64(0)
+	class := qos.Class(req.TrafficClass)
+	off := req.Offset
 	buff := make([]byte, ChunkSize, ChunkSize)
 
 	for {
+		if req.Length > 0 {
+			remaining := req.Offset + req.Length - off
+			if remaining <= 0 {
+				break
+			}
+			if remaining < int64(len(buff)) {
+				buff = buff[:remaining]
+			}
+		}
+
 		n, err := reader.ReadAt(buff, off)
 		if err != nil && err != io.EOF {
 			return err
@@ -61,6 +83,10 @@ func (server *fileServer) Read(req *proto.ReadRequest, stream proto.File_ReadSer
 			break
 		}
 
+		if err = server.limiter.Wait(ctx, class, n); err != nil {
+			return err
+		}
+
 		msg := &proto.ReadReply{
 			Content: buff[:n],
 		}
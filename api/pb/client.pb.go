@@ -0,0 +1,2001 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: client.proto
+
+package proto
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type GetACLRequest struct {
+	Path                 string   `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetACLRequest) Reset()         { *m = GetACLRequest{} }
+func (m *GetACLRequest) String() string { return proto.CompactTextString(m) }
+func (*GetACLRequest) ProtoMessage()    {}
+func (*GetACLRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{0}
+}
+
+func (m *GetACLRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetACLRequest.Unmarshal(m, b)
+}
+func (m *GetACLRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetACLRequest.Marshal(b, m, deterministic)
+}
+func (m *GetACLRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetACLRequest.Merge(m, src)
+}
+func (m *GetACLRequest) XXX_Size() int {
+	return xxx_messageInfo_GetACLRequest.Size(m)
+}
+func (m *GetACLRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetACLRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetACLRequest proto.InternalMessageInfo
+
+func (m *GetACLRequest) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+type GetACLReply struct {
+	// users mapped to their permissions (store.Permission) in the directory
+	Acl map[string]uint32 `protobuf:"bytes,1,rep,name=acl,proto3" json:"acl,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	// inherited is true if the directory doesn't have an ACL and the ACL is that of the closest ancestor which does
+	Inherited            bool     `protobuf:"varint,2,opt,name=inherited,proto3" json:"inherited,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetACLReply) Reset()         { *m = GetACLReply{} }
+func (m *GetACLReply) String() string { return proto.CompactTextString(m) }
+func (*GetACLReply) ProtoMessage()    {}
+func (*GetACLReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{1}
+}
+
+func (m *GetACLReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetACLReply.Unmarshal(m, b)
+}
+func (m *GetACLReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetACLReply.Marshal(b, m, deterministic)
+}
+func (m *GetACLReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetACLReply.Merge(m, src)
+}
+func (m *GetACLReply) XXX_Size() int {
+	return xxx_messageInfo_GetACLReply.Size(m)
+}
+func (m *GetACLReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetACLReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetACLReply proto.InternalMessageInfo
+
+func (m *GetACLReply) GetAcl() map[string]uint32 {
+	if m != nil {
+		return m.Acl
+	}
+	return nil
+}
+
+func (m *GetACLReply) GetInherited() bool {
+	if m != nil {
+		return m.Inherited
+	}
+	return false
+}
+
+type SetACLRequest struct {
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// users mapped to their permissions (store.Permission); empty means the directory inherits the ACL of its parent
+	Acl                  map[string]uint32 `protobuf:"bytes,2,rep,name=acl,proto3" json:"acl,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *SetACLRequest) Reset()         { *m = SetACLRequest{} }
+func (m *SetACLRequest) String() string { return proto.CompactTextString(m) }
+func (*SetACLRequest) ProtoMessage()    {}
+func (*SetACLRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{2}
+}
+
+func (m *SetACLRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetACLRequest.Unmarshal(m, b)
+}
+func (m *SetACLRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetACLRequest.Marshal(b, m, deterministic)
+}
+func (m *SetACLRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetACLRequest.Merge(m, src)
+}
+func (m *SetACLRequest) XXX_Size() int {
+	return xxx_messageInfo_SetACLRequest.Size(m)
+}
+func (m *SetACLRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetACLRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetACLRequest proto.InternalMessageInfo
+
+func (m *SetACLRequest) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *SetACLRequest) GetAcl() map[string]uint32 {
+	if m != nil {
+		return m.Acl
+	}
+	return nil
+}
+
+type SetACLReply struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetACLReply) Reset()         { *m = SetACLReply{} }
+func (m *SetACLReply) String() string { return proto.CompactTextString(m) }
+func (*SetACLReply) ProtoMessage()    {}
+func (*SetACLReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{3}
+}
+
+func (m *SetACLReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetACLReply.Unmarshal(m, b)
+}
+func (m *SetACLReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetACLReply.Marshal(b, m, deterministic)
+}
+func (m *SetACLReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetACLReply.Merge(m, src)
+}
+func (m *SetACLReply) XXX_Size() int {
+	return xxx_messageInfo_SetACLReply.Size(m)
+}
+func (m *SetACLReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetACLReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetACLReply proto.InternalMessageInfo
+
+type GetQuotaRequest struct {
+	Path                 string   `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetQuotaRequest) Reset()         { *m = GetQuotaRequest{} }
+func (m *GetQuotaRequest) String() string { return proto.CompactTextString(m) }
+func (*GetQuotaRequest) ProtoMessage()    {}
+func (*GetQuotaRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{4}
+}
+
+func (m *GetQuotaRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetQuotaRequest.Unmarshal(m, b)
+}
+func (m *GetQuotaRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetQuotaRequest.Marshal(b, m, deterministic)
+}
+func (m *GetQuotaRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetQuotaRequest.Merge(m, src)
+}
+func (m *GetQuotaRequest) XXX_Size() int {
+	return xxx_messageInfo_GetQuotaRequest.Size(m)
+}
+func (m *GetQuotaRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetQuotaRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetQuotaRequest proto.InternalMessageInfo
+
+func (m *GetQuotaRequest) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+type GetQuotaReply struct {
+	// the limits are 0 if they aren't set
+	MaxBytes  int64 `protobuf:"varint,1,opt,name=max_bytes,json=maxBytes,proto3" json:"max_bytes,omitempty"`
+	MaxInodes int64 `protobuf:"varint,2,opt,name=max_inodes,json=maxInodes,proto3" json:"max_inodes,omitempty"`
+	// the usage is only known for directories with a quota
+	UsedBytes            int64    `protobuf:"varint,3,opt,name=used_bytes,json=usedBytes,proto3" json:"used_bytes,omitempty"`
+	UsedInodes           int64    `protobuf:"varint,4,opt,name=used_inodes,json=usedInodes,proto3" json:"used_inodes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetQuotaReply) Reset()         { *m = GetQuotaReply{} }
+func (m *GetQuotaReply) String() string { return proto.CompactTextString(m) }
+func (*GetQuotaReply) ProtoMessage()    {}
+func (*GetQuotaReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{5}
+}
+
+func (m *GetQuotaReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetQuotaReply.Unmarshal(m, b)
+}
+func (m *GetQuotaReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetQuotaReply.Marshal(b, m, deterministic)
+}
+func (m *GetQuotaReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetQuotaReply.Merge(m, src)
+}
+func (m *GetQuotaReply) XXX_Size() int {
+	return xxx_messageInfo_GetQuotaReply.Size(m)
+}
+func (m *GetQuotaReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetQuotaReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetQuotaReply proto.InternalMessageInfo
+
+func (m *GetQuotaReply) GetMaxBytes() int64 {
+	if m != nil {
+		return m.MaxBytes
+	}
+	return 0
+}
+
+func (m *GetQuotaReply) GetMaxInodes() int64 {
+	if m != nil {
+		return m.MaxInodes
+	}
+	return 0
+}
+
+func (m *GetQuotaReply) GetUsedBytes() int64 {
+	if m != nil {
+		return m.UsedBytes
+	}
+	return 0
+}
+
+func (m *GetQuotaReply) GetUsedInodes() int64 {
+	if m != nil {
+		return m.UsedInodes
+	}
+	return 0
+}
+
+type SetQuotaRequest struct {
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// 0 means unlimited; if both are 0 the quota is removed
+	MaxBytes             int64    `protobuf:"varint,2,opt,name=max_bytes,json=maxBytes,proto3" json:"max_bytes,omitempty"`
+	MaxInodes            int64    `protobuf:"varint,3,opt,name=max_inodes,json=maxInodes,proto3" json:"max_inodes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetQuotaRequest) Reset()         { *m = SetQuotaRequest{} }
+func (m *SetQuotaRequest) String() string { return proto.CompactTextString(m) }
+func (*SetQuotaRequest) ProtoMessage()    {}
+func (*SetQuotaRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{6}
+}
+
+func (m *SetQuotaRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetQuotaRequest.Unmarshal(m, b)
+}
+func (m *SetQuotaRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetQuotaRequest.Marshal(b, m, deterministic)
+}
+func (m *SetQuotaRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetQuotaRequest.Merge(m, src)
+}
+func (m *SetQuotaRequest) XXX_Size() int {
+	return xxx_messageInfo_SetQuotaRequest.Size(m)
+}
+func (m *SetQuotaRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetQuotaRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetQuotaRequest proto.InternalMessageInfo
+
+func (m *SetQuotaRequest) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *SetQuotaRequest) GetMaxBytes() int64 {
+	if m != nil {
+		return m.MaxBytes
+	}
+	return 0
+}
+
+func (m *SetQuotaRequest) GetMaxInodes() int64 {
+	if m != nil {
+		return m.MaxInodes
+	}
+	return 0
+}
+
+type SetQuotaReply struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetQuotaReply) Reset()         { *m = SetQuotaReply{} }
+func (m *SetQuotaReply) String() string { return proto.CompactTextString(m) }
+func (*SetQuotaReply) ProtoMessage()    {}
+func (*SetQuotaReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{7}
+}
+
+func (m *SetQuotaReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetQuotaReply.Unmarshal(m, b)
+}
+func (m *SetQuotaReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetQuotaReply.Marshal(b, m, deterministic)
+}
+func (m *SetQuotaReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetQuotaReply.Merge(m, src)
+}
+func (m *SetQuotaReply) XXX_Size() int {
+	return xxx_messageInfo_SetQuotaReply.Size(m)
+}
+func (m *SetQuotaReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetQuotaReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetQuotaReply proto.InternalMessageInfo
+
+type ListSnapshotsRequest struct {
+	// path of the directory
+	Path                 string   `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListSnapshotsRequest) Reset()         { *m = ListSnapshotsRequest{} }
+func (m *ListSnapshotsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListSnapshotsRequest) ProtoMessage()    {}
+func (*ListSnapshotsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{8}
+}
+
+func (m *ListSnapshotsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListSnapshotsRequest.Unmarshal(m, b)
+}
+func (m *ListSnapshotsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListSnapshotsRequest.Marshal(b, m, deterministic)
+}
+func (m *ListSnapshotsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListSnapshotsRequest.Merge(m, src)
+}
+func (m *ListSnapshotsRequest) XXX_Size() int {
+	return xxx_messageInfo_ListSnapshotsRequest.Size(m)
+}
+func (m *ListSnapshotsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListSnapshotsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListSnapshotsRequest proto.InternalMessageInfo
+
+func (m *ListSnapshotsRequest) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+type ListSnapshotsReply struct {
+	// from the oldest to the newest
+	Snapshots            []*ListSnapshotsReply_Snapshot `protobuf:"bytes,1,rep,name=snapshots,proto3" json:"snapshots,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                       `json:"-"`
+	XXX_unrecognized     []byte                         `json:"-"`
+	XXX_sizecache        int32                          `json:"-"`
+}
+
+func (m *ListSnapshotsReply) Reset()         { *m = ListSnapshotsReply{} }
+func (m *ListSnapshotsReply) String() string { return proto.CompactTextString(m) }
+func (*ListSnapshotsReply) ProtoMessage()    {}
+func (*ListSnapshotsReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{9}
+}
+
+func (m *ListSnapshotsReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListSnapshotsReply.Unmarshal(m, b)
+}
+func (m *ListSnapshotsReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListSnapshotsReply.Marshal(b, m, deterministic)
+}
+func (m *ListSnapshotsReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListSnapshotsReply.Merge(m, src)
+}
+func (m *ListSnapshotsReply) XXX_Size() int {
+	return xxx_messageInfo_ListSnapshotsReply.Size(m)
+}
+func (m *ListSnapshotsReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListSnapshotsReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListSnapshotsReply proto.InternalMessageInfo
+
+func (m *ListSnapshotsReply) GetSnapshots() []*ListSnapshotsReply_Snapshot {
+	if m != nil {
+		return m.Snapshots
+	}
+	return nil
+}
+
+type ListSnapshotsReply_Snapshot struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// when the snapshot was taken in unix nanoseconds
+	Created              int64    `protobuf:"varint,2,opt,name=created,proto3" json:"created,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListSnapshotsReply_Snapshot) Reset()         { *m = ListSnapshotsReply_Snapshot{} }
+func (m *ListSnapshotsReply_Snapshot) String() string { return proto.CompactTextString(m) }
+func (*ListSnapshotsReply_Snapshot) ProtoMessage()    {}
+func (*ListSnapshotsReply_Snapshot) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{9, 0}
+}
+
+func (m *ListSnapshotsReply_Snapshot) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListSnapshotsReply_Snapshot.Unmarshal(m, b)
+}
+func (m *ListSnapshotsReply_Snapshot) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListSnapshotsReply_Snapshot.Marshal(b, m, deterministic)
+}
+func (m *ListSnapshotsReply_Snapshot) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListSnapshotsReply_Snapshot.Merge(m, src)
+}
+func (m *ListSnapshotsReply_Snapshot) XXX_Size() int {
+	return xxx_messageInfo_ListSnapshotsReply_Snapshot.Size(m)
+}
+func (m *ListSnapshotsReply_Snapshot) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListSnapshotsReply_Snapshot.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListSnapshotsReply_Snapshot proto.InternalMessageInfo
+
+func (m *ListSnapshotsReply_Snapshot) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ListSnapshotsReply_Snapshot) GetCreated() int64 {
+	if m != nil {
+		return m.Created
+	}
+	return 0
+}
+
+type CreateSnapshotRequest struct {
+	// path of the directory
+	Path                 string   `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CreateSnapshotRequest) Reset()         { *m = CreateSnapshotRequest{} }
+func (m *CreateSnapshotRequest) String() string { return proto.CompactTextString(m) }
+func (*CreateSnapshotRequest) ProtoMessage()    {}
+func (*CreateSnapshotRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{10}
+}
+
+func (m *CreateSnapshotRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateSnapshotRequest.Unmarshal(m, b)
+}
+func (m *CreateSnapshotRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateSnapshotRequest.Marshal(b, m, deterministic)
+}
+func (m *CreateSnapshotRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateSnapshotRequest.Merge(m, src)
+}
+func (m *CreateSnapshotRequest) XXX_Size() int {
+	return xxx_messageInfo_CreateSnapshotRequest.Size(m)
+}
+func (m *CreateSnapshotRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateSnapshotRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateSnapshotRequest proto.InternalMessageInfo
+
+func (m *CreateSnapshotRequest) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *CreateSnapshotRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+type CreateSnapshotReply struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CreateSnapshotReply) Reset()         { *m = CreateSnapshotReply{} }
+func (m *CreateSnapshotReply) String() string { return proto.CompactTextString(m) }
+func (*CreateSnapshotReply) ProtoMessage()    {}
+func (*CreateSnapshotReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{11}
+}
+
+func (m *CreateSnapshotReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateSnapshotReply.Unmarshal(m, b)
+}
+func (m *CreateSnapshotReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateSnapshotReply.Marshal(b, m, deterministic)
+}
+func (m *CreateSnapshotReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateSnapshotReply.Merge(m, src)
+}
+func (m *CreateSnapshotReply) XXX_Size() int {
+	return xxx_messageInfo_CreateSnapshotReply.Size(m)
+}
+func (m *CreateSnapshotReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateSnapshotReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateSnapshotReply proto.InternalMessageInfo
+
+type DeleteSnapshotRequest struct {
+	// path of the directory
+	Path                 string   `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteSnapshotRequest) Reset()         { *m = DeleteSnapshotRequest{} }
+func (m *DeleteSnapshotRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteSnapshotRequest) ProtoMessage()    {}
+func (*DeleteSnapshotRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{12}
+}
+
+func (m *DeleteSnapshotRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteSnapshotRequest.Unmarshal(m, b)
+}
+func (m *DeleteSnapshotRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteSnapshotRequest.Marshal(b, m, deterministic)
+}
+func (m *DeleteSnapshotRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteSnapshotRequest.Merge(m, src)
+}
+func (m *DeleteSnapshotRequest) XXX_Size() int {
+	return xxx_messageInfo_DeleteSnapshotRequest.Size(m)
+}
+func (m *DeleteSnapshotRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteSnapshotRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteSnapshotRequest proto.InternalMessageInfo
+
+func (m *DeleteSnapshotRequest) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *DeleteSnapshotRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+type DeleteSnapshotReply struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteSnapshotReply) Reset()         { *m = DeleteSnapshotReply{} }
+func (m *DeleteSnapshotReply) String() string { return proto.CompactTextString(m) }
+func (*DeleteSnapshotReply) ProtoMessage()    {}
+func (*DeleteSnapshotReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{13}
+}
+
+func (m *DeleteSnapshotReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteSnapshotReply.Unmarshal(m, b)
+}
+func (m *DeleteSnapshotReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteSnapshotReply.Marshal(b, m, deterministic)
+}
+func (m *DeleteSnapshotReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteSnapshotReply.Merge(m, src)
+}
+func (m *DeleteSnapshotReply) XXX_Size() int {
+	return xxx_messageInfo_DeleteSnapshotReply.Size(m)
+}
+func (m *DeleteSnapshotReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteSnapshotReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteSnapshotReply proto.InternalMessageInfo
+
+type ListVersionsRequest struct {
+	// the path of the file; it may have been deleted
+	Path                 string   `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListVersionsRequest) Reset()         { *m = ListVersionsRequest{} }
+func (m *ListVersionsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListVersionsRequest) ProtoMessage()    {}
+func (*ListVersionsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{14}
+}
+
+func (m *ListVersionsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListVersionsRequest.Unmarshal(m, b)
+}
+func (m *ListVersionsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListVersionsRequest.Marshal(b, m, deterministic)
+}
+func (m *ListVersionsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListVersionsRequest.Merge(m, src)
+}
+func (m *ListVersionsRequest) XXX_Size() int {
+	return xxx_messageInfo_ListVersionsRequest.Size(m)
+}
+func (m *ListVersionsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListVersionsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListVersionsRequest proto.InternalMessageInfo
+
+func (m *ListVersionsRequest) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+type ListVersionsReply struct {
+	// from the newest to the oldest
+	Versions             []*ListVersionsReply_Version `protobuf:"bytes,1,rep,name=versions,proto3" json:"versions,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                     `json:"-"`
+	XXX_unrecognized     []byte                       `json:"-"`
+	XXX_sizecache        int32                        `json:"-"`
+}
+
+func (m *ListVersionsReply) Reset()         { *m = ListVersionsReply{} }
+func (m *ListVersionsReply) String() string { return proto.CompactTextString(m) }
+func (*ListVersionsReply) ProtoMessage()    {}
+func (*ListVersionsReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{15}
+}
+
+func (m *ListVersionsReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListVersionsReply.Unmarshal(m, b)
+}
+func (m *ListVersionsReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListVersionsReply.Marshal(b, m, deterministic)
+}
+func (m *ListVersionsReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListVersionsReply.Merge(m, src)
+}
+func (m *ListVersionsReply) XXX_Size() int {
+	return xxx_messageInfo_ListVersionsReply.Size(m)
+}
+func (m *ListVersionsReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListVersionsReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListVersionsReply proto.InternalMessageInfo
+
+func (m *ListVersionsReply) GetVersions() []*ListVersionsReply_Version {
+	if m != nil {
+		return m.Versions
+	}
+	return nil
+}
+
+type ListVersionsReply_Version struct {
+	Version uint64 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Size    int64  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	// unix nanoseconds of when the version was written and when it was superseded or its file was deleted
+	Modified             int64    `protobuf:"varint,3,opt,name=modified,proto3" json:"modified,omitempty"`
+	Retired              int64    `protobuf:"varint,4,opt,name=retired,proto3" json:"retired,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListVersionsReply_Version) Reset()         { *m = ListVersionsReply_Version{} }
+func (m *ListVersionsReply_Version) String() string { return proto.CompactTextString(m) }
+func (*ListVersionsReply_Version) ProtoMessage()    {}
+func (*ListVersionsReply_Version) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{15, 0}
+}
+
+func (m *ListVersionsReply_Version) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListVersionsReply_Version.Unmarshal(m, b)
+}
+func (m *ListVersionsReply_Version) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListVersionsReply_Version.Marshal(b, m, deterministic)
+}
+func (m *ListVersionsReply_Version) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListVersionsReply_Version.Merge(m, src)
+}
+func (m *ListVersionsReply_Version) XXX_Size() int {
+	return xxx_messageInfo_ListVersionsReply_Version.Size(m)
+}
+func (m *ListVersionsReply_Version) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListVersionsReply_Version.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListVersionsReply_Version proto.InternalMessageInfo
+
+func (m *ListVersionsReply_Version) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *ListVersionsReply_Version) GetSize() int64 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
+func (m *ListVersionsReply_Version) GetModified() int64 {
+	if m != nil {
+		return m.Modified
+	}
+	return 0
+}
+
+func (m *ListVersionsReply_Version) GetRetired() int64 {
+	if m != nil {
+		return m.Retired
+	}
+	return 0
+}
+
+type RestoreVersionRequest struct {
+	// the path of the file; if it was deleted, it's created again
+	Path                 string   `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Version              uint64   `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RestoreVersionRequest) Reset()         { *m = RestoreVersionRequest{} }
+func (m *RestoreVersionRequest) String() string { return proto.CompactTextString(m) }
+func (*RestoreVersionRequest) ProtoMessage()    {}
+func (*RestoreVersionRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{16}
+}
+
+func (m *RestoreVersionRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RestoreVersionRequest.Unmarshal(m, b)
+}
+func (m *RestoreVersionRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RestoreVersionRequest.Marshal(b, m, deterministic)
+}
+func (m *RestoreVersionRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RestoreVersionRequest.Merge(m, src)
+}
+func (m *RestoreVersionRequest) XXX_Size() int {
+	return xxx_messageInfo_RestoreVersionRequest.Size(m)
+}
+func (m *RestoreVersionRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RestoreVersionRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RestoreVersionRequest proto.InternalMessageInfo
+
+func (m *RestoreVersionRequest) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *RestoreVersionRequest) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+type RestoreVersionReply struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RestoreVersionReply) Reset()         { *m = RestoreVersionReply{} }
+func (m *RestoreVersionReply) String() string { return proto.CompactTextString(m) }
+func (*RestoreVersionReply) ProtoMessage()    {}
+func (*RestoreVersionReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{17}
+}
+
+func (m *RestoreVersionReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RestoreVersionReply.Unmarshal(m, b)
+}
+func (m *RestoreVersionReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RestoreVersionReply.Marshal(b, m, deterministic)
+}
+func (m *RestoreVersionReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RestoreVersionReply.Merge(m, src)
+}
+func (m *RestoreVersionReply) XXX_Size() int {
+	return xxx_messageInfo_RestoreVersionReply.Size(m)
+}
+func (m *RestoreVersionReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_RestoreVersionReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RestoreVersionReply proto.InternalMessageInfo
+
+type CopyFileRequest struct {
+	SourcePath string `protobuf:"bytes,1,opt,name=source_path,json=sourcePath,proto3" json:"source_path,omitempty"`
+	// the path of the new file; it can't exist yet
+	DestinationPath      string   `protobuf:"bytes,2,opt,name=destination_path,json=destinationPath,proto3" json:"destination_path,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CopyFileRequest) Reset()         { *m = CopyFileRequest{} }
+func (m *CopyFileRequest) String() string { return proto.CompactTextString(m) }
+func (*CopyFileRequest) ProtoMessage()    {}
+func (*CopyFileRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{18}
+}
+
+func (m *CopyFileRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CopyFileRequest.Unmarshal(m, b)
+}
+func (m *CopyFileRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CopyFileRequest.Marshal(b, m, deterministic)
+}
+func (m *CopyFileRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CopyFileRequest.Merge(m, src)
+}
+func (m *CopyFileRequest) XXX_Size() int {
+	return xxx_messageInfo_CopyFileRequest.Size(m)
+}
+func (m *CopyFileRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CopyFileRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CopyFileRequest proto.InternalMessageInfo
+
+func (m *CopyFileRequest) GetSourcePath() string {
+	if m != nil {
+		return m.SourcePath
+	}
+	return ""
+}
+
+func (m *CopyFileRequest) GetDestinationPath() string {
+	if m != nil {
+		return m.DestinationPath
+	}
+	return ""
+}
+
+type CopyFileReply struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CopyFileReply) Reset()         { *m = CopyFileReply{} }
+func (m *CopyFileReply) String() string { return proto.CompactTextString(m) }
+func (*CopyFileReply) ProtoMessage()    {}
+func (*CopyFileReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{19}
+}
+
+func (m *CopyFileReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CopyFileReply.Unmarshal(m, b)
+}
+func (m *CopyFileReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CopyFileReply.Marshal(b, m, deterministic)
+}
+func (m *CopyFileReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CopyFileReply.Merge(m, src)
+}
+func (m *CopyFileReply) XXX_Size() int {
+	return xxx_messageInfo_CopyFileReply.Size(m)
+}
+func (m *CopyFileReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_CopyFileReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CopyFileReply proto.InternalMessageInfo
+
+type BatchRequest struct {
+	// applied in order; paths see the effects of the earlier operations
+	Operations           []*BatchRequest_Operation `protobuf:"bytes,1,rep,name=operations,proto3" json:"operations,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                  `json:"-"`
+	XXX_unrecognized     []byte                    `json:"-"`
+	XXX_sizecache        int32                     `json:"-"`
+}
+
+func (m *BatchRequest) Reset()         { *m = BatchRequest{} }
+func (m *BatchRequest) String() string { return proto.CompactTextString(m) }
+func (*BatchRequest) ProtoMessage()    {}
+func (*BatchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{20}
+}
+
+func (m *BatchRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BatchRequest.Unmarshal(m, b)
+}
+func (m *BatchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BatchRequest.Marshal(b, m, deterministic)
+}
+func (m *BatchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BatchRequest.Merge(m, src)
+}
+func (m *BatchRequest) XXX_Size() int {
+	return xxx_messageInfo_BatchRequest.Size(m)
+}
+func (m *BatchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_BatchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BatchRequest proto.InternalMessageInfo
+
+func (m *BatchRequest) GetOperations() []*BatchRequest_Operation {
+	if m != nil {
+		return m.Operations
+	}
+	return nil
+}
+
+type BatchRequest_Create struct {
+	Path                 string   `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Directory            bool     `protobuf:"varint,2,opt,name=directory,proto3" json:"directory,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BatchRequest_Create) Reset()         { *m = BatchRequest_Create{} }
+func (m *BatchRequest_Create) String() string { return proto.CompactTextString(m) }
+func (*BatchRequest_Create) ProtoMessage()    {}
+func (*BatchRequest_Create) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{20, 0}
+}
+
+func (m *BatchRequest_Create) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BatchRequest_Create.Unmarshal(m, b)
+}
+func (m *BatchRequest_Create) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BatchRequest_Create.Marshal(b, m, deterministic)
+}
+func (m *BatchRequest_Create) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BatchRequest_Create.Merge(m, src)
+}
+func (m *BatchRequest_Create) XXX_Size() int {
+	return xxx_messageInfo_BatchRequest_Create.Size(m)
+}
+func (m *BatchRequest_Create) XXX_DiscardUnknown() {
+	xxx_messageInfo_BatchRequest_Create.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BatchRequest_Create proto.InternalMessageInfo
+
+func (m *BatchRequest_Create) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *BatchRequest_Create) GetDirectory() bool {
+	if m != nil {
+		return m.Directory
+	}
+	return false
+}
+
+type BatchRequest_Write struct {
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// replaces the whole content of the file
+	Data                 []byte   `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BatchRequest_Write) Reset()         { *m = BatchRequest_Write{} }
+func (m *BatchRequest_Write) String() string { return proto.CompactTextString(m) }
+func (*BatchRequest_Write) ProtoMessage()    {}
+func (*BatchRequest_Write) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{20, 1}
+}
+
+func (m *BatchRequest_Write) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BatchRequest_Write.Unmarshal(m, b)
+}
+func (m *BatchRequest_Write) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BatchRequest_Write.Marshal(b, m, deterministic)
+}
+func (m *BatchRequest_Write) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BatchRequest_Write.Merge(m, src)
+}
+func (m *BatchRequest_Write) XXX_Size() int {
+	return xxx_messageInfo_BatchRequest_Write.Size(m)
+}
+func (m *BatchRequest_Write) XXX_DiscardUnknown() {
+	xxx_messageInfo_BatchRequest_Write.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BatchRequest_Write proto.InternalMessageInfo
+
+func (m *BatchRequest_Write) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *BatchRequest_Write) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+type BatchRequest_Rename struct {
+	Path                 string   `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	NewPath              string   `protobuf:"bytes,2,opt,name=new_path,json=newPath,proto3" json:"new_path,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BatchRequest_Rename) Reset()         { *m = BatchRequest_Rename{} }
+func (m *BatchRequest_Rename) String() string { return proto.CompactTextString(m) }
+func (*BatchRequest_Rename) ProtoMessage()    {}
+func (*BatchRequest_Rename) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{20, 2}
+}
+
+func (m *BatchRequest_Rename) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BatchRequest_Rename.Unmarshal(m, b)
+}
+func (m *BatchRequest_Rename) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BatchRequest_Rename.Marshal(b, m, deterministic)
+}
+func (m *BatchRequest_Rename) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BatchRequest_Rename.Merge(m, src)
+}
+func (m *BatchRequest_Rename) XXX_Size() int {
+	return xxx_messageInfo_BatchRequest_Rename.Size(m)
+}
+func (m *BatchRequest_Rename) XXX_DiscardUnknown() {
+	xxx_messageInfo_BatchRequest_Rename.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BatchRequest_Rename proto.InternalMessageInfo
+
+func (m *BatchRequest_Rename) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *BatchRequest_Rename) GetNewPath() string {
+	if m != nil {
+		return m.NewPath
+	}
+	return ""
+}
+
+type BatchRequest_Delete struct {
+	Path                 string   `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BatchRequest_Delete) Reset()         { *m = BatchRequest_Delete{} }
+func (m *BatchRequest_Delete) String() string { return proto.CompactTextString(m) }
+func (*BatchRequest_Delete) ProtoMessage()    {}
+func (*BatchRequest_Delete) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{20, 3}
+}
+
+func (m *BatchRequest_Delete) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BatchRequest_Delete.Unmarshal(m, b)
+}
+func (m *BatchRequest_Delete) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BatchRequest_Delete.Marshal(b, m, deterministic)
+}
+func (m *BatchRequest_Delete) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BatchRequest_Delete.Merge(m, src)
+}
+func (m *BatchRequest_Delete) XXX_Size() int {
+	return xxx_messageInfo_BatchRequest_Delete.Size(m)
+}
+func (m *BatchRequest_Delete) XXX_DiscardUnknown() {
+	xxx_messageInfo_BatchRequest_Delete.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BatchRequest_Delete proto.InternalMessageInfo
+
+func (m *BatchRequest_Delete) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+type BatchRequest_Operation struct {
+	// Types that are valid to be assigned to Operation:
+	//	*BatchRequest_Operation_Create
+	//	*BatchRequest_Operation_Write
+	//	*BatchRequest_Operation_Rename
+	//	*BatchRequest_Operation_Delete
+	Operation            isBatchRequest_Operation_Operation `protobuf_oneof:"operation"`
+	XXX_NoUnkeyedLiteral struct{}                           `json:"-"`
+	XXX_unrecognized     []byte                             `json:"-"`
+	XXX_sizecache        int32                              `json:"-"`
+}
+
+func (m *BatchRequest_Operation) Reset()         { *m = BatchRequest_Operation{} }
+func (m *BatchRequest_Operation) String() string { return proto.CompactTextString(m) }
+func (*BatchRequest_Operation) ProtoMessage()    {}
+func (*BatchRequest_Operation) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{20, 4}
+}
+
+func (m *BatchRequest_Operation) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BatchRequest_Operation.Unmarshal(m, b)
+}
+func (m *BatchRequest_Operation) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BatchRequest_Operation.Marshal(b, m, deterministic)
+}
+func (m *BatchRequest_Operation) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BatchRequest_Operation.Merge(m, src)
+}
+func (m *BatchRequest_Operation) XXX_Size() int {
+	return xxx_messageInfo_BatchRequest_Operation.Size(m)
+}
+func (m *BatchRequest_Operation) XXX_DiscardUnknown() {
+	xxx_messageInfo_BatchRequest_Operation.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BatchRequest_Operation proto.InternalMessageInfo
+
+type isBatchRequest_Operation_Operation interface {
+	isBatchRequest_Operation_Operation()
+}
+
+type BatchRequest_Operation_Create struct {
+	Create *BatchRequest_Create `protobuf:"bytes,1,opt,name=create,proto3,oneof"`
+}
+
+type BatchRequest_Operation_Write struct {
+	Write *BatchRequest_Write `protobuf:"bytes,2,opt,name=write,proto3,oneof"`
+}
+
+type BatchRequest_Operation_Rename struct {
+	Rename *BatchRequest_Rename `protobuf:"bytes,3,opt,name=rename,proto3,oneof"`
+}
+
+type BatchRequest_Operation_Delete struct {
+	Delete *BatchRequest_Delete `protobuf:"bytes,4,opt,name=delete,proto3,oneof"`
+}
+
+func (*BatchRequest_Operation_Create) isBatchRequest_Operation_Operation() {}
+
+func (*BatchRequest_Operation_Write) isBatchRequest_Operation_Operation() {}
+
+func (*BatchRequest_Operation_Rename) isBatchRequest_Operation_Operation() {}
+
+func (*BatchRequest_Operation_Delete) isBatchRequest_Operation_Operation() {}
+
+func (m *BatchRequest_Operation) GetOperation() isBatchRequest_Operation_Operation {
+	if m != nil {
+		return m.Operation
+	}
+	return nil
+}
+
+func (m *BatchRequest_Operation) GetCreate() *BatchRequest_Create {
+	if x, ok := m.GetOperation().(*BatchRequest_Operation_Create); ok {
+		return x.Create
+	}
+	return nil
+}
+
+func (m *BatchRequest_Operation) GetWrite() *BatchRequest_Write {
+	if x, ok := m.GetOperation().(*BatchRequest_Operation_Write); ok {
+		return x.Write
+	}
+	return nil
+}
+
+func (m *BatchRequest_Operation) GetRename() *BatchRequest_Rename {
+	if x, ok := m.GetOperation().(*BatchRequest_Operation_Rename); ok {
+		return x.Rename
+	}
+	return nil
+}
+
+func (m *BatchRequest_Operation) GetDelete() *BatchRequest_Delete {
+	if x, ok := m.GetOperation().(*BatchRequest_Operation_Delete); ok {
+		return x.Delete
+	}
+	return nil
+}
+
+// XXX_OneofWrappers is for the internal use of the proto package.
+func (*BatchRequest_Operation) XXX_OneofWrappers() []interface{} {
+	return []interface{}{
+		(*BatchRequest_Operation_Create)(nil),
+		(*BatchRequest_Operation_Write)(nil),
+		(*BatchRequest_Operation_Rename)(nil),
+		(*BatchRequest_Operation_Delete)(nil),
+	}
+}
+
+type BatchReply struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BatchReply) Reset()         { *m = BatchReply{} }
+func (m *BatchReply) String() string { return proto.CompactTextString(m) }
+func (*BatchReply) ProtoMessage()    {}
+func (*BatchReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{21}
+}
+
+func (m *BatchReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BatchReply.Unmarshal(m, b)
+}
+func (m *BatchReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BatchReply.Marshal(b, m, deterministic)
+}
+func (m *BatchReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BatchReply.Merge(m, src)
+}
+func (m *BatchReply) XXX_Size() int {
+	return xxx_messageInfo_BatchReply.Size(m)
+}
+func (m *BatchReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_BatchReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BatchReply proto.InternalMessageInfo
+
+type DeleteFileRequest struct {
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// without it, only empty directories can be deleted
+	Recursive            bool     `protobuf:"varint,2,opt,name=recursive,proto3" json:"recursive,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteFileRequest) Reset()         { *m = DeleteFileRequest{} }
+func (m *DeleteFileRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteFileRequest) ProtoMessage()    {}
+func (*DeleteFileRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{22}
+}
+
+func (m *DeleteFileRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteFileRequest.Unmarshal(m, b)
+}
+func (m *DeleteFileRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteFileRequest.Marshal(b, m, deterministic)
+}
+func (m *DeleteFileRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteFileRequest.Merge(m, src)
+}
+func (m *DeleteFileRequest) XXX_Size() int {
+	return xxx_messageInfo_DeleteFileRequest.Size(m)
+}
+func (m *DeleteFileRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteFileRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteFileRequest proto.InternalMessageInfo
+
+func (m *DeleteFileRequest) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *DeleteFileRequest) GetRecursive() bool {
+	if m != nil {
+		return m.Recursive
+	}
+	return false
+}
+
+type DeleteFileReply struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteFileReply) Reset()         { *m = DeleteFileReply{} }
+func (m *DeleteFileReply) String() string { return proto.CompactTextString(m) }
+func (*DeleteFileReply) ProtoMessage()    {}
+func (*DeleteFileReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_014de31d7ac8c57c, []int{23}
+}
+
+func (m *DeleteFileReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteFileReply.Unmarshal(m, b)
+}
+func (m *DeleteFileReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteFileReply.Marshal(b, m, deterministic)
+}
+func (m *DeleteFileReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteFileReply.Merge(m, src)
+}
+func (m *DeleteFileReply) XXX_Size() int {
+	return xxx_messageInfo_DeleteFileReply.Size(m)
+}
+func (m *DeleteFileReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteFileReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteFileReply proto.InternalMessageInfo
+
+func init() {
+	proto.RegisterType((*GetACLRequest)(nil), "GetACLRequest")
+	proto.RegisterType((*GetACLReply)(nil), "GetACLReply")
+	proto.RegisterMapType((map[string]uint32)(nil), "GetACLReply.AclEntry")
+	proto.RegisterType((*SetACLRequest)(nil), "SetACLRequest")
+	proto.RegisterMapType((map[string]uint32)(nil), "SetACLRequest.AclEntry")
+	proto.RegisterType((*SetACLReply)(nil), "SetACLReply")
+	proto.RegisterType((*GetQuotaRequest)(nil), "GetQuotaRequest")
+	proto.RegisterType((*GetQuotaReply)(nil), "GetQuotaReply")
+	proto.RegisterType((*SetQuotaRequest)(nil), "SetQuotaRequest")
+	proto.RegisterType((*SetQuotaReply)(nil), "SetQuotaReply")
+	proto.RegisterType((*ListSnapshotsRequest)(nil), "ListSnapshotsRequest")
+	proto.RegisterType((*ListSnapshotsReply)(nil), "ListSnapshotsReply")
+	proto.RegisterType((*ListSnapshotsReply_Snapshot)(nil), "ListSnapshotsReply.Snapshot")
+	proto.RegisterType((*CreateSnapshotRequest)(nil), "CreateSnapshotRequest")
+	proto.RegisterType((*CreateSnapshotReply)(nil), "CreateSnapshotReply")
+	proto.RegisterType((*DeleteSnapshotRequest)(nil), "DeleteSnapshotRequest")
+	proto.RegisterType((*DeleteSnapshotReply)(nil), "DeleteSnapshotReply")
+	proto.RegisterType((*ListVersionsRequest)(nil), "ListVersionsRequest")
+	proto.RegisterType((*ListVersionsReply)(nil), "ListVersionsReply")
+	proto.RegisterType((*ListVersionsReply_Version)(nil), "ListVersionsReply.Version")
+	proto.RegisterType((*RestoreVersionRequest)(nil), "RestoreVersionRequest")
+	proto.RegisterType((*RestoreVersionReply)(nil), "RestoreVersionReply")
+	proto.RegisterType((*CopyFileRequest)(nil), "CopyFileRequest")
+	proto.RegisterType((*CopyFileReply)(nil), "CopyFileReply")
+	proto.RegisterType((*BatchRequest)(nil), "BatchRequest")
+	proto.RegisterType((*BatchRequest_Create)(nil), "BatchRequest.Create")
+	proto.RegisterType((*BatchRequest_Write)(nil), "BatchRequest.Write")
+	proto.RegisterType((*BatchRequest_Rename)(nil), "BatchRequest.Rename")
+	proto.RegisterType((*BatchRequest_Delete)(nil), "BatchRequest.Delete")
+	proto.RegisterType((*BatchRequest_Operation)(nil), "BatchRequest.Operation")
+	proto.RegisterType((*BatchReply)(nil), "BatchReply")
+	proto.RegisterType((*DeleteFileRequest)(nil), "DeleteFileRequest")
+	proto.RegisterType((*DeleteFileReply)(nil), "DeleteFileReply")
+}
+
+func init() { proto.RegisterFile("client.proto", fileDescriptor_014de31d7ac8c57c) }
+
+var fileDescriptor_014de31d7ac8c57c = []byte{
+	// 975 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x56, 0xed, 0x6e, 0x1b, 0x45,
+	0x14, 0xf5, 0x7a, 0x6d, 0xc7, 0xbe, 0x6b, 0xc7, 0xce, 0xc4, 0x4e, 0xcd, 0x10, 0xd4, 0x68, 0x51,
+	0x45, 0x0a, 0xd2, 0x20, 0x05, 0xd4, 0x56, 0x91, 0x50, 0x69, 0x42, 0x68, 0x91, 0x2a, 0x01, 0xb3,
+	0x12, 0x48, 0x48, 0xa8, 0xda, 0x7a, 0x2f, 0xca, 0x0a, 0x7b, 0xd7, 0xec, 0x8e, 0x93, 0x9a, 0x07,
+	0xe0, 0x47, 0xe1, 0x89, 0x78, 0x0f, 0x9e, 0x80, 0x17, 0x41, 0x33, 0xb3, 0xe3, 0xfd, 0xf0, 0xd6,
+	0x95, 0xe8, 0x2f, 0xcf, 0xdc, 0x39, 0xf7, 0xcc, 0x9d, 0x33, 0x77, 0xcf, 0x18, 0xfa, 0xb3, 0x79,
+	0x88, 0x91, 0x60, 0xcb, 0x24, 0x16, 0xb1, 0xfb, 0x21, 0x0c, 0x9e, 0xa2, 0x78, 0x72, 0xf9, 0x9c,
+	0xe3, 0x6f, 0x2b, 0x4c, 0x05, 0x21, 0xd0, 0x5a, 0xfa, 0xe2, 0x7a, 0x6a, 0x9d, 0x58, 0xa7, 0x3d,
+	0xae, 0xc6, 0xee, 0x5f, 0x16, 0x38, 0x06, 0xb5, 0x9c, 0xaf, 0xc9, 0x47, 0x60, 0xfb, 0xb3, 0xf9,
+	0xd4, 0x3a, 0xb1, 0x4f, 0x9d, 0xb3, 0x09, 0x2b, 0x2c, 0xb1, 0x27, 0xb3, 0xf9, 0x55, 0x24, 0x92,
+	0x35, 0x97, 0x08, 0x72, 0x0c, 0xbd, 0x30, 0xba, 0xc6, 0x24, 0x14, 0x18, 0x4c, 0x9b, 0x27, 0xd6,
+	0x69, 0x97, 0xe7, 0x01, 0xfa, 0x00, 0xba, 0x06, 0x4e, 0x46, 0x60, 0xff, 0x8a, 0xeb, 0x6c, 0x57,
+	0x39, 0x24, 0x63, 0x68, 0xdf, 0xf8, 0xf3, 0x15, 0xaa, 0xbc, 0x01, 0xd7, 0x93, 0xf3, 0xe6, 0x23,
+	0xcb, 0xfd, 0xc3, 0x82, 0x81, 0xf7, 0xb6, 0xa2, 0xc9, 0x7d, 0x5d, 0x64, 0x53, 0x15, 0x79, 0x87,
+	0x95, 0x12, 0xca, 0x65, 0xfe, 0xef, 0x42, 0x06, 0xe0, 0x78, 0xf9, 0xd9, 0xdd, 0x7b, 0x30, 0x7c,
+	0x8a, 0xe2, 0xfb, 0x55, 0x2c, 0xfc, 0x5d, 0x6a, 0xfe, 0x69, 0xc1, 0x20, 0xc7, 0x49, 0x3d, 0xdf,
+	0x87, 0xde, 0xc2, 0x7f, 0xf5, 0xe2, 0xe5, 0x5a, 0x60, 0xaa, 0xa0, 0x36, 0xef, 0x2e, 0xfc, 0x57,
+	0x17, 0x72, 0x4e, 0x3e, 0x00, 0x90, 0x8b, 0x61, 0x14, 0x07, 0x98, 0xaa, 0x1a, 0x6c, 0x2e, 0xe1,
+	0xdf, 0xa8, 0x80, 0x5c, 0x5e, 0xa5, 0x18, 0x64, 0xc9, 0xb6, 0x5e, 0x96, 0x11, 0x9d, 0x7d, 0x17,
+	0x1c, 0xb5, 0x9c, 0xa5, 0xb7, 0xd4, 0xba, 0xca, 0xd0, 0xf9, 0xae, 0x0f, 0x43, 0xef, 0xed, 0x45,
+	0x97, 0x4b, 0x6c, 0xee, 0x2c, 0xd1, 0xae, 0x94, 0xe8, 0x0e, 0x61, 0x90, 0x6f, 0x21, 0x85, 0xfa,
+	0x18, 0xc6, 0xcf, 0xc3, 0x54, 0x78, 0x91, 0xbf, 0x4c, 0xaf, 0x63, 0x91, 0xee, 0x52, 0xeb, 0xb5,
+	0x05, 0xa4, 0x02, 0x96, 0x92, 0x9d, 0x43, 0x2f, 0x35, 0x91, 0xac, 0x11, 0x8f, 0xd9, 0x36, 0x8e,
+	0x99, 0x29, 0xcf, 0xe1, 0xf4, 0x11, 0x74, 0x4d, 0x58, 0x6e, 0x19, 0xf9, 0x0b, 0x34, 0x5b, 0xca,
+	0x31, 0x99, 0xc2, 0xde, 0x2c, 0x41, 0xdf, 0xf4, 0xac, 0xcd, 0xcd, 0xd4, 0x7d, 0x0c, 0x93, 0x4b,
+	0x35, 0xdc, 0xd0, 0xee, 0x90, 0xcc, 0x50, 0x37, 0x73, 0x6a, 0x77, 0x02, 0x87, 0x55, 0x02, 0x29,
+	0xc8, 0x63, 0x98, 0x7c, 0x85, 0x73, 0x7c, 0x27, 0xde, 0x2a, 0x81, 0xe4, 0xbd, 0x0f, 0x87, 0x52,
+	0x93, 0x1f, 0x30, 0x49, 0xc3, 0x38, 0xda, 0xa9, 0xf3, 0xdf, 0x16, 0x1c, 0x94, 0xb1, 0x52, 0xe6,
+	0x07, 0xd0, 0xbd, 0xc9, 0x02, 0x99, 0xca, 0x94, 0x6d, 0xa1, 0x58, 0x36, 0xe3, 0x1b, 0x2c, 0x5d,
+	0xc0, 0x5e, 0x16, 0x94, 0x6a, 0x66, 0x61, 0xb5, 0x5f, 0x8b, 0x9b, 0xa9, 0x2c, 0x23, 0x0d, 0x7f,
+	0xc7, 0x4c, 0x64, 0x35, 0x26, 0x14, 0xba, 0x8b, 0x38, 0x08, 0x7f, 0x09, 0x31, 0xc8, 0x1a, 0x69,
+	0x33, 0x97, 0x4c, 0x09, 0x8a, 0x30, 0xc1, 0x20, 0xeb, 0x63, 0x33, 0x75, 0xaf, 0x60, 0xc2, 0x31,
+	0x15, 0x71, 0x82, 0xa6, 0x94, 0x1d, 0xfa, 0x15, 0x0a, 0x6a, 0x96, 0x0a, 0x92, 0x2a, 0x56, 0x69,
+	0xa4, 0x8a, 0x3f, 0xc3, 0xf0, 0x32, 0x5e, 0xae, 0xbf, 0x0e, 0xe7, 0x68, 0x78, 0xef, 0x82, 0x93,
+	0xc6, 0xab, 0x64, 0x86, 0x2f, 0x0a, 0xf4, 0xa0, 0x43, 0xdf, 0x69, 0xf7, 0x19, 0x05, 0x98, 0x8a,
+	0x30, 0xf2, 0x45, 0x18, 0x47, 0x1a, 0xa5, 0x2f, 0x6c, 0x58, 0x88, 0x4b, 0xa8, 0xfc, 0x3c, 0x72,
+	0x7a, 0xb9, 0xdf, 0xbf, 0x36, 0xf4, 0x2f, 0x7c, 0x31, 0xbb, 0x36, 0xbb, 0x3d, 0x04, 0x88, 0x97,
+	0x98, 0xf8, 0xa2, 0x70, 0x0f, 0x77, 0x58, 0x11, 0xc2, 0xbe, 0x35, 0xeb, 0xbc, 0x00, 0xa5, 0xe7,
+	0xd0, 0xd1, 0xed, 0x56, 0x2b, 0xc4, 0x31, 0xf4, 0x82, 0x30, 0xc1, 0x99, 0x88, 0x93, 0xb5, 0x71,
+	0xe7, 0x4d, 0x80, 0x7e, 0x0a, 0xed, 0x1f, 0x93, 0xf0, 0x0d, 0xa9, 0x04, 0x5a, 0x81, 0x2f, 0x7c,
+	0x95, 0xd5, 0xe7, 0x6a, 0x4c, 0x1f, 0x42, 0x87, 0xa3, 0xfa, 0x80, 0xea, 0x32, 0xde, 0x83, 0x6e,
+	0x84, 0xb7, 0x45, 0x21, 0xf6, 0x22, 0xbc, 0x95, 0x02, 0xd0, 0x63, 0xe8, 0xe8, 0xe6, 0xad, 0x4b,
+	0xa4, 0xff, 0x58, 0xd0, 0xdb, 0x9c, 0x8e, 0x30, 0xe8, 0xe8, 0x8f, 0x51, 0x61, 0x9c, 0xb3, 0x71,
+	0x59, 0x06, 0x7d, 0xda, 0x67, 0x0d, 0x9e, 0xa1, 0xc8, 0x27, 0xd0, 0xbe, 0x95, 0xa7, 0x50, 0x7b,
+	0x3a, 0x67, 0x87, 0x65, 0xb8, 0x3a, 0xe0, 0xb3, 0x06, 0xd7, 0x18, 0x49, 0x9e, 0xa8, 0x13, 0x4c,
+	0xed, 0x3a, 0x72, 0x7d, 0x3a, 0x49, 0xae, 0x51, 0x12, 0x1f, 0xa8, 0xc2, 0xa7, 0xad, 0x3a, 0xbc,
+	0x3e, 0x94, 0xc4, 0x6b, 0xd4, 0x85, 0x03, 0xbd, 0xcd, 0xe5, 0xb8, 0x7d, 0x80, 0x0c, 0x2d, 0xef,
+	0xfc, 0x0a, 0x0e, 0x34, 0xbc, 0xd8, 0x65, 0x6f, 0xb8, 0xb4, 0x04, 0x67, 0xab, 0x24, 0x0d, 0x6f,
+	0xd0, 0x5c, 0xda, 0x26, 0xe0, 0x1e, 0xc0, 0xb0, 0x48, 0xb3, 0x9c, 0xaf, 0xcf, 0x5e, 0xb7, 0xa1,
+	0x73, 0xa9, 0x9e, 0x7c, 0x72, 0x0a, 0x1d, 0xfd, 0x56, 0x93, 0x7d, 0x56, 0x7a, 0xf5, 0x69, 0xbf,
+	0xf8, 0x88, 0xbb, 0x0d, 0x89, 0xf4, 0x0c, 0xd2, 0xab, 0x20, 0xbd, 0x12, 0x92, 0x41, 0xd7, 0x3c,
+	0x66, 0x64, 0xc4, 0x2a, 0xef, 0x1f, 0xdd, 0x67, 0xa5, 0x97, 0x4e, 0xe3, 0xbd, 0x1c, 0xef, 0x6d,
+	0xe1, 0xbd, 0x0a, 0xfe, 0x0b, 0x18, 0x94, 0x6c, 0x9d, 0x4c, 0x58, 0xdd, 0xdb, 0x41, 0x0f, 0x6b,
+	0xdc, 0xdf, 0x6d, 0x90, 0x2f, 0x61, 0xbf, 0x6c, 0xb8, 0xe4, 0x88, 0xd5, 0x5a, 0x38, 0x1d, 0xb3,
+	0x3a, 0x67, 0x56, 0x0c, 0x65, 0x6b, 0x25, 0x47, 0xac, 0xd6, 0xac, 0xe9, 0x98, 0xd5, 0x79, 0x70,
+	0x83, 0x9c, 0x43, 0xbf, 0xe8, 0x99, 0x64, 0xcc, 0x6a, 0x4c, 0x99, 0x92, 0x6d, 0x63, 0xd5, 0xbb,
+	0x97, 0x2d, 0x89, 0x1c, 0xb1, 0x5a, 0xab, 0xa3, 0x63, 0x56, 0xe7, 0x5d, 0x4a, 0x70, 0x63, 0x2f,
+	0x64, 0xc4, 0x2a, 0x46, 0x46, 0xf7, 0x59, 0xd9, 0x7b, 0x1a, 0xe4, 0x1e, 0xb4, 0x55, 0x5f, 0x92,
+	0x41, 0xa9, 0x9b, 0xa9, 0xc3, 0x0a, 0xed, 0xda, 0x20, 0x9f, 0x03, 0xe4, 0x9d, 0x46, 0x08, 0xdb,
+	0xea, 0x5e, 0x3a, 0x62, 0x95, 0x56, 0x74, 0x1b, 0x17, 0x7b, 0x3f, 0xb5, 0xd5, 0xff, 0xce, 0x97,
+	0x1d, 0xf5, 0xf3, 0xd9, 0x7f, 0x03, 0x00, 0x4a, 0x78, 0x39, 0x63, 0x8e, 0x0a, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// ClientClient is the client API for Client service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type ClientClient interface {
+	GetACL(ctx context.Context, in *GetACLRequest, opts ...grpc.CallOption) (*GetACLReply, error)
+	SetACL(ctx context.Context, in *SetACLRequest, opts ...grpc.CallOption) (*SetACLReply, error)
+	GetQuota(ctx context.Context, in *GetQuotaRequest, opts ...grpc.CallOption) (*GetQuotaReply, error)
+	SetQuota(ctx context.Context, in *SetQuotaRequest, opts ...grpc.CallOption) (*SetQuotaReply, error)
+	// snapshots of a directory are accessible in the mount under <directory>/.snapshots/<name>
+	ListSnapshots(ctx context.Context, in *ListSnapshotsRequest, opts ...grpc.CallOption) (*ListSnapshotsReply, error)
+	CreateSnapshot(ctx context.Context, in *CreateSnapshotRequest, opts ...grpc.CallOption) (*CreateSnapshotReply, error)
+	DeleteSnapshot(ctx context.Context, in *DeleteSnapshotRequest, opts ...grpc.CallOption) (*DeleteSnapshotReply, error)
+	// retained versions of the files in a directory are accessible in the mount under <directory>/.versions/<name>
+	ListVersions(ctx context.Context, in *ListVersionsRequest, opts ...grpc.CallOption) (*ListVersionsReply, error)
+	RestoreVersion(ctx context.Context, in *RestoreVersionRequest, opts ...grpc.CallOption) (*RestoreVersionReply, error)
+	// CopyFile copies a file without the content going through the client or the node serving the call
+	CopyFile(ctx context.Context, in *CopyFileRequest, opts ...grpc.CallOption) (*CopyFileReply, error)
+	// Batch applies the operations atomically; other clients either see all of them or none
+	Batch(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*BatchReply, error)
+	// DeleteFile deletes a file or a directory; with recursive, everything in the directory is deleted with it
+	DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*DeleteFileReply, error)
+}
+
+type clientClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewClientClient(cc *grpc.ClientConn) ClientClient {
+	return &clientClient{cc}
+}
+
+func (c *clientClient) GetACL(ctx context.Context, in *GetACLRequest, opts ...grpc.CallOption) (*GetACLReply, error) {
+	out := new(GetACLReply)
+	err := c.cc.Invoke(ctx, "/Client/GetACL", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clientClient) SetACL(ctx context.Context, in *SetACLRequest, opts ...grpc.CallOption) (*SetACLReply, error) {
+	out := new(SetACLReply)
+	err := c.cc.Invoke(ctx, "/Client/SetACL", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clientClient) GetQuota(ctx context.Context, in *GetQuotaRequest, opts ...grpc.CallOption) (*GetQuotaReply, error) {
+	out := new(GetQuotaReply)
+	err := c.cc.Invoke(ctx, "/Client/GetQuota", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clientClient) SetQuota(ctx context.Context, in *SetQuotaRequest, opts ...grpc.CallOption) (*SetQuotaReply, error) {
+	out := new(SetQuotaReply)
+	err := c.cc.Invoke(ctx, "/Client/SetQuota", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clientClient) ListSnapshots(ctx context.Context, in *ListSnapshotsRequest, opts ...grpc.CallOption) (*ListSnapshotsReply, error) {
+	out := new(ListSnapshotsReply)
+	err := c.cc.Invoke(ctx, "/Client/ListSnapshots", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clientClient) CreateSnapshot(ctx context.Context, in *CreateSnapshotRequest, opts ...grpc.CallOption) (*CreateSnapshotReply, error) {
+	out := new(CreateSnapshotReply)
+	err := c.cc.Invoke(ctx, "/Client/CreateSnapshot", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clientClient) DeleteSnapshot(ctx context.Context, in *DeleteSnapshotRequest, opts ...grpc.CallOption) (*DeleteSnapshotReply, error) {
+	out := new(DeleteSnapshotReply)
+	err := c.cc.Invoke(ctx, "/Client/DeleteSnapshot", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clientClient) ListVersions(ctx context.Context, in *ListVersionsRequest, opts ...grpc.CallOption) (*ListVersionsReply, error) {
+	out := new(ListVersionsReply)
+	err := c.cc.Invoke(ctx, "/Client/ListVersions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clientClient) RestoreVersion(ctx context.Context, in *RestoreVersionRequest, opts ...grpc.CallOption) (*RestoreVersionReply, error) {
+	out := new(RestoreVersionReply)
+	err := c.cc.Invoke(ctx, "/Client/RestoreVersion", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clientClient) CopyFile(ctx context.Context, in *CopyFileRequest, opts ...grpc.CallOption) (*CopyFileReply, error) {
+	out := new(CopyFileReply)
+	err := c.cc.Invoke(ctx, "/Client/CopyFile", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clientClient) Batch(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*BatchReply, error) {
+	out := new(BatchReply)
+	err := c.cc.Invoke(ctx, "/Client/Batch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clientClient) DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*DeleteFileReply, error) {
+	out := new(DeleteFileReply)
+	err := c.cc.Invoke(ctx, "/Client/DeleteFile", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ClientServer is the server API for Client service.
+type ClientServer interface {
+	GetACL(context.Context, *GetACLRequest) (*GetACLReply, error)
+	SetACL(context.Context, *SetACLRequest) (*SetACLReply, error)
+	GetQuota(context.Context, *GetQuotaRequest) (*GetQuotaReply, error)
+	SetQuota(context.Context, *SetQuotaRequest) (*SetQuotaReply, error)
+	// snapshots of a directory are accessible in the mount under <directory>/.snapshots/<name>
+	ListSnapshots(context.Context, *ListSnapshotsRequest) (*ListSnapshotsReply, error)
+	CreateSnapshot(context.Context, *CreateSnapshotRequest) (*CreateSnapshotReply, error)
+	DeleteSnapshot(context.Context, *DeleteSnapshotRequest) (*DeleteSnapshotReply, error)
+	// retained versions of the files in a directory are accessible in the mount under <directory>/.versions/<name>
+	ListVersions(context.Context, *ListVersionsRequest) (*ListVersionsReply, error)
+	RestoreVersion(context.Context, *RestoreVersionRequest) (*RestoreVersionReply, error)
+	// CopyFile copies a file without the content going through the client or the node serving the call
+	CopyFile(context.Context, *CopyFileRequest) (*CopyFileReply, error)
+	// Batch applies the operations atomically; other clients either see all of them or none
+	Batch(context.Context, *BatchRequest) (*BatchReply, error)
+	// DeleteFile deletes a file or a directory; with recursive, everything in the directory is deleted with it
+	DeleteFile(context.Context, *DeleteFileRequest) (*DeleteFileReply, error)
+}
+
+// UnimplementedClientServer can be embedded to have forward compatible implementations.
+type UnimplementedClientServer struct {
+}
+
+func (*UnimplementedClientServer) GetACL(ctx context.Context, req *GetACLRequest) (*GetACLReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetACL not implemented")
+}
+func (*UnimplementedClientServer) SetACL(ctx context.Context, req *SetACLRequest) (*SetACLReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetACL not implemented")
+}
+func (*UnimplementedClientServer) GetQuota(ctx context.Context, req *GetQuotaRequest) (*GetQuotaReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetQuota not implemented")
+}
+func (*UnimplementedClientServer) SetQuota(ctx context.Context, req *SetQuotaRequest) (*SetQuotaReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetQuota not implemented")
+}
+func (*UnimplementedClientServer) ListSnapshots(ctx context.Context, req *ListSnapshotsRequest) (*ListSnapshotsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSnapshots not implemented")
+}
+func (*UnimplementedClientServer) CreateSnapshot(ctx context.Context, req *CreateSnapshotRequest) (*CreateSnapshotReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSnapshot not implemented")
+}
+func (*UnimplementedClientServer) DeleteSnapshot(ctx context.Context, req *DeleteSnapshotRequest) (*DeleteSnapshotReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSnapshot not implemented")
+}
+func (*UnimplementedClientServer) ListVersions(ctx context.Context, req *ListVersionsRequest) (*ListVersionsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListVersions not implemented")
+}
+func (*UnimplementedClientServer) RestoreVersion(ctx context.Context, req *RestoreVersionRequest) (*RestoreVersionReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreVersion not implemented")
+}
+func (*UnimplementedClientServer) CopyFile(ctx context.Context, req *CopyFileRequest) (*CopyFileReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CopyFile not implemented")
+}
+func (*UnimplementedClientServer) Batch(ctx context.Context, req *BatchRequest) (*BatchReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Batch not implemented")
+}
+func (*UnimplementedClientServer) DeleteFile(ctx context.Context, req *DeleteFileRequest) (*DeleteFileReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteFile not implemented")
+}
+
+func RegisterClientServer(s *grpc.Server, srv ClientServer) {
+	s.RegisterService(&_Client_serviceDesc, srv)
+}
+
+func _Client_GetACL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetACLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClientServer).GetACL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Client/GetACL",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClientServer).GetACL(ctx, req.(*GetACLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Client_SetACL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetACLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClientServer).SetACL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Client/SetACL",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClientServer).SetACL(ctx, req.(*SetACLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Client_GetQuota_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetQuotaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClientServer).GetQuota(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Client/GetQuota",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClientServer).GetQuota(ctx, req.(*GetQuotaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Client_SetQuota_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetQuotaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClientServer).SetQuota(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Client/SetQuota",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClientServer).SetQuota(ctx, req.(*SetQuotaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Client_ListSnapshots_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSnapshotsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClientServer).ListSnapshots(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Client/ListSnapshots",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClientServer).ListSnapshots(ctx, req.(*ListSnapshotsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Client_CreateSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClientServer).CreateSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Client/CreateSnapshot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClientServer).CreateSnapshot(ctx, req.(*CreateSnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Client_DeleteSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClientServer).DeleteSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Client/DeleteSnapshot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClientServer).DeleteSnapshot(ctx, req.(*DeleteSnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Client_ListVersions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListVersionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClientServer).ListVersions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Client/ListVersions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClientServer).ListVersions(ctx, req.(*ListVersionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Client_RestoreVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreVersionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClientServer).RestoreVersion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Client/RestoreVersion",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClientServer).RestoreVersion(ctx, req.(*RestoreVersionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Client_CopyFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CopyFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClientServer).CopyFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Client/CopyFile",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClientServer).CopyFile(ctx, req.(*CopyFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Client_Batch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClientServer).Batch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Client/Batch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClientServer).Batch(ctx, req.(*BatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Client_DeleteFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClientServer).DeleteFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Client/DeleteFile",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClientServer).DeleteFile(ctx, req.(*DeleteFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Client_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "Client",
+	HandlerType: (*ClientServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetACL",
+			Handler:    _Client_GetACL_Handler,
+		},
+		{
+			MethodName: "SetACL",
+			Handler:    _Client_SetACL_Handler,
+		},
+		{
+			MethodName: "GetQuota",
+			Handler:    _Client_GetQuota_Handler,
+		},
+		{
+			MethodName: "SetQuota",
+			Handler:    _Client_SetQuota_Handler,
+		},
+		{
+			MethodName: "ListSnapshots",
+			Handler:    _Client_ListSnapshots_Handler,
+		},
+		{
+			MethodName: "CreateSnapshot",
+			Handler:    _Client_CreateSnapshot_Handler,
+		},
+		{
+			MethodName: "DeleteSnapshot",
+			Handler:    _Client_DeleteSnapshot_Handler,
+		},
+		{
+			MethodName: "ListVersions",
+			Handler:    _Client_ListVersions_Handler,
+		},
+		{
+			MethodName: "RestoreVersion",
+			Handler:    _Client_RestoreVersion_Handler,
+		},
+		{
+			MethodName: "CopyFile",
+			Handler:    _Client_CopyFile_Handler,
+		},
+		{
+			MethodName: "Batch",
+			Handler:    _Client_Batch_Handler,
+		},
+		{
+			MethodName: "DeleteFile",
+			Handler:    _Client_DeleteFile_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "client.proto",
+}
@@ -25,8 +25,14 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
 type ReadRequest struct {
-	Id                   uint64   `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
-	Version              uint64   `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
+	Id      uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Version uint64 `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
+	// offset is where in the file to start reading from
+	Offset int64 `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
+	// length is the maximum number of bytes to read; 0 means until the end of the file
+	Length int64 `protobuf:"varint,4,opt,name=length,proto3" json:"length,omitempty"`
+	// traffic_class is the qos.Class of the transfer; the server limits how fast it sends accordingly
+	TrafficClass         int32    `protobuf:"varint,5,opt,name=traffic_class,json=trafficClass,proto3" json:"traffic_class,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -71,6 +77,27 @@ func (m *ReadRequest) GetVersion() uint64 {
 	return 0
 }
 
+func (m *ReadRequest) GetOffset() int64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+func (m *ReadRequest) GetLength() int64 {
+	if m != nil {
+		return m.Length
+	}
+	return 0
+}
+
+func (m *ReadRequest) GetTrafficClass() int32 {
+	if m != nil {
+		return m.TrafficClass
+	}
+	return 0
+}
+
 type ReadReply struct {
 	Content              []byte   `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -110,25 +137,124 @@ func (m *ReadReply) GetContent() []byte {
 	return nil
 }
 
+type DiskUsageRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DiskUsageRequest) Reset()         { *m = DiskUsageRequest{} }
+func (m *DiskUsageRequest) String() string { return proto.CompactTextString(m) }
+func (*DiskUsageRequest) ProtoMessage()    {}
+func (*DiskUsageRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4e99986fd8b1e48c, []int{2}
+}
+
+func (m *DiskUsageRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DiskUsageRequest.Unmarshal(m, b)
+}
+func (m *DiskUsageRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DiskUsageRequest.Marshal(b, m, deterministic)
+}
+func (m *DiskUsageRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DiskUsageRequest.Merge(m, src)
+}
+func (m *DiskUsageRequest) XXX_Size() int {
+	return xxx_messageInfo_DiskUsageRequest.Size(m)
+}
+func (m *DiskUsageRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DiskUsageRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DiskUsageRequest proto.InternalMessageInfo
+
+// DiskUsageReply is about the file system on which the data_dir of the peer is
+type DiskUsageReply struct {
+	TotalBytes uint64 `protobuf:"varint,1,opt,name=total_bytes,json=totalBytes,proto3" json:"total_bytes,omitempty"`
+	FreeBytes  uint64 `protobuf:"varint,2,opt,name=free_bytes,json=freeBytes,proto3" json:"free_bytes,omitempty"`
+	// available_bytes are the free bytes which aren't reserved for root
+	AvailableBytes       uint64   `protobuf:"varint,3,opt,name=available_bytes,json=availableBytes,proto3" json:"available_bytes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DiskUsageReply) Reset()         { *m = DiskUsageReply{} }
+func (m *DiskUsageReply) String() string { return proto.CompactTextString(m) }
+func (*DiskUsageReply) ProtoMessage()    {}
+func (*DiskUsageReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4e99986fd8b1e48c, []int{3}
+}
+
+func (m *DiskUsageReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DiskUsageReply.Unmarshal(m, b)
+}
+func (m *DiskUsageReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DiskUsageReply.Marshal(b, m, deterministic)
+}
+func (m *DiskUsageReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DiskUsageReply.Merge(m, src)
+}
+func (m *DiskUsageReply) XXX_Size() int {
+	return xxx_messageInfo_DiskUsageReply.Size(m)
+}
+func (m *DiskUsageReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_DiskUsageReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DiskUsageReply proto.InternalMessageInfo
+
+func (m *DiskUsageReply) GetTotalBytes() uint64 {
+	if m != nil {
+		return m.TotalBytes
+	}
+	return 0
+}
+
+func (m *DiskUsageReply) GetFreeBytes() uint64 {
+	if m != nil {
+		return m.FreeBytes
+	}
+	return 0
+}
+
+func (m *DiskUsageReply) GetAvailableBytes() uint64 {
+	if m != nil {
+		return m.AvailableBytes
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*ReadRequest)(nil), "ReadRequest")
 	proto.RegisterType((*ReadReply)(nil), "ReadReply")
+	proto.RegisterType((*DiskUsageRequest)(nil), "DiskUsageRequest")
+	proto.RegisterType((*DiskUsageReply)(nil), "DiskUsageReply")
 }
 
 func init() { proto.RegisterFile("sporkserver.proto", fileDescriptor_4e99986fd8b1e48c) }
 
 var fileDescriptor_4e99986fd8b1e48c = []byte{
-	// 154 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x12, 0x2c, 0x2e, 0xc8, 0x2f,
-	0xca, 0x2e, 0x4e, 0x2d, 0x2a, 0x4b, 0x2d, 0xd2, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x57, 0x32, 0xe7,
-	0xe2, 0x0e, 0x4a, 0x4d, 0x4c, 0x09, 0x4a, 0x2d, 0x2c, 0x4d, 0x2d, 0x2e, 0x11, 0xe2, 0xe3, 0x62,
-	0xca, 0x4c, 0x91, 0x60, 0x54, 0x60, 0xd4, 0x60, 0x09, 0x62, 0xca, 0x4c, 0x11, 0x92, 0xe0, 0x62,
-	0x2f, 0x4b, 0x2d, 0x2a, 0xce, 0xcc, 0xcf, 0x93, 0x60, 0x02, 0x0b, 0xc2, 0xb8, 0x4a, 0xaa, 0x5c,
-	0x9c, 0x10, 0x8d, 0x05, 0x39, 0x95, 0x20, 0x65, 0xc9, 0xf9, 0x79, 0x25, 0xa9, 0x79, 0x25, 0x60,
-	0xbd, 0x3c, 0x41, 0x30, 0xae, 0x91, 0x0e, 0x17, 0x8b, 0x5b, 0x66, 0x4e, 0xaa, 0x90, 0x0a, 0x17,
-	0x0b, 0x48, 0xb9, 0x10, 0x8f, 0x1e, 0x92, 0x75, 0x52, 0x5c, 0x7a, 0x70, 0x33, 0x94, 0x18, 0x0c,
-	0x18, 0x9d, 0xd8, 0xa3, 0x58, 0xc1, 0xce, 0x4a, 0x62, 0x03, 0x53, 0xc6, 0x80, 0x00, 0x00, 0x00,
-	0xff, 0xff, 0xdc, 0x2d, 0x22, 0xb8, 0xb2, 0x00, 0x00, 0x00,
+	// 297 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x5c, 0x91, 0xcd, 0x6a, 0x3a, 0x31,
+	0x14, 0xc5, 0x8d, 0x8e, 0x8a, 0x57, 0xff, 0xfa, 0x37, 0x8b, 0x12, 0x84, 0x52, 0x49, 0x5b, 0xea,
+	0x6a, 0xe8, 0xc7, 0x1b, 0xd8, 0xd2, 0x07, 0x08, 0x74, 0xd3, 0x8d, 0x44, 0xbd, 0x63, 0x83, 0x61,
+	0x62, 0x93, 0x74, 0x60, 0xde, 0xa1, 0x0f, 0x5d, 0x92, 0xf9, 0xc0, 0x76, 0x15, 0xce, 0xef, 0x9e,
+	0x90, 0x73, 0x72, 0x61, 0xee, 0x4e, 0xc6, 0x1e, 0x1d, 0xda, 0x02, 0x6d, 0x7a, 0xb2, 0xc6, 0x1b,
+	0xfe, 0x4d, 0x60, 0x2c, 0x50, 0xee, 0x05, 0x7e, 0x7e, 0xa1, 0xf3, 0x74, 0x0a, 0x5d, 0xb5, 0x67,
+	0x64, 0x49, 0x56, 0x89, 0xe8, 0xaa, 0x3d, 0x65, 0x30, 0x2c, 0xd0, 0x3a, 0x65, 0x72, 0xd6, 0x8d,
+	0xb0, 0x91, 0xf4, 0x02, 0x06, 0x26, 0xcb, 0x1c, 0x7a, 0xd6, 0x5b, 0x92, 0x55, 0x4f, 0xd4, 0x2a,
+	0x70, 0x8d, 0xf9, 0xc1, 0x7f, 0xb0, 0xa4, 0xe2, 0x95, 0xa2, 0xd7, 0xf0, 0xcf, 0x5b, 0x99, 0x65,
+	0x6a, 0xb7, 0xd9, 0x69, 0xe9, 0x1c, 0xeb, 0x2f, 0xc9, 0xaa, 0x2f, 0x26, 0x35, 0x7c, 0x0e, 0x8c,
+	0xdf, 0xc2, 0xa8, 0x4a, 0x73, 0xd2, 0x65, 0x78, 0x7b, 0x67, 0x72, 0x8f, 0xb9, 0x8f, 0x81, 0x26,
+	0xa2, 0x91, 0x9c, 0xc2, 0xff, 0x17, 0xe5, 0x8e, 0x6f, 0x4e, 0x1e, 0xb0, 0x4e, 0xce, 0x4b, 0x98,
+	0x9e, 0xb1, 0x70, 0xff, 0x0a, 0xc6, 0xde, 0x78, 0xa9, 0x37, 0xdb, 0xd2, 0xa3, 0xab, 0x4b, 0x41,
+	0x44, 0xeb, 0x40, 0xe8, 0x25, 0x40, 0x66, 0x11, 0xeb, 0x79, 0xd5, 0x6f, 0x14, 0x48, 0x35, 0xbe,
+	0x83, 0x99, 0x2c, 0xa4, 0xd2, 0x72, 0xab, 0x1b, 0x4f, 0x2f, 0x7a, 0xa6, 0x2d, 0x8e, 0xc6, 0xc7,
+	0x0d, 0x24, 0xaf, 0x4a, 0x23, 0xbd, 0x81, 0x24, 0xa4, 0xa7, 0x93, 0xf4, 0xec, 0x4b, 0x17, 0x90,
+	0xb6, 0x95, 0x78, 0xe7, 0x9e, 0xd0, 0x07, 0x18, 0xb5, 0x41, 0xe9, 0x3c, 0xfd, 0x5b, 0x64, 0x31,
+	0x4b, 0x7f, 0xf7, 0xe0, 0x9d, 0xf5, 0xf0, 0xbd, 0x1f, 0xd7, 0xb5, 0x1d, 0xc4, 0xe3, 0xe9, 0x67,
+	0x00, 0x44, 0x44, 0xb4, 0x51, 0xca, 0x01, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -144,6 +270,7 @@ const _ = grpc.SupportPackageIsVersion4
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type FileClient interface {
 	Read(ctx context.Context, in *ReadRequest, opts ...grpc.CallOption) (File_ReadClient, error)
+	DiskUsage(ctx context.Context, in *DiskUsageRequest, opts ...grpc.CallOption) (*DiskUsageReply, error)
 }
 
 type fileClient struct {
@@ -186,9 +313,19 @@ func (x *fileReadClient) Recv() (*ReadReply, error) {
 	return m, nil
 }
 
+func (c *fileClient) DiskUsage(ctx context.Context, in *DiskUsageRequest, opts ...grpc.CallOption) (*DiskUsageReply, error) {
+	out := new(DiskUsageReply)
+	err := c.cc.Invoke(ctx, "/File/DiskUsage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // FileServer is the server API for File service.
 type FileServer interface {
 	Read(*ReadRequest, File_ReadServer) error
+	DiskUsage(context.Context, *DiskUsageRequest) (*DiskUsageReply, error)
 }
 
 // UnimplementedFileServer can be embedded to have forward compatible implementations.
@@ -198,6 +335,9 @@ type UnimplementedFileServer struct {
 func (*UnimplementedFileServer) Read(req *ReadRequest, srv File_ReadServer) error {
 	return status.Errorf(codes.Unimplemented, "method Read not implemented")
 }
+func (*UnimplementedFileServer) DiskUsage(ctx context.Context, req *DiskUsageRequest) (*DiskUsageReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DiskUsage not implemented")
+}
 
 func RegisterFileServer(s *grpc.Server, srv FileServer) {
 	s.RegisterService(&_File_serviceDesc, srv)
@@ -224,10 +364,33 @@ func (x *fileReadServer) Send(m *ReadReply) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _File_DiskUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DiskUsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileServer).DiskUsage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/File/DiskUsage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileServer).DiskUsage(ctx, req.(*DiskUsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _File_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "File",
 	HandlerType: (*FileServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "DiskUsage",
+			Handler:    _File_DiskUsage_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "Read",
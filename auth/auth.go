@@ -0,0 +1,124 @@
+// Package auth authenticates the users of the client API. Users authenticate either with a bearer token or with
+// a client certificate signed by the cluster's CA, in which case the user is the certificate's common name.
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"github.com/dimitarvdimitrov/sporkfs/log"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const bearerPrefix = "Bearer "
+
+type Config struct {
+	// Tokens maps users to the bearer tokens with which they authenticate
+	Tokens map[string]string `toml:"tokens"`
+}
+
+type userKey struct{}
+
+// WithUser returns a context of a request made by the user.
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFrom returns the user who made the request. It returns false if the request doesn't come from the client API,
+// e.g. if it comes through the local mount.
+func UserFrom(ctx context.Context) (string, bool) {
+	user, ok := ctx.Value(userKey{}).(string)
+	return user, ok
+}
+
+type Authenticator struct {
+	tokens map[string]string
+}
+
+func New(cfg Config) Authenticator {
+	return Authenticator{tokens: cfg.Tokens}
+}
+
+// ServerOptions return the options which authenticate every call to a server and reject the unauthenticated ones.
+func (a Authenticator) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			ctx, err := a.authenticate(ctx, info.FullMethod)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+			if err != nil {
+				return err
+			}
+			return handler(srv, authenticatedStream{ServerStream: ss, ctx: ctx})
+		}),
+	}
+}
+
+func (a Authenticator) authenticate(ctx context.Context, method string) (context.Context, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, header := range md.Get("authorization") {
+			if !strings.HasPrefix(header, bearerPrefix) {
+				continue
+			}
+			if tlsInfo(ctx) == nil {
+				// the token was sent in plain text, so it can't be trusted to come from the user
+				log.Warn("[auth] rejecting call with a token over a connection without TLS", zap.String("method", method))
+				return nil, status.Error(codes.Unauthenticated, "tokens are only accepted over TLS")
+			}
+			if user, ok := a.userWithToken(strings.TrimPrefix(header, bearerPrefix)); ok {
+				return WithUser(ctx, user), nil
+			}
+			log.Warn("[auth] rejecting call with unknown token", zap.String("method", method))
+			return nil, status.Error(codes.Unauthenticated, "unknown token")
+		}
+	}
+
+	if info := tlsInfo(ctx); info != nil && len(info.State.VerifiedChains) > 0 && len(info.State.VerifiedChains[0]) > 0 {
+		if cn := info.State.VerifiedChains[0][0].Subject.CommonName; cn != "" {
+			return WithUser(ctx, cn), nil
+		}
+	}
+	return nil, status.Error(codes.Unauthenticated, "no token or client certificate")
+}
+
+// tlsInfo returns the TLS state of the connection of the call or nil if it doesn't use TLS.
+func tlsInfo(ctx context.Context) *credentials.TLSInfo {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+		return &info
+	}
+	return nil
+}
+
+func (a Authenticator) userWithToken(token string) (string, bool) {
+	for user, t := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return user, true
+		}
+	}
+	return "", false
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s authenticatedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthenticate(t *testing.T) {
+	testCases := map[string]struct {
+		header string
+		tls    bool
+		// verified is true if the client's certificate was verified; cn is its common name
+		verified bool
+		cn       string
+		user     string
+	}{
+		"token":                          {header: "Bearer alice-token", tls: true, user: "alice"},
+		"token without TLS":              {header: "Bearer alice-token"},
+		"unknown token":                  {header: "Bearer bob-token", tls: true},
+		"token and certificate":          {header: "Bearer alice-token", tls: true, verified: true, cn: "bob", user: "alice"},
+		"unknown token with certificate": {header: "Bearer bob-token", tls: true, verified: true, cn: "bob"},
+		"unverified certificate":         {tls: true, cn: "bob"},
+		"verified certificate":           {tls: true, verified: true, cn: "bob", user: "bob"},
+		"certificate without a name":     {tls: true, verified: true},
+		"other authorization":            {header: "Basic YWxpY2U6c2VjcmV0", tls: true, verified: true, cn: "bob", user: "bob"},
+		"nothing":                        {},
+	}
+
+	a := New(Config{Tokens: map[string]string{"alice": "alice-token"}})
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.header != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", tc.header))
+			}
+			p := &peer.Peer{}
+			if tc.tls {
+				var state tls.ConnectionState
+				if tc.verified {
+					state.VerifiedChains = [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: tc.cn}}}}
+				}
+				p.AuthInfo = credentials.TLSInfo{State: state}
+			}
+			ctx = peer.NewContext(ctx, p)
+
+			ctx, err := a.authenticate(ctx, "/Client/Test")
+			if tc.user == "" {
+				require.Equal(t, codes.Unauthenticated, status.Code(err))
+				return
+			}
+			require.NoError(t, err)
+			user, ok := UserFrom(ctx)
+			require.True(t, ok)
+			require.Equal(t, tc.user, user)
+		})
+	}
+}
+
+func TestUserFrom(t *testing.T) {
+	_, ok := UserFrom(context.Background())
+	require.False(t, ok, "calls through the mount have no user")
+
+	user, ok := UserFrom(WithUser(context.Background(), "alice"))
+	require.True(t, ok)
+	require.Equal(t, "alice", user)
+}
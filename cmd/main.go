@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	_ "expvar"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -16,6 +18,7 @@ import (
 	"github.com/dimitarvdimitrov/sporkfs/log"
 	"github.com/dimitarvdimitrov/sporkfs/spork"
 	"github.com/dimitarvdimitrov/sporkfs/store"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
 	"github.com/seaweedfs/fuse"
 	"github.com/seaweedfs/fuse/fs"
 	"go.uber.org/zap"
@@ -32,6 +35,16 @@ func main() {
 		log.Fatal("couldn't create backing storage directory", zap.Error(err))
 	}
 
+	flushTraces, err := trace.Init(cfg.Tracing, cfg.ThisPeer)
+	if err != nil {
+		log.Fatal("init tracing", zap.Error(err))
+	}
+	defer flushTraces()
+
+	if cfg.MetricsAddr != "" {
+		serveMetrics(cfg.MetricsAddr)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	invFiles := make(chan *store.File)
@@ -115,6 +128,15 @@ func startFuseServer(ctx context.Context, cancel context.CancelFunc, mountpoint
 	go vfs.WatchDeletions()
 }
 
+// serveMetrics exposes the expvar metrics at /debug/vars
+func serveMetrics(addr string) {
+	go func() {
+		if err := http.ListenAndServe(addr, nil); err != nil {
+			log.Error("serve metrics", zap.Error(err))
+		}
+	}()
+}
+
 func parseConfig(dir string) (cfg spork.Config) {
 	_, err := toml.DecodeFile(dir, &cfg)
 	if err != nil {
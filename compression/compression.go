@@ -0,0 +1,24 @@
+// Package compression decides which gRPC calls between peers are compressed. Compression is negotiated per call:
+// the client compresses its request with gzip and the server compresses its replies with whatever the client used.
+package compression
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding/gzip" // also registers the compressor so that servers can use it
+)
+
+type Config struct {
+	Enabled bool `toml:"enabled"`
+	// MinSize is the number of bytes below which a call isn't compressed.
+	MinSize int64 `toml:"min_size"`
+}
+
+// CallOptions returns the options for a call which is expected to transfer size bytes.
+// A negative size means that it isn't known in advance; such calls aren't compressed, since they may well
+// be below the minimum size. The server replies uncompressed to uncompressed calls.
+func (c Config) CallOptions(size int64) []grpc.CallOption {
+	if !c.Enabled || size < 0 || size < c.MinSize {
+		return nil
+	}
+	return []grpc.CallOption{grpc.UseCompressor(gzip.Name)}
+}
@@ -0,0 +1,542 @@
+// Package crypt encrypts files at rest with AES-256-GCM. Files are split into chunks which are encrypted
+// independently, so they can still be read and written at random offsets.
+package crypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// An encrypted file starts with a header followed by the chunks. Each chunk is stored as a random nonce followed
+// by the sealed chunkSize bytes of the file, except the last one which may hold fewer. The header holds a random
+// id of the file which, together with the index of the chunk, authenticates each chunk. This way chunks can't be
+// swapped within a file or between files. Every chunk is sealed, including the zeros left by writes past the end
+// of the file and by Truncate, so that no part of a file can be replaced without it being noticed.
+//
+// The header also holds the sealed size of the file, so that a file can't be shortened without it being noticed
+// either. The size is recorded when the file is synced, closed or truncated. Chunks which were written after that
+// are only used if the last of them is authentic.
+//
+//	| magic | file id | nonce | size | tag | nonce | chunk 0 | tag | nonce | chunk 1 | tag | ...
+//
+// Nonces are random, so a key can only seal about 2^32 times before the chance that a nonce repeats, which breaks
+// GCM, becomes too high. Each file is therefore sealed with its own key, which is derived from the key and the id
+// of the file. Every write of a chunk and of the size counts, so a file can be rewritten about 16 TiB in total.
+// Files which are rewritten for good, like the compacted raft log, are new files with new ids and keys.
+// Files written before keys were derived (with legacyMagic) are sealed with the key itself.
+const (
+	chunkSize       = 1 << 12
+	nonceSize       = 12
+	tagSize         = 16
+	overhead        = nonceSize + tagSize
+	storedChunkSize = chunkSize + overhead
+
+	magic          = "sporkec2"
+	legacyMagic    = "sporkec1"
+	idSize         = 16
+	sizeOffset     = len(magic) + idSize
+	storedSizeSize = 8 + overhead
+	headerSize     = sizeOffset + storedSizeSize
+
+	// fillChunks is how many chunks of zeros are written at once when a file is extended
+	fillChunks = 256
+)
+
+// ErrCorrupt means that a file was modified by something other than a File with the same key.
+var ErrCorrupt = errors.New("encrypted file is corrupt")
+
+type Key struct {
+	key  []byte
+	aead cipher.AEAD
+}
+
+// LoadKey reads a 256-bit key from the file. The file contains either the 32 raw bytes of the key or their
+// hexadecimal encoding.
+func LoadKey(path string) (*Key, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading key file: %w", err)
+	}
+
+	key := content
+	if trimmed := bytes.TrimSpace(content); len(trimmed) == 64 {
+		if key, err = hex.DecodeString(string(trimmed)); err != nil {
+			return nil, fmt.Errorf("decoding key file: %w", err)
+		}
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key needs to be 32 bytes, got %d", len(key))
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{key: key, aead: aead}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// fileAEAD returns the cipher of the file with the id. Its key is the HMAC-SHA256 of the id under the key.
+func (k *Key) fileAEAD(id []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, k.key)
+	mac.Write([]byte("sporkfs file key "))
+	mac.Write(id)
+	return newAEAD(mac.Sum(nil))
+}
+
+// File is an encrypted file. It's safe for concurrent use.
+type File struct {
+	f    *os.File
+	key  *Key
+	aead cipher.AEAD // nil until the header is read or written
+
+	m       sync.RWMutex
+	id      []byte // nil until the header is written
+	size    int64  // the size of the plain content
+	resized bool   // whether size changed since it was written to the header
+	offset  int64  // used by Read and Write
+}
+
+// OpenFile opens the file like os.OpenFile. The file needs to be either empty or previously written by a File
+// with the same key. The file is always opened for reading as well, since partial writes to a chunk need to
+// decrypt the rest of it.
+func (k *Key) OpenFile(path string, flag int, perm os.FileMode) (*File, error) {
+	appending := flag&os.O_APPEND != 0
+	flag &^= os.O_APPEND | os.O_WRONLY
+	flag |= os.O_RDWR
+
+	f, err := os.OpenFile(path, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	file := &File{f: f, key: k}
+	if err = file.readHeader(); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("opening encrypted file %s: %w", path, err)
+	}
+	if appending {
+		file.offset = file.size
+	}
+	return file, nil
+}
+
+func (f *File) readHeader() error {
+	info, err := f.f.Stat()
+	if err != nil {
+		return err
+	}
+	stored := info.Size()
+	if stored == 0 {
+		return nil
+	}
+	if stored < int64(headerSize) {
+		return fmt.Errorf("header is cut short: %w", ErrCorrupt)
+	}
+
+	header := make([]byte, headerSize)
+	if _, err = f.f.ReadAt(header, 0); err != nil {
+		return fmt.Errorf("reading header: %w", err)
+	}
+	f.id = header[len(magic):sizeOffset]
+	switch string(header[:len(magic)]) {
+	case magic:
+		if f.aead, err = f.key.fileAEAD(f.id); err != nil {
+			return err
+		}
+	case legacyMagic:
+		f.aead = f.key.aead
+	default:
+		return fmt.Errorf("file isn't encrypted")
+	}
+
+	sealedSize := header[sizeOffset:]
+	size, err := f.aead.Open(nil, sealedSize[:nonceSize], sealedSize[nonceSize:], f.id)
+	if err != nil {
+		return fmt.Errorf("decrypting size: %w", ErrCorrupt)
+	}
+	recorded := int64(binary.BigEndian.Uint64(size))
+	f.size = recorded
+
+	switch {
+	case stored < storedSize(recorded):
+		return fmt.Errorf("file has %d bytes, expected at least %d: %w", stored, storedSize(recorded), ErrCorrupt)
+	case stored > storedSize(recorded):
+		// the file was written after its size was last recorded; the chunks which were written are only
+		// used if the last of them can be decrypted
+		f.size = plainSize(stored)
+		if _, err = f.readChunk((f.size - 1) / chunkSize); err != nil {
+			f.size = recorded
+		}
+	}
+	return nil
+}
+
+// ensureHeader writes the header if the file is still empty. It expects the file to be locked.
+func (f *File) ensureHeader() error {
+	if f.id != nil {
+		return nil
+	}
+	id := make([]byte, idSize)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	aead, err := f.key.fileAEAD(id)
+	if err != nil {
+		return err
+	}
+	if _, err := f.f.WriteAt(append([]byte(magic), id...), 0); err != nil {
+		return err
+	}
+	f.id, f.aead = id, aead
+	return f.recordSize()
+}
+
+// recordSize writes the current size to the header. It expects the file to be locked.
+func (f *File) recordSize() error {
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(f.size))
+
+	stored := make([]byte, nonceSize, storedSizeSize)
+	if _, err := rand.Read(stored); err != nil {
+		return err
+	}
+	stored = f.aead.Seal(stored, stored[:nonceSize], size, f.id)
+	if _, err := f.f.WriteAt(stored, int64(sizeOffset)); err != nil {
+		return fmt.Errorf("writing size: %w", err)
+	}
+	f.resized = false
+	return nil
+}
+
+func (f *File) Name() string {
+	return f.f.Name()
+}
+
+// Size returns the size of the plain content of the file.
+func (f *File) Size() (int64, error) {
+	f.m.RLock()
+	defer f.m.RUnlock()
+
+	return f.size, nil
+}
+
+// Sync commits the content and then the size of the file to disk. This way the recorded size never
+// covers chunks which may not be on disk.
+func (f *File) Sync() error {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if err := f.f.Sync(); err != nil {
+		return err
+	}
+	if !f.resized {
+		return nil
+	}
+	if err := f.recordSize(); err != nil {
+		return err
+	}
+	return f.f.Sync()
+}
+
+func (f *File) Close() error {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if f.resized {
+		if err := f.recordSize(); err != nil {
+			_ = f.f.Close()
+			return err
+		}
+	}
+	return f.f.Close()
+}
+
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	f.m.RLock()
+	defer f.m.RUnlock()
+
+	return f.readAt(p, off)
+}
+
+// readAt expects the file to be at least read-locked.
+func (f *File) readAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) && off < f.size {
+		chunk := off / chunkSize
+		plain, err := f.readChunk(chunk)
+		if err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], plain[off-chunk*chunkSize:])
+		n += copied
+		off += int64(copied)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *File) Read(p []byte) (int, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	n, err := f.readAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *File) Write(p []byte) (int, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	n, err := f.writeAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *File) WriteAt(p []byte, off int64) (int, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	return f.writeAt(p, off)
+}
+
+// writeAt expects the file to be locked.
+func (f *File) writeAt(p []byte, off int64) (int, error) {
+	if err := f.ensureHeader(); err != nil {
+		return 0, err
+	}
+	if off > f.size {
+		if err := f.fill(off); err != nil {
+			return 0, err
+		}
+	}
+
+	n := 0
+	for n < len(p) {
+		chunk := off / chunkSize
+		chunkStart := chunk * chunkSize
+		inChunk := off - chunkStart
+		toWrite := len(p) - n
+		if toWrite > chunkSize-int(inChunk) {
+			toWrite = chunkSize - int(inChunk)
+		}
+
+		var plain []byte
+		existing := f.size - chunkStart
+		if existing > chunkSize {
+			existing = chunkSize
+		}
+		if existing > 0 && (inChunk > 0 || int64(toWrite) < existing) {
+			// the write doesn't replace all of the chunk, so we keep the rest of it
+			var err error
+			if plain, err = f.readChunk(chunk); err != nil {
+				return n, err
+			}
+		}
+		if len(plain) < int(inChunk)+toWrite {
+			plain = append(plain, make([]byte, int(inChunk)+toWrite-len(plain))...)
+		}
+		copy(plain[inChunk:], p[n:n+toWrite])
+
+		if err := f.writeChunk(chunk, plain); err != nil {
+			return n, err
+		}
+		n += toWrite
+		off += int64(toWrite)
+		if off > f.size {
+			f.size = off
+			f.resized = true
+		}
+	}
+	return n, nil
+}
+
+// Truncate changes the size of the plain content of the file.
+func (f *File) Truncate(size int64) error {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if err := f.ensureHeader(); err != nil {
+		return err
+	}
+
+	switch {
+	case size > f.size:
+		if err := f.fill(size); err != nil {
+			return err
+		}
+	case size < f.size && size%chunkSize != 0:
+		// the new last chunk needs to be sealed with its new length
+		chunk := size / chunkSize
+		plain, err := f.readChunk(chunk)
+		if err != nil {
+			return err
+		}
+		if err = f.writeChunk(chunk, plain[:size%chunkSize]); err != nil {
+			return err
+		}
+	}
+
+	f.size = size
+	if err := f.recordSize(); err != nil {
+		return err
+	}
+	return f.f.Truncate(storedSize(size))
+}
+
+// fill extends the plain content of the file with zeros up to size. It expects the file to be locked.
+func (f *File) fill(size int64) error {
+	if err := f.padLastChunk(size); err != nil {
+		return err
+	}
+
+	zeros := make([]byte, chunkSize)
+	for f.size < size {
+		// the file ends at a chunk boundary now
+		first := f.size / chunkSize
+		batch := make([]byte, 0, fillChunks*storedChunkSize)
+		filled := f.size
+		for chunk := first; chunk < first+fillChunks && filled < size; chunk++ {
+			plainLen := size - filled
+			if plainLen > chunkSize {
+				plainLen = chunkSize
+			}
+			sealed, err := f.seal(chunk, zeros[:plainLen])
+			if err != nil {
+				return err
+			}
+			batch = append(batch, sealed...)
+			filled += plainLen
+		}
+
+		if _, err := f.f.WriteAt(batch, chunkOffset(first)); err != nil {
+			return fmt.Errorf("writing chunk %d: %w", first, err)
+		}
+		f.size = filled
+		f.resized = true
+	}
+	return nil
+}
+
+// padLastChunk fills the last chunk with zeros up to the end of the chunk or up to size, whichever is first.
+// It expects the file to be locked.
+func (f *File) padLastChunk(size int64) error {
+	inChunk := f.size % chunkSize
+	if inChunk == 0 {
+		return nil
+	}
+	chunk := f.size / chunkSize
+	plain, err := f.readChunk(chunk)
+	if err != nil {
+		return err
+	}
+
+	newLen := int64(chunkSize)
+	if size-chunk*chunkSize < newLen {
+		newLen = size - chunk*chunkSize
+	}
+	plain = append(plain, make([]byte, newLen-inChunk)...)
+	if err = f.writeChunk(chunk, plain); err != nil {
+		return err
+	}
+	f.size = chunk*chunkSize + newLen
+	f.resized = true
+	return nil
+}
+
+// readChunk returns the plain content of the chunk. It expects the file to be at least read-locked.
+func (f *File) readChunk(chunk int64) ([]byte, error) {
+	plainLen := f.size - chunk*chunkSize
+	if plainLen > chunkSize {
+		plainLen = chunkSize
+	}
+	if plainLen <= 0 {
+		return nil, nil
+	}
+
+	stored := make([]byte, plainLen+overhead)
+	if _, err := f.f.ReadAt(stored, chunkOffset(chunk)); err != nil {
+		return nil, fmt.Errorf("reading chunk %d: %w", chunk, err)
+	}
+
+	plain, err := f.aead.Open(nil, stored[:nonceSize], stored[nonceSize:], f.additionalData(chunk))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting chunk %d: %w", chunk, err)
+	}
+	return plain, nil
+}
+
+// writeChunk encrypts the plain content of the chunk with a new nonce. It expects the file to be locked.
+func (f *File) writeChunk(chunk int64, plain []byte) error {
+	stored, err := f.seal(chunk, plain)
+	if err != nil {
+		return err
+	}
+	if _, err = f.f.WriteAt(stored, chunkOffset(chunk)); err != nil {
+		return fmt.Errorf("writing chunk %d: %w", chunk, err)
+	}
+	return nil
+}
+
+// seal returns the chunk as it's stored.
+func (f *File) seal(chunk int64, plain []byte) ([]byte, error) {
+	stored := make([]byte, nonceSize, len(plain)+overhead)
+	if _, err := rand.Read(stored); err != nil {
+		return nil, err
+	}
+	return f.aead.Seal(stored, stored[:nonceSize], plain, f.additionalData(chunk)), nil
+}
+
+func (f *File) additionalData(chunk int64) []byte {
+	ad := make([]byte, idSize+8)
+	copy(ad, f.id)
+	binary.BigEndian.PutUint64(ad[idSize:], uint64(chunk))
+	return ad
+}
+
+func chunkOffset(chunk int64) int64 {
+	return int64(headerSize) + chunk*storedChunkSize
+}
+
+// storedSize returns the size on disk of a file with size bytes of plain content.
+func storedSize(size int64) int64 {
+	stored := int64(headerSize) + size/chunkSize*storedChunkSize
+	if rem := size % chunkSize; rem > 0 {
+		stored += rem + overhead
+	}
+	return stored
+}
+
+// plainSize is the inverse of storedSize.
+func plainSize(stored int64) int64 {
+	stored -= int64(headerSize)
+	if stored <= 0 {
+		return 0
+	}
+	size := stored / storedChunkSize * chunkSize
+	if rem := stored % storedChunkSize; rem > overhead {
+		size += rem - overhead
+	}
+	return size
+}
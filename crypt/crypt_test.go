@@ -0,0 +1,362 @@
+package crypt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKey(t *testing.T, dir string, seed int64) *Key {
+	raw := make([]byte, 32)
+	rand.New(rand.NewSource(seed)).Read(raw)
+	path := filepath.Join(dir, "key")
+	require.NoError(t, ioutil.WriteFile(path, []byte(hex.EncodeToString(raw)+"\n"), 0600))
+
+	key, err := LoadKey(path)
+	require.NoError(t, err)
+	return key
+}
+
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "sporkfs-crypt")
+	require.NoError(t, err)
+	return dir, func() { _ = os.RemoveAll(dir) }
+}
+
+func readAll(t *testing.T, f *File) []byte {
+	size, err := f.Size()
+	require.NoError(t, err)
+	content := make([]byte, size)
+	n, err := f.ReadAt(content, 0)
+	if err != io.EOF {
+		require.NoError(t, err)
+	}
+	require.EqualValues(t, size, n)
+	return content
+}
+
+// plainModel mirrors the writes to an encrypted file on a byte slice.
+type plainModel []byte
+
+func (m *plainModel) writeAt(p []byte, off int64) {
+	if end := off + int64(len(p)); end > int64(len(*m)) {
+		*m = append(*m, make([]byte, end-int64(len(*m)))...)
+	}
+	copy((*m)[off:], p)
+}
+
+func (m *plainModel) truncate(size int64) {
+	if size > int64(len(*m)) {
+		*m = append(*m, make([]byte, size-int64(len(*m)))...)
+	}
+	*m = (*m)[:size]
+}
+
+func TestRoundTrip(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	key := newTestKey(t, dir, 1)
+	random := rand.New(rand.NewSource(1))
+
+	type op struct {
+		off, len int64
+		truncate bool
+	}
+	testCases := map[string][]op{
+		"empty":                  {},
+		"within a chunk":         {{off: 0, len: 100}, {off: 10, len: 20}},
+		"exactly a chunk":        {{off: 0, len: chunkSize}},
+		"across chunks":          {{off: 0, len: 3*chunkSize + 5}, {off: chunkSize - 3, len: 10}},
+		"past the end":           {{off: 0, len: 10}, {off: 5*chunkSize + 7, len: 100}},
+		"starting past the end":  {{off: 3 * chunkSize, len: chunkSize}},
+		"many chunks of zeros":   {{off: (fillChunks + 3) * chunkSize, len: 1}},
+		"truncate to grow":       {{off: 0, len: 100}, {off: 3*chunkSize + 1, truncate: true}},
+		"truncate mid chunk":     {{off: 0, len: 3 * chunkSize}, {off: chunkSize + 1, truncate: true}, {off: 2 * chunkSize, len: 5}},
+		"truncate at a chunk":    {{off: 0, len: 3 * chunkSize}, {off: chunkSize, truncate: true}},
+		"truncate to zero":       {{off: 0, len: 3 * chunkSize}, {off: 0, truncate: true}, {off: 10, len: 10}},
+		"overwrite with shorter": {{off: 0, len: 2 * chunkSize}, {off: 0, len: 1}},
+	}
+
+	for name, ops := range testCases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			f, err := key.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+			require.NoError(t, err)
+
+			model := plainModel{}
+			for _, o := range ops {
+				if o.truncate {
+					require.NoError(t, f.Truncate(o.off))
+					model.truncate(o.off)
+					continue
+				}
+				p := make([]byte, o.len)
+				random.Read(p)
+				n, err := f.WriteAt(p, o.off)
+				require.NoError(t, err)
+				require.EqualValues(t, o.len, n)
+				model.writeAt(p, o.off)
+			}
+			require.Equal(t, []byte(model), readAll(t, f))
+			require.NoError(t, f.Close())
+
+			f, err = key.OpenFile(path, os.O_RDONLY, 0)
+			require.NoError(t, err)
+			defer f.Close()
+			require.Equal(t, []byte(model), readAll(t, f))
+
+			if len(model) > 0 {
+				off := random.Int63n(int64(len(model)))
+				p := make([]byte, int64(len(model))-off)
+				_, err = f.ReadAt(p, off)
+				require.NoError(t, err)
+				require.Equal(t, []byte(model[off:]), p)
+			}
+		})
+	}
+}
+
+func TestSequentialReadAndWrite(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	key := newTestKey(t, dir, 1)
+	path := filepath.Join(dir, "file")
+
+	content := make([]byte, 3*chunkSize+17)
+	rand.New(rand.NewSource(1)).Read(content)
+
+	f, err := key.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	require.NoError(t, err)
+	_, err = f.Write(content[:chunkSize+1])
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	f, err = key.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write(content[chunkSize+1:])
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	f, err = key.OpenFile(path, os.O_RDONLY, 0)
+	require.NoError(t, err)
+	defer f.Close()
+	actual, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, content, actual)
+}
+
+func TestUnrecordedWrites(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	key := newTestKey(t, dir, 1)
+	path := filepath.Join(dir, "file")
+
+	content := make([]byte, 2*chunkSize+10)
+	rand.New(rand.NewSource(1)).Read(content)
+
+	f, err := key.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.WriteAt(content[:chunkSize], 0)
+	require.NoError(t, err)
+	require.NoError(t, f.Sync())
+	_, err = f.WriteAt(content[chunkSize:], chunkSize)
+	require.NoError(t, err)
+
+	// the size in the header is still one chunk, but the chunks after it are authentic
+	other, err := key.OpenFile(path, os.O_RDONLY, 0)
+	require.NoError(t, err)
+	require.Equal(t, content, readAll(t, other))
+	require.NoError(t, other.Close())
+
+	// a torn write of the last chunk leaves only what was synced
+	require.NoError(t, os.Truncate(path, storedSize(int64(len(content)))-1))
+	other, err = key.OpenFile(path, os.O_RDONLY, 0)
+	require.NoError(t, err)
+	require.Equal(t, content[:chunkSize], readAll(t, other))
+	require.NoError(t, other.Close())
+}
+
+func TestTampering(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	key := newTestKey(t, dir, 1)
+
+	content := make([]byte, 4*chunkSize+100)
+	rand.New(rand.NewSource(1)).Read(content)
+	// a chunk of zeros, like the ones left by writes past the end of the file
+	copy(content[2*chunkSize:3*chunkSize], make([]byte, chunkSize))
+
+	type tamperFunc func(t *testing.T, path string, stored []byte)
+	rewrite := func(modify func(stored []byte) []byte) tamperFunc {
+		return func(t *testing.T, path string, stored []byte) {
+			require.NoError(t, ioutil.WriteFile(path, modify(append([]byte(nil), stored...)), 0600))
+		}
+	}
+
+	testCases := map[string]struct {
+		tamper tamperFunc
+		// failsOpen is true if the file can't be opened at all; otherwise reading it fails
+		failsOpen bool
+	}{
+		"flipped bit in a chunk": {
+			tamper: rewrite(func(stored []byte) []byte {
+				stored[chunkOffset(1)+nonceSize+10] ^= 1
+				return stored
+			}),
+		},
+		"zeroed chunk": {
+			tamper: rewrite(func(stored []byte) []byte {
+				copy(stored[chunkOffset(1):chunkOffset(2)], make([]byte, storedChunkSize))
+				return stored
+			}),
+		},
+		"zeroed chunk of zeros": {
+			tamper: rewrite(func(stored []byte) []byte {
+				copy(stored[chunkOffset(2):chunkOffset(3)], make([]byte, storedChunkSize))
+				return stored
+			}),
+		},
+		"swapped chunks": {
+			tamper: rewrite(func(stored []byte) []byte {
+				first := append([]byte(nil), stored[chunkOffset(0):chunkOffset(1)]...)
+				copy(stored[chunkOffset(0):], stored[chunkOffset(1):chunkOffset(2)])
+				copy(stored[chunkOffset(1):], first)
+				return stored
+			}),
+		},
+		"truncated at a chunk boundary": {
+			tamper: func(t *testing.T, path string, _ []byte) {
+				require.NoError(t, os.Truncate(path, chunkOffset(3)))
+			},
+			failsOpen: true,
+		},
+		"truncated to the header": {
+			tamper: func(t *testing.T, path string, _ []byte) {
+				require.NoError(t, os.Truncate(path, int64(headerSize)))
+			},
+			failsOpen: true,
+		},
+		"downgraded to the legacy format": {
+			tamper: rewrite(func(stored []byte) []byte {
+				copy(stored, legacyMagic)
+				return stored
+			}),
+			failsOpen: true,
+		},
+		"modified size": {
+			tamper: rewrite(func(stored []byte) []byte {
+				stored[sizeOffset+nonceSize] ^= 1
+				return stored
+			}),
+			failsOpen: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, "file")
+			f, err := key.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+			require.NoError(t, err)
+			// the chunk of zeros is written by extending the file
+			_, err = f.WriteAt(content[3*chunkSize:], 3*chunkSize)
+			require.NoError(t, err)
+			_, err = f.WriteAt(content[:2*chunkSize], 0)
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+
+			stored, err := ioutil.ReadFile(path)
+			require.NoError(t, err)
+			tc.tamper(t, path, stored)
+
+			f, err = key.OpenFile(path, os.O_RDONLY, 0)
+			if tc.failsOpen {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer f.Close()
+
+			_, err = f.ReadAt(make([]byte, len(content)), 0)
+			require.Error(t, err)
+			require.NotEqual(t, io.EOF, err)
+		})
+	}
+}
+
+func TestWrongKey(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "file")
+
+	f, err := newTestKey(t, dir, 1).OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("secret"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	_, err = newTestKey(t, dir, 2).OpenFile(path, os.O_RDONLY, 0)
+	require.Error(t, err)
+
+	stored, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.False(t, bytes.Contains(stored, []byte("secret")))
+}
+
+func TestLegacyFiles(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	key := newTestKey(t, dir, 1)
+	path := filepath.Join(dir, "file")
+
+	// a file which was written before keys were derived for each file
+	stored, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	require.NoError(t, err)
+	id := make([]byte, idSize)
+	rand.New(rand.NewSource(1)).Read(id)
+	_, err = stored.WriteAt(append([]byte(legacyMagic), id...), 0)
+	require.NoError(t, err)
+	legacy := &File{f: stored, key: key, aead: key.aead, id: id}
+	require.NoError(t, legacy.recordSize())
+	_, err = legacy.Write([]byte("legacy"))
+	require.NoError(t, err)
+	require.NoError(t, legacy.Close())
+
+	f, err := key.OpenFile(path, os.O_RDWR, 0)
+	require.NoError(t, err)
+	require.Equal(t, []byte("legacy"), readAll(t, f))
+	// it stays in the legacy format when it's written again
+	_, err = f.WriteAt([]byte("written"), 6)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	f, err = key.OpenFile(path, os.O_RDONLY, 0)
+	require.NoError(t, err)
+	defer f.Close()
+	require.Equal(t, []byte("legacywritten"), readAll(t, f))
+}
+
+func TestFileKeys(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	key := newTestKey(t, dir, 1)
+
+	// the same chunk is sealed differently in each file even with the same nonce
+	var sealed [][]byte
+	for _, id := range [][]byte{make([]byte, idSize), append(make([]byte, idSize-1), 1)} {
+		aead, err := key.fileAEAD(id)
+		require.NoError(t, err)
+		sealed = append(sealed, aead.Seal(nil, make([]byte, nonceSize), []byte("chunk"), nil))
+	}
+	sealed = append(sealed, key.aead.Seal(nil, make([]byte, nonceSize), []byte("chunk"), nil))
+	require.NotEqual(t, sealed[0], sealed[1])
+	require.NotEqual(t, sealed[0], sealed[2])
+	require.NotEqual(t, sealed[1], sealed[2])
+}
@@ -1,16 +1,26 @@
 package fuse
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"syscall"
 
 	"github.com/dimitarvdimitrov/sporkfs/log"
+	"github.com/dimitarvdimitrov/sporkfs/raft"
 	"github.com/dimitarvdimitrov/sporkfs/spork"
 	"github.com/dimitarvdimitrov/sporkfs/store"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
 	"github.com/seaweedfs/fuse"
 	"github.com/seaweedfs/fuse/fs"
 )
 
+const (
+	statfsBlockSize  = 4096
+	statfsFreeFiles  = 1 << 32
+	statfsMaxNameLen = 255
+)
+
 type Fs struct {
 	S            *spork.Spork
 	invalidFiles chan *store.File
@@ -35,6 +45,28 @@ func (f Fs) Root() (fs.Node, error) {
 	return newNode(f.S.Root(), f.S, f.reg), nil
 }
 
+// Statfs reports the capacity of the whole cluster. Spork doesn't limit the number of files, so there are always
+// statfsFreeFiles free ones.
+func (f Fs) Statfs(ctx context.Context, req *fuse.StatfsRequest, resp *fuse.StatfsResponse) (err error) {
+	ctx, span := trace.Start(ctx, "fuse.Fs.Statfs")
+	defer trace.End(span, &err)
+
+	stats, err := f.S.Statfs(ctx)
+	if err != nil {
+		return parseError(err)
+	}
+
+	resp.Bsize = statfsBlockSize
+	resp.Frsize = statfsBlockSize
+	resp.Blocks = stats.TotalBytes / statfsBlockSize
+	resp.Bfree = stats.FreeBytes / statfsBlockSize
+	resp.Bavail = stats.AvailableBytes / statfsBlockSize
+	resp.Files = stats.Files + statfsFreeFiles
+	resp.Ffree = statfsFreeFiles
+	resp.Namelen = statfsMaxNameLen
+	return nil
+}
+
 func (f Fs) Destroy() {
 	log.Info("stopping virtual file system...")
 	f.S.Close()
@@ -46,14 +78,14 @@ func (f Fs) WatchInvalidations(server *fs.Server) {
 		var pid, ppid uint64
 		var pname string
 		if parent := file.Parent; parent != nil {
-			pid = parent.Id
+			pid = parent.Ino()
 			pname = parent.Name
 			if pparent := parent.Parent; pparent != nil {
-				ppid = pparent.Id
+				ppid = pparent.Ino()
 			}
 		}
 		// in a goroutine because of https://github.com/bazil/fuse/issues/220
-		go invalidateFile(file.Id, pid, ppid, file.Name, pname, f.reg, server)
+		go invalidateFile(file.Ino(), pid, ppid, file.Name, pname, f.reg, server)
 	}
 }
 
@@ -87,15 +119,29 @@ func (f Fs) WatchDeletions() {
 }
 
 func parseError(err error) error {
-	switch err {
-	case store.ErrNoSuchFile:
+	switch {
+	case errors.Is(err, store.ErrNoSuchFile):
 		return fuse.ENOENT
-	case store.ErrFileAlreadyExists:
+	case errors.Is(err, store.ErrFileAlreadyExists):
 		return fuse.EEXIST
-	case store.ErrDirectoryNotEmpty:
+	case errors.Is(err, store.ErrDirectoryNotEmpty):
 		return fuse.Errno(syscall.ENOTEMPTY)
-	case store.ErrStaleHandle:
+	case errors.Is(err, store.ErrStaleHandle):
 		return fuse.ESTALE
+	case errors.Is(err, store.ErrPermissionDenied):
+		return fuse.Errno(syscall.EACCES)
+	case errors.Is(err, store.ErrNotDirectory):
+		return fuse.Errno(syscall.ENOTDIR)
+	case errors.Is(err, store.ErrIsDirectory):
+		return fuse.Errno(syscall.EISDIR)
+	case errors.Is(err, store.ErrQuotaExceeded):
+		return fuse.Errno(syscall.EDQUOT)
+	case errors.Is(err, store.ErrReadOnly):
+		return fuse.Errno(syscall.EROFS)
+	case errors.Is(err, raft.ErrNoLeader):
+		return fuse.Errno(syscall.EAGAIN)
+	case errors.Is(err, raft.ErrTimedOut):
+		return fuse.Errno(syscall.ETIMEDOUT)
 	default:
 		return err
 	}
@@ -8,7 +8,9 @@ import (
 	"github.com/dimitarvdimitrov/sporkfs/log"
 	"github.com/dimitarvdimitrov/sporkfs/spork"
 	"github.com/dimitarvdimitrov/sporkfs/store"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
 	"github.com/seaweedfs/fuse"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
@@ -33,7 +35,14 @@ func newHandle(n node, r spork.ReadCloser, w spork.WriteCloser) handle {
 	return h
 }
 
-func (h handle) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+func (h handle) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) (err error) {
+	_, span := trace.Start(ctx, "fuse.handle.Read",
+		trace.Id(h.node.Id),
+		attribute.Int64("offset", req.Offset),
+		attribute.Int("size", req.Size),
+	)
+	defer trace.End(span, &err)
+
 	data := make([]byte, req.Size)
 	n, err := h.r.ReadAt(data, req.Offset)
 	if err != nil && err != io.EOF {
@@ -44,11 +53,21 @@ func (h handle) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.Read
 }
 
 func (h handle) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	if _, err := h.node.spork.Linearize(ctx); err != nil {
+		return nil, parseError(err)
+	}
 	files := h.node.File.Children
 	return toDirEnts(files), nil
 }
 
 func (h handle) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.WriteResponse) (err error) {
+	_, span := trace.Start(ctx, "fuse.handle.Write",
+		trace.Id(h.node.Id),
+		attribute.Int64("offset", req.Offset),
+		attribute.Int("size", len(req.Data)),
+	)
+	defer trace.End(span, &err)
+
 	if req.FileFlags&fuse.OpenAppend != 0 {
 		resp.Size, err = h.w.Write(req.Data)
 	} else {
@@ -68,7 +87,7 @@ func toDirEnts(files []*store.File) []fuse.Dirent {
 		}
 
 		dirEnts[i] = fuse.Dirent{
-			Inode: f.Id,
+			Inode: f.Ino(),
 			Type:  typ,
 			Name:  f.Name,
 		}
@@ -87,10 +106,12 @@ func (h handle) sync() {
 	}
 }
 
-func (h handle) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+func (h handle) Release(ctx context.Context, req *fuse.ReleaseRequest) (err error) {
 	if req.Dir {
 		return nil
 	}
+	_, span := trace.Start(ctx, "fuse.handle.Release", trace.Id(h.node.Id))
+	defer trace.End(span, &err)
 
 	fId := h.node.File.Id
 
@@ -98,7 +119,6 @@ func (h handle) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
 		h.sync()
 	}
 
-	var err error
 	if h.r != nil {
 		if rErr := h.r.Close(); rErr != nil {
 			log.Error("closing reader", log.Id(fId), zap.Error(rErr))
@@ -111,5 +131,5 @@ func (h handle) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
 			err = wErr
 		}
 	}
-	return err
+	return parseError(err)
 }
@@ -7,6 +7,7 @@ import (
 
 	"github.com/dimitarvdimitrov/sporkfs/spork"
 	"github.com/dimitarvdimitrov/sporkfs/store"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
 	"github.com/seaweedfs/fuse"
 	"github.com/seaweedfs/fuse/fs"
 )
@@ -28,10 +29,14 @@ func newNode(f *store.File, s *spork.Spork, r nodeRegistrar) node {
 }
 
 func (n node) Attr(ctx context.Context, attr *fuse.Attr) error {
+	if _, err := n.spork.Linearize(ctx); err != nil {
+		return parseError(err)
+	}
+
 	n.File.RLock()
 	defer n.File.RUnlock()
 
-	attr.Inode = n.Id
+	attr.Inode = n.Ino()
 	attr.Mode = n.Mode
 	attr.Size = uint64(n.Size)
 	attr.Atime = n.Atime
@@ -42,6 +47,9 @@ func (n node) Attr(ctx context.Context, attr *fuse.Attr) error {
 }
 
 func (n node) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	if n.ReadOnly {
+		return parseError(store.ErrReadOnly)
+	}
 	n.File.Lock()
 	if req.Valid&fuse.SetattrSize != 0 {
 		n.Size = int64(req.Size)
@@ -68,21 +76,26 @@ func (n node) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.
 	return n.Attr(ctx, &resp.Attr)
 }
 
-func (n node) Lookup(ctx context.Context, name string) (fs.Node, error) {
-	file, err := n.spork.Lookup(n.File, name)
+func (n node) Lookup(ctx context.Context, name string) (_ fs.Node, err error) {
+	ctx, span := trace.Start(ctx, "fuse.node.Lookup", trace.Id(n.Id), trace.Name(name))
+	defer trace.End(span, &err)
+
+	file, err := n.spork.Lookup(ctx, n.File, name)
 	if err != nil {
 		return nil, parseError(err)
 	}
 	return newNode(file, n.spork, n.registrar), nil
 }
 
-func (n node) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+func (n node) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (_ fs.Handle, err error) {
+	ctx, span := trace.Start(ctx, "fuse.node.Open", trace.Id(n.Id), trace.Name(n.Name))
+	defer trace.End(span, &err)
+
 	var r spork.ReadCloser
 	var w spork.WriteCloser
 
 	if !req.Dir {
-		var err error
-		r, w, err = n.open(int(req.Flags))
+		r, w, err = n.open(ctx, int(req.Flags))
 		if err != nil {
 			return nil, err
 		}
@@ -92,7 +105,7 @@ func (n node) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenRe
 	return handle, nil
 }
 
-func (n node) open(flags int) (r spork.ReadCloser, w spork.WriteCloser, err error) {
+func (n node) open(ctx context.Context, flags int) (r spork.ReadCloser, w spork.WriteCloser, err error) {
 	if !n.registrar.nodeRegistered(n) {
 		return nil, nil, store.ErrStaleHandle
 	}
@@ -101,18 +114,21 @@ func (n node) open(flags int) (r spork.ReadCloser, w spork.WriteCloser, err erro
 
 	switch {
 	case fuseFlags.IsReadWrite():
-		rw, er := n.spork.ReadWriter(n.File, flags)
+		rw, er := n.spork.ReadWriter(ctx, n.File, flags)
 		r, w, err = rw, rw, er
 	case fuseFlags.IsWriteOnly():
-		w, err = n.spork.Write(n.File, flags)
+		w, err = n.spork.Write(ctx, n.File, flags)
 	default:
-		r, err = n.spork.Read(n.File, flags)
+		r, err = n.spork.Read(ctx, n.File, flags)
 	}
 
 	return
 }
 
-func (n node) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.CreateResponse) (fs.Node, fs.Handle, error) {
+func (n node) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.CreateResponse) (_ fs.Node, _ fs.Handle, err error) {
+	ctx, span := trace.Start(ctx, "fuse.node.Create", trace.Id(n.Id), trace.Name(req.Name))
+	defer trace.End(span, &err)
+
 	f, err := n.create(ctx, req.Name, req.Mode)
 	if err != nil {
 		return nil, nil, err
@@ -123,7 +139,7 @@ func (n node) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.Cr
 	var writer spork.WriteCloser
 
 	if node.Mode.IsRegular() {
-		reader, writer, err = node.open(int(req.Flags))
+		reader, writer, err = node.open(ctx, int(req.Flags))
 	}
 	h := newHandle(node, reader, writer)
 	resp.Handle = h.id
@@ -132,7 +148,10 @@ func (n node) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.Cr
 	return node, h, err
 }
 
-func (n node) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error) {
+func (n node) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (_ fs.Node, err error) {
+	ctx, span := trace.Start(ctx, "fuse.node.Mkdir", trace.Id(n.Id), trace.Name(req.Name))
+	defer trace.End(span, &err)
+
 	newFile, err := n.create(ctx, req.Name, req.Mode)
 	if err != nil {
 		return nil, err
@@ -141,25 +160,31 @@ func (n node) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error
 }
 
 func (n node) create(ctx context.Context, name string, mode os.FileMode) (*store.File, error) {
-	f, err := n.spork.CreateFile(n.File, name, mode)
+	f, err := n.spork.CreateFile(ctx, n.File, name, mode)
 	if err != nil {
 		return nil, parseError(err)
 	}
 	return f, nil
 }
 
-func (n node) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Node) error {
+func (n node) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Node) (err error) {
+	ctx, span := trace.Start(ctx, "fuse.node.Rename", trace.Id(n.Id), trace.Name(req.OldName))
+	defer trace.End(span, &err)
+
 	newParent := newDir.(node)
-	file, err := n.spork.Lookup(n.File, req.OldName)
+	file, err := n.spork.Lookup(ctx, n.File, req.OldName)
 	if err != nil {
 		return parseError(err)
 	}
 
-	return n.spork.Rename(file, n.File, newParent.File, req.NewName)
+	return parseError(n.spork.Rename(ctx, file, n.File, newParent.File, req.NewName))
 }
 
-func (n node) Link(ctx context.Context, req *fuse.LinkRequest, old fs.Node) (fs.Node, error) {
-	f, err := n.spork.CreateLink(old.(node).File, n.File, req.NewName)
+func (n node) Link(ctx context.Context, req *fuse.LinkRequest, old fs.Node) (_ fs.Node, err error) {
+	ctx, span := trace.Start(ctx, "fuse.node.Link", trace.Id(n.Id), trace.Name(req.NewName))
+	defer trace.End(span, &err)
+
+	f, err := n.spork.CreateLink(ctx, old.(node).File, n.File, req.NewName)
 	if err != nil {
 		return nil, parseError(err)
 	}
@@ -167,12 +192,15 @@ func (n node) Link(ctx context.Context, req *fuse.LinkRequest, old fs.Node) (fs.
 	return newNode(f, n.spork, n.registrar), nil
 }
 
-func (n node) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
-	file, err := n.spork.Lookup(n.File, req.Name)
+func (n node) Remove(ctx context.Context, req *fuse.RemoveRequest) (err error) {
+	ctx, span := trace.Start(ctx, "fuse.node.Remove", trace.Id(n.Id), trace.Name(req.Name))
+	defer trace.End(span, &err)
+
+	file, err := n.spork.Lookup(ctx, n.File, req.Name)
 	if err != nil {
 		return err
 	}
-	return parseError(n.spork.Delete(file))
+	return parseError(n.spork.Delete(ctx, file))
 }
 
 // seaweedfs also do this so fuck it. This method is supposed to be on the handle, and there is a TO DO in
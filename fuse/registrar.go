@@ -11,6 +11,7 @@ type nodeRegistrar interface {
 	getNode(uint64, uint64, string) (node, bool)
 }
 
+// registrar keeps the nodes by their inode number. Nodes are told apart by their parent's inode number and their name.
 type registrar struct {
 	*sync.RWMutex
 
@@ -20,7 +21,7 @@ type registrar struct {
 func (r *registrar) registerNode(n node) {
 	r.Lock()
 	defer r.Unlock()
-	r.registeredNodes[n.Id] = append(r.registeredNodes[n.Id], n)
+	r.registeredNodes[n.Ino()] = append(r.registeredNodes[n.Ino()], n)
 }
 
 func (r *registrar) deleteNode(f node) {
@@ -28,29 +29,29 @@ func (r *registrar) deleteNode(f node) {
 	defer r.Unlock()
 
 	foundAt := -1
-	for i, link := range r.registeredNodes[f.Id] {
+	for i, link := range r.registeredNodes[f.Ino()] {
 		if link.Name == f.Name &&
 			((link.Parent == nil && f.Parent == nil) ||
-				(link.Parent != nil && f.Parent != nil && link.Parent.Id == f.Parent.Id)) {
+				(link.Parent != nil && f.Parent != nil && link.Parent.Ino() == f.Parent.Ino())) {
 			foundAt = i
 		}
 	}
 	if foundAt != -1 {
-		r.registeredNodes[f.Id] = append(r.registeredNodes[f.Id][:foundAt], r.registeredNodes[f.Id][foundAt+1:]...)
+		r.registeredNodes[f.Ino()] = append(r.registeredNodes[f.Ino()][:foundAt], r.registeredNodes[f.Ino()][foundAt+1:]...)
 	}
 
-	if len(r.registeredNodes[f.Id]) == 0 {
-		delete(r.registeredNodes, f.Id)
+	if len(r.registeredNodes[f.Ino()]) == 0 {
+		delete(r.registeredNodes, f.Ino())
 	}
 }
 
 func (r *registrar) nodeRegistered(f node) bool {
 	r.RLock()
 	defer r.RUnlock()
-	for _, link := range r.registeredNodes[f.Id] {
+	for _, link := range r.registeredNodes[f.Ino()] {
 		if link.Name == f.Name &&
 			((link.Parent == nil && f.Parent == nil) ||
-				(link.Parent != nil && f.Parent != nil && link.Parent.Id == f.Parent.Id)) {
+				(link.Parent != nil && f.Parent != nil && link.Parent.Ino() == f.Parent.Ino())) {
 			return true
 		}
 	}
@@ -61,7 +62,7 @@ func (r *registrar) getNode(id, parent uint64, name string) (node, bool) {
 	r.RLock()
 	defer r.RUnlock()
 	for _, node := range r.registeredNodes[id] {
-		if ((node.Parent == nil && parent == 0) || node.Parent.Id == parent) && node.Name == name {
+		if ((node.Parent == nil && parent == 0) || node.Parent.Ino() == parent) && node.Name == name {
 			return node, true
 		}
 	}
@@ -0,0 +1,154 @@
+// Package mtls authenticates peers to each other with TLS certificates signed by a common CA.
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+
+	"github.com/dimitarvdimitrov/sporkfs/log"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type Config struct {
+	CA   string `toml:"ca"`
+	Cert string `toml:"cert"`
+	Key  string `toml:"key"`
+	// AllowedPeers are the names which a peer's certificate needs to be valid for. If it's empty, the hosts
+	// of all_peers are allowed.
+	AllowedPeers []string `toml:"allowed_peers"`
+}
+
+func (c Config) Enabled() bool {
+	return c.CA != "" || c.Cert != "" || c.Key != ""
+}
+
+// Credentials authenticate this node to its peers and its peers to this node. The zero value doesn't authenticate
+// anyone and connections aren't encrypted.
+type Credentials struct {
+	server, client *tls.Config
+	allowedPeers   []string
+}
+
+// Load reads the certificates from the config. If the config doesn't allow any peers explicitly, the hosts of
+// allPeers are allowed.
+func Load(cfg Config, allPeers []string) (Credentials, error) {
+	if !cfg.Enabled() {
+		return Credentials{}, nil
+	}
+
+	caPEM, err := ioutil.ReadFile(cfg.CA)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("reading CA: %w", err)
+	}
+	ca := x509.NewCertPool()
+	if !ca.AppendCertsFromPEM(caPEM) {
+		return Credentials{}, fmt.Errorf("no certificates found in %s", cfg.CA)
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("loading certificate: %w", err)
+	}
+
+	allowed := cfg.AllowedPeers
+	if len(allowed) == 0 {
+		for _, p := range allPeers {
+			host, _, err := net.SplitHostPort(p)
+			if err != nil {
+				return Credentials{}, fmt.Errorf("parsing peer address %s: %w", p, err)
+			}
+			allowed = append(allowed, host)
+		}
+	}
+
+	return Credentials{
+		server: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			ClientCAs:    ca,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			MinVersion:   tls.VersionTLS12,
+		},
+		client: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			RootCAs:      ca,
+			MinVersion:   tls.VersionTLS12,
+		},
+		allowedPeers: allowed,
+	}, nil
+}
+
+// DialOption returns the option with which to dial peers.
+func (c Credentials) DialOption() grpc.DialOption {
+	if c.client == nil {
+		return grpc.WithInsecure()
+	}
+	return grpc.WithTransportCredentials(credentials.NewTLS(c.client))
+}
+
+// ServerOptions return the options with which to serve peers. Calls from peers whose certificates aren't valid
+// for any of the allowed peers are rejected.
+func (c Credentials) ServerOptions() []grpc.ServerOption {
+	if c.server == nil {
+		return nil
+	}
+	return []grpc.ServerOption{
+		grpc.Creds(credentials.NewTLS(c.server)),
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := c.authorize(ctx, info.FullMethod); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := c.authorize(ss.Context(), info.FullMethod); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	}
+}
+
+// ClientOptions return the options with which to serve clients. Clients don't need a certificate, but if they
+// present one, it needs to be signed by the CA.
+func (c Credentials) ClientOptions() []grpc.ServerOption {
+	if c.server == nil {
+		return nil
+	}
+	cfg := c.server.Clone()
+	cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	return []grpc.ServerOption{grpc.Creds(credentials.NewTLS(cfg))}
+}
+
+func (c Credentials) authorize(ctx context.Context, method string) error {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "unknown peer")
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return status.Error(codes.Unauthenticated, "no verified certificate")
+	}
+
+	cert := info.State.VerifiedChains[0][0]
+	for _, name := range c.allowedPeers {
+		if cert.VerifyHostname(name) == nil {
+			return nil
+		}
+	}
+
+	log.Warn("[mtls] rejecting call from peer which isn't allowed",
+		zap.String("method", method),
+		zap.String("addr", p.Addr.String()),
+		zap.String("subject", cert.Subject.String()),
+	)
+	return status.Error(codes.PermissionDenied, "peer isn't allowed")
+}
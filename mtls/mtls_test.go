@@ -0,0 +1,181 @@
+package mtls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// writeTestCerts writes a CA and a certificate for node-1 signed by it to dir and returns the config for them.
+func writeTestCerts(t *testing.T, dir string) Config {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "sporkfs test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "node-1"},
+		DNSNames:     []string{"node-1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caTemplate, &key.PublicKey, caKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	cfg := Config{
+		CA:   filepath.Join(dir, "ca.pem"),
+		Cert: filepath.Join(dir, "node.pem"),
+		Key:  filepath.Join(dir, "node-key.pem"),
+	}
+	writePEM := func(path, typ string, der []byte) {
+		require.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600))
+	}
+	writePEM(cfg.CA, "CERTIFICATE", caDER)
+	writePEM(cfg.Cert, "CERTIFICATE", der)
+	writePEM(cfg.Key, "EC PRIVATE KEY", keyDER)
+	return cfg
+}
+
+func TestLoad(t *testing.T) {
+	testCases := map[string]struct {
+		// configure changes the config with the test certificates
+		configure func(cfg *Config)
+		allPeers  []string
+		err       bool
+		// enabled is true if the credentials use TLS
+		enabled bool
+		allowed []string
+	}{
+		"disabled": {
+			configure: func(cfg *Config) { *cfg = Config{} },
+		},
+		"allowed peers": {
+			configure: func(cfg *Config) { cfg.AllowedPeers = []string{"node-2"} },
+			allPeers:  []string{"node-1:9000", "node-3:9000"},
+			enabled:   true,
+			allowed:   []string{"node-2"},
+		},
+		"hosts of all peers": {
+			allPeers: []string{"node-1:9000", "10.0.0.2:9000"},
+			enabled:  true,
+			allowed:  []string{"node-1", "10.0.0.2"},
+		},
+		"peer without a port": {
+			allPeers: []string{"node-1"},
+			err:      true,
+		},
+		"missing CA": {
+			configure: func(cfg *Config) { cfg.CA = filepath.Join(filepath.Dir(cfg.CA), "missing.pem") },
+			err:       true,
+		},
+		"CA without certificates": {
+			configure: func(cfg *Config) { cfg.CA = cfg.Key },
+			err:       true,
+		},
+		"key of another certificate": {
+			configure: func(cfg *Config) { cfg.Key = cfg.CA },
+			err:       true,
+		},
+		"only a CA": {
+			configure: func(cfg *Config) { cfg.Cert, cfg.Key = "", "" },
+			err:       true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "sporkfs-mtls")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+			cfg := writeTestCerts(t, dir)
+			if tc.configure != nil {
+				tc.configure(&cfg)
+			}
+
+			creds, err := Load(cfg, tc.allPeers)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.enabled, creds.server != nil)
+			require.Equal(t, tc.enabled, creds.ServerOptions() != nil)
+			require.Equal(t, tc.enabled, creds.ClientOptions() != nil)
+			require.Equal(t, tc.allowed, creds.allowedPeers)
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	testCases := map[string]struct {
+		peer bool
+		tls  bool
+		// cert is the verified certificate of the peer if it isn't nil
+		cert *x509.Certificate
+		code codes.Code
+	}{
+		"allowed name":         {peer: true, tls: true, cert: &x509.Certificate{DNSNames: []string{"node-1"}}, code: codes.OK},
+		"allowed address":      {peer: true, tls: true, cert: &x509.Certificate{IPAddresses: []net.IP{net.ParseIP("10.0.0.2")}}, code: codes.OK},
+		"wildcard name":        {peer: true, tls: true, cert: &x509.Certificate{DNSNames: []string{"*.peers"}}, code: codes.OK},
+		"other name":           {peer: true, tls: true, cert: &x509.Certificate{DNSNames: []string{"node-2"}}, code: codes.PermissionDenied},
+		"only a common name":   {peer: true, tls: true, cert: &x509.Certificate{Subject: pkix.Name{CommonName: "node-1"}}, code: codes.PermissionDenied},
+		"unverified":           {peer: true, tls: true, code: codes.Unauthenticated},
+		"without TLS":          {peer: true, code: codes.Unauthenticated},
+		"without a known peer": {code: codes.Unauthenticated},
+	}
+
+	creds := Credentials{allowedPeers: []string{"node-1", "10.0.0.2", "node-3.peers"}}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.peer {
+				p := &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.9"), Port: 9000}}
+				if tc.tls {
+					var state tls.ConnectionState
+					if tc.cert != nil {
+						state.VerifiedChains = [][]*x509.Certificate{{tc.cert}}
+					}
+					p.AuthInfo = credentials.TLSInfo{State: state}
+				}
+				ctx = peer.NewContext(ctx, p)
+			}
+
+			err := creds.authorize(ctx, "/raftpb.Raft/Step")
+			require.Equal(t, tc.code, status.Code(err))
+		})
+	}
+}
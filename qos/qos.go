@@ -0,0 +1,186 @@
+// Package qos limits the bandwidth used by file transfers between peers. Transfers are classified by
+// what caused them. Each background class can have its own limit and background transfers yield
+// to interactive ones, i.e. ones which a user of the file system is waiting on.
+package qos
+
+import (
+	"context"
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Class int32
+
+const (
+	// Interactive transfers are the ones which a FUSE operation is waiting for. They are never limited.
+	Interactive Class = iota
+	// CacheFill transfers update cached copies of remote files.
+	CacheFill
+	// Replication transfers copy the new versions of files to the peers responsible for them.
+	Replication
+	// Rebalance transfers move files between peers when responsibility for them changes.
+	Rebalance
+)
+
+func (c Class) String() string {
+	switch c {
+	case Interactive:
+		return "interactive"
+	case CacheFill:
+		return "cache_fill"
+	case Replication:
+		return "replication"
+	case Rebalance:
+		return "rebalance"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	// preemptWindow is how long background transfers yield after the last chunk of interactive traffic
+	preemptWindow = time.Millisecond * 50
+	// maxPreemption is the longest a single chunk of background traffic yields, so that it's never starved
+	maxPreemption = time.Second
+)
+
+var transferredMetric = expvar.NewMap("qos_transferred_bytes")
+
+type Config struct {
+	// all limits are in bytes per second; 0 means unlimited
+	ReplicationLimit int64 `toml:"replication_bytes_per_sec"`
+	CacheFillLimit   int64 `toml:"cache_fill_bytes_per_sec"`
+	RebalanceLimit   int64 `toml:"rebalance_bytes_per_sec"`
+}
+
+type classKey struct{}
+
+// WithClass returns a context whose transfers are of the class.
+func WithClass(ctx context.Context, class Class) context.Context {
+	return context.WithValue(ctx, classKey{}, class)
+}
+
+// ClassFrom returns the class of transfers made with the context. It's Interactive unless set otherwise.
+func ClassFrom(ctx context.Context) Class {
+	class, _ := ctx.Value(classKey{}).(Class)
+	return class
+}
+
+// Limiter is shared by all transfers on a node. A nil *Limiter doesn't limit anything.
+type Limiter struct {
+	buckets map[Class]*bucket
+	// lastInteractive is the time in unix nanoseconds of the last interactive chunk
+	lastInteractive int64
+}
+
+func NewLimiter(cfg Config) *Limiter {
+	l := &Limiter{buckets: make(map[Class]*bucket)}
+	for class, limit := range map[Class]int64{
+		CacheFill:   cfg.CacheFillLimit,
+		Replication: cfg.ReplicationLimit,
+		Rebalance:   cfg.RebalanceLimit,
+	} {
+		if limit > 0 {
+			l.buckets[class] = newBucket(limit)
+		}
+	}
+	return l
+}
+
+// Wait blocks until n bytes of the class can be transferred. Background classes first yield to any ongoing
+// interactive transfers and then wait for their own limit.
+func (l *Limiter) Wait(ctx context.Context, class Class, n int) error {
+	transferredMetric.Add(class.String(), int64(n))
+	if l == nil {
+		return nil
+	}
+
+	if class == Interactive {
+		atomic.StoreInt64(&l.lastInteractive, time.Now().UnixNano())
+		return nil
+	}
+
+	if err := l.yield(ctx); err != nil {
+		return err
+	}
+
+	b, ok := l.buckets[class]
+	if !ok {
+		return nil
+	}
+	return sleep(ctx, b.reserve(n))
+}
+
+// yield waits until there has been no interactive traffic for preemptWindow, but no longer than maxPreemption.
+func (l *Limiter) yield(ctx context.Context) error {
+	deadline := time.Now().Add(maxPreemption)
+	for {
+		sinceInteractive := time.Since(time.Unix(0, atomic.LoadInt64(&l.lastInteractive)))
+		if sinceInteractive >= preemptWindow {
+			return nil
+		}
+
+		wait := preemptWindow - sinceInteractive
+		if remaining := time.Until(deadline); remaining < wait {
+			wait = remaining
+		}
+		if wait <= 0 {
+			return nil
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// bucket is a token bucket which allows bursts of up to a second worth of traffic.
+type bucket struct {
+	m      sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(bytesPerSec int64) *bucket {
+	return &bucket{
+		rate:   float64(bytesPerSec),
+		tokens: float64(bytesPerSec),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes n tokens from the bucket and returns how long the caller needs to wait until they are available.
+func (b *bucket) reserve(n int) time.Duration {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.last = now
+
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
@@ -0,0 +1,88 @@
+package qos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket(t *testing.T) {
+	const rate = 1000
+	testCases := map[string]struct {
+		tokens  float64
+		elapsed time.Duration
+		n       int
+		wait    time.Duration
+	}{
+		"within the burst":   {tokens: rate, n: 500},
+		"the whole burst":    {tokens: rate, n: rate},
+		"over the burst":     {tokens: rate, n: 1500, wait: 500 * time.Millisecond},
+		"empty":              {tokens: 0, n: 100, wait: 100 * time.Millisecond},
+		"in debt":            {tokens: -rate, n: 100, wait: 1100 * time.Millisecond},
+		"refilled":           {tokens: 0, elapsed: 500 * time.Millisecond, n: 500},
+		"partly refilled":    {tokens: 0, elapsed: 500 * time.Millisecond, n: 700, wait: 200 * time.Millisecond},
+		"refill is capped":   {tokens: 0, elapsed: 10 * time.Second, n: 1500, wait: 500 * time.Millisecond},
+		"debt is paid first": {tokens: -rate, elapsed: 500 * time.Millisecond, n: 0, wait: 500 * time.Millisecond},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			b := newBucket(rate)
+			b.tokens = tc.tokens
+			b.last = time.Now().Add(-tc.elapsed)
+
+			// the time between setting up the bucket and reserving refills it a little
+			require.InDelta(t, tc.wait.Seconds(), b.reserve(tc.n).Seconds(), 0.01)
+		})
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	testCases := map[string]struct {
+		limiter *Limiter
+		class   Class
+		// interactive is true if there was interactive traffic right before
+		interactive bool
+		n           int
+		// minWait is how long the transfer at least needs to wait
+		minWait time.Duration
+	}{
+		"nil limiter":            {class: Replication, n: 1 << 30},
+		"interactive":            {limiter: NewLimiter(Config{CacheFillLimit: 1}), class: Interactive, n: 1 << 30},
+		"unlimited class":        {limiter: NewLimiter(Config{CacheFillLimit: 1}), class: Replication, n: 1 << 30},
+		"within the limit":       {limiter: NewLimiter(Config{ReplicationLimit: 1000}), class: Replication, n: 1000},
+		"over the limit":         {limiter: NewLimiter(Config{ReplicationLimit: 1000}), class: Replication, n: 1100, minWait: 90 * time.Millisecond},
+		"yielding":               {limiter: NewLimiter(Config{}), class: Rebalance, interactive: true, minWait: preemptWindow},
+		"yielding and the limit": {limiter: NewLimiter(Config{RebalanceLimit: 1000}), class: Rebalance, interactive: true, n: 1100, minWait: preemptWindow + 90*time.Millisecond},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if tc.interactive {
+				require.NoError(t, tc.limiter.Wait(context.Background(), Interactive, 1))
+			}
+
+			start := time.Now()
+			require.NoError(t, tc.limiter.Wait(context.Background(), tc.class, tc.n))
+			waited := time.Since(start)
+			require.True(t, waited >= tc.minWait, "waited %s, expected at least %s", waited, tc.minWait)
+			require.True(t, waited < tc.minWait+maxPreemption/2, "waited %s, expected about %s", waited, tc.minWait)
+		})
+	}
+}
+
+func TestLimiterCancelled(t *testing.T) {
+	l := NewLimiter(Config{ReplicationLimit: 1})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	require.Equal(t, context.DeadlineExceeded, l.Wait(ctx, Replication, 100))
+}
+
+func TestClass(t *testing.T) {
+	ctx := context.Background()
+	require.Equal(t, Interactive, ClassFrom(ctx))
+	require.Equal(t, Rebalance, ClassFrom(WithClass(ctx, Rebalance)))
+}
@@ -1,6 +1,8 @@
 package raft
 
 import (
+	"context"
+	"fmt"
 	"math/rand"
 	"sync"
 	"time"
@@ -8,28 +10,66 @@ import (
 	"github.com/dimitarvdimitrov/sporkfs/log"
 	raftpb "github.com/dimitarvdimitrov/sporkfs/raft/pb"
 	"github.com/dimitarvdimitrov/sporkfs/store"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
 // applier terminates when the commits channel has been closed. Applier accepts proposals and keeps track of them.
 // See implementation of
 type applier struct {
-	proposeC chan<- *raftpb.Entry
+	proposeC chan<- proposal
 	commitC  <-chan UnactionedMessage
 	syncC    chan<- UnactionedMessage
 
 	l        sync.Mutex
 	wg       *sync.WaitGroup
 	inFlight map[uint64]chan func() // entries for which we haven't received a committed raft entry yet
+	applied  *recentIds             // entries which were committed recently
 	done     chan struct{}
 }
 
-func newApplier(commits <-chan UnactionedMessage, proposals chan<- *raftpb.Entry) (*applier, <-chan UnactionedMessage) {
+// appliedWindow is how many of the last committed entries are remembered in order to drop them if they are
+// committed again, e.g. when the leader received a forwarded proposal but the reply was lost and it was sent again.
+const appliedWindow = 1 << 14
+
+// recentIds is a set of the last ids which were added to it.
+type recentIds struct {
+	ids  map[uint64]struct{}
+	ring []uint64
+	next int
+}
+
+func newRecentIds(size int) *recentIds {
+	return &recentIds{
+		ids:  make(map[uint64]struct{}, size),
+		ring: make([]uint64, 0, size),
+	}
+}
+
+// add adds the id to the set and returns false if it was already in it.
+func (r *recentIds) add(id uint64) bool {
+	if _, ok := r.ids[id]; ok {
+		return false
+	}
+	if len(r.ring) < cap(r.ring) {
+		r.ring = append(r.ring, id)
+	} else {
+		delete(r.ids, r.ring[r.next])
+		r.ring[r.next] = id
+		r.next = (r.next + 1) % len(r.ring)
+	}
+	r.ids[id] = struct{}{}
+	return true
+}
+
+func newApplier(commits <-chan UnactionedMessage, proposals chan<- proposal) (*applier, <-chan UnactionedMessage) {
 	syncC := make(chan UnactionedMessage)
 	w := &applier{
 		proposeC: proposals,
 		commitC:  commits,
 		inFlight: make(map[uint64]chan func()),
+		applied:  newRecentIds(appliedWindow),
 		done:     make(chan struct{}),
 		syncC:    syncC,
 		wg:       &sync.WaitGroup{},
@@ -49,6 +89,12 @@ func (w *applier) isRegistered(reqId uint64) bool {
 func (w *applier) watchCommits() {
 	defer w.wg.Done()
 	for entry := range w.commitC {
+		if !w.applied.add(entry.Id) {
+			log.Warn("[applier] dropping raft entry which was already applied", zap.Uint64("entry_rand_id", entry.Id))
+			entry.Action()
+			continue
+		}
+
 		w.l.Lock()
 		resultC, ok := w.inFlight[entry.Id]
 		if ok {
@@ -67,7 +113,7 @@ func (w *applier) watchCommits() {
 	}
 }
 
-func (w *applier) ProposeChange(id, version, offset, peer uint64, size int64) (bool, func()) {
+func (w *applier) ProposeChange(ctx context.Context, id, version, offset, peer uint64, size int64, changed time.Time) (func(), error) {
 	c := &raftpb.Change{
 		Id:      id,
 		Version: version,
@@ -76,13 +122,14 @@ func (w *applier) ProposeChange(id, version, offset, peer uint64, size int64) (b
 		PeerId:  peer,
 	}
 	entry := &raftpb.Entry{
+		Time:    changed.UnixNano(),
 		Message: &raftpb.Entry_Change{Change: c},
 	}
 
-	return w.propose(entry)
+	return w.propose(ctx, entry)
 }
 
-func (w *applier) ProposeAdd(id, parentId uint64, name string, mode store.FileMode) (bool, func()) {
+func (w *applier) ProposeAdd(ctx context.Context, id, parentId uint64, name string, mode store.FileMode) (func(), error) {
 	a := &raftpb.Add{
 		Id:       id,
 		ParentId: parentId,
@@ -93,10 +140,10 @@ func (w *applier) ProposeAdd(id, parentId uint64, name string, mode store.FileMo
 		Message: &raftpb.Entry_Add{Add: a},
 	}
 
-	return w.propose(entry)
+	return w.propose(ctx, entry)
 }
 
-func (w *applier) ProposeRename(id, oldParentId, newParentId uint64, oldName, newName string) (bool, func()) {
+func (w *applier) ProposeRename(ctx context.Context, id, oldParentId, newParentId uint64, oldName, newName string, renamed time.Time) (func(), error) {
 	r := &raftpb.Rename{
 		Id:          id,
 		OldParentId: oldParentId,
@@ -105,34 +152,131 @@ func (w *applier) ProposeRename(id, oldParentId, newParentId uint64, oldName, ne
 		OldName:     oldName,
 	}
 	entry := &raftpb.Entry{
+		Time:    renamed.UnixNano(),
 		Message: &raftpb.Entry_Rename{Rename: r},
 	}
-	return w.propose(entry)
+	return w.propose(ctx, entry)
 }
 
-func (w *applier) ProposeDelete(id, parentId uint64, name string) (bool, func()) {
+func (w *applier) ProposeDelete(ctx context.Context, id, parentId uint64, name string, deleted time.Time) (func(), error) {
 	d := &raftpb.Delete{
 		Id:       id,
 		ParentId: parentId,
 		Name:     name,
 	}
 	entry := &raftpb.Entry{
+		Time:    deleted.UnixNano(),
+		Message: &raftpb.Entry_Delete{Delete: d},
+	}
+	return w.propose(ctx, entry)
+}
+
+func (w *applier) ProposeDeleteAll(ctx context.Context, id, parentId uint64, name string, deleted time.Time) (func(), error) {
+	d := &raftpb.Delete{
+		Id:        id,
+		ParentId:  parentId,
+		Name:      name,
+		Recursive: true,
+	}
+	entry := &raftpb.Entry{
+		Time:    deleted.UnixNano(),
 		Message: &raftpb.Entry_Delete{Delete: d},
 	}
-	return w.propose(entry)
+	return w.propose(ctx, entry)
+}
+
+func (w *applier) ProposeSetACL(ctx context.Context, id uint64, acl store.ACL) (func(), error) {
+	a := &raftpb.SetACL{
+		Id:  id,
+		Acl: make(map[string]uint32, len(acl)),
+	}
+	for user, perm := range acl {
+		a.Acl[user] = uint32(perm)
+	}
+	entry := &raftpb.Entry{
+		Message: &raftpb.Entry_SetAcl{SetAcl: a},
+	}
+	return w.propose(ctx, entry)
+}
+
+func (w *applier) ProposeSetQuota(ctx context.Context, id uint64, maxBytes, maxInodes int64) (func(), error) {
+	q := &raftpb.SetQuota{
+		Id:        id,
+		MaxBytes:  maxBytes,
+		MaxInodes: maxInodes,
+	}
+	entry := &raftpb.Entry{
+		Message: &raftpb.Entry_SetQuota{SetQuota: q},
+	}
+	return w.propose(ctx, entry)
 }
 
-func (w *applier) propose(entry *raftpb.Entry) (bool, func()) {
+func (w *applier) ProposeCreateSnapshot(ctx context.Context, id uint64, name string, created time.Time) (func(), error) {
+	s := &raftpb.CreateSnapshot{
+		Id:      id,
+		Name:    name,
+		Created: created.UnixNano(),
+	}
+	entry := &raftpb.Entry{
+		Message: &raftpb.Entry_CreateSnapshot{CreateSnapshot: s},
+	}
+	return w.propose(ctx, entry)
+}
+
+func (w *applier) ProposeDeleteSnapshot(ctx context.Context, id uint64, name string) (func(), error) {
+	s := &raftpb.DeleteSnapshot{
+		Id:   id,
+		Name: name,
+	}
+	entry := &raftpb.Entry{
+		Message: &raftpb.Entry_DeleteSnapshot{DeleteSnapshot: s},
+	}
+	return w.propose(ctx, entry)
+}
+
+func (w *applier) ProposeCopy(ctx context.Context, id, parentId uint64, name string, mode store.FileMode, srcId, srcVersion, version uint64, size int64) (func(), error) {
+	c := &raftpb.Copy{
+		Id:            id,
+		ParentId:      parentId,
+		Name:          name,
+		Mode:          uint32(mode),
+		SourceId:      srcId,
+		SourceVersion: srcVersion,
+		Version:       version,
+		Size:          size,
+	}
+	entry := &raftpb.Entry{
+		Message: &raftpb.Entry_Copy{Copy: c},
+	}
+	return w.propose(ctx, entry)
+}
+
+func (w *applier) ProposeBatch(ctx context.Context, ops []*raftpb.Batch_Operation, applied time.Time) (func(), error) {
+	entry := &raftpb.Entry{
+		Time:    applied.UnixNano(),
+		Message: &raftpb.Entry_Batch{Batch: &raftpb.Batch{Operations: ops}},
+	}
+	return w.propose(ctx, entry)
+}
+
+func (w *applier) propose(ctx context.Context, entry *raftpb.Entry) (action func(), err error) {
+	ctx, span := trace.Start(ctx, "raft.propose", attribute.String("type", fmt.Sprintf("%T", entry.Message)))
+	defer trace.End(span, &err)
+
 	select {
 	case <-w.done:
-		return false, noop
+		return noop, ErrStopped
 	default:
 	}
 
 	w.wg.Add(1)
 	defer w.wg.Done()
 
-	resultC := make(chan func())
+	if entry.Time == 0 {
+		entry.Time = time.Now().UnixNano()
+	}
+	// the result is sent while holding the lock, so it's never lost even if we stop waiting for it
+	resultC := make(chan func(), 1)
 	w.l.Lock()
 	entry.Id = w.generateId()
 	w.inFlight[entry.Id] = resultC
@@ -141,32 +285,55 @@ func (w *applier) propose(entry *raftpb.Entry) (bool, func()) {
 	defer func() {
 		w.l.Lock()
 		delete(w.inFlight, entry.Id)
+		select {
+		case committed, ok := <-resultC:
+			// the entry was committed right after we gave up waiting for it
+			if ok && err != nil {
+				action, err = committed, nil
+			}
+		default:
+		}
 		w.l.Unlock()
 	}()
 
 	// an election timeout should be enough to hear back for the entry
 	// about 10 messages would have been exchanged between us and the leader
-	timeout := time.NewTimer(electionTimeout).C
+	ctx, cancel := context.WithTimeout(ctx, electionTimeout)
+	defer cancel()
 
+	deliveredC := make(chan error, 1)
 	select {
 	case <-w.done:
-		return false, noop
-	case <-timeout:
-		return false, noop
-	case w.proposeC <- entry:
+		return noop, ErrStopped
+	case <-ctx.Done():
+		span.AddEvent("timed out waiting to propose")
+		return noop, ErrTimedOut
+	case w.proposeC <- proposal{ctx: ctx, entry: entry, delivered: deliveredC}:
 		log.Debug("[applier] proposed entry", zap.Uint64("entry_rand_id", entry.Id))
 	}
 
-	select {
-	case <-w.done:
-		return false, noop
-	case <-timeout:
-		return false, noop
-	case callback, ok := <-resultC:
-		if !ok {
-			return ok, noop
+	// the entry may be committed before we hear back that it was delivered, so we wait for both at the same time;
+	// until it's delivered, the node gives up by itself when ctx is done and tells us why
+	var timeout <-chan struct{}
+	for {
+		select {
+		case <-w.done:
+			return noop, ErrStopped
+		case err = <-deliveredC:
+			if err != nil {
+				return noop, err
+			}
+			span.AddEvent("delivered to leader")
+			deliveredC, timeout = nil, ctx.Done()
+		case <-timeout:
+			span.AddEvent("timed out waiting for commit")
+			return noop, fmt.Errorf("%w: waiting for commit", ErrTimedOut)
+		case callback, ok := <-resultC:
+			if !ok {
+				return noop, ErrStopped
+			}
+			return callback, nil
 		}
-		return ok, callback
 	}
 }
 
@@ -0,0 +1,70 @@
+package raft
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	raftpb "github.com/dimitarvdimitrov/sporkfs/raft/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecentIds(t *testing.T) {
+	type add struct {
+		id  uint64
+		new bool
+	}
+	r := newRecentIds(2)
+	for i, a := range []add{
+		{id: 1, new: true},
+		{id: 2, new: true},
+		{id: 1},
+		{id: 2},
+		{id: 3, new: true}, // 1 is forgotten
+		{id: 1, new: true}, // 2 is forgotten
+		{id: 3},
+		{id: 2, new: true},
+	} {
+		require.Equal(t, a.new, r.add(a.id), "add %d: %d", i, a.id)
+	}
+}
+
+func TestCommittedTwiceAppliedOnce(t *testing.T) {
+	commits, proposals := make(chan UnactionedMessage), make(chan proposal)
+	w, syncC := newApplier(commits, proposals)
+	defer func() {
+		close(commits)
+		w.close()
+	}()
+	actioned := make(chan uint64, 10)
+	commitTwice := func(entry *raftpb.Entry) {
+		for i := 0; i < 2; i++ {
+			commits <- UnactionedMessage{Entry: entry, Action: func() { actioned <- entry.Id }}
+		}
+	}
+
+	// the leader received a forwarded proposal, but the reply was lost and it was forwarded again
+	go func() {
+		p := <-proposals
+		p.delivered <- nil
+		commitTwice(p.entry)
+	}()
+	callback, err := w.propose(context.Background(), &raftpb.Entry{})
+	require.NoError(t, err)
+	id := <-actioned // the second one is dropped
+	callback()
+	require.Equal(t, id, <-actioned)
+
+	// an entry from another node is also only applied once
+	go commitTwice(&raftpb.Entry{Id: id + 1})
+	applied := <-syncC
+	require.Equal(t, id+1, applied.Id)
+	require.Equal(t, id+1, <-actioned)
+	applied.Action()
+	require.Equal(t, id+1, <-actioned)
+	select {
+	case m := <-syncC:
+		t.Fatalf("entry %d applied again", m.Id)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
@@ -1,8 +1,20 @@
 package raft
 
+import (
+	"github.com/dimitarvdimitrov/sporkfs/compression"
+	"github.com/dimitarvdimitrov/sporkfs/crypt"
+	"github.com/dimitarvdimitrov/sporkfs/mtls"
+)
+
 type Config struct {
-	AllPeers   []string `toml:"all_peers"`
-	ThisPeer   string   `toml:"this_peer"`
-	Redundancy int      `toml:"redundancy"`
-	DataDir    string
+	AllPeers    []string           `toml:"all_peers"`
+	ThisPeer    string             `toml:"this_peer"`
+	Redundancy  int                `toml:"redundancy"`
+	Compression compression.Config `toml:"compression"`
+	TLS         mtls.Config        `toml:"tls"`
+	DataDir     string
+	// Key encrypts the raft log at rest; nil means it's stored in plain
+	Key *crypt.Key `toml:"-"`
+	// Credentials are loaded from TLS and used to dial peers
+	Credentials mtls.Credentials `toml:"-"`
 }
@@ -0,0 +1,13 @@
+package raft
+
+import "errors"
+
+var (
+	// ErrNoLeader is returned when a proposal couldn't be delivered because the cluster had no leader the whole time,
+	// e.g. because a quorum of nodes is down.
+	ErrNoLeader = errors.New("[raft]: no leader")
+	// ErrTimedOut is returned when a proposal wasn't committed in time even though there was a leader.
+	ErrTimedOut = errors.New("[raft]: timed out")
+	// ErrStopped is returned when the node is stopped while a proposal is in flight.
+	ErrStopped = errors.New("[raft]: stopped")
+)
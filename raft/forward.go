@@ -0,0 +1,149 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/raft"
+	etcdraftpb "github.com/coreos/etcd/raft/raftpb"
+	"github.com/dimitarvdimitrov/sporkfs/log"
+	raftpb "github.com/dimitarvdimitrov/sporkfs/raft/pb"
+	"github.com/golang/protobuf/proto"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// proposal is an entry which is waiting to be delivered to the leader.
+type proposal struct {
+	ctx   context.Context
+	entry *raftpb.Entry
+	// delivered receives the result of delivering the entry; it needs to be buffered
+	delivered chan<- error
+}
+
+// leader is the id of the raft leader as known by this node.
+type leader struct {
+	l       sync.Mutex
+	id      uint64
+	changed chan struct{} // closed and replaced every time the leader changes
+}
+
+func newLeader() *leader {
+	return &leader{changed: make(chan struct{})}
+}
+
+func (l *leader) set(id uint64) {
+	l.l.Lock()
+	defer l.l.Unlock()
+
+	if id == l.id {
+		return
+	}
+	l.id = id
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// get returns the leader, which is raft.None if there isn't one, and a channel which is closed when it changes.
+func (l *leader) get() (uint64, <-chan struct{}) {
+	l.l.Lock()
+	defer l.l.Unlock()
+
+	return l.id, l.changed
+}
+
+// deliver hands the entries to the leader: it proposes them if this node is the leader and forwards them to the
+// leader otherwise. If that fails because there is no leader or the node it was sent to wasn't the leader, it
+// retries when the leader changes or after a short while, until ctx is done. Other errors aren't retried since
+// the leader may have appended the entries anyway. Delivered entries are only committed if the leader keeps its
+// leadership until then.
+func (s *node) deliver(ctx context.Context, entries []*raftpb.Entry) error {
+	marshalled, err := marshalEntries(entries)
+	if err != nil {
+		return err
+	}
+
+	retry := time.NewTicker(bcastTime)
+	defer retry.Stop()
+
+	for {
+		lead, changed := s.leader.get()
+		switch lead {
+		case raft.None:
+			err = ErrNoLeader
+		case s.peers.thisPeerRaftId():
+			err = s.proposeLocally(ctx, marshalled)
+		default:
+			err = s.forward(ctx, lead, entries)
+		}
+		if err == nil {
+			return nil
+		}
+		if !notLeader(err) {
+			return fmt.Errorf("%w: delivering entries to leader %d: %s", ErrTimedOut, lead, err)
+		}
+		log.Debug("[node] delivering entries to leader failed; retrying", zap.Uint64("leader", lead), zap.Int("entries", len(entries)), zap.Error(err))
+
+		select {
+		case <-changed:
+		case <-retry.C:
+		case <-s.done:
+			return ErrStopped
+		case <-ctx.Done():
+			if err == ErrNoLeader {
+				return err
+			}
+			return fmt.Errorf("%w: delivering entries to leader %d: %s", ErrTimedOut, lead, err)
+		}
+	}
+}
+
+// proposeLocally proposes the entries if this node is the leader. They are appended to the log with
+// a single proposal, so they are replicated together.
+func (s *node) proposeLocally(ctx context.Context, entries []etcdraftpb.Entry) error {
+	if lead, _ := s.leader.get(); lead != s.peers.thisPeerRaftId() {
+		return status.Error(codes.FailedPrecondition, "not the leader")
+	}
+
+	// this is a local action, so shouldn't take long, but still short circuit if raft got stuck
+	ctx, cancel := context.WithTimeout(ctx, bcastTime)
+	defer cancel()
+	return s.raft.Step(ctx, etcdraftpb.Message{Type: etcdraftpb.MsgProp, Entries: entries})
+}
+
+// forward sends the entries to the leader, which proposes them.
+func (s *node) forward(ctx context.Context, lead uint64, entries []*raftpb.Entry) error {
+	peer, ok := s.clients[s.peers.GetPeerRaft(lead)]
+	if !ok {
+		return fmt.Errorf("%w %d", errUnknownLeader, lead)
+	}
+
+	p := &raftpb.Proposal{Entries: entries}
+	ctx, cancel := context.WithTimeout(ctx, bcastTime*10)
+	defer cancel()
+	_, err := peer.Propose(ctx, p, s.compression.CallOptions(int64(proto.Size(p)))...)
+	return err
+}
+
+var errUnknownLeader = errors.New("unknown leader")
+
+// notLeader returns true if the error means that the entries certainly didn't reach the leader.
+func notLeader(err error) bool {
+	return err == ErrNoLeader || errors.Is(err, errUnknownLeader) || status.Code(err) == codes.FailedPrecondition
+}
+
+func marshalEntries(entries []*raftpb.Entry) ([]etcdraftpb.Entry, error) {
+	marshalled := make([]etcdraftpb.Entry, 0, len(entries))
+	for _, e := range entries {
+		data, err := proto.Marshal(e)
+		if err != nil {
+			return nil, fmt.Errorf("marshalling entry: %w", err)
+		}
+		marshalled = append(marshalled, etcdraftpb.Entry{Data: data})
+	}
+	return marshalled, nil
+}
@@ -0,0 +1,118 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/etcd/raft"
+	raftpb "github.com/dimitarvdimitrov/sporkfs/raft/pb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// leaderClient replies to the forwarded proposals with the errors in order and with nil once they run out.
+type leaderClient struct {
+	raftpb.RaftClient
+
+	m        sync.Mutex
+	errs     []error
+	proposed int
+}
+
+func (c *leaderClient) Propose(context.Context, *raftpb.Proposal, ...grpc.CallOption) (*raftpb.Empty, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.proposed++
+	if len(c.errs) == 0 {
+		return &raftpb.Empty{}, nil
+	}
+	err := c.errs[0]
+	c.errs = c.errs[1:]
+	return nil, err
+}
+
+// newForwardingNode returns a follower of the leader with the client.
+func newForwardingNode(lead uint64, client raftpb.RaftClient) *node {
+	peers := NewPeerList(Config{AllPeers: []string{"a", "b"}, ThisPeer: "a"})
+	n := &node{
+		clients: map[string]raftpb.RaftClient{"b": client},
+		peers:   peers,
+		leader:  newLeader(),
+		done:    make(chan struct{}),
+	}
+	n.leader.set(lead)
+	return n
+}
+
+func TestDeliver(t *testing.T) {
+	testCases := map[string]struct {
+		lead uint64
+		errs []error
+		// proposed is how many times the entries were forwarded to the leader
+		proposed int
+		err      error
+	}{
+		"delivered":                 {lead: 2, proposed: 1},
+		"not the leader is retried": {lead: 2, errs: []error{status.Error(codes.FailedPrecondition, "not the leader")}, proposed: 2},
+		"unknown outcome isn't retried": {
+			lead:     2,
+			errs:     []error{status.Error(codes.Unavailable, "connection reset")},
+			proposed: 1,
+			err:      ErrTimedOut,
+		},
+		"timed out forwarding isn't retried": {
+			lead:     2,
+			errs:     []error{status.Error(codes.DeadlineExceeded, "deadline exceeded")},
+			proposed: 1,
+			err:      ErrTimedOut,
+		},
+		"no leader":      {lead: raft.None, err: ErrNoLeader},
+		"unknown leader": {lead: 3, err: ErrTimedOut},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			client := &leaderClient{errs: tc.errs}
+			n := newForwardingNode(tc.lead, client)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*bcastTime)
+			defer cancel()
+
+			err := n.deliver(ctx, []*raftpb.Entry{{Id: 1}})
+			require.True(t, errors.Is(err, tc.err), "%v", err)
+			require.Equal(t, tc.proposed, client.proposed)
+		})
+	}
+}
+
+func TestDeliverRetriedOnLeaderChange(t *testing.T) {
+	client := &leaderClient{}
+	n := newForwardingNode(raft.None, client)
+	go func() {
+		time.Sleep(bcastTime / 2)
+		n.leader.set(2)
+	}()
+
+	require.NoError(t, n.deliver(context.Background(), []*raftpb.Entry{{Id: 1}}))
+	require.Equal(t, 1, client.proposed)
+}
+
+func TestNotLeader(t *testing.T) {
+	testCases := map[error]bool{
+		ErrNoLeader:                              true,
+		fmt.Errorf("%w %d", errUnknownLeader, 3): true,
+		status.Error(codes.FailedPrecondition, "not the leader"): true,
+		status.Error(codes.Unavailable, "connection reset"):      false,
+		status.Error(codes.DeadlineExceeded, "timed out"):        false,
+		context.DeadlineExceeded:                                 false,
+	}
+
+	for err, expected := range testCases {
+		require.Equal(t, expected, notLeader(err), "%v", err)
+	}
+}
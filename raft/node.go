@@ -6,13 +6,16 @@ import (
 	"fmt"
 	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/coreos/etcd/raft"
 	etcdraftpb "github.com/coreos/etcd/raft/raftpb"
+	"github.com/dimitarvdimitrov/sporkfs/compression"
 	"github.com/dimitarvdimitrov/sporkfs/log"
 	raftpb "github.com/dimitarvdimitrov/sporkfs/raft/pb"
 	"github.com/dimitarvdimitrov/sporkfs/raft/storage"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
 	"github.com/golang/protobuf/proto"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
@@ -22,6 +25,11 @@ const (
 	bcastTime       = time.Millisecond * 10 // usual time it takes to send a message and receive a reply
 	heartbeatPeriod = bcastTime * 5
 	electionTimeout = heartbeatPeriod * 10
+
+	// proposalBatchWindow is how long proposals are collected before they are delivered to the leader together
+	proposalBatchWindow = bcastTime / 10
+	// maxProposalBatch is the most proposals which are delivered together
+	maxProposalBatch = 256
 )
 
 type node struct {
@@ -29,20 +37,30 @@ type node struct {
 	storage     storage.Storage
 	snapshotter *snapshotter
 
-	clients map[string]raftpb.RaftClient
-	peers   *Peers
+	clients     map[string]raftpb.RaftClient
+	compression compression.Config
+	peers       *Peers
 
 	t            *time.Ticker
 	commitC      chan<- UnactionedMessage
-	proposeC     <-chan *raftpb.Entry
+	proposeC     <-chan proposal
 	entryTracker *entryTracker
+	leader       *leader
+	applied      *appliedIndex
+	readIndexes  *readIndexes
+	// delivering is how many batches of proposals are being delivered to the leader; accessed atomically
+	delivering int32
+
+	// replayed is closed once all entries which were committed before the node started have been actioned
+	replayed    chan struct{}
+	replayUntil uint64
 
 	done chan struct{}
 	wg   *sync.WaitGroup
 }
 
-func newNode(peers *Peers, storeLocation string, stateSources ...StateSource) (*node, <-chan UnactionedMessage, chan<- *raftpb.Entry) {
-	s := storage.New(storeLocation, peers.confState())
+func newNode(peers *Peers, cfg Config, stateSources ...StateSource) (*node, <-chan UnactionedMessage, chan<- proposal) {
+	s := storage.New(cfg.DataDir, peers.confState(), cfg.Key)
 
 	config := &raft.Config{
 		ID:              uint64(peers.thisPeer) + 1,
@@ -51,13 +69,16 @@ func newNode(peers *Peers, storeLocation string, stateSources ...StateSource) (*
 		Applied:         0, // why bother with replaying since raft can do it for us?
 		Storage:         s,
 		MaxInflightMsgs: 256,
-		MaxSizePerMsg:   math.MaxUint64,
-		Logger:          log.Logger(),
+		// the leader steps down when it doesn't hear from a quorum for an election timeout, so a partitioned
+		// leader doesn't keep accepting proposals which can't be committed
+		CheckQuorum:   true,
+		MaxSizePerMsg: math.MaxUint64,
+		Logger:        log.Logger(),
 	}
 
 	clients := make(map[string]raftpb.RaftClient, peers.Len())
 	err := peers.ForEach(func(peerAddr string) error {
-		cc, err := grpc.Dial(peerAddr, grpc.WithInsecure())
+		cc, err := grpc.Dial(peerAddr, cfg.Credentials.DialOption())
 		clients[peerAddr] = raftpb.NewRaftClient(cc)
 		return err
 	})
@@ -68,20 +89,30 @@ func newNode(peers *Peers, storeLocation string, stateSources ...StateSource) (*
 	raftNode := raft.RestartNode(config)
 
 	commitC := make(chan UnactionedMessage)
-	proposeC := make(chan *raftpb.Entry)
+	proposeC := make(chan proposal)
 
 	node := &node{
 		raft:         raftNode,
 		storage:      s,
 		clients:      clients,
+		compression:  cfg.Compression,
 		peers:        peers,
 		t:            time.NewTicker(heartbeatPeriod),
 		commitC:      commitC,
 		proposeC:     proposeC,
 		entryTracker: newInFlight(),
+		leader:       newLeader(),
+		applied:      newAppliedIndex(),
+		readIndexes:  newReadIndexes(),
+		replayed:     make(chan struct{}),
+		replayUntil:  s.HardState().Commit,
 		done:         make(chan struct{}),
 		wg:           &sync.WaitGroup{},
 	}
+	if node.replayUntil == 0 {
+		close(node.replayed)
+	}
+
 	node.wg.Add(3)
 	go node.runRaft()
 	go node.tickRaft()
@@ -107,14 +138,21 @@ func (s *node) runRaft() {
 	for {
 		select {
 		case rd := <-s.raft.Ready():
+			if rd.SoftState != nil {
+				s.leader.set(rd.SoftState.Lead)
+			}
 			s.saveToStorage(rd.HardState, rd.Entries, rd.Snapshot)
 			s.send(rd.Messages)
+			s.readIndexes.resolve(rd.ReadStates)
 			if !raft.IsEmptySnap(rd.Snapshot) {
 				s.applySnapshot(rd.Snapshot)
 			}
 			for _, entry := range rd.CommittedEntries {
 				s.process(entry)
 			}
+			if n := len(rd.CommittedEntries); n > 0 {
+				s.maybeFinishReplay(rd.CommittedEntries[n-1].Index)
+			}
 			//s.maybeCreateSnapshot()
 			s.raft.Advance()
 		case <-s.done:
@@ -123,6 +161,9 @@ func (s *node) runRaft() {
 	}
 }
 
+// serveProposals delivers the proposals to the leader. While earlier batches are being delivered, proposals which
+// arrive within proposalBatchWindow of each other are delivered together and appended to the log with a single raft
+// proposal. Batches are delivered concurrently, so a batch doesn't wait for the ones before it to be committed.
 func (s *node) serveProposals() {
 	defer s.wg.Done()
 	for {
@@ -132,17 +173,25 @@ func (s *node) serveProposals() {
 				return
 			}
 
-			data, err := proto.Marshal(prop)
-			if err != nil {
-				log.Error("couldn't marshall proposed entry")
-				break
+			batch, open := s.collectProposals(prop)
+			select {
+			case <-s.done:
+				for _, p := range batch {
+					p.delivered <- ErrStopped
+				}
+				return
+			default:
 			}
-
-			// this is a local action, so shouldn't take long, but still short circuit if raft got stuck
-			ctx, _ := context.WithTimeout(context.Background(), time.Millisecond*10)
-			err = s.raft.Propose(ctx, data)
-			if err != nil {
-				log.Error("error proposing in raft", zap.Error(err))
+			// this goroutine is counted in wg until it returns, so close can't be done waiting for it yet
+			s.wg.Add(1)
+			atomic.AddInt32(&s.delivering, 1)
+			go func() {
+				defer s.wg.Done()
+				defer atomic.AddInt32(&s.delivering, -1)
+				s.deliverBatch(batch)
+			}()
+			if !open {
+				return
 			}
 		case <-s.done:
 			return
@@ -150,6 +199,100 @@ func (s *node) serveProposals() {
 	}
 }
 
+// collectProposals returns the proposal together with the ones which are already waiting. If other batches are
+// being delivered, it also waits for the ones which arrive within proposalBatchWindow after it; otherwise there is
+// nothing to wait for, so the proposal is delivered right away. It returns false if the proposals channel was closed.
+func (s *node) collectProposals(first proposal) (_ []proposal, open bool) {
+	batch := []proposal{first}
+	if atomic.LoadInt32(&s.delivering) == 0 {
+		for len(batch) < maxProposalBatch {
+			select {
+			case prop, ok := <-s.proposeC:
+				if !ok {
+					return batch, false
+				}
+				batch = append(batch, prop)
+			default:
+				return batch, true
+			}
+		}
+		return batch, true
+	}
+
+	window := time.NewTimer(proposalBatchWindow)
+	defer window.Stop()
+
+	for len(batch) < maxProposalBatch {
+		select {
+		case prop, ok := <-s.proposeC:
+			if !ok {
+				return batch, false
+			}
+			batch = append(batch, prop)
+		case <-window.C:
+			return batch, true
+		case <-s.done:
+			return batch, true
+		}
+	}
+	return batch, true
+}
+
+// deliverBatch delivers the entries of the proposals together and tells each of them the result. It gives up once
+// all proposals have timed out or were cancelled. The delivery is traced in the span of the first proposal.
+func (s *node) deliverBatch(batch []proposal) {
+	var deadline time.Time
+	entries := make([]*raftpb.Entry, len(batch))
+	for i, prop := range batch {
+		entries[i] = prop.entry
+		if d, ok := prop.ctx.Deadline(); ok && d.After(deadline) {
+			deadline = d
+		}
+		trace.AddEvent(prop.ctx, fmt.Sprintf("delivering with %d other proposals", len(batch)-1))
+	}
+	if deadline.IsZero() {
+		deadline = time.Now().Add(electionTimeout)
+	}
+	ctx, cancel := context.WithDeadline(trace.Detach(batch[0].ctx), deadline)
+	defer cancel()
+	go func() {
+		for _, prop := range batch {
+			select {
+			case <-prop.ctx.Done():
+			case <-ctx.Done():
+				return
+			}
+		}
+		cancel()
+	}()
+
+	err := s.deliver(ctx, entries)
+	if err != nil {
+		log.Error("[node] couldn't deliver entries to the leader", zap.Int("entries", len(entries)), zap.Error(err))
+	} else {
+		log.Debug("[node] delivered entries to the leader", zap.Int("entries", len(entries)))
+	}
+	for _, prop := range batch {
+		prop.delivered <- err
+	}
+}
+
+// maybeFinishReplay closes the replayed channel if lastCommitted is the last entry that was committed
+// before the node was started. It blocks until all entries up to it have been actioned.
+func (s *node) maybeFinishReplay(lastCommitted uint64) {
+	select {
+	case <-s.replayed:
+		return
+	default:
+	}
+
+	if lastCommitted >= s.replayUntil {
+		s.entryTracker.wait()
+		close(s.replayed)
+		log.Info("[node] finished replaying raft log", zap.Uint64("index", lastCommitted))
+	}
+}
+
 func (s *node) saveToStorage(state etcdraftpb.HardState, entries []etcdraftpb.Entry, snapshot etcdraftpb.Snapshot) {
 	if err := s.storage.Append(entries); err != nil {
 		log.Error("[raft node] appending entries", zap.Error(err))
@@ -186,7 +329,7 @@ func (s *node) send(messages []etcdraftpb.Message) {
 		wg.Add(1)
 		go func() {
 			ctx, _ := context.WithTimeout(context.Background(), bcastTime*10)
-			_, err := peer.Step(ctx, &m)
+			_, err := peer.Step(ctx, &m, s.compression.CallOptions(int64(m.Size()))...)
 			wg.Done()
 
 			if m.Type == etcdraftpb.MsgSnap {
@@ -268,10 +411,15 @@ func (s *node) process(e etcdraftpb.Entry) {
 
 		callback := s.entryTracker.watch(e.Index)
 		s.commitC <- UnactionedMessage{
-			Entry:  msg,
-			Action: callback,
+			Entry: msg,
+			Action: func() {
+				callback()
+				s.applied.set(e.Index)
+			},
 		}
+		return
 	}
+	s.applied.set(e.Index)
 }
 
 func (s *node) close() {
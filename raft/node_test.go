@@ -0,0 +1,59 @@
+package raft
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	raftpb "github.com/dimitarvdimitrov/sporkfs/raft/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectProposals(t *testing.T) {
+	testCases := map[string]struct {
+		delivering int32
+		// waiting is how many proposals are waiting to be collected; later are proposed after the first was collected
+		waiting, later int
+		closed         bool
+		batch          int
+	}{
+		"nothing else to deliver":          {batch: 1},
+		"waiting proposals":                {waiting: 2, batch: 3},
+		"later while delivering":           {delivering: 1, later: 2, batch: 3},
+		"later while nothing is delivered": {later: 2, batch: 1},
+		"up to the maximum":                {delivering: 1, waiting: maxProposalBatch, batch: maxProposalBatch},
+		"closed":                           {closed: true, batch: 1},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			proposeC := make(chan proposal)
+			n := &node{proposeC: proposeC, delivering: tc.delivering, done: make(chan struct{})}
+			defer close(n.done)
+			propose := func() {
+				select {
+				case proposeC <- proposal{ctx: context.Background(), entry: &raftpb.Entry{}, delivered: make(chan error, 1)}:
+				case <-n.done:
+				}
+			}
+			for i := 0; i < tc.waiting; i++ {
+				go propose()
+			}
+			if tc.closed {
+				close(proposeC)
+			}
+			// give the waiting ones time to block on the channel
+			time.Sleep(10 * time.Millisecond)
+			later := tc.later
+			go func() {
+				for i := 0; i < later; i++ {
+					propose()
+				}
+			}()
+
+			batch, open := n.collectProposals(proposal{})
+			require.Len(t, batch, tc.batch)
+			require.Equal(t, !tc.closed, open)
+		})
+	}
+}
@@ -178,7 +178,9 @@ type Delete struct {
 	// id of the file's parent
 	ParentId uint64 `protobuf:"varint,2,opt,name=parent_id,json=parentId,proto3" json:"parent_id,omitempty"`
 	// name of the file
-	Name                 string   `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Name string `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	// delete the directory with everything in it
+	Recursive            bool     `protobuf:"varint,4,opt,name=recursive,proto3" json:"recursive,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -230,6 +232,13 @@ func (m *Delete) GetName() string {
 	return ""
 }
 
+func (m *Delete) GetRecursive() bool {
+	if m != nil {
+		return m.Recursive
+	}
+	return false
+}
+
 type Add struct {
 	// id of file to add
 	Id uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -306,13 +315,484 @@ func (m *Add) GetIsHardLink() bool {
 	return false
 }
 
+type SetACL struct {
+	// id of the directory
+	Id uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// users mapped to their permissions (store.Permission); empty means the directory inherits the ACL of its parent
+	Acl                  map[string]uint32 `protobuf:"bytes,2,rep,name=acl,proto3" json:"acl,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *SetACL) Reset()         { *m = SetACL{} }
+func (m *SetACL) String() string { return proto.CompactTextString(m) }
+func (*SetACL) ProtoMessage()    {}
+func (*SetACL) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a245e8f22934927e, []int{4}
+}
+
+func (m *SetACL) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetACL.Unmarshal(m, b)
+}
+func (m *SetACL) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetACL.Marshal(b, m, deterministic)
+}
+func (m *SetACL) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetACL.Merge(m, src)
+}
+func (m *SetACL) XXX_Size() int {
+	return xxx_messageInfo_SetACL.Size(m)
+}
+func (m *SetACL) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetACL.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetACL proto.InternalMessageInfo
+
+func (m *SetACL) GetId() uint64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *SetACL) GetAcl() map[string]uint32 {
+	if m != nil {
+		return m.Acl
+	}
+	return nil
+}
+
+type SetQuota struct {
+	// id of the directory
+	Id uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// maximum bytes in the directory and its descendants; 0 means unlimited
+	MaxBytes int64 `protobuf:"varint,2,opt,name=max_bytes,json=maxBytes,proto3" json:"max_bytes,omitempty"`
+	// maximum files and directories in the directory and its descendants; 0 means unlimited
+	MaxInodes            int64    `protobuf:"varint,3,opt,name=max_inodes,json=maxInodes,proto3" json:"max_inodes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetQuota) Reset()         { *m = SetQuota{} }
+func (m *SetQuota) String() string { return proto.CompactTextString(m) }
+func (*SetQuota) ProtoMessage()    {}
+func (*SetQuota) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a245e8f22934927e, []int{5}
+}
+
+func (m *SetQuota) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetQuota.Unmarshal(m, b)
+}
+func (m *SetQuota) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetQuota.Marshal(b, m, deterministic)
+}
+func (m *SetQuota) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetQuota.Merge(m, src)
+}
+func (m *SetQuota) XXX_Size() int {
+	return xxx_messageInfo_SetQuota.Size(m)
+}
+func (m *SetQuota) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetQuota.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetQuota proto.InternalMessageInfo
+
+func (m *SetQuota) GetId() uint64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *SetQuota) GetMaxBytes() int64 {
+	if m != nil {
+		return m.MaxBytes
+	}
+	return 0
+}
+
+func (m *SetQuota) GetMaxInodes() int64 {
+	if m != nil {
+		return m.MaxInodes
+	}
+	return 0
+}
+
+type CreateSnapshot struct {
+	// id of the directory
+	Id   uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// when the snapshot was taken in unix nanoseconds
+	Created              int64    `protobuf:"varint,3,opt,name=created,proto3" json:"created,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CreateSnapshot) Reset()         { *m = CreateSnapshot{} }
+func (m *CreateSnapshot) String() string { return proto.CompactTextString(m) }
+func (*CreateSnapshot) ProtoMessage()    {}
+func (*CreateSnapshot) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a245e8f22934927e, []int{6}
+}
+
+func (m *CreateSnapshot) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateSnapshot.Unmarshal(m, b)
+}
+func (m *CreateSnapshot) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateSnapshot.Marshal(b, m, deterministic)
+}
+func (m *CreateSnapshot) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateSnapshot.Merge(m, src)
+}
+func (m *CreateSnapshot) XXX_Size() int {
+	return xxx_messageInfo_CreateSnapshot.Size(m)
+}
+func (m *CreateSnapshot) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateSnapshot.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateSnapshot proto.InternalMessageInfo
+
+func (m *CreateSnapshot) GetId() uint64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *CreateSnapshot) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *CreateSnapshot) GetCreated() int64 {
+	if m != nil {
+		return m.Created
+	}
+	return 0
+}
+
+type DeleteSnapshot struct {
+	// id of the directory
+	Id                   uint64   `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteSnapshot) Reset()         { *m = DeleteSnapshot{} }
+func (m *DeleteSnapshot) String() string { return proto.CompactTextString(m) }
+func (*DeleteSnapshot) ProtoMessage()    {}
+func (*DeleteSnapshot) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a245e8f22934927e, []int{7}
+}
+
+func (m *DeleteSnapshot) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteSnapshot.Unmarshal(m, b)
+}
+func (m *DeleteSnapshot) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteSnapshot.Marshal(b, m, deterministic)
+}
+func (m *DeleteSnapshot) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteSnapshot.Merge(m, src)
+}
+func (m *DeleteSnapshot) XXX_Size() int {
+	return xxx_messageInfo_DeleteSnapshot.Size(m)
+}
+func (m *DeleteSnapshot) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteSnapshot.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteSnapshot proto.InternalMessageInfo
+
+func (m *DeleteSnapshot) GetId() uint64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *DeleteSnapshot) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+type Copy struct {
+	// id of the new file
+	Id uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// id of the parent of the new file
+	ParentId uint64 `protobuf:"varint,2,opt,name=parent_id,json=parentId,proto3" json:"parent_id,omitempty"`
+	// name of the new file
+	Name string `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	// file mode (store.FileMode)
+	Mode uint32 `protobuf:"varint,4,opt,name=mode,proto3" json:"mode,omitempty"`
+	// the file and version whose content is copied
+	SourceId      uint64 `protobuf:"varint,5,opt,name=source_id,json=sourceId,proto3" json:"source_id,omitempty"`
+	SourceVersion uint64 `protobuf:"varint,6,opt,name=source_version,json=sourceVersion,proto3" json:"source_version,omitempty"`
+	// version of the new file
+	Version              uint64   `protobuf:"varint,7,opt,name=version,proto3" json:"version,omitempty"`
+	Size                 int64    `protobuf:"varint,8,opt,name=size,proto3" json:"size,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Copy) Reset()         { *m = Copy{} }
+func (m *Copy) String() string { return proto.CompactTextString(m) }
+func (*Copy) ProtoMessage()    {}
+func (*Copy) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a245e8f22934927e, []int{8}
+}
+
+func (m *Copy) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Copy.Unmarshal(m, b)
+}
+func (m *Copy) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Copy.Marshal(b, m, deterministic)
+}
+func (m *Copy) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Copy.Merge(m, src)
+}
+func (m *Copy) XXX_Size() int {
+	return xxx_messageInfo_Copy.Size(m)
+}
+func (m *Copy) XXX_DiscardUnknown() {
+	xxx_messageInfo_Copy.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Copy proto.InternalMessageInfo
+
+func (m *Copy) GetId() uint64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *Copy) GetParentId() uint64 {
+	if m != nil {
+		return m.ParentId
+	}
+	return 0
+}
+
+func (m *Copy) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Copy) GetMode() uint32 {
+	if m != nil {
+		return m.Mode
+	}
+	return 0
+}
+
+func (m *Copy) GetSourceId() uint64 {
+	if m != nil {
+		return m.SourceId
+	}
+	return 0
+}
+
+func (m *Copy) GetSourceVersion() uint64 {
+	if m != nil {
+		return m.SourceVersion
+	}
+	return 0
+}
+
+func (m *Copy) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *Copy) GetSize() int64 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
+// Batch holds operations which are applied atomically and in order
+type Batch struct {
+	Operations           []*Batch_Operation `protobuf:"bytes,1,rep,name=operations,proto3" json:"operations,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *Batch) Reset()         { *m = Batch{} }
+func (m *Batch) String() string { return proto.CompactTextString(m) }
+func (*Batch) ProtoMessage()    {}
+func (*Batch) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a245e8f22934927e, []int{9}
+}
+
+func (m *Batch) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Batch.Unmarshal(m, b)
+}
+func (m *Batch) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Batch.Marshal(b, m, deterministic)
+}
+func (m *Batch) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Batch.Merge(m, src)
+}
+func (m *Batch) XXX_Size() int {
+	return xxx_messageInfo_Batch.Size(m)
+}
+func (m *Batch) XXX_DiscardUnknown() {
+	xxx_messageInfo_Batch.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Batch proto.InternalMessageInfo
+
+func (m *Batch) GetOperations() []*Batch_Operation {
+	if m != nil {
+		return m.Operations
+	}
+	return nil
+}
+
+type Batch_Operation struct {
+	// Types that are valid to be assigned to Operation:
+	//	*Batch_Operation_Add
+	//	*Batch_Operation_Rename
+	//	*Batch_Operation_Delete
+	//	*Batch_Operation_Change
+	Operation            isBatch_Operation_Operation `protobuf_oneof:"operation"`
+	XXX_NoUnkeyedLiteral struct{}                    `json:"-"`
+	XXX_unrecognized     []byte                      `json:"-"`
+	XXX_sizecache        int32                       `json:"-"`
+}
+
+func (m *Batch_Operation) Reset()         { *m = Batch_Operation{} }
+func (m *Batch_Operation) String() string { return proto.CompactTextString(m) }
+func (*Batch_Operation) ProtoMessage()    {}
+func (*Batch_Operation) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a245e8f22934927e, []int{9, 0}
+}
+
+func (m *Batch_Operation) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Batch_Operation.Unmarshal(m, b)
+}
+func (m *Batch_Operation) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Batch_Operation.Marshal(b, m, deterministic)
+}
+func (m *Batch_Operation) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Batch_Operation.Merge(m, src)
+}
+func (m *Batch_Operation) XXX_Size() int {
+	return xxx_messageInfo_Batch_Operation.Size(m)
+}
+func (m *Batch_Operation) XXX_DiscardUnknown() {
+	xxx_messageInfo_Batch_Operation.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Batch_Operation proto.InternalMessageInfo
+
+type isBatch_Operation_Operation interface {
+	isBatch_Operation_Operation()
+}
+
+type Batch_Operation_Add struct {
+	Add *Add `protobuf:"bytes,1,opt,name=add,proto3,oneof"`
+}
+
+type Batch_Operation_Rename struct {
+	Rename *Rename `protobuf:"bytes,2,opt,name=rename,proto3,oneof"`
+}
+
+type Batch_Operation_Delete struct {
+	Delete *Delete `protobuf:"bytes,3,opt,name=delete,proto3,oneof"`
+}
+
+type Batch_Operation_Change struct {
+	Change *Change `protobuf:"bytes,4,opt,name=change,proto3,oneof"`
+}
+
+func (*Batch_Operation_Add) isBatch_Operation_Operation() {}
+
+func (*Batch_Operation_Rename) isBatch_Operation_Operation() {}
+
+func (*Batch_Operation_Delete) isBatch_Operation_Operation() {}
+
+func (*Batch_Operation_Change) isBatch_Operation_Operation() {}
+
+func (m *Batch_Operation) GetOperation() isBatch_Operation_Operation {
+	if m != nil {
+		return m.Operation
+	}
+	return nil
+}
+
+func (m *Batch_Operation) GetAdd() *Add {
+	if x, ok := m.GetOperation().(*Batch_Operation_Add); ok {
+		return x.Add
+	}
+	return nil
+}
+
+func (m *Batch_Operation) GetRename() *Rename {
+	if x, ok := m.GetOperation().(*Batch_Operation_Rename); ok {
+		return x.Rename
+	}
+	return nil
+}
+
+func (m *Batch_Operation) GetDelete() *Delete {
+	if x, ok := m.GetOperation().(*Batch_Operation_Delete); ok {
+		return x.Delete
+	}
+	return nil
+}
+
+func (m *Batch_Operation) GetChange() *Change {
+	if x, ok := m.GetOperation().(*Batch_Operation_Change); ok {
+		return x.Change
+	}
+	return nil
+}
+
+// XXX_OneofWrappers is for the internal use of the proto package.
+func (*Batch_Operation) XXX_OneofWrappers() []interface{} {
+	return []interface{}{
+		(*Batch_Operation_Add)(nil),
+		(*Batch_Operation_Rename)(nil),
+		(*Batch_Operation_Delete)(nil),
+		(*Batch_Operation_Change)(nil),
+	}
+}
+
 type Entry struct {
 	Id uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// when the entry was proposed in unix nanoseconds; the versions which it supersedes or deletes are retired then
+	Time int64 `protobuf:"varint,12,opt,name=time,proto3" json:"time,omitempty"`
 	// Types that are valid to be assigned to Message:
 	//	*Entry_Rename
 	//	*Entry_Delete
 	//	*Entry_Change
 	//	*Entry_Add
+	//	*Entry_SetAcl
+	//	*Entry_SetQuota
+	//	*Entry_CreateSnapshot
+	//	*Entry_DeleteSnapshot
+	//	*Entry_Copy
+	//	*Entry_Batch
 	Message              isEntry_Message `protobuf_oneof:"message"`
 	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
 	XXX_unrecognized     []byte          `json:"-"`
@@ -323,7 +803,7 @@ func (m *Entry) Reset()         { *m = Entry{} }
 func (m *Entry) String() string { return proto.CompactTextString(m) }
 func (*Entry) ProtoMessage()    {}
 func (*Entry) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a245e8f22934927e, []int{4}
+	return fileDescriptor_a245e8f22934927e, []int{10}
 }
 
 func (m *Entry) XXX_Unmarshal(b []byte) error {
@@ -351,6 +831,13 @@ func (m *Entry) GetId() uint64 {
 	return 0
 }
 
+func (m *Entry) GetTime() int64 {
+	if m != nil {
+		return m.Time
+	}
+	return 0
+}
+
 type isEntry_Message interface {
 	isEntry_Message()
 }
@@ -371,6 +858,30 @@ type Entry_Add struct {
 	Add *Add `protobuf:"bytes,5,opt,name=add,proto3,oneof"`
 }
 
+type Entry_SetAcl struct {
+	SetAcl *SetACL `protobuf:"bytes,6,opt,name=set_acl,json=setAcl,proto3,oneof"`
+}
+
+type Entry_SetQuota struct {
+	SetQuota *SetQuota `protobuf:"bytes,7,opt,name=set_quota,json=setQuota,proto3,oneof"`
+}
+
+type Entry_CreateSnapshot struct {
+	CreateSnapshot *CreateSnapshot `protobuf:"bytes,8,opt,name=create_snapshot,json=createSnapshot,proto3,oneof"`
+}
+
+type Entry_DeleteSnapshot struct {
+	DeleteSnapshot *DeleteSnapshot `protobuf:"bytes,9,opt,name=delete_snapshot,json=deleteSnapshot,proto3,oneof"`
+}
+
+type Entry_Copy struct {
+	Copy *Copy `protobuf:"bytes,10,opt,name=copy,proto3,oneof"`
+}
+
+type Entry_Batch struct {
+	Batch *Batch `protobuf:"bytes,11,opt,name=batch,proto3,oneof"`
+}
+
 func (*Entry_Rename) isEntry_Message() {}
 
 func (*Entry_Delete) isEntry_Message() {}
@@ -379,6 +890,18 @@ func (*Entry_Change) isEntry_Message() {}
 
 func (*Entry_Add) isEntry_Message() {}
 
+func (*Entry_SetAcl) isEntry_Message() {}
+
+func (*Entry_SetQuota) isEntry_Message() {}
+
+func (*Entry_CreateSnapshot) isEntry_Message() {}
+
+func (*Entry_DeleteSnapshot) isEntry_Message() {}
+
+func (*Entry_Copy) isEntry_Message() {}
+
+func (*Entry_Batch) isEntry_Message() {}
+
 func (m *Entry) GetMessage() isEntry_Message {
 	if m != nil {
 		return m.Message
@@ -414,6 +937,48 @@ func (m *Entry) GetAdd() *Add {
 	return nil
 }
 
+func (m *Entry) GetSetAcl() *SetACL {
+	if x, ok := m.GetMessage().(*Entry_SetAcl); ok {
+		return x.SetAcl
+	}
+	return nil
+}
+
+func (m *Entry) GetSetQuota() *SetQuota {
+	if x, ok := m.GetMessage().(*Entry_SetQuota); ok {
+		return x.SetQuota
+	}
+	return nil
+}
+
+func (m *Entry) GetCreateSnapshot() *CreateSnapshot {
+	if x, ok := m.GetMessage().(*Entry_CreateSnapshot); ok {
+		return x.CreateSnapshot
+	}
+	return nil
+}
+
+func (m *Entry) GetDeleteSnapshot() *DeleteSnapshot {
+	if x, ok := m.GetMessage().(*Entry_DeleteSnapshot); ok {
+		return x.DeleteSnapshot
+	}
+	return nil
+}
+
+func (m *Entry) GetCopy() *Copy {
+	if x, ok := m.GetMessage().(*Entry_Copy); ok {
+		return x.Copy
+	}
+	return nil
+}
+
+func (m *Entry) GetBatch() *Batch {
+	if x, ok := m.GetMessage().(*Entry_Batch); ok {
+		return x.Batch
+	}
+	return nil
+}
+
 // XXX_OneofWrappers is for the internal use of the proto package.
 func (*Entry) XXX_OneofWrappers() []interface{} {
 	return []interface{}{
@@ -421,6 +986,12 @@ func (*Entry) XXX_OneofWrappers() []interface{} {
 		(*Entry_Delete)(nil),
 		(*Entry_Change)(nil),
 		(*Entry_Add)(nil),
+		(*Entry_SetAcl)(nil),
+		(*Entry_SetQuota)(nil),
+		(*Entry_CreateSnapshot)(nil),
+		(*Entry_DeleteSnapshot)(nil),
+		(*Entry_Copy)(nil),
+		(*Entry_Batch)(nil),
 	}
 }
 
@@ -429,35 +1000,67 @@ func init() {
 	proto.RegisterType((*Rename)(nil), "Rename")
 	proto.RegisterType((*Delete)(nil), "Delete")
 	proto.RegisterType((*Add)(nil), "Add")
+	proto.RegisterType((*SetACL)(nil), "SetACL")
+	proto.RegisterMapType((map[string]uint32)(nil), "SetACL.AclEntry")
+	proto.RegisterType((*SetQuota)(nil), "SetQuota")
+	proto.RegisterType((*CreateSnapshot)(nil), "CreateSnapshot")
+	proto.RegisterType((*DeleteSnapshot)(nil), "DeleteSnapshot")
+	proto.RegisterType((*Copy)(nil), "Copy")
+	proto.RegisterType((*Batch)(nil), "Batch")
+	proto.RegisterType((*Batch_Operation)(nil), "Batch.Operation")
 	proto.RegisterType((*Entry)(nil), "Entry")
 }
 
 func init() { proto.RegisterFile("pb/entry.proto", fileDescriptor_a245e8f22934927e) }
 
 var fileDescriptor_a245e8f22934927e = []byte{
-	// 377 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x52, 0xc1, 0x8e, 0xd3, 0x40,
-	0x0c, 0xdd, 0x34, 0x69, 0x92, 0x3a, 0xec, 0x1e, 0xe6, 0x00, 0x59, 0x71, 0x29, 0x39, 0xed, 0xa9,
-	0x48, 0xcb, 0x17, 0xec, 0x02, 0x52, 0x2b, 0x21, 0x84, 0xe6, 0xc8, 0x25, 0x9a, 0x62, 0x77, 0x3b,
-	0x6a, 0x3a, 0x13, 0x4d, 0x22, 0x22, 0x10, 0xdf, 0xc1, 0x1f, 0xf0, 0x9f, 0xc8, 0x9e, 0x16, 0x21,
-	0x7a, 0xe4, 0x66, 0xfb, 0xbd, 0xf1, 0x7b, 0x63, 0x1b, 0x6e, 0xfa, 0xed, 0x6b, 0x72, 0x63, 0xf8,
-	0xb6, 0xea, 0x83, 0x1f, 0x7d, 0x33, 0x41, 0xfe, 0x76, 0x6f, 0xdc, 0x13, 0xa9, 0x1b, 0x98, 0x59,
-	0xac, 0x93, 0x65, 0x72, 0x97, 0xe9, 0x99, 0x45, 0x55, 0x43, 0xf1, 0x95, 0xc2, 0x60, 0xbd, 0xab,
-	0x67, 0x52, 0x3c, 0xa7, 0xea, 0x39, 0xe4, 0x7e, 0xb7, 0x1b, 0x68, 0xac, 0x53, 0x01, 0x4e, 0x99,
-	0x52, 0x90, 0x0d, 0xf6, 0x3b, 0xd5, 0xd9, 0x32, 0xb9, 0x4b, 0xb5, 0xc4, 0xea, 0x05, 0x14, 0x3d,
-	0x51, 0x68, 0x2d, 0xd6, 0xf3, 0x48, 0xe6, 0x74, 0x83, 0xcd, 0xcf, 0x04, 0x72, 0x4d, 0xce, 0x1c,
-	0x2f, 0x95, 0x1b, 0xb8, 0xf6, 0x1d, 0xb6, 0xbd, 0x09, 0xe4, 0x46, 0x7e, 0x19, 0xf5, 0x2b, 0xdf,
-	0xe1, 0x27, 0xa9, 0x6d, 0x84, 0xe3, 0x68, 0xfa, 0x8b, 0x13, 0xad, 0x54, 0x8e, 0xa6, 0x3f, 0x9c,
-	0x5b, 0x28, 0x99, 0xc3, 0x1a, 0xe2, 0x69, 0xa1, 0x0b, 0x47, 0xd3, 0x47, 0x96, 0xbc, 0x85, 0x92,
-	0x25, 0x04, 0x9a, 0x47, 0xc8, 0x77, 0xc8, 0x50, 0xb3, 0x81, 0xfc, 0x1d, 0x75, 0x34, 0x5e, 0xfa,
-	0x7a, 0x09, 0x8b, 0x7f, 0x3d, 0x95, 0xfd, 0x59, 0x4c, 0x41, 0x26, 0xdd, 0x52, 0xe9, 0x26, 0x71,
-	0xf3, 0x03, 0xd2, 0x07, 0xc4, 0xff, 0xee, 0xc3, 0xb5, 0xa3, 0xc7, 0xf8, 0x89, 0x6b, 0x2d, 0xb1,
-	0x5a, 0xc2, 0x33, 0x3b, 0xb4, 0x7b, 0x13, 0xb0, 0xed, 0xac, 0x3b, 0xc8, 0x2f, 0x4a, 0x0d, 0x76,
-	0x58, 0x9b, 0x80, 0x1f, 0xac, 0x3b, 0x34, 0xbf, 0x12, 0x98, 0xbf, 0xe7, 0x55, 0x5f, 0x18, 0x78,
-	0x05, 0x79, 0x90, 0xd1, 0x8b, 0x7a, 0x75, 0x5f, 0xac, 0xe2, 0x26, 0xd6, 0x57, 0xfa, 0x04, 0x30,
-	0x05, 0x65, 0x0a, 0x62, 0x84, 0x29, 0x71, 0x28, 0x4c, 0x89, 0x00, 0x53, 0xbe, 0xc8, 0xe9, 0x88,
-	0x2f, 0xa6, 0xc4, 0x4b, 0x62, 0x4a, 0x04, 0x54, 0x0d, 0xa9, 0xc1, 0xb8, 0xf9, 0xea, 0x3e, 0x5b,
-	0x3d, 0x20, 0xae, 0xaf, 0x34, 0x97, 0x1e, 0x17, 0x50, 0x1c, 0x69, 0x18, 0xcc, 0x13, 0x3d, 0x96,
-	0x9f, 0xf3, 0x60, 0x76, 0x63, 0xbf, 0xdd, 0xe6, 0x72, 0x93, 0x6f, 0x7e, 0x07, 0x00, 0x00, 0xff,
-	0xff, 0x7d, 0xcc, 0x5c, 0x27, 0xa5, 0x02, 0x00, 0x00,
+	// 764 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x55, 0xcd, 0x8e, 0xf3, 0x34,
+	0x14, 0x6d, 0x9a, 0xff, 0x9b, 0xaf, 0xfd, 0x3e, 0x59, 0x08, 0x32, 0x33, 0x80, 0x4a, 0x24, 0xa4,
+	0xae, 0x02, 0x2a, 0x08, 0xa1, 0xd9, 0xb5, 0x05, 0xa9, 0x95, 0x46, 0x03, 0x78, 0xa4, 0x59, 0xb0,
+	0xa9, 0xdc, 0xd8, 0x33, 0x8d, 0x26, 0x8d, 0x43, 0xec, 0x4e, 0xa7, 0x88, 0xe7, 0x60, 0xc3, 0xab,
+	0xf0, 0x0a, 0xac, 0x79, 0x1d, 0x64, 0x3b, 0xe9, 0x0f, 0x9d, 0x0d, 0x42, 0xb0, 0xb3, 0xcf, 0x39,
+	0xd7, 0xf7, 0xfa, 0xfa, 0x9e, 0x04, 0xfa, 0xd5, 0xf2, 0x33, 0x56, 0xca, 0x7a, 0x97, 0x56, 0x35,
+	0x97, 0x3c, 0xd9, 0x82, 0x37, 0x5d, 0x91, 0xf2, 0x91, 0xa1, 0x3e, 0x74, 0x73, 0x1a, 0x5b, 0x03,
+	0x6b, 0xe8, 0xe0, 0x6e, 0x4e, 0x51, 0x0c, 0xfe, 0x33, 0xab, 0x45, 0xce, 0xcb, 0xb8, 0xab, 0xc1,
+	0x76, 0x8b, 0xde, 0x07, 0x8f, 0x3f, 0x3c, 0x08, 0x26, 0x63, 0x5b, 0x13, 0xcd, 0x0e, 0x21, 0x70,
+	0x44, 0xfe, 0x33, 0x8b, 0x9d, 0x81, 0x35, 0xb4, 0xb1, 0x5e, 0xa3, 0x0f, 0xc0, 0xaf, 0x18, 0xab,
+	0x17, 0x39, 0x8d, 0x5d, 0x23, 0x56, 0xdb, 0x39, 0x4d, 0x7e, 0xb5, 0xc0, 0xc3, 0xac, 0x24, 0xeb,
+	0xf3, 0xcc, 0x09, 0xf4, 0x78, 0x41, 0x17, 0x15, 0xa9, 0x59, 0x29, 0x55, 0xa4, 0xc9, 0x1f, 0xf1,
+	0x82, 0x7e, 0xaf, 0xb1, 0xb9, 0xd6, 0x94, 0x6c, 0x7b, 0xa4, 0x31, 0xa5, 0x44, 0x25, 0xdb, 0xee,
+	0x35, 0x17, 0x10, 0x28, 0x8d, 0xca, 0xa1, 0x6b, 0x0a, 0xb1, 0x5f, 0xb2, 0xed, 0xad, 0x4a, 0x79,
+	0x01, 0x81, 0x4a, 0xa1, 0x29, 0xd7, 0x50, 0xbc, 0xa0, 0x8a, 0x4a, 0x1e, 0xc1, 0xfb, 0x86, 0x15,
+	0x4c, 0x9e, 0xd7, 0x75, 0x05, 0xe1, 0xdf, 0x6b, 0x0a, 0xaa, 0x36, 0x19, 0x02, 0x47, 0x9f, 0x66,
+	0xeb, 0xd3, 0xf4, 0x1a, 0x7d, 0x08, 0x61, 0xcd, 0xb2, 0x4d, 0x2d, 0xf2, 0x67, 0x53, 0x41, 0x80,
+	0x0f, 0x40, 0xf2, 0x0b, 0xd8, 0x63, 0x4a, 0xff, 0x7d, 0x16, 0x04, 0xce, 0x9a, 0x53, 0x93, 0xa0,
+	0x87, 0xf5, 0x1a, 0x0d, 0xe0, 0x4d, 0x2e, 0x16, 0x2b, 0x52, 0xd3, 0x45, 0x91, 0x97, 0x4f, 0xfa,
+	0x8e, 0x01, 0x86, 0x5c, 0xcc, 0x48, 0x4d, 0x6f, 0xf2, 0xf2, 0x29, 0x91, 0xe0, 0xdd, 0x31, 0x39,
+	0x9e, 0xde, 0xbc, 0xd2, 0x7e, 0x9b, 0x64, 0x45, 0xdc, 0x1d, 0xd8, 0xc3, 0x68, 0xf4, 0x2e, 0x35,
+	0xaa, 0x74, 0x9c, 0x15, 0xdf, 0xaa, 0xb9, 0xc1, 0x8a, 0xbc, 0xfc, 0x0a, 0x82, 0x16, 0x40, 0xef,
+	0xc0, 0x7e, 0x62, 0x3b, 0x7d, 0x40, 0x88, 0xd5, 0x12, 0xbd, 0x07, 0xee, 0x33, 0x29, 0x36, 0x4c,
+	0x97, 0xdf, 0xc3, 0x66, 0x73, 0xdd, 0xfd, 0xda, 0x4a, 0xee, 0x21, 0xb8, 0x63, 0xf2, 0x87, 0x0d,
+	0x97, 0xe4, 0xb5, 0x8b, 0xaf, 0xc9, 0xcb, 0x62, 0xb9, 0x93, 0x4c, 0xe8, 0x48, 0x1b, 0x07, 0x6b,
+	0xf2, 0x32, 0x51, 0x7b, 0xf4, 0x11, 0x80, 0x22, 0xf3, 0x92, 0x53, 0x26, 0xf4, 0xf5, 0x6d, 0xac,
+	0xe4, 0x73, 0x0d, 0x24, 0xb7, 0xd0, 0x9f, 0xd6, 0x8c, 0x48, 0x76, 0x57, 0x92, 0x4a, 0xac, 0xb8,
+	0x3c, 0x3b, 0xbd, 0xed, 0x5c, 0xf7, 0xa8, 0x73, 0x31, 0xf8, 0x99, 0x8e, 0xa2, 0xcd, 0x89, 0xed,
+	0x36, 0xf9, 0x12, 0xfa, 0x66, 0x08, 0xfe, 0xc9, 0x79, 0xc9, 0x1f, 0x16, 0x38, 0x53, 0x5e, 0xed,
+	0xfe, 0x9b, 0x37, 0xbd, 0x82, 0x50, 0xf0, 0x4d, 0x9d, 0xb1, 0x83, 0x99, 0x02, 0x03, 0xcc, 0x29,
+	0xfa, 0x14, 0xfa, 0x0d, 0xd9, 0x9a, 0xd6, 0xd3, 0x8a, 0x9e, 0x41, 0xef, 0x0d, 0x78, 0x6c, 0x6a,
+	0xff, 0xd4, 0xd4, 0xad, 0x79, 0x83, 0x83, 0x79, 0x93, 0x3f, 0x2d, 0x70, 0x27, 0x44, 0x66, 0x2b,
+	0xf4, 0x39, 0x00, 0xaf, 0x58, 0x4d, 0x64, 0xce, 0x4b, 0x11, 0x5b, 0xcd, 0x68, 0x68, 0x2e, 0xfd,
+	0xae, 0x25, 0xf0, 0x91, 0xe6, 0xf2, 0x37, 0x0b, 0xc2, 0x3d, 0x83, 0x62, 0xb0, 0x09, 0x35, 0x1d,
+	0x89, 0x46, 0x4e, 0x3a, 0xa6, 0x74, 0xd6, 0xc1, 0x0a, 0x42, 0x9f, 0x80, 0x57, 0xb3, 0x7d, 0x27,
+	0xa3, 0x91, 0x9f, 0x9a, 0xaf, 0xc2, 0xac, 0x83, 0x1b, 0x42, 0x49, 0xa8, 0x7e, 0x8c, 0xd8, 0x6e,
+	0x24, 0xe6, 0x6d, 0x94, 0xc4, 0x10, 0x4a, 0x92, 0xe9, 0xcf, 0x58, 0xec, 0x34, 0x12, 0xf3, 0x55,
+	0x53, 0x12, 0x43, 0x4c, 0x22, 0x08, 0xf7, 0xe5, 0x25, 0xbf, 0xdb, 0xe0, 0x9a, 0xe9, 0x7d, 0xe5,
+	0x5d, 0x65, 0xbe, 0x66, 0xf1, 0x1b, 0xd3, 0x07, 0xb5, 0xfe, 0xdf, 0x6a, 0x6c, 0xdb, 0xe4, 0x9e,
+	0xb7, 0x29, 0x01, 0x5f, 0x30, 0xb9, 0x50, 0xc6, 0xf4, 0x9a, 0x68, 0x63, 0x4c, 0x15, 0x2d, 0x98,
+	0x1c, 0x67, 0x05, 0x1a, 0x42, 0xa8, 0x34, 0x3f, 0x29, 0x77, 0xe9, 0xe7, 0x8d, 0x46, 0x61, 0xda,
+	0xda, 0x6d, 0xd6, 0xc1, 0x81, 0x68, 0xd6, 0xe8, 0x1a, 0xde, 0x9a, 0x49, 0x5f, 0x88, 0x66, 0xbe,
+	0xf5, 0xbb, 0x47, 0xa3, 0xb7, 0xe9, 0xa9, 0x8d, 0x66, 0x1d, 0xdc, 0xcf, 0x4e, 0x10, 0x15, 0x6b,
+	0x2e, 0x74, 0x88, 0x0d, 0x9b, 0xd8, 0x53, 0xcb, 0xa8, 0x58, 0x7a, 0x82, 0xa0, 0x2b, 0x70, 0x32,
+	0x5e, 0xed, 0x62, 0xd0, 0x01, 0x6e, 0xaa, 0xcc, 0x32, 0xeb, 0x60, 0x0d, 0xa2, 0x8f, 0xc1, 0x5d,
+	0xaa, 0x81, 0x8a, 0x23, 0xcd, 0x7a, 0x66, 0xbc, 0x66, 0x1d, 0x6c, 0xe0, 0x49, 0x08, 0xfe, 0x9a,
+	0x09, 0x41, 0x1e, 0xd9, 0x24, 0xf8, 0xd1, 0xab, 0xc9, 0x83, 0xac, 0x96, 0x4b, 0x4f, 0xff, 0xc6,
+	0xbe, 0xf8, 0x6b, 0x00, 0x53, 0x41, 0x57, 0x56, 0xd8, 0x06, 0x00, 0x00,
 }
@@ -56,21 +56,66 @@ func (m *Empty) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_Empty proto.InternalMessageInfo
 
+type Proposal struct {
+	// appended to the log in this order, each as its own raft entry
+	Entries              []*Entry `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Proposal) Reset()         { *m = Proposal{} }
+func (m *Proposal) String() string { return proto.CompactTextString(m) }
+func (*Proposal) ProtoMessage()    {}
+func (*Proposal) Descriptor() ([]byte, []int) {
+	return fileDescriptor_72e83c28469e72c9, []int{1}
+}
+
+func (m *Proposal) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Proposal.Unmarshal(m, b)
+}
+func (m *Proposal) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Proposal.Marshal(b, m, deterministic)
+}
+func (m *Proposal) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Proposal.Merge(m, src)
+}
+func (m *Proposal) XXX_Size() int {
+	return xxx_messageInfo_Proposal.Size(m)
+}
+func (m *Proposal) XXX_DiscardUnknown() {
+	xxx_messageInfo_Proposal.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Proposal proto.InternalMessageInfo
+
+func (m *Proposal) GetEntries() []*Entry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Empty)(nil), "Empty")
+	proto.RegisterType((*Proposal)(nil), "Proposal")
 }
 
 func init() { proto.RegisterFile("pb/raft.proto", fileDescriptor_72e83c28469e72c9) }
 
 var fileDescriptor_72e83c28469e72c9 = []byte{
-	// 105 bytes of a gzipped FileDescriptorProto
+	// 162 bytes of a gzipped FileDescriptorProto
 	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x2d, 0x48, 0xd2, 0x2f,
 	0x4a, 0x4c, 0x2b, 0xd1, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x97, 0x12, 0x4b, 0x2d, 0x49, 0x4e, 0x01,
-	0x0b, 0xa0, 0x88, 0x2b, 0xb1, 0x73, 0xb1, 0xba, 0xe6, 0x16, 0x94, 0x54, 0x1a, 0x69, 0x72, 0xb1,
-	0x04, 0x25, 0xa6, 0x95, 0x08, 0x29, 0x72, 0xb1, 0x04, 0x97, 0xa4, 0x16, 0x08, 0xf1, 0xeb, 0x41,
-	0x14, 0xeb, 0xf9, 0xa6, 0x16, 0x17, 0x27, 0xa6, 0xa7, 0x4a, 0xb1, 0xe9, 0x81, 0x15, 0x2a, 0x31,
-	0x38, 0x71, 0x44, 0xb1, 0x41, 0xe4, 0x92, 0xd8, 0xc0, 0x86, 0x18, 0x03, 0x02, 0x00, 0x00, 0xff,
-	0xff, 0xa6, 0xc8, 0xc4, 0xbc, 0x6d, 0x00, 0x00, 0x00,
+	0x0b, 0xa0, 0x8a, 0xf3, 0x15, 0x24, 0xe9, 0xa7, 0xe6, 0x95, 0x14, 0x55, 0x42, 0xf8, 0x4a, 0xec,
+	0x5c, 0xac, 0xae, 0xb9, 0x05, 0x25, 0x95, 0x4a, 0x3a, 0x5c, 0x1c, 0x01, 0x45, 0xf9, 0x05, 0xf9,
+	0xc5, 0x89, 0x39, 0x42, 0x0a, 0x5c, 0xec, 0x20, 0x35, 0x99, 0xa9, 0xc5, 0x12, 0x8c, 0x0a, 0xcc,
+	0x1a, 0xdc, 0x46, 0x6c, 0x7a, 0xae, 0x20, 0x3d, 0x41, 0x30, 0x61, 0x23, 0x4f, 0x2e, 0x96, 0xa0,
+	0xc4, 0xb4, 0x12, 0x21, 0x45, 0x2e, 0x96, 0xe0, 0x92, 0xd4, 0x02, 0x21, 0x7e, 0x3d, 0x88, 0x55,
+	0x7a, 0xbe, 0xa9, 0xc5, 0xc5, 0x89, 0xe9, 0xa9, 0x52, 0x6c, 0x7a, 0x10, 0x63, 0x19, 0x84, 0xe4,
+	0xb8, 0xd8, 0x21, 0x06, 0xa7, 0x0a, 0x71, 0xea, 0xc1, 0xac, 0x40, 0xc8, 0x3b, 0x71, 0x44, 0xb1,
+	0x41, 0xf4, 0x26, 0xb1, 0x81, 0x9d, 0x64, 0x0c, 0x18, 0x00, 0x9a, 0xd4, 0x4f, 0x4a, 0xcb, 0x00,
+	0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -86,6 +131,8 @@ const _ = grpc.SupportPackageIsVersion4
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type RaftClient interface {
 	Step(ctx context.Context, in *raftpb.Message, opts ...grpc.CallOption) (*Empty, error)
+	// Propose proposes the entries if this node is the leader. Followers forward proposals through it.
+	Propose(ctx context.Context, in *Proposal, opts ...grpc.CallOption) (*Empty, error)
 }
 
 type raftClient struct {
@@ -105,9 +152,20 @@ func (c *raftClient) Step(ctx context.Context, in *raftpb.Message, opts ...grpc.
 	return out, nil
 }
 
+func (c *raftClient) Propose(ctx context.Context, in *Proposal, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/Raft/Propose", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // RaftServer is the server API for Raft service.
 type RaftServer interface {
 	Step(context.Context, *raftpb.Message) (*Empty, error)
+	// Propose proposes the entries if this node is the leader. Followers forward proposals through it.
+	Propose(context.Context, *Proposal) (*Empty, error)
 }
 
 // UnimplementedRaftServer can be embedded to have forward compatible implementations.
@@ -117,6 +175,9 @@ type UnimplementedRaftServer struct {
 func (*UnimplementedRaftServer) Step(ctx context.Context, req *raftpb.Message) (*Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Step not implemented")
 }
+func (*UnimplementedRaftServer) Propose(ctx context.Context, req *Proposal) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Propose not implemented")
+}
 
 func RegisterRaftServer(s *grpc.Server, srv RaftServer) {
 	s.RegisterService(&_Raft_serviceDesc, srv)
@@ -140,6 +201,24 @@ func _Raft_Step_Handler(srv interface{}, ctx context.Context, dec func(interface
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Raft_Propose_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Proposal)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftServer).Propose(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Raft/Propose",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftServer).Propose(ctx, req.(*Proposal))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Raft_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "Raft",
 	HandlerType: (*RaftServer)(nil),
@@ -148,6 +227,10 @@ var _Raft_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Step",
 			Handler:    _Raft_Step_Handler,
 		},
+		{
+			MethodName: "Propose",
+			Handler:    _Raft_Propose_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "pb/raft.proto",
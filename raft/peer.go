@@ -36,6 +36,14 @@ func (p Peers) Len() int {
 	return len(p.p)
 }
 
+// Replicas returns on how many peers each file is stored.
+func (p Peers) Replicas() int {
+	if p.redundancy > len(p.p) {
+		return len(p.p)
+	}
+	return p.redundancy
+}
+
 // ForEach will call the function for every available peer. If the
 // function returns a non-nil error, the iterations will be stopped immediately
 // and the error will be returned directly.
@@ -74,6 +82,12 @@ func (p Peers) peersWithFile(id uint64) []int {
 	return peerIndices
 }
 
+// IsPrimary returns true if this peer is the first of the peers which hold the file. It's used to pick
+// a single peer which acts on the file.
+func (p Peers) IsPrimary(id uint64) bool {
+	return p.peersWithFile(id)[0] == p.thisPeer
+}
+
 func (p Peers) IsLocalFile(id uint64) bool {
 	peersWithFile := p.peersWithFile(id)
 	for _, peerIndex := range peersWithFile {
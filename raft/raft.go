@@ -2,23 +2,36 @@ package raft
 
 import (
 	"context"
+	"time"
 
 	etcdraftpb "github.com/coreos/etcd/raft/raftpb"
 	"github.com/dimitarvdimitrov/sporkfs/log"
 	raftpb "github.com/dimitarvdimitrov/sporkfs/raft/pb"
 	"github.com/dimitarvdimitrov/sporkfs/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-// Committer tries to commit the entry to raft. It returns true or false if the entry get completed in a timely
-// manner. After proposing a change, which has been approved by raft, you need to invoke the callback
+// Committer tries to commit the entry to raft. It returns an error if the entry didn't get committed in a timely
+// manner: ErrNoLeader if there was no leader to deliver it to and ErrTimedOut if it wasn't committed in time.
+// After proposing a change, which has been approved by raft, you need to invoke the callback
 // function returned by the Committer's method. Even if you fail to action the result, you need to invoke the callback;
 // otherwise bad things will happen. If the change wasn't approved you don't need to call the callback,
 // and calling it will be a noop.
+// Entries which supersede or delete versions carry the time when they did it, so that all peers retire the versions
+// at the same time.
 type Committer interface {
-	Add(id, parentId uint64, name string, mode store.FileMode) (bool, func())
-	Change(id, version, offset uint64, size int64) (bool, func())
-	Rename(id, oldParentId, newParentId uint64, oldName, newName string) (bool, func())
-	Delete(id, parentId uint64, newName string) (bool, func())
+	Add(ctx context.Context, id, parentId uint64, name string, mode store.FileMode) (func(), error)
+	Change(ctx context.Context, id, version, offset uint64, size int64, changed time.Time) (func(), error)
+	Rename(ctx context.Context, id, oldParentId, newParentId uint64, oldName, newName string, renamed time.Time) (func(), error)
+	Delete(ctx context.Context, id, parentId uint64, newName string, deleted time.Time) (func(), error)
+	DeleteAll(ctx context.Context, id, parentId uint64, name string, deleted time.Time) (func(), error)
+	SetACL(ctx context.Context, id uint64, acl store.ACL) (func(), error)
+	SetQuota(ctx context.Context, id uint64, maxBytes, maxInodes int64) (func(), error)
+	CreateSnapshot(ctx context.Context, id uint64, name string, created time.Time) (func(), error)
+	DeleteSnapshot(ctx context.Context, id uint64, name string) (func(), error)
+	Copy(ctx context.Context, id, parentId uint64, name string, mode store.FileMode, srcId, srcVersion, version uint64, size int64) (func(), error)
+	Batch(ctx context.Context, ops []*raftpb.Batch_Operation, applied time.Time) (func(), error)
 }
 
 type Raft struct {
@@ -28,7 +41,7 @@ type Raft struct {
 
 func New(cfg Config, states ...StateSource) (*Raft, <-chan UnactionedMessage, *Peers) {
 	peers := NewPeerList(cfg)
-	n, commits, proposals := newNode(peers, cfg.DataDir, states...)
+	n, commits, proposals := newNode(peers, cfg, states...)
 	a, syncC := newApplier(commits, proposals)
 
 	return &Raft{
@@ -37,26 +50,81 @@ func New(cfg Config, states ...StateSource) (*Raft, <-chan UnactionedMessage, *P
 	}, syncC, peers
 }
 
-func (r *Raft) Add(id, parentId uint64, name string, mode store.FileMode) (bool, func()) {
-	return r.a.ProposeAdd(id, parentId, name, mode)
+func (r *Raft) Add(ctx context.Context, id, parentId uint64, name string, mode store.FileMode) (func(), error) {
+	return r.a.ProposeAdd(ctx, id, parentId, name, mode)
 }
 
-func (r *Raft) Change(id, version, offset uint64, size int64) (bool, func()) {
-	return r.a.ProposeChange(id, version, offset, r.n.peers.thisPeerRaftId(), size)
+func (r *Raft) Change(ctx context.Context, id, version, offset uint64, size int64, changed time.Time) (func(), error) {
+	return r.a.ProposeChange(ctx, id, version, offset, r.n.peers.thisPeerRaftId(), size, changed)
 }
 
-func (r *Raft) Rename(id, oldParentId, newParentId uint64, oldName, newName string) (bool, func()) {
-	return r.a.ProposeRename(id, oldParentId, newParentId, oldName, newName)
+func (r *Raft) Rename(ctx context.Context, id, oldParentId, newParentId uint64, oldName, newName string, renamed time.Time) (func(), error) {
+	return r.a.ProposeRename(ctx, id, oldParentId, newParentId, oldName, newName, renamed)
 }
 
-func (r *Raft) Delete(id, parentId uint64, name string) (bool, func()) {
-	return r.a.ProposeDelete(id, parentId, name)
+func (r *Raft) Delete(ctx context.Context, id, parentId uint64, name string, deleted time.Time) (func(), error) {
+	return r.a.ProposeDelete(ctx, id, parentId, name, deleted)
+}
+
+func (r *Raft) DeleteAll(ctx context.Context, id, parentId uint64, name string, deleted time.Time) (func(), error) {
+	return r.a.ProposeDeleteAll(ctx, id, parentId, name, deleted)
+}
+
+func (r *Raft) SetACL(ctx context.Context, id uint64, acl store.ACL) (func(), error) {
+	return r.a.ProposeSetACL(ctx, id, acl)
+}
+
+func (r *Raft) SetQuota(ctx context.Context, id uint64, maxBytes, maxInodes int64) (func(), error) {
+	return r.a.ProposeSetQuota(ctx, id, maxBytes, maxInodes)
+}
+
+func (r *Raft) CreateSnapshot(ctx context.Context, id uint64, name string, created time.Time) (func(), error) {
+	return r.a.ProposeCreateSnapshot(ctx, id, name, created)
+}
+
+func (r *Raft) DeleteSnapshot(ctx context.Context, id uint64, name string) (func(), error) {
+	return r.a.ProposeDeleteSnapshot(ctx, id, name)
+}
+
+func (r *Raft) Copy(ctx context.Context, id, parentId uint64, name string, mode store.FileMode, srcId, srcVersion, version uint64, size int64) (func(), error) {
+	return r.a.ProposeCopy(ctx, id, parentId, name, mode, srcId, srcVersion, version, size)
+}
+
+// Batch proposes the operations as a single entry. This peer is set as the peer which has the new versions of changes.
+func (r *Raft) Batch(ctx context.Context, ops []*raftpb.Batch_Operation, applied time.Time) (func(), error) {
+	for _, op := range ops {
+		if c := op.GetChange(); c != nil {
+			c.PeerId = r.n.peers.thisPeerRaftId()
+		}
+	}
+	return r.a.ProposeBatch(ctx, ops, applied)
+}
+
+// Replayed returns a channel which is closed once all entries, which were committed before this node
+// started, have been actioned.
+func (r *Raft) Replayed() <-chan struct{} {
+	return r.n.replayed
+}
+
+// Linearize blocks until this node has actioned all entries which were committed in the cluster when it was
+// called, so that reads after it observe all writes which completed before it.
+func (r *Raft) Linearize(ctx context.Context) error {
+	return r.n.linearize(ctx)
 }
 
 func (r *Raft) Step(ctx context.Context, e *etcdraftpb.Message) (*raftpb.Empty, error) {
 	return &raftpb.Empty{}, r.n.raft.Step(ctx, *e)
 }
 
+// Propose proposes the entries if this node is the leader; other nodes forward their proposals to it.
+func (r *Raft) Propose(ctx context.Context, p *raftpb.Proposal) (*raftpb.Empty, error) {
+	entries, err := marshalEntries(p.Entries)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &raftpb.Empty{}, r.n.proposeLocally(ctx, entries)
+}
+
 func (r *Raft) Shutdown() {
 	log.Info("stopping raft...")
 	r.n.close()
@@ -0,0 +1,156 @@
+package raft
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/raft"
+	"github.com/dimitarvdimitrov/sporkfs/log"
+	"go.uber.org/zap"
+)
+
+// readIndexRetry is how often a read index request is repeated if there's no reply. The leader drops them
+// until it has committed an entry in its term and followers drop them while there's no leader.
+const readIndexRetry = heartbeatPeriod * 2
+
+// appliedIndex is the index of the last entry which has been actioned.
+type appliedIndex struct {
+	l       sync.Mutex
+	index   uint64
+	changed chan struct{} // closed and replaced every time the index changes
+}
+
+func newAppliedIndex() *appliedIndex {
+	return &appliedIndex{changed: make(chan struct{})}
+}
+
+// set moves the index forward; it never goes back.
+func (a *appliedIndex) set(index uint64) {
+	a.l.Lock()
+	defer a.l.Unlock()
+
+	if index <= a.index {
+		return
+	}
+	a.index = index
+	close(a.changed)
+	a.changed = make(chan struct{})
+}
+
+// wait blocks until the index is at least index.
+func (a *appliedIndex) wait(ctx context.Context, index uint64) error {
+	for {
+		a.l.Lock()
+		applied, changed := a.index, a.changed
+		a.l.Unlock()
+
+		if applied >= index {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// readIndexes keeps track of the read index requests which this node is waiting on.
+type readIndexes struct {
+	l       sync.Mutex
+	pending map[uint64]chan uint64
+}
+
+func newReadIndexes() *readIndexes {
+	return &readIndexes{pending: make(map[uint64]chan uint64)}
+}
+
+func (r *readIndexes) register() (id uint64, indexC <-chan uint64) {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	for {
+		id = rand.Uint64()
+		if _, ok := r.pending[id]; !ok {
+			break
+		}
+	}
+	c := make(chan uint64, 1)
+	r.pending[id] = c
+	return id, c
+}
+
+func (r *readIndexes) unregister(id uint64) {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	delete(r.pending, id)
+}
+
+// resolve passes the indexes from the read states to the requests waiting on them.
+func (r *readIndexes) resolve(states []raft.ReadState) {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	for _, s := range states {
+		if len(s.RequestCtx) != 8 {
+			continue
+		}
+		c, ok := r.pending[binary.BigEndian.Uint64(s.RequestCtx)]
+		if !ok {
+			continue
+		}
+		select {
+		case c <- s.Index:
+		default: // a reply to a repeated request was already passed on
+		}
+	}
+}
+
+// linearize blocks until this node has actioned all entries which were committed in the cluster when it was called.
+// Reads which follow it observe every write which completed before it was called on any node.
+func (s *node) linearize(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, electionTimeout)
+	defer cancel()
+
+	id, indexC := s.readIndexes.register()
+	defer s.readIndexes.unregister(id)
+
+	rctx := make([]byte, 8)
+	binary.BigEndian.PutUint64(rctx, id)
+
+	if err := s.raft.ReadIndex(ctx, rctx); err != nil {
+		return fmt.Errorf("requesting read index: %w", err)
+	}
+	retry := time.NewTicker(readIndexRetry)
+	defer retry.Stop()
+
+	var index uint64
+	for waiting := true; waiting; {
+		select {
+		case index = <-indexC:
+			waiting = false
+		case <-retry.C:
+			if err := s.raft.ReadIndex(ctx, rctx); err != nil {
+				return fmt.Errorf("requesting read index: %w", err)
+			}
+		case <-s.done:
+			return ErrStopped
+		case <-ctx.Done():
+			if lead, _ := s.leader.get(); lead == raft.None {
+				return ErrNoLeader
+			}
+			return fmt.Errorf("%w: waiting for read index", ErrTimedOut)
+		}
+	}
+
+	log.Debug("[node] waiting for read index to be applied", zap.Uint64("index", index))
+	if err := s.applied.wait(ctx, index); err != nil {
+		return fmt.Errorf("%w: waiting for index %d to be applied", ErrTimedOut, index)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/etcd/raft"
+	"github.com/stretchr/testify/require"
+)
+
+// readIndexNode replies to the read index requests from the reply-th on with the index, like the leader does once
+// it has committed an entry in its term. It never replies if reply is 0.
+type readIndexNode struct {
+	raft.Node
+	indexes *readIndexes
+	reply   int
+	index   uint64
+
+	m         sync.Mutex
+	requested int
+}
+
+func (n *readIndexNode) ReadIndex(_ context.Context, rctx []byte) error {
+	n.m.Lock()
+	defer n.m.Unlock()
+	n.requested++
+	if n.reply != 0 && n.requested >= n.reply {
+		n.indexes.resolve([]raft.ReadState{{Index: n.index, RequestCtx: rctx}})
+	}
+	return nil
+}
+
+func TestLinearize(t *testing.T) {
+	testCases := map[string]struct {
+		lead       uint64
+		reply      int
+		applied    uint64
+		applyLater bool
+		stopped    bool
+		err        error
+		// requested is how many read index requests are sent at least
+		requested int
+	}{
+		"applied already":            {lead: 1, reply: 1, applied: 5, requested: 1},
+		"dropped requests":           {lead: 1, reply: 3, applied: 5, requested: 3},
+		"waiting until it's applied": {lead: 1, reply: 1, applied: 4, applyLater: true, requested: 1},
+		"not applied in time":        {lead: 1, reply: 1, applied: 4, err: ErrTimedOut, requested: 1},
+		"no reply":                   {lead: 1, err: ErrTimedOut, requested: 2},
+		"no leader":                  {lead: raft.None, err: ErrNoLeader, requested: 2},
+		"stopped":                    {lead: 1, stopped: true, err: ErrStopped, requested: 1},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			indexes := newReadIndexes()
+			raftNode := &readIndexNode{indexes: indexes, reply: tc.reply, index: 5}
+			n := &node{
+				raft:        raftNode,
+				leader:      newLeader(),
+				applied:     newAppliedIndex(),
+				readIndexes: indexes,
+				done:        make(chan struct{}),
+			}
+			n.leader.set(tc.lead)
+			n.applied.set(tc.applied)
+			if tc.stopped {
+				close(n.done)
+			}
+			if tc.applyLater {
+				go func() {
+					time.Sleep(10 * time.Millisecond)
+					n.applied.set(5)
+				}()
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 3*readIndexRetry+readIndexRetry/2)
+			defer cancel()
+
+			err := n.linearize(ctx)
+			require.True(t, errors.Is(err, tc.err), "%v", err)
+			require.True(t, raftNode.requested >= tc.requested, "requested %d times", raftNode.requested)
+			require.Empty(t, indexes.pending)
+		})
+	}
+}
+
+func TestAppliedIndex(t *testing.T) {
+	a := newAppliedIndex()
+	a.set(2)
+	a.set(1) // it never goes back
+	require.NoError(t, a.wait(context.Background(), 2))
+
+	waited := make(chan error)
+	go func() { waited <- a.wait(context.Background(), 3) }()
+	select {
+	case <-waited:
+		t.Fatal("index 3 isn't applied yet")
+	case <-time.After(10 * time.Millisecond):
+	}
+	a.set(3)
+	require.NoError(t, <-waited)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Equal(t, context.Canceled, a.wait(ctx, 4))
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/coreos/etcd/raft"
 	etcdraftpb "github.com/coreos/etcd/raft/raftpb"
+	"github.com/dimitarvdimitrov/sporkfs/crypt"
 	"github.com/dimitarvdimitrov/sporkfs/log"
 	"github.com/golang/protobuf/proto"
 	"go.uber.org/zap"
@@ -42,9 +43,23 @@ func (e entry) Marshal() ([]byte, error) {
 	return result, nil
 }
 
+// file is what the storage needs from the files in which it persists its state.
+type file interface {
+	io.Reader
+	io.ReaderAt
+	io.WriterAt
+	io.Writer
+	io.Closer
+	Sync() error
+	Name() string
+}
+
 type storage struct {
 	*sync.Mutex
 
+	// key encrypts all files of the storage; nil means they are stored in plain
+	key *crypt.Key
+
 	hardStatePath string
 	hardState     etcdraftpb.HardState
 
@@ -53,12 +68,14 @@ type storage struct {
 
 	entriesPath string
 	entries     []entry
-	entriesFile *os.File
+	entriesFile file
 }
 
-func New(location string, confState etcdraftpb.ConfState) *storage {
+// New returns a storage which persists its state at location. If key isn't nil, the state is encrypted with it.
+func New(location string, confState etcdraftpb.ConfState, key *crypt.Key) *storage {
 	s := &storage{
 		Mutex:         &sync.Mutex{},
+		key:           key,
 		snapshotPath:  location + "/snapshot",
 		entriesPath:   location + "/entries",
 		hardStatePath: location + "/hardState",
@@ -72,7 +89,7 @@ func New(location string, confState etcdraftpb.ConfState) *storage {
 		},
 	}
 
-	entriesFile, err := os.OpenFile(s.entriesPath, os.O_RDWR|os.O_CREATE, 0666)
+	entriesFile, err := s.openFile(s.entriesPath, os.O_RDWR|os.O_CREATE)
 	if err != nil {
 		log.Panic("[raft storage] opening entries file", zap.Error(err))
 	}
@@ -81,9 +98,16 @@ func New(location string, confState etcdraftpb.ConfState) *storage {
 	return s
 }
 
+func (s *storage) openFile(path string, flag int) (file, error) {
+	if s.key != nil {
+		return s.key.OpenFile(path, flag, 0666)
+	}
+	return os.OpenFile(path, flag, 0666)
+}
+
 func (s *storage) tryRecover() {
-	tryRecover(s.snapshotPath, &s.snap)
-	tryRecover(s.hardStatePath, &s.hardState)
+	s.tryRecoverState(s.snapshotPath, &s.snap)
+	s.tryRecoverState(s.hardStatePath, &s.hardState)
 	s.tryRecoverEntries()
 	s.entries[0].e.Index = s.snap.Metadata.Index
 	s.entries[0].e.Term = s.snap.Metadata.Term
@@ -315,7 +339,7 @@ func (s *storage) compact(compactIndex uint64) error {
 		notCompacted = append(notCompacted, e.e)
 	}
 
-	newEntriesFile, err := os.Create(s.entriesPath + ".new")
+	newEntriesFile, err := s.openFile(s.entriesPath+".new", os.O_RDWR|os.O_CREATE|os.O_TRUNC)
 	if err != nil {
 		return err
 	}
@@ -330,7 +354,7 @@ func (s *storage) compact(compactIndex uint64) error {
 		return err
 	}
 
-	newEntriesFile, err = os.OpenFile(s.entriesPath, os.O_RDWR, 0)
+	newEntriesFile, err = s.openFile(s.entriesPath, os.O_RDWR)
 	if err != nil {
 		return err
 	}
@@ -358,7 +382,7 @@ func (s *storage) HardState() etcdraftpb.HardState {
 }
 
 func (s *storage) write(path string, msg proto.Marshaler) error {
-	f, err := os.Create(path + ".new")
+	f, err := s.openFile(path+".new", os.O_RDWR|os.O_CREATE|os.O_TRUNC)
 	if err != nil {
 		return err
 	}
@@ -379,8 +403,8 @@ func (s *storage) write(path string, msg proto.Marshaler) error {
 	return os.Rename(f.Name(), path)
 }
 
-func tryRecover(path string, dest proto.Unmarshaler) {
-	f, err := os.Open(path)
+func (s *storage) tryRecoverState(path string, dest proto.Unmarshaler) {
+	f, err := s.openFile(path, os.O_RDONLY)
 	if err != nil {
 		log.Error("[raft storage] couldn't open saved state, maybe it's a first time run", zap.Error(err))
 		return
@@ -401,7 +425,7 @@ func tryRecover(path string, dest proto.Unmarshaler) {
 }
 
 // writeEntries writes the provided entries after where the appendAfter entry is supposed to be in the log/file.
-func writeEntries(file *os.File, appendAfter entry, entries []etcdraftpb.Entry) ([]entry, error) {
+func writeEntries(file file, appendAfter entry, entries []etcdraftpb.Entry) ([]entry, error) {
 	writeOffset := appendAfter.offset + appendAfter.size + 8 // offset + size of protobuf + 8 for entry.size uint64
 	result := make([]entry, len(entries))
 	buff := bytes.Buffer{}
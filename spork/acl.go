@@ -0,0 +1,83 @@
+package spork
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dimitarvdimitrov/sporkfs/auth"
+	"github.com/dimitarvdimitrov/sporkfs/store"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
+)
+
+// authorize returns store.ErrPermissionDenied if the user of the context doesn't have the permissions in dir.
+// In the trash the user also needs to administer the root. Requests which don't come from the client API,
+// e.g. the ones through the local mount, aren't restricted. It needs to be called before locking dir.
+func (s Spork) authorize(ctx context.Context, dir *store.File, p store.Permission) error {
+	user, ok := auth.UserFrom(ctx)
+	if !ok {
+		return nil
+	}
+	if inTrash(dir) {
+		if acl, _ := effectiveACL(s.Root()); !acl.Allows(user, store.PermAdmin) {
+			return store.ErrPermissionDenied
+		}
+	}
+	if acl, _ := effectiveACL(dir); acl.Allows(user, p) {
+		return nil
+	}
+	return store.ErrPermissionDenied
+}
+
+// effectiveACL returns the ACL of the closest ancestor of dir, including dir, which has one. If none of them does,
+// everyone is allowed everything.
+func effectiveACL(dir *store.File) (_ store.ACL, inherited bool) {
+	for f := dir; f != nil; {
+		f.RLock()
+		acl, parent := f.ACL, f.Parent
+		f.RUnlock()
+
+		if acl != nil {
+			return acl, f != dir
+		}
+		f = parent
+	}
+	return store.ACL{store.Everyone: store.PermAll}, true
+}
+
+// ACL returns the ACL which applies to the directory and whether it was inherited from one of its ancestors.
+func (s Spork) ACL(ctx context.Context, dir *store.File) (store.ACL, bool, error) {
+	if err := s.authorize(ctx, dir, store.PermList); err != nil {
+		return nil, false, err
+	}
+	acl, inherited := effectiveACL(dir)
+	return acl, inherited, nil
+}
+
+// SetACL replaces the ACL of the directory. A nil ACL makes the directory inherit the ACL of its parent.
+func (s Spork) SetACL(ctx context.Context, dir *store.File, acl store.ACL) (err error) {
+	ctx, span := trace.Start(ctx, "spork.SetACL", trace.Id(dir.Id))
+	defer trace.End(span, &err)
+
+	if dir.Mode&store.ModeDirectory == 0 {
+		return store.ErrNotDirectory
+	}
+	if dir.ReadOnly {
+		return store.ErrReadOnly
+	}
+	if err = s.authorize(ctx, dir, store.PermAdmin); err != nil {
+		return err
+	}
+
+	dir.Lock()
+	defer dir.Unlock()
+	span.AddEvent("acquired file lock")
+
+	callback, err := s.raft.SetACL(ctx, dir.Id, acl)
+	if err != nil {
+		return fmt.Errorf("couldn't vote acl change in raft: %w", err)
+	}
+	defer callback()
+
+	s.inventory.SetACL(dir.Id, acl)
+	return nil
+}
@@ -0,0 +1,595 @@
+package spork
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/dimitarvdimitrov/sporkfs/log"
+	raftpb "github.com/dimitarvdimitrov/sporkfs/raft/pb"
+	"github.com/dimitarvdimitrov/sporkfs/store"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// Batch applies the operations atomically. They are proposed as a single raft entry and each peer applies all of
+// them while holding the locks of the files they touch, so no one observes only some of them. If any of them
+// can't be applied anymore when the entry is applied, none of them are. The content of writes is stored locally
+// before the batch is proposed and the other peers fetch it like any other change.
+func (s Spork) Batch(ctx context.Context, ops []store.Op) (err error) {
+	ctx, span := trace.Start(ctx, "spork.Batch", attribute.Int("operations", len(ops)))
+	defer trace.End(span, &err)
+
+	// the operations are checked against the files once, so the lookups don't need to catch up each time
+	if ctx, err = s.linearize(ctx, 0); err != nil {
+		return err
+	}
+
+	p := &batchPlan{
+		s:        s,
+		ctx:      ctx,
+		files:    make(map[string]*store.File),
+		children: make(map[uint64]int),
+	}
+	defer func() {
+		if err != nil {
+			p.cancel()
+		}
+	}()
+
+	for i, op := range ops {
+		if err = p.add(op); err != nil {
+			return fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	if len(p.ops) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	callback, err := s.raft.Batch(ctx, p.ops, now)
+	if err != nil {
+		return fmt.Errorf("couldn't vote batch in raft: %w", err)
+	}
+	defer callback()
+
+	return s.applyBatch(ctx, &raftpb.Batch{Operations: p.ops}, now)
+}
+
+// applyBatch applies the operations of the batch in order while holding the locks of all files they touch.
+// The operations are checked first and if any of them can't be applied, none of them are. All peers check them
+// against the same files, so they all come to the same result.
+func (s Spork) applyBatch(ctx context.Context, batch *raftpb.Batch, at time.Time) error {
+	var ids []uint64
+	for _, op := range batch.Operations {
+		switch o := op.Operation.(type) {
+		case *raftpb.Batch_Operation_Add:
+			ids = append(ids, o.Add.Id, o.Add.ParentId)
+		case *raftpb.Batch_Operation_Rename:
+			ids = append(ids, o.Rename.Id, o.Rename.OldParentId, o.Rename.NewParentId)
+			if targetId, ok := s.childId(o.Rename.NewParentId, o.Rename.NewName); ok {
+				ids = append(ids, targetId)
+			}
+		case *raftpb.Batch_Operation_Delete:
+			ids = append(ids, o.Delete.Id, o.Delete.ParentId)
+		case *raftpb.Batch_Operation_Change:
+			ids = append(ids, o.Change.Id)
+		}
+	}
+	// files which are added by the batch aren't locked, but no one can reach them before their parent is unlocked
+	unlock := s.lockFiles(ids...)
+	defer unlock()
+	trace.AddEvent(ctx, "acquired file locks")
+
+	if err := s.checkBatch(batch); err != nil {
+		return err
+	}
+	for i, op := range batch.Operations {
+		var err error
+		switch o := op.Operation.(type) {
+		case *raftpb.Batch_Operation_Add:
+			err = s.applyAdd(o.Add, at)
+		case *raftpb.Batch_Operation_Rename:
+			err = s.applyRename(o.Rename, at)
+		case *raftpb.Batch_Operation_Delete:
+			err = s.applyDelete(o.Delete, at)
+		case *raftpb.Batch_Operation_Change:
+			err = s.applyChange(ctx, o.Change, at)
+		default:
+			err = fmt.Errorf("unknown operation %T", op.Operation)
+		}
+		if err != nil {
+			log.Error("[spork] applying batch operation", zap.Int("operation", i), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// checkBatch returns an error if any of the operations of the batch can't be applied to the files as they will
+// be after the earlier operations. The files which the operations touch need to be locked.
+func (s Spork) checkBatch(batch *raftpb.Batch) error {
+	c := &batchCheck{
+		s:        s,
+		names:    make(map[batchName]uint64),
+		children: make(map[uint64]int),
+		links:    make(map[uint64]int),
+		parents:  make(map[uint64]uint64),
+		modes:    make(map[uint64]store.FileMode),
+	}
+	for i, op := range batch.Operations {
+		var err error
+		switch o := op.Operation.(type) {
+		case *raftpb.Batch_Operation_Add:
+			err = c.add(o.Add)
+		case *raftpb.Batch_Operation_Rename:
+			err = c.rename(o.Rename)
+		case *raftpb.Batch_Operation_Delete:
+			err = c.delete(o.Delete)
+		case *raftpb.Batch_Operation_Change:
+			err = c.change(o.Change)
+		default:
+			err = fmt.Errorf("unknown operation %T", op.Operation)
+		}
+		if err != nil {
+			return fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+type batchName struct {
+	dirId uint64
+	name  string
+}
+
+// batchCheck keeps track of the changes of the earlier operations of a batch without applying them.
+type batchCheck struct {
+	s Spork
+
+	// names are the ids of the files which earlier operations gave a name in a directory; 0 means the name was freed
+	names map[batchName]uint64
+	// children is how many children earlier operations added to (or removed from if negative) each directory
+	children map[uint64]int
+	// links is how many links to each file earlier operations added (or removed if negative)
+	links map[uint64]int
+	// parents are the parents of the directories which earlier operations added or moved
+	parents map[uint64]uint64
+	// modes are the modes of the files which earlier operations added
+	modes map[uint64]store.FileMode
+}
+
+func (c *batchCheck) add(req *raftpb.Add) error {
+	if err := c.checkDir(req.ParentId); err != nil {
+		return err
+	}
+	if c.lookup(req.ParentId, req.Name) != 0 {
+		return store.ErrFileAlreadyExists
+	}
+	mode := store.FileMode(req.Mode)
+	if existing, ok := c.mode(req.Id); ok {
+		mode = existing
+	}
+
+	c.names[batchName{req.ParentId, req.Name}] = req.Id
+	c.children[req.ParentId]++
+	c.links[req.Id]++
+	c.modes[req.Id] = mode
+	if mode&store.ModeDirectory != 0 {
+		c.parents[req.Id] = req.ParentId
+	}
+	return nil
+}
+
+func (c *batchCheck) rename(req *raftpb.Rename) error {
+	if c.lookup(req.OldParentId, req.OldName) != req.Id {
+		return store.ErrNoSuchFile
+	}
+	if err := c.checkDir(req.NewParentId); err != nil {
+		return err
+	}
+	mode, _ := c.mode(req.Id)
+	isDir := mode&store.ModeDirectory != 0
+	if isDir {
+		for d, ok := req.NewParentId, true; ok; d, ok = c.parent(d) {
+			if d == req.Id {
+				return fmt.Errorf("can't move a directory into itself")
+			}
+		}
+	}
+
+	target := c.lookup(req.NewParentId, req.NewName)
+	if target == req.Id {
+		return nil
+	}
+	if target != 0 {
+		targetMode, _ := c.mode(target)
+		targetIsDir := targetMode&store.ModeDirectory != 0
+		switch {
+		case isDir && !targetIsDir:
+			return store.ErrNotDirectory
+		case !isDir && targetIsDir:
+			return store.ErrIsDirectory
+		case targetIsDir && !c.empty(target):
+			return store.ErrDirectoryNotEmpty
+		}
+		c.children[req.NewParentId]--
+		c.links[target]--
+	}
+
+	c.names[batchName{req.OldParentId, req.OldName}] = 0
+	c.names[batchName{req.NewParentId, req.NewName}] = req.Id
+	c.children[req.OldParentId]--
+	c.children[req.NewParentId]++
+	if isDir {
+		c.parents[req.Id] = req.NewParentId
+	}
+	return nil
+}
+
+func (c *batchCheck) delete(req *raftpb.Delete) error {
+	if c.lookup(req.ParentId, req.Name) != req.Id {
+		return store.ErrNoSuchFile
+	}
+	if mode, _ := c.mode(req.Id); mode&store.ModeDirectory != 0 && !c.empty(req.Id) {
+		return store.ErrDirectoryNotEmpty
+	}
+
+	c.names[batchName{req.ParentId, req.Name}] = 0
+	c.children[req.ParentId]--
+	c.links[req.Id]--
+	return nil
+}
+
+func (c *batchCheck) change(req *raftpb.Change) error {
+	mode, ok := c.mode(req.Id)
+	if !ok || len(c.s.inventory.GetAll(req.Id))+c.links[req.Id] <= 0 {
+		return store.ErrNoSuchFile
+	}
+	if mode&store.ModeDirectory != 0 {
+		return store.ErrIsDirectory
+	}
+	return nil
+}
+
+// checkDir returns an error if the directory with the id doesn't exist or isn't a directory.
+func (c *batchCheck) checkDir(id uint64) error {
+	mode, ok := c.mode(id)
+	if !ok || (id != c.s.Root().Id && len(c.s.inventory.GetAll(id))+c.links[id] <= 0) {
+		return store.ErrNoSuchFile
+	}
+	if mode&store.ModeDirectory == 0 {
+		return store.ErrNotDirectory
+	}
+	return nil
+}
+
+// lookup returns the id of the file with the name in the directory or 0 if there is none.
+func (c *batchCheck) lookup(dirId uint64, name string) uint64 {
+	if id, ok := c.names[batchName{dirId, name}]; ok {
+		return id
+	}
+	dir, err := c.s.inventory.GetAny(dirId)
+	if err != nil {
+		return 0
+	}
+	if f := childNamed(dir, name); f != nil {
+		return f.Id
+	}
+	return 0
+}
+
+func (c *batchCheck) mode(id uint64) (store.FileMode, bool) {
+	if mode, ok := c.modes[id]; ok {
+		return mode, true
+	}
+	f, err := c.s.inventory.GetAny(id)
+	if err != nil {
+		return 0, false
+	}
+	return f.Mode, true
+}
+
+// parent returns the id of the parent of the directory or false if it's the root.
+func (c *batchCheck) parent(dirId uint64) (uint64, bool) {
+	if parentId, ok := c.parents[dirId]; ok {
+		return parentId, true
+	}
+	dir, err := c.s.inventory.GetAny(dirId)
+	if err != nil || dir.Parent == nil {
+		return 0, false
+	}
+	return dir.Parent.Id, true
+}
+
+// empty returns true if the directory has no children and no snapshots.
+func (c *batchCheck) empty(dirId uint64) bool {
+	children := c.children[dirId]
+	if dir, err := c.s.inventory.GetAny(dirId); err == nil {
+		if len(dir.Snapshots) != 0 {
+			return false
+		}
+		children += len(dir.Children)
+	}
+	return children == 0
+}
+
+// batchPlan turns the operations of a batch into raft operations. It checks them against the files as they
+// will be after the earlier operations in the batch.
+type batchPlan struct {
+	s   Spork
+	ctx context.Context
+
+	// files are the files which earlier operations created or moved, by their path; deleted and moved away files are nil
+	files map[string]*store.File
+	// children is how many children earlier operations added to (or removed from if negative) each directory
+	children map[uint64]int
+	// removeWritten removes the versions which were written for the batch
+	removeWritten []func()
+
+	ops []*raftpb.Batch_Operation
+}
+
+func (p *batchPlan) add(op store.Op) error {
+	switch op.Kind {
+	case store.OpCreate:
+		return p.create(op.Path, op.Mode)
+	case store.OpWrite:
+		return p.write(op.Path, op.Data)
+	case store.OpRename:
+		return p.rename(op.Path, op.NewPath)
+	case store.OpDelete:
+		return p.delete(op.Path)
+	default:
+		return fmt.Errorf("unknown operation kind %d", op.Kind)
+	}
+}
+
+func (p *batchPlan) cancel() {
+	for _, remove := range p.removeWritten {
+		remove()
+	}
+}
+
+func (p *batchPlan) create(name string, mode store.FileMode) error {
+	name = cleanPath(name)
+	parent, base, err := p.parentOf(name)
+	if err != nil {
+		return err
+	}
+	if p.exists(name) {
+		return store.ErrFileAlreadyExists
+	}
+	if err = store.CheckQuota(parent, store.Usage{Inodes: 1}); err != nil {
+		return err
+	}
+	if mode == 0 {
+		mode = store.ModeRegularFile
+	}
+
+	f := p.s.newFile(base, mode)
+	f.Parent = parent
+	p.files[name] = f
+	p.children[parent.Id]++
+
+	p.ops = append(p.ops, &raftpb.Batch_Operation{Operation: &raftpb.Batch_Operation_Add{Add: &raftpb.Add{
+		Id:       f.Id,
+		ParentId: parent.Id,
+		Name:     f.Name,
+		Mode:     uint32(f.Mode),
+	}}})
+	return nil
+}
+
+func (p *batchPlan) write(name string, data []byte) error {
+	f, err := p.lookup(name)
+	if err != nil {
+		return err
+	}
+	if f.Mode&store.ModeDirectory != 0 {
+		return fmt.Errorf("can't write to a directory")
+	}
+	if f.ReadOnly {
+		return store.ErrReadOnly
+	}
+	if err = p.s.authorize(p.ctx, f.Parent, store.PermWrite); err != nil {
+		return err
+	}
+	size := int64(len(data))
+	if err = store.CheckQuota(f.Parent, store.Usage{Bytes: size - f.Size}); err != nil {
+		return err
+	}
+
+	id, version := f.Id, rand.Uint64()
+	driver := p.s.driverFor(id)
+	w, err := driver.Writer(id, 0, version, os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(data); err != nil {
+		w.Cancel()
+		return err
+	}
+	w.Commit()
+	p.removeWritten = append(p.removeWritten, func() { driver.Remove(id, version) })
+
+	p.ops = append(p.ops, &raftpb.Batch_Operation{Operation: &raftpb.Batch_Operation_Change{Change: &raftpb.Change{
+		Id:      id,
+		Version: version,
+		Size:    size,
+	}}})
+	return nil
+}
+
+func (p *batchPlan) rename(oldName, newName string) error {
+	oldName, newName = cleanPath(oldName), cleanPath(newName)
+	f, err := p.lookup(oldName)
+	if err != nil {
+		return err
+	}
+	if f.ReadOnly {
+		return store.ErrReadOnly
+	}
+	if err = p.s.authorize(p.ctx, f.Parent, store.PermWrite); err != nil {
+		return err
+	}
+	newParent, base, err := p.parentOf(newName)
+	if err != nil {
+		return err
+	}
+	target, err := p.lookup(newName)
+	if err == nil {
+		if target.Id == f.Id {
+			return nil
+		}
+		target.RLock()
+		err = checkReplace(f, target)
+		if err == nil && p.children[target.Id] > 0 {
+			err = store.ErrDirectoryNotEmpty
+		}
+		target.RUnlock()
+		if err != nil {
+			return err
+		}
+		// the rename replaces it
+		p.children[newParent.Id]--
+	} else {
+		target = nil
+	}
+	for d := newParent; d != nil; d = d.Parent {
+		if d.Id == f.Id {
+			return fmt.Errorf("can't move a directory into itself")
+		}
+	}
+	if f.Parent.Id != newParent.Id {
+		f.RLock()
+		err = checkMoveQuota(f, target, f.Parent, newParent)
+		f.RUnlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	p.move(f, oldName, newParent, newName, base)
+	return nil
+}
+
+func (p *batchPlan) delete(name string) error {
+	name = cleanPath(name)
+	f, err := p.lookup(name)
+	if err != nil {
+		return err
+	}
+	if f.ReadOnly || f.Parent == nil {
+		return store.ErrReadOnly
+	}
+	if err = p.s.authorize(p.ctx, f.Parent, store.PermWrite); err != nil {
+		return err
+	}
+
+	f.RLock()
+	notEmpty := len(f.Children)+p.children[f.Id] != 0 || len(f.Snapshots) != 0
+	f.RUnlock()
+	if notEmpty {
+		return store.ErrDirectoryNotEmpty
+	}
+
+	if p.s.trash > 0 && !inTrash(f) {
+		trash, err := p.s.trashDir(p.ctx)
+		if err != nil {
+			return err
+		}
+		base := time.Now().UTC().Format(trashTimeFormat) + " " + f.Name
+		p.move(f, name, trash, path.Join("/", TrashDirName, base), base)
+		return nil
+	}
+
+	p.files[name] = nil
+	p.children[f.Parent.Id]--
+
+	p.ops = append(p.ops, &raftpb.Batch_Operation{Operation: &raftpb.Batch_Operation_Delete{Delete: &raftpb.Delete{
+		Id:       f.Id,
+		ParentId: f.Parent.Id,
+		Name:     f.Name,
+	}}})
+	return nil
+}
+
+func (p *batchPlan) move(f *store.File, oldName string, newParent *store.File, newName, base string) {
+	f.RLock()
+	moved := *f
+	f.RUnlock()
+	moved.Name, moved.Parent = base, newParent
+
+	p.files[oldName] = nil
+	p.files[newName] = &moved
+	p.children[f.Parent.Id]--
+	p.children[newParent.Id]++
+
+	p.ops = append(p.ops, &raftpb.Batch_Operation{Operation: &raftpb.Batch_Operation_Rename{Rename: &raftpb.Rename{
+		Id:          f.Id,
+		OldParentId: f.Parent.Id,
+		NewParentId: newParent.Id,
+		OldName:     f.Name,
+		NewName:     base,
+	}}})
+}
+
+// parentOf returns the directory in which the file at the path would be and the name of the file. The directory
+// needs to be writable.
+func (p *batchPlan) parentOf(name string) (*store.File, string, error) {
+	base := path.Base(name)
+	if base == "/" || base == "." || base == ".." {
+		return nil, "", fmt.Errorf("invalid path %q", name)
+	}
+	parent, err := p.lookup(path.Dir(name))
+	if err != nil {
+		return nil, "", err
+	}
+	if parent.Mode&store.ModeDirectory == 0 {
+		return nil, "", store.ErrNotDirectory
+	}
+	if parent.ReadOnly {
+		return nil, "", store.ErrReadOnly
+	}
+	if err = p.s.authorize(p.ctx, parent, store.PermWrite); err != nil {
+		return nil, "", err
+	}
+	return parent, base, nil
+}
+
+func (p *batchPlan) exists(name string) bool {
+	_, err := p.lookup(name)
+	return err == nil
+}
+
+// lookup returns the file at the path after the earlier operations.
+func (p *batchPlan) lookup(name string) (f *store.File, err error) {
+	f = p.s.Root()
+	current := ""
+	for _, n := range strings.Split(cleanPath(name), "/") {
+		if n == "" {
+			continue
+		}
+		current += "/" + n
+		if planned, ok := p.files[current]; ok {
+			if planned == nil {
+				return nil, store.ErrNoSuchFile
+			}
+			f = planned
+			continue
+		}
+		if f, err = p.s.Lookup(p.ctx, f, n); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func cleanPath(name string) string {
+	return path.Clean("/" + name)
+}
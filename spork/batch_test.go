@@ -0,0 +1,221 @@
+package spork
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	raftpb "github.com/dimitarvdimitrov/sporkfs/raft/pb"
+	"github.com/dimitarvdimitrov/sporkfs/store"
+	"github.com/stretchr/testify/require"
+)
+
+func readTestFile(t *testing.T, s *Spork, f *store.File) string {
+	r, err := s.Read(context.Background(), f, os.O_RDONLY)
+	require.NoError(t, err)
+	defer r.Close()
+	content, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func lookupTestPath(s *Spork, path string) (f *store.File, err error) {
+	f = s.Root()
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		if f, err = s.Lookup(context.Background(), f, name); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// newBatchTestTree creates the file /existing with the content "old", the empty directory /empty and the directory
+// /full with the file /full/c.
+func newBatchTestTree(t *testing.T, s *Spork) {
+	existing := createTestFile(t, s, s.Root(), "existing", false)
+	writeTestFile(t, s, existing, "old")
+	createTestFile(t, s, s.Root(), "empty", true)
+	full := createTestFile(t, s, s.Root(), "full", true)
+	createTestFile(t, s, full, "c", false)
+}
+
+func TestBatch(t *testing.T) {
+	const dir = "<dir>"
+	testCases := map[string]struct {
+		ops []store.Op
+		err error
+		// files are the contents of the files by their path after the batch, or dir for directories; files which
+		// aren't there are nil
+		files map[string]interface{}
+	}{
+		"create and write": {
+			ops: []store.Op{
+				{Kind: store.OpCreate, Path: "/dir", Mode: store.ModeDirectory},
+				{Kind: store.OpCreate, Path: "/dir/a"},
+				{Kind: store.OpWrite, Path: "/dir/a", Data: []byte("a")},
+			},
+			files: map[string]interface{}{"/dir": dir, "/dir/a": "a"},
+		},
+		"write and rename": {
+			ops: []store.Op{
+				{Kind: store.OpWrite, Path: "/existing", Data: []byte("new")},
+				{Kind: store.OpRename, Path: "/existing", NewPath: "/empty/moved"},
+			},
+			files: map[string]interface{}{"/existing": nil, "/empty/moved": "new"},
+		},
+		"replace a created file": {
+			ops: []store.Op{
+				{Kind: store.OpCreate, Path: "/a"},
+				{Kind: store.OpWrite, Path: "/a", Data: []byte("a")},
+				{Kind: store.OpRename, Path: "/a", NewPath: "/existing"},
+			},
+			files: map[string]interface{}{"/a": nil, "/existing": "a"},
+		},
+		"empty a directory and delete it": {
+			ops: []store.Op{
+				{Kind: store.OpDelete, Path: "/full/c"},
+				{Kind: store.OpDelete, Path: "/full"},
+			},
+			files: map[string]interface{}{"/full": nil},
+		},
+		"create an existing file": {
+			ops: []store.Op{{Kind: store.OpCreate, Path: "/existing"}},
+			err: store.ErrFileAlreadyExists,
+		},
+		"write a deleted file": {
+			ops: []store.Op{
+				{Kind: store.OpDelete, Path: "/existing"},
+				{Kind: store.OpWrite, Path: "/existing", Data: []byte("new")},
+			},
+			err:   store.ErrNoSuchFile,
+			files: map[string]interface{}{"/existing": "old"},
+		},
+		"delete a directory which isn't empty": {
+			ops: []store.Op{
+				{Kind: store.OpCreate, Path: "/a"},
+				{Kind: store.OpDelete, Path: "/full"},
+			},
+			err:   store.ErrDirectoryNotEmpty,
+			files: map[string]interface{}{"/a": nil, "/full": dir},
+		},
+		"fill a directory before replacing it": {
+			ops: []store.Op{
+				{Kind: store.OpCreate, Path: "/dir", Mode: store.ModeDirectory},
+				{Kind: store.OpCreate, Path: "/empty/a"},
+				{Kind: store.OpRename, Path: "/dir", NewPath: "/empty"},
+			},
+			err:   store.ErrDirectoryNotEmpty,
+			files: map[string]interface{}{"/dir": nil, "/empty/a": nil},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			s, cleanup := newTestSpork(t)
+			defer cleanup()
+			newBatchTestTree(t, s)
+
+			err := s.Batch(context.Background(), tc.ops)
+			require.True(t, errors.Is(err, tc.err), err)
+			for path, expected := range tc.files {
+				f, err := lookupTestPath(s, path)
+				switch {
+				case expected == nil:
+					require.Equal(t, store.ErrNoSuchFile, err, path)
+				case expected == dir:
+					require.NoError(t, err, path)
+					require.NotZero(t, f.Mode&store.ModeDirectory, path)
+				default:
+					require.NoError(t, err, path)
+					require.Equal(t, expected, readTestFile(t, s, f), path)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyBatchRejected(t *testing.T) {
+	s, cleanup := newTestSpork(t)
+	defer cleanup()
+	newBatchTestTree(t, s)
+	root := s.Root()
+	empty := lookupTestFile(t, s, "empty")
+	src := createTestFile(t, s, root, "src", true)
+
+	// the batch was planned while /empty was empty, but a file was created in it before the batch was applied
+	batch := &raftpb.Batch{Operations: []*raftpb.Batch_Operation{
+		{Operation: &raftpb.Batch_Operation_Add{Add: &raftpb.Add{
+			Id: s.inventory.NewId(), ParentId: root.Id, Name: "new", Mode: uint32(store.ModeRegularFile),
+		}}},
+		{Operation: &raftpb.Batch_Operation_Rename{Rename: &raftpb.Rename{
+			Id: src.Id, OldParentId: root.Id, NewParentId: root.Id, OldName: "src", NewName: "empty",
+		}}},
+	}}
+	createTestFile(t, s, empty, "a", false)
+
+	err := s.applyBatch(context.Background(), batch, time.Now())
+	require.True(t, errors.Is(err, store.ErrDirectoryNotEmpty), err)
+	// none of the operations are applied
+	_, err = s.Lookup(context.Background(), root, "new")
+	require.Equal(t, store.ErrNoSuchFile, err)
+	require.Equal(t, src.Id, lookupTestFile(t, s, "src").Id)
+	require.Equal(t, empty.Id, lookupTestFile(t, s, "empty").Id)
+}
+
+func TestCheckBatch(t *testing.T) {
+	s, cleanup := newTestSpork(t)
+	defer cleanup()
+	newBatchTestTree(t, s)
+	root := s.Root()
+	existing, full := lookupTestFile(t, s, "existing"), lookupTestFile(t, s, "full")
+	c := lookupTestFile(t, s, "full", "c")
+	newId := s.inventory.NewId()
+
+	add := func(id, parentId uint64, name string, mode store.FileMode) *raftpb.Batch_Operation {
+		return &raftpb.Batch_Operation{Operation: &raftpb.Batch_Operation_Add{Add: &raftpb.Add{
+			Id: id, ParentId: parentId, Name: name, Mode: uint32(mode),
+		}}}
+	}
+	rename := func(id, oldParentId, newParentId uint64, oldName, newName string) *raftpb.Batch_Operation {
+		return &raftpb.Batch_Operation{Operation: &raftpb.Batch_Operation_Rename{Rename: &raftpb.Rename{
+			Id: id, OldParentId: oldParentId, NewParentId: newParentId, OldName: oldName, NewName: newName,
+		}}}
+	}
+	del := func(id, parentId uint64, name string) *raftpb.Batch_Operation {
+		return &raftpb.Batch_Operation{Operation: &raftpb.Batch_Operation_Delete{Delete: &raftpb.Delete{
+			Id: id, ParentId: parentId, Name: name,
+		}}}
+	}
+	change := func(id uint64) *raftpb.Batch_Operation {
+		return &raftpb.Batch_Operation{Operation: &raftpb.Batch_Operation_Change{Change: &raftpb.Change{Id: id}}}
+	}
+
+	testCases := map[string]struct {
+		ops []*raftpb.Batch_Operation
+		err error
+	}{
+		"add and delete":             {ops: []*raftpb.Batch_Operation{add(newId, root.Id, "new", store.ModeRegularFile), del(newId, root.Id, "new")}},
+		"add twice":                  {ops: []*raftpb.Batch_Operation{add(newId, root.Id, "new", store.ModeRegularFile), add(newId, root.Id, "new", store.ModeRegularFile)}, err: store.ErrFileAlreadyExists},
+		"add to a file":              {ops: []*raftpb.Batch_Operation{add(newId, existing.Id, "new", store.ModeRegularFile)}, err: store.ErrNotDirectory},
+		"add to a deleted directory": {ops: []*raftpb.Batch_Operation{del(c.Id, full.Id, "c"), del(full.Id, root.Id, "full"), add(newId, full.Id, "new", store.ModeRegularFile)}, err: store.ErrNoSuchFile},
+		"delete a filled directory":  {ops: []*raftpb.Batch_Operation{add(newId, root.Id, "dir", store.ModeDirectory), add(s.inventory.NewId(), newId, "a", store.ModeRegularFile), del(newId, root.Id, "dir")}, err: store.ErrDirectoryNotEmpty},
+		"change a renamed file":      {ops: []*raftpb.Batch_Operation{rename(existing.Id, root.Id, full.Id, "existing", "moved"), change(existing.Id)}},
+		"change a deleted file":      {ops: []*raftpb.Batch_Operation{del(existing.Id, root.Id, "existing"), change(existing.Id)}, err: store.ErrNoSuchFile},
+		"change a directory":         {ops: []*raftpb.Batch_Operation{change(full.Id)}, err: store.ErrIsDirectory},
+		"rename a renamed file":      {ops: []*raftpb.Batch_Operation{rename(existing.Id, root.Id, root.Id, "existing", "moved"), rename(existing.Id, root.Id, root.Id, "existing", "other")}, err: store.ErrNoSuchFile},
+		"rename over a file":         {ops: []*raftpb.Batch_Operation{rename(c.Id, full.Id, root.Id, "c", "existing")}},
+		"rename a file over a dir":   {ops: []*raftpb.Batch_Operation{rename(existing.Id, root.Id, root.Id, "existing", "full")}, err: store.ErrIsDirectory},
+		"rename a dir into itself":   {ops: []*raftpb.Batch_Operation{add(newId, full.Id, "sub", store.ModeDirectory), rename(full.Id, root.Id, newId, "full", "full")}, err: errors.New("can't move a directory into itself")},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := s.checkBatch(&raftpb.Batch{Operations: tc.ops})
+			require.Equal(t, tc.err, errors.Unwrap(err))
+		})
+	}
+}
@@ -1,9 +1,25 @@
 package spork
 
-import "github.com/dimitarvdimitrov/sporkfs/raft"
+import (
+	"github.com/dimitarvdimitrov/sporkfs/auth"
+	"github.com/dimitarvdimitrov/sporkfs/qos"
+	"github.com/dimitarvdimitrov/sporkfs/raft"
+	"github.com/dimitarvdimitrov/sporkfs/store/history"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
+)
 
 type Config struct {
-	DataDir     string `toml:"data_dir"`
-	MountPoint  string `toml:"mount_point"`
-	raft.Config `toml:""`
+	DataDir            string         `toml:"data_dir"`
+	MountPoint         string         `toml:"mount_point"`
+	CacheSize          int64          `toml:"cache_size"`          // in bytes; 0 means unlimited
+	MetricsAddr        string         `toml:"metrics_addr"`        // serves expvar metrics at /debug/vars; empty disables it
+	StorageCompression string         `toml:"storage_compression"` // "gzip" or empty to store files uncompressed
+	KeyFile            string         `toml:"key_file"`            // encrypts everything in DataDir; empty disables it
+	ClientAddr         string         `toml:"client_addr"`         // serves the client API; empty disables it
+	LinearizableReads  bool           `toml:"linearizable_reads"`  // every read waits until this node has caught up with the cluster
+	Auth               auth.Config    `toml:"auth"`
+	Retention          history.Config `toml:"retention"`
+	Tracing            trace.Config   `toml:"tracing"`
+	QoS                qos.Config     `toml:"qos"`
+	raft.Config        `toml:""`
 }
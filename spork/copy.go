@@ -0,0 +1,139 @@
+package spork
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+
+	"github.com/dimitarvdimitrov/sporkfs/log"
+	"github.com/dimitarvdimitrov/sporkfs/qos"
+	"github.com/dimitarvdimitrov/sporkfs/store"
+	storedata "github.com/dimitarvdimitrov/sporkfs/store/data"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
+	"go.uber.org/zap"
+)
+
+// Copy creates a file with the name in parent which has the content of the file. The content doesn't go through
+// this node; each of the peers which hold the copy duplicates the source locally or fetches it from the peers
+// which hold it in the background. Reads of the copy wait for it. The file can be read-only, e.g. in a snapshot.
+func (s Spork) Copy(ctx context.Context, file, parent *store.File, name string) (_ *store.File, err error) {
+	ctx, span := trace.Start(ctx, "spork.Copy", trace.Id(file.Id), trace.Name(name))
+	defer trace.End(span, &err)
+
+	if file.Mode&store.ModeDirectory != 0 {
+		return nil, fmt.Errorf("can't copy directories")
+	}
+	if parent.Mode&store.ModeDirectory == 0 {
+		return nil, store.ErrNotDirectory
+	}
+	if parent.ReadOnly {
+		return nil, store.ErrReadOnly
+	}
+	if err = s.authorize(ctx, file.Parent, store.PermRead); err != nil {
+		return nil, err
+	}
+	if err = s.authorize(ctx, parent, store.PermWrite); err != nil {
+		return nil, err
+	}
+
+	parent.Lock()
+	defer parent.Unlock()
+	file.RLock()
+	defer file.RUnlock()
+	span.AddEvent("acquired file and parent locks")
+
+	for _, c := range parent.Children {
+		if c.Name == name {
+			return nil, store.ErrFileAlreadyExists
+		}
+	}
+	if err = store.CheckQuota(parent, store.Usage{Bytes: file.Size, Inodes: 1}); err != nil {
+		return nil, err
+	}
+
+	c := s.newFile(name, file.Mode)
+	c.Version = rand.Uint64()
+	c.Size = file.Size
+	c.Lock()
+	defer c.Unlock()
+
+	callback, err := s.raft.Copy(ctx, c.Id, parent.Id, c.Name, c.Mode, file.Id, file.Version, c.Version, c.Size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy file in raft: %w", err)
+	}
+	defer callback()
+
+	s.add(c, parent)
+
+	if s.peers.IsLocalFile(c.Id) {
+		if err = s.copyDataLater(file.Id, file.Version, c.Id, c.Version, c.Size); err != nil {
+			// the other peers which hold the copy can still serve it
+			log.Error("[spork] copying file", log.Id(c.Id), zap.Uint64("source_id", file.Id), zap.Error(err))
+		}
+	}
+	return c, nil
+}
+
+// copyDataLater duplicates the source version as the version of the file in the local data in the background.
+// All blocks of the version are claimed before it returns, so that readers of the file wait for the copy instead
+// of fetching the blocks from the peers, which may not have them yet either.
+func (s Spork) copyDataLater(srcId, srcVersion, id, version uint64, size int64) error {
+	ctx := qos.WithClass(s.ctx, qos.Replication)
+	if size == 0 {
+		return s.download(ctx, srcId, srcVersion, id, version, size, "", s.data)
+	}
+
+	partial, err := s.data.Partial(id, version, size)
+	if err != nil {
+		return err
+	}
+	claimed := partial.Claim(0, size)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer partial.Close()
+
+		err := s.copyData(ctx, srcId, srcVersion, claimed, partial)
+		for _, rng := range claimed {
+			partial.Unclaim(rng) // only the blocks which weren't written are unclaimed
+		}
+		if err != nil {
+			log.Error("[spork] copying file", log.Id(id), zap.Uint64("source_id", srcId), zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// copyData writes the ranges of the source version to the partial file. The source is read locally if this peer
+// holds it; otherwise it's fetched from the peers which hold it.
+func (s Spork) copyData(ctx context.Context, srcId, srcVersion uint64, ranges []storedata.Range, dst storedata.PartialFile) error {
+	if !s.data.Contains(srcId, srcVersion) {
+		return s.fetcher.Download(ctx, srcId, srcVersion, ranges, dst)
+	}
+
+	src, err := s.data.Reader(srcId, srcVersion, os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	buf := make([]byte, storedata.BlockSize)
+	for _, rng := range ranges {
+		for off := rng.Off; off < rng.Off+rng.Len; off += storedata.BlockSize {
+			n := rng.Off + rng.Len - off
+			if n > storedata.BlockSize {
+				n = storedata.BlockSize
+			}
+			if _, err = src.ReadAt(buf[:n], off); err != nil && err != io.EOF {
+				return err
+			}
+			if _, err = dst.WriteAt(buf[:n], off); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package spork
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dimitarvdimitrov/sporkfs/store"
+	"github.com/dimitarvdimitrov/sporkfs/store/history"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
+)
+
+// DeleteAll deletes the file and, if it's a directory, everything in it. The whole tree is deleted with a single
+// raft entry and the data of the deleted files is removed in the background. When the trash is enabled, the tree
+// is moved to it instead.
+func (s Spork) DeleteAll(ctx context.Context, file *store.File) (err error) {
+	ctx, span := trace.Start(ctx, "spork.DeleteAll", trace.Id(file.Id), trace.Name(file.Name))
+	defer trace.End(span, &err)
+
+	if file.ReadOnly || file.Parent == nil {
+		return store.ErrReadOnly
+	}
+	if err = s.authorize(ctx, file.Parent, store.PermWrite); err != nil {
+		return err
+	}
+	if err = s.checkDeleteAll(ctx, file); err != nil {
+		return err
+	}
+	if s.trash > 0 && !inTrash(file) {
+		return s.moveToTrash(ctx, file)
+	}
+
+	file.Lock()
+	defer file.Unlock()
+
+	parent := file.Parent
+	parent.Lock()
+	defer parent.Unlock()
+	span.AddEvent("acquired file and parent locks")
+
+	if childNamed(parent, file.Name) != file {
+		return store.ErrNoSuchFile
+	}
+
+	now := time.Now()
+	callback, err := s.raft.DeleteAll(ctx, file.Id, parent.Id, file.Name, now)
+	if err != nil {
+		return fmt.Errorf("couldn't vote removal in raft: %w", err)
+	}
+	defer callback()
+
+	s.removeVersionsLater(s.deleteTree(file, now))
+	// the file system doesn't know about the deletion, since it didn't go through it
+	s.deleted <- file
+
+	return nil
+}
+
+// checkDeleteAll returns an error if the user isn't allowed to delete everything in the directory or if any of the
+// directories in it has snapshots.
+func (s Spork) checkDeleteAll(ctx context.Context, dir *store.File) error {
+	dir.RLock()
+	children := append([]*store.File(nil), dir.Children...)
+	hasSnapshots := len(dir.Snapshots) != 0
+	dir.RUnlock()
+
+	if hasSnapshots {
+		return store.ErrDirectoryNotEmpty
+	}
+	if len(children) == 0 {
+		return nil
+	}
+	if err := s.authorize(ctx, dir, store.PermWrite); err != nil {
+		return err
+	}
+	for _, c := range children {
+		if err := s.checkDeleteAll(ctx, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteTree deletes the file and everything in it at the time and returns the versions which aren't retained
+// anymore. The file and its parent need to be locked.
+func (s Spork) deleteTree(file *store.File, at time.Time) (expired []history.Revision) {
+	for _, c := range append([]*store.File(nil), file.Children...) {
+		c.Lock()
+		expired = append(expired, s.deleteTree(c, at)...)
+		c.Unlock()
+		s.deleted <- c
+	}
+	return append(expired, s.unlink(file, at)...)
+}
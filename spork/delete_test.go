@@ -0,0 +1,68 @@
+package spork
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	raftpb "github.com/dimitarvdimitrov/sporkfs/raft/pb"
+	"github.com/dimitarvdimitrov/sporkfs/store"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFile(t *testing.T, s *Spork, f *store.File, content string) {
+	w, err := s.Write(context.Background(), f, os.O_WRONLY)
+	require.NoError(t, err)
+	_, err = w.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.True(t, s.data.Contains(f.Id, f.Version))
+}
+
+// newTestTree creates dir/a and dir/sub/b with some content in the files and returns all of them.
+func newTestTree(t *testing.T, s *Spork) (dir *store.File, files []*store.File) {
+	dir = createTestFile(t, s, s.Root(), "dir", true)
+	a := createTestFile(t, s, dir, "a", false)
+	sub := createTestFile(t, s, dir, "sub", true)
+	b := createTestFile(t, s, sub, "b", false)
+	writeTestFile(t, s, a, "a")
+	writeTestFile(t, s, b, "b")
+	return dir, []*store.File{dir, a, sub, b}
+}
+
+// requireTreeDeleted requires that none of the files are known anymore and that their data is removed.
+func requireTreeDeleted(t *testing.T, s *Spork, files []*store.File) {
+	_, err := s.Lookup(context.Background(), s.Root(), "dir")
+	require.Equal(t, store.ErrNoSuchFile, err)
+	for _, f := range files {
+		_, err := s.inventory.GetAny(f.Id)
+		require.Equal(t, store.ErrNoSuchFile, err, f.Name)
+		if f.Mode&store.ModeDirectory == 0 {
+			require.Eventually(t, func() bool { return !s.data.Contains(f.Id, f.Version) }, time.Second, time.Millisecond, f.Name)
+		}
+	}
+}
+
+func TestDeleteAll(t *testing.T) {
+	s, cleanup := newTestSpork(t)
+	defer cleanup()
+	dir, files := newTestTree(t, s)
+
+	require.Equal(t, store.ErrDirectoryNotEmpty, s.Delete(context.Background(), dir))
+	require.NoError(t, s.DeleteAll(context.Background(), dir))
+	requireTreeDeleted(t, s, files)
+}
+
+func TestApplyRecursiveDelete(t *testing.T) {
+	s, cleanup := newTestSpork(t)
+	defer cleanup()
+	dir, files := newTestTree(t, s)
+
+	// the whole tree is deleted by a single entry from another peer
+	unlock := s.lockFiles(dir.Id, s.Root().Id)
+	err := s.applyDelete(&raftpb.Delete{Id: dir.Id, ParentId: s.Root().Id, Name: "dir", Recursive: true}, time.Now())
+	unlock()
+	require.NoError(t, err)
+	requireTreeDeleted(t, s, files)
+}
@@ -0,0 +1,35 @@
+package spork
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/dimitarvdimitrov/sporkfs/trace"
+)
+
+type linearizedKey struct{}
+
+// Linearize waits until this node has applied all changes which were committed in the cluster before it was called
+// if linearizable reads are enabled. Calls with the returned context don't wait again, so it can be used for
+// a series of reads which only need to observe the writes that completed before the first of them.
+func (s Spork) Linearize(ctx context.Context) (context.Context, error) {
+	return s.linearize(ctx, 0)
+}
+
+// linearize is like Linearize, but it also waits if the file is opened with O_SYNC (or O_RSYNC, which is the same
+// on Linux) in flags.
+func (s Spork) linearize(ctx context.Context, flags int) (context.Context, error) {
+	if !s.linearizable && flags&os.O_SYNC != os.O_SYNC {
+		return ctx, nil
+	}
+	if ctx.Value(linearizedKey{}) != nil {
+		return ctx, nil
+	}
+
+	if err := s.raft.Linearize(ctx); err != nil {
+		return ctx, fmt.Errorf("linearizing read: %w", err)
+	}
+	trace.AddEvent(ctx, "caught up with the cluster")
+	return context.WithValue(ctx, linearizedKey{}, true), nil
+}
@@ -0,0 +1,42 @@
+package spork
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinearize(t *testing.T) {
+	testCases := map[string]struct {
+		linearizable bool
+		flags        int
+		linearized   bool
+	}{
+		"by default":          {flags: os.O_RDONLY},
+		"O_SYNC":              {flags: os.O_RDONLY | os.O_SYNC, linearized: true},
+		"O_DSYNC":             {flags: os.O_RDONLY | syscall.O_DSYNC},
+		"linearizable reads":  {linearizable: true, flags: os.O_RDONLY, linearized: true},
+		"linearizable O_SYNC": {linearizable: true, flags: os.O_RDWR | os.O_SYNC, linearized: true},
+	}
+
+	s, cleanup := newTestSpork(t)
+	defer cleanup()
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			s.linearizable = tc.linearizable
+			ctx, err := s.linearize(context.Background(), tc.flags)
+			require.NoError(t, err)
+			require.Equal(t, tc.linearized, ctx.Value(linearizedKey{}) != nil)
+
+			// a linearized context isn't linearized again
+			if tc.linearized {
+				again, err := s.linearize(ctx, tc.flags)
+				require.NoError(t, err)
+				require.Equal(t, ctx, again)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package spork
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dimitarvdimitrov/sporkfs/store"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
+)
+
+// Quota returns the quota of the directory. It returns nil if the directory doesn't have one.
+func (s Spork) Quota(ctx context.Context, dir *store.File) (*store.Quota, error) {
+	if err := s.authorize(ctx, dir, store.PermList); err != nil {
+		return nil, err
+	}
+
+	dir.RLock()
+	defer dir.RUnlock()
+
+	return dir.Quota, nil
+}
+
+// SetQuota limits the bytes and files in the directory and its descendants. Limits of 0 mean unlimited and if both
+// are 0 the quota is removed. The current usage may already exceed the new limits, in which case only new writes
+// and files are rejected.
+func (s Spork) SetQuota(ctx context.Context, dir *store.File, maxBytes, maxInodes int64) (err error) {
+	ctx, span := trace.Start(ctx, "spork.SetQuota", trace.Id(dir.Id))
+	defer trace.End(span, &err)
+
+	if dir.Mode&store.ModeDirectory == 0 {
+		return store.ErrNotDirectory
+	}
+	if dir.ReadOnly {
+		return store.ErrReadOnly
+	}
+	if maxBytes < 0 || maxInodes < 0 {
+		return fmt.Errorf("quota limits can't be negative")
+	}
+	if err = s.authorize(ctx, dir, store.PermAdmin); err != nil {
+		return err
+	}
+
+	dir.Lock()
+	defer dir.Unlock()
+	span.AddEvent("acquired file lock")
+
+	callback, err := s.raft.SetQuota(ctx, dir.Id, maxBytes, maxInodes)
+	if err != nil {
+		return fmt.Errorf("couldn't vote quota change in raft: %w", err)
+	}
+	defer callback()
+
+	s.inventory.SetQuota(dir.Id, maxBytes, maxInodes)
+	return nil
+}
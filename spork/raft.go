@@ -1,146 +1,345 @@
 package spork
 
 import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/dimitarvdimitrov/sporkfs/log"
+	"github.com/dimitarvdimitrov/sporkfs/qos"
 	raftpb "github.com/dimitarvdimitrov/sporkfs/raft/pb"
 	"github.com/dimitarvdimitrov/sporkfs/store"
 	"github.com/dimitarvdimitrov/sporkfs/store/data"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
 func (s Spork) watchRaft() {
 	defer s.wg.Done()
 	for entry := range s.commitC {
+		ctx, span := trace.Start(context.Background(), "spork.applyRaftEntry", attribute.String("type", fmt.Sprintf("%T", entry.Message)))
+		at := entryTime(entry.Entry)
+
 		switch msg := entry.Message.(type) {
 		case *raftpb.Entry_Add:
 			req := msg.Add
 			log.Debug("[spork] processing add raft entry", log.Id(req.Id), log.Name(req.Name))
-			parent, err := s.inventory.GetAny(req.ParentId)
-			if err != nil {
+
+			unlock := s.lockFiles(req.ParentId, req.Id)
+			if err := s.applyAdd(req, at); err != nil {
 				log.Error("add raft entry unsuccessful", zap.Error(err))
-				break
 			}
-			parent.Lock()
-
-			// if it's a link we copy everything we know about the file
-			file := s.newFile(req.Name, store.FileMode(req.Mode))
-			file.Id = req.Id
-			if existingFile, err := s.inventory.GetAny(file.Id); err == nil {
-				file.RWMutex = existingFile.RWMutex
-				file.Version = existingFile.Version
-				file.Size = existingFile.Size
-				file.Atime = existingFile.Atime
-				file.Mtime = existingFile.Mtime
+			unlock()
+		case *raftpb.Entry_Copy:
+			req := msg.Copy
+			log.Debug("[spork] processing copy raft entry", log.Id(req.Id), log.Name(req.Name), zap.Uint64("source_id", req.SourceId))
+
+			ids := []uint64{req.ParentId}
+			if targetId, ok := s.childId(req.ParentId, req.Name); ok {
+				ids = append(ids, targetId)
 			}
-			file.Lock()
-
-			// we also need to make sure there isn't the same file locally in case this
-			// is a race condition between changes from different nodes
-			for i, c := range parent.Children {
-				if c.Name == file.Name {
-					parent.Children = append(parent.Children[:i], parent.Children[i+1:]...)
-					parent.Size--
-					s.delete(c)
-					s.deleted <- c
-					log.Debug("[spork] replacing file with same name")
-					break
-				}
+			unlock := s.lockFiles(ids...)
+			if err := s.applyCopy(req, at); err != nil {
+				log.Error("[spork] copy file for raft", zap.Error(err))
 			}
-
-			s.add(file, parent)
-			s.invalid <- parent
-
-			parent.Unlock()
-			file.Unlock()
+			unlock()
 		case *raftpb.Entry_Rename:
 			req := msg.Rename
 			log.Debug("[spork] processing rename raft entry", log.Id(req.Id), zap.String("new_name", req.NewName))
 
-			file, err := s.inventory.GetSpecific(req.Id, req.OldParentId, req.OldName)
-			if err != nil {
-				log.Error("rename file for raft (file)", zap.Error(err))
-				break
-			}
-			oldParent := file.Parent
-			newParent, err := s.inventory.GetAny(req.NewParentId)
-			if err != nil {
-				log.Error("rename file for raft (new parent)", zap.Error(err))
-				break
-			}
-			file.Lock()
-			oldParent.Lock()
-			if oldParent.Id != newParent.Id {
-				newParent.Lock()
+			ids := []uint64{req.Id, req.OldParentId, req.NewParentId}
+			if targetId, ok := s.childId(req.NewParentId, req.NewName); ok {
+				ids = append(ids, targetId)
 			}
-
-			// we copy the file so that the rename below doesn't affect what the invalidator reads
-			oldFile := *file
-			s.invalid <- &oldFile
-
-			s.rename(file, newParent, oldParent, req.NewName)
-
-			file.Unlock()
-			oldParent.Unlock()
-			if oldParent.Id != newParent.Id {
-				newParent.Unlock()
+			unlock := s.lockFiles(ids...)
+			if err := s.applyRename(req, at); err != nil {
+				log.Error("rename file for raft", zap.Error(err))
 			}
+			unlock()
 		case *raftpb.Entry_Delete:
 			req := msg.Delete
 			log.Debug("[spork] processing delete raft entry", log.Id(req.Id))
 
-			file, err := s.inventory.GetSpecific(req.Id, req.ParentId, req.Name)
-			if err != nil {
+			unlock := s.lockFiles(req.Id, req.ParentId)
+			if err := s.applyDelete(req, at); err != nil {
 				log.Error("[spork] delete file for raft", zap.Error(err))
-				break
 			}
-			file.Lock()
-			file.Parent.Lock()
-			s.delete(file)
-			s.deleted <- file
-			file.Unlock()
-			file.Parent.Unlock()
+			unlock()
 		case *raftpb.Entry_Change:
 			req := msg.Change
 			log.Debug("[spork] processing change raft entry", log.Id(req.Id), log.Ver(req.Version), zap.Uint64("from", req.PeerId))
 
-			file, err := s.inventory.GetAny(req.Id)
-			if err != nil {
+			unlock := s.lockFiles(req.Id)
+			span.AddEvent("acquired file lock")
+			if err := s.applyChange(ctx, req, at); err != nil {
 				log.Error("get updated file for raft", zap.Error(err))
-				break
 			}
+			unlock()
+		case *raftpb.Entry_Batch:
+			req := msg.Batch
+			log.Debug("[spork] processing batch raft entry", zap.Int("operations", len(req.Operations)))
 
-			file.Lock()
-			oldVersion := file.Version
-			now := time.Now()
-			s.inventory.SetVersion(file.Id, req.Version)
-			s.inventory.SetSize(file.Id, int64(req.Offset)+req.Size)
-			file.Mtime, file.Atime = now, now
+			if err := s.applyBatch(ctx, req, at); err != nil {
+				log.Warn("[spork] batch raft entry rejected", zap.Error(err))
+			}
+		case *raftpb.Entry_SetAcl:
+			req := msg.SetAcl
+			log.Debug("[spork] processing set acl raft entry", log.Id(req.Id))
 
-			peer := s.peers.GetPeerRaft(req.PeerId)
+			dir, err := s.inventory.GetAny(req.Id)
+			if err != nil {
+				log.Error("[spork] set acl for raft", zap.Error(err))
+				break
+			}
 
-			if s.peers.IsLocalFile(req.Id) || s.cache.ContainsAny(req.Id) {
-				var dest data.Driver = s.cache
-				if s.peers.IsLocalFile(req.Id) {
-					dest = s.data
+			var acl store.ACL
+			if len(req.Acl) > 0 {
+				acl = make(store.ACL, len(req.Acl))
+				for user, perm := range req.Acl {
+					acl[user] = store.Permission(perm)
 				}
+			}
+			dir.Lock()
+			s.inventory.SetACL(dir.Id, acl)
+			dir.Unlock()
+		case *raftpb.Entry_SetQuota:
+			req := msg.SetQuota
+			log.Debug("[spork] processing set quota raft entry", log.Id(req.Id), zap.Int64("max_bytes", req.MaxBytes), zap.Int64("max_inodes", req.MaxInodes))
 
-				if err := s.updateLocalFile(req.Id, oldVersion, req.Version, peer, dest); err != nil {
-					log.Error("[spork] transferring changed file from raft", zap.Error(err))
-				} else {
-					if oldVersion != req.Version {
-						dest.Remove(file.Id, oldVersion)
-					}
-				}
+			dir, err := s.inventory.GetAny(req.Id)
+			if err != nil {
+				log.Error("[spork] set quota for raft", zap.Error(err))
+				break
+			}
+			dir.Lock()
+			s.inventory.SetQuota(dir.Id, req.MaxBytes, req.MaxInodes)
+			dir.Unlock()
+		case *raftpb.Entry_CreateSnapshot:
+			req := msg.CreateSnapshot
+			log.Debug("[spork] processing create snapshot raft entry", log.Id(req.Id), log.Name(req.Name))
+
+			dir, err := s.inventory.GetAny(req.Id)
+			if err != nil {
+				log.Error("[spork] create snapshot for raft", zap.Error(err))
+				break
 			}
+			dir.Lock()
+			s.deleteSnapshot(dir, req.Name) // in case of a race between nodes creating snapshots with the same name
+			s.createSnapshot(dir, req.Name, time.Unix(0, req.Created))
+			dir.Unlock()
+		case *raftpb.Entry_DeleteSnapshot:
+			req := msg.DeleteSnapshot
+			log.Debug("[spork] processing delete snapshot raft entry", log.Id(req.Id), log.Name(req.Name))
 
-			for _, link := range s.inventory.GetAll(file.Id) {
-				s.invalid <- link
+			dir, err := s.inventory.GetAny(req.Id)
+			if err != nil {
+				log.Error("[spork] delete snapshot for raft", zap.Error(err))
+				break
 			}
-			file.Unlock()
+			dir.Lock()
+			s.deleteSnapshot(dir, req.Name)
+			dir.Unlock()
 		}
+		span.End()
 		entry.Action()
 		log.Debug("[spork] finished processing raft entry")
 	}
 }
+
+// entryTime returns the time when the entry was proposed. Entries from before it was recorded are applied at the
+// current time.
+func entryTime(entry *raftpb.Entry) time.Time {
+	if entry.Time == 0 {
+		return time.Now()
+	}
+	return time.Unix(0, entry.Time)
+}
+
+// lockFiles locks the files with the ids in the order of the ids, so that it doesn't deadlock with other calls
+// to it. Links share their lock, so it's only locked once. Files which don't exist are skipped.
+// It returns a function which unlocks them.
+func (s Spork) lockFiles(ids ...uint64) (unlock func()) {
+	sorted := append([]uint64(nil), ids...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var locked []*sync.RWMutex
+	seen := make(map[*sync.RWMutex]bool)
+	for _, id := range sorted {
+		f, err := s.inventory.GetAny(id)
+		if err != nil || seen[f.RWMutex] {
+			continue
+		}
+		seen[f.RWMutex] = true
+		f.Lock()
+		locked = append(locked, f.RWMutex)
+	}
+
+	return func() {
+		for i := len(locked) - 1; i >= 0; i-- {
+			locked[i].Unlock()
+		}
+	}
+}
+
+// childId returns the id of the child of the directory with the name.
+func (s Spork) childId(dirId uint64, name string) (uint64, bool) {
+	dir, err := s.inventory.GetAny(dirId)
+	if err != nil {
+		return 0, false
+	}
+	dir.RLock()
+	defer dir.RUnlock()
+
+	if c := childNamed(dir, name); c != nil {
+		return c.Id, true
+	}
+	return 0, false
+}
+
+// applyAdd adds the file or hard link from the entry, which was proposed at the time. The parent and the file,
+// if it's a link, need to be locked.
+func (s Spork) applyAdd(req *raftpb.Add, at time.Time) error {
+	parent, err := s.inventory.GetAny(req.ParentId)
+	if err != nil {
+		return err
+	}
+	if c := childNamed(parent, req.Name); c != nil && c.Id == req.Id {
+		// the file is already there, e.g. because peers created the trash directory at the same time
+		return nil
+	}
+
+	// if it's a link we copy everything we know about the file
+	file := s.newFile(req.Name, store.FileMode(req.Mode))
+	file.Id = req.Id
+	if existingFile, err := s.inventory.GetAny(file.Id); err == nil {
+		file.RWMutex = existingFile.RWMutex
+		file.Version = existingFile.Version
+		file.Size = existingFile.Size
+		file.Atime = existingFile.Atime
+		file.Mtime = existingFile.Mtime
+	}
+
+	s.replaceChild(parent, file, at)
+	s.invalid <- parent
+	return nil
+}
+
+// applyCopy adds the copy from the entry, which was proposed at the time, and copies the content if this peer holds
+// it. A file which already has the name is replaced, unless it can't be, e.g. because it's a directory. The parent
+// and the replaced file need to be locked.
+func (s Spork) applyCopy(req *raftpb.Copy, at time.Time) error {
+	parent, err := s.inventory.GetAny(req.ParentId)
+	if err != nil {
+		return err
+	}
+
+	file := s.newFile(req.Name, store.FileMode(req.Mode))
+	file.Id = req.Id
+	file.Version = req.Version
+	file.Size = req.Size
+	file.Lock()
+	defer file.Unlock()
+
+	if target := childNamed(parent, req.Name); target != nil {
+		if err := checkReplace(file, target); err != nil {
+			return fmt.Errorf("replacing %s: %w", req.Name, err)
+		}
+	}
+	s.replaceChild(parent, file, at)
+	s.invalid <- parent
+
+	if s.peers.IsLocalFile(file.Id) {
+		if err := s.copyDataLater(req.SourceId, req.SourceVersion, file.Id, file.Version, file.Size); err != nil {
+			log.Error("[spork] copying file from raft", log.Id(file.Id), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// applyRename moves the file from the entry and replaces the file which already has the new name, if there is one.
+// If that file can't be replaced anymore, e.g. because it's a directory which isn't empty, the entry is rejected.
+// The entry was proposed at the time. The file, both parents and the replaced file need to be locked.
+func (s Spork) applyRename(req *raftpb.Rename, at time.Time) error {
+	file, err := s.inventory.GetSpecific(req.Id, req.OldParentId, req.OldName)
+	if err != nil {
+		return fmt.Errorf("file: %w", err)
+	}
+	oldParent := file.Parent
+	newParent, err := s.inventory.GetAny(req.NewParentId)
+	if err != nil {
+		return fmt.Errorf("new parent: %w", err)
+	}
+
+	if target := childNamed(newParent, req.NewName); target != nil && target.Id != file.Id {
+		// the target may have changed since the rename was proposed, e.g. a file may have been created in it
+		if err := checkReplace(file, target); err != nil {
+			return fmt.Errorf("replacing %s: %w", req.NewName, err)
+		}
+		s.delete(target, at)
+		s.deleted <- target
+	}
+
+	// we copy the file so that the rename below doesn't affect what the invalidator reads
+	oldFile := *file
+	s.invalid <- &oldFile
+
+	s.rename(file, newParent, oldParent, req.NewName)
+	return nil
+}
+
+// applyDelete deletes the file from the entry, with everything in it if the entry is recursive. The entry was
+// proposed at the time. The file and its parent need to be locked.
+func (s Spork) applyDelete(req *raftpb.Delete, at time.Time) error {
+	file, err := s.inventory.GetSpecific(req.Id, req.ParentId, req.Name)
+	if err != nil {
+		return err
+	}
+	if req.Recursive {
+		s.removeVersionsLater(s.deleteTree(file, at))
+	} else {
+		s.delete(file, at)
+	}
+	s.deleted <- file
+	return nil
+}
+
+// applyChange sets the version from the entry and fetches it if this peer holds the file. The entry was proposed
+// at the time. The file needs to be locked.
+func (s Spork) applyChange(ctx context.Context, req *raftpb.Change, at time.Time) error {
+	file, err := s.inventory.GetAny(req.Id)
+	if err != nil {
+		return err
+	}
+
+	oldVersion := file.Version
+	if oldVersion != req.Version {
+		s.history.Forget(file.Id, req.Version)
+		s.retire(file, at)
+	}
+	s.inventory.SetVersion(file.Id, req.Version)
+	s.inventory.SetSize(file.Id, int64(req.Offset)+req.Size)
+	file.Mtime, file.Atime = at, at
+
+	peer := s.peers.GetPeerRaft(req.PeerId)
+
+	if s.peers.IsLocalFile(req.Id) || s.cache.ContainsAny(req.Id) {
+		var dest data.Driver = s.cache
+		class := qos.CacheFill
+		if s.peers.IsLocalFile(req.Id) {
+			dest = s.data
+			class = qos.Replication
+		}
+
+		if err := s.updateLocalFile(qos.WithClass(ctx, class), req.Id, oldVersion, req.Version, int64(req.Offset)+req.Size, peer, dest); err != nil {
+			log.Error("[spork] transferring changed file from raft", zap.Error(err))
+		}
+	}
+
+	for _, link := range s.inventory.GetAll(file.Id) {
+		s.invalid <- link
+	}
+	return nil
+}
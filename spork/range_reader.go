@@ -0,0 +1,183 @@
+package spork
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/dimitarvdimitrov/sporkfs/log"
+	"github.com/dimitarvdimitrov/sporkfs/store/data"
+	"github.com/dimitarvdimitrov/sporkfs/store/remote"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	minReadAhead = 2 * data.BlockSize
+	maxReadAhead = 64 * data.BlockSize
+)
+
+// rangeReader reads a remote file which is only partially cached. Blocks which aren't cached are fetched
+// from the peers holding the file the first time they are read. Reads return as soon as the blocks they need
+// have arrived.
+//
+// When the file is read sequentially, the reader fetches the blocks after the last read in the background.
+// The read-ahead window doubles with every sequential read, up to maxReadAhead, and is reset by a random read.
+type rangeReader struct {
+	ctx         context.Context
+	cancel      context.CancelFunc
+	id, version uint64
+
+	file    data.PartialFile
+	fetcher remote.Readerer
+
+	m           sync.Mutex
+	lastReadEnd int64
+	readAhead   int64
+	prefetching bool
+	wg          sync.WaitGroup
+}
+
+func newRangeReader(ctx context.Context, id, version uint64, file data.PartialFile, fetcher remote.Readerer) *rangeReader {
+	ctx, cancel := context.WithCancel(trace.Detach(ctx))
+	return &rangeReader{
+		ctx:     ctx,
+		cancel:  cancel,
+		id:      id,
+		version: version,
+		file:    file,
+		fetcher: fetcher,
+	}
+}
+
+func (r *rangeReader) ReadAt(p []byte, off int64) (int, error) {
+	size := r.file.Size()
+	if off >= size {
+		return 0, io.EOF
+	}
+	length := int64(len(p))
+
+	r.maybeReadAhead(off, length)
+	if err := r.ensure(off, length); err != nil {
+		return 0, err
+	}
+
+	n, err := r.file.ReadAt(p, off)
+	if err == nil && off+int64(n) == size {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	return r.ReadAt(p, 0)
+}
+
+func (r *rangeReader) Close() error {
+	r.cancel()
+	r.wg.Wait()
+	return r.file.Close()
+}
+
+// ensure blocks until [off, off+length) is present locally, fetching any blocks which no one else is fetching.
+func (r *rangeReader) ensure(off, length int64) error {
+	for {
+		for _, claimed := range r.file.Claim(off, length) {
+			if err := r.fetch(claimed); err != nil {
+				return fmt.Errorf("fetching range of id:%d, version:%d, err:%w", r.id, r.version, err)
+			}
+		}
+		// someone else may have given up on fetching a block we need, so we try to claim it ourselves
+		if r.file.Wait(off, length) {
+			return nil
+		}
+	}
+}
+
+// maybeReadAhead starts fetching the blocks following the read in the background if the read is sequential.
+func (r *rangeReader) maybeReadAhead(off, length int64) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if off == r.lastReadEnd {
+		r.readAhead *= 2
+		if r.readAhead < minReadAhead {
+			r.readAhead = minReadAhead
+		}
+		if r.readAhead > maxReadAhead {
+			r.readAhead = maxReadAhead
+		}
+	} else {
+		r.readAhead = 0
+	}
+	r.lastReadEnd = off + length
+
+	if r.readAhead == 0 || r.prefetching {
+		return
+	}
+
+	claimed := r.file.Claim(off+length, r.readAhead)
+	if len(claimed) == 0 {
+		return
+	}
+
+	r.prefetching = true
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		for _, rng := range claimed {
+			if err := r.fetch(rng); err != nil {
+				log.Debug("[spork] read-ahead failed", log.Id(r.id), log.Ver(r.version), zap.Error(err))
+			}
+		}
+		r.m.Lock()
+		r.prefetching = false
+		r.m.Unlock()
+	}()
+}
+
+// fetch writes the claimed range to the partial file. If it fails, the part of the range that wasn't written
+// is unclaimed.
+func (r *rangeReader) fetch(rng data.Range) (err error) {
+	ctx, span := trace.Start(r.ctx, "spork.rangeReader.fetch",
+		trace.Id(r.id),
+		trace.Ver(r.version),
+		attribute.Int64("offset", rng.Off),
+		attribute.Int64("length", rng.Len),
+	)
+	defer trace.End(span, &err)
+	log.Debug("[spork] fetching remote range", log.Id(r.id), log.Ver(r.version), zap.Int64("offset", rng.Off), zap.Int64("length", rng.Len))
+
+	written := int64(0)
+	defer func() {
+		if err != nil {
+			r.file.Unclaim(data.Range{Off: rng.Off + written, Len: rng.Len - written})
+		}
+	}()
+
+	src, err := r.fetcher.RangeReader(ctx, r.id, r.version, rng.Off, rng.Len)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// blocks are only marked as present once they are fully written, so we write one block at a time
+	// which also lets waiting readers continue before the whole range has arrived
+	buff := make([]byte, data.BlockSize)
+	for written < rng.Len {
+		block := buff
+		if remaining := rng.Len - written; remaining < int64(len(block)) {
+			block = block[:remaining]
+		}
+		if _, err = io.ReadFull(src, block); err != nil {
+			return err
+		}
+		if _, err = r.file.WriteAt(block, rng.Off+written); err != nil {
+			return err
+		}
+		written += int64(len(block))
+	}
+	return nil
+}
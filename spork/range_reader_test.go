@@ -0,0 +1,214 @@
+package spork
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/dimitarvdimitrov/sporkfs/store/data"
+	"github.com/dimitarvdimitrov/sporkfs/store/remote"
+	"github.com/stretchr/testify/require"
+)
+
+// rangeFetcher serves ranges of content and records which bytes were fetched.
+type rangeFetcher struct {
+	remote.Readerer
+	content []byte
+
+	m       sync.Mutex
+	fetched []int // the number of times each byte was fetched
+}
+
+func (f *rangeFetcher) RangeReader(_ context.Context, _, _ uint64, off, length int64) (io.ReadCloser, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	for i := off; i < off+length; i++ {
+		f.fetched[i]++
+	}
+	return ioutil.NopCloser(bytes.NewReader(f.content[off : off+length])), nil
+}
+
+func TestRangeReader(t *testing.T) {
+	const size = 8*data.BlockSize + 100
+	type read struct {
+		off, len int64
+		// readAhead is the read-ahead window after the read
+		readAhead int64
+	}
+	testCases := map[string][]read{
+		"sequential": {
+			{off: 0, len: 100, readAhead: minReadAhead},
+			{off: 100, len: 100, readAhead: 2 * minReadAhead},
+			{off: 200, len: data.BlockSize, readAhead: 4 * minReadAhead},
+		},
+		"random": {
+			{off: 3 * data.BlockSize, len: 100},
+			{off: 10, len: 100},
+		},
+		"random after sequential": {
+			{off: 0, len: 100, readAhead: minReadAhead},
+			{off: 100, len: 100, readAhead: 2 * minReadAhead},
+			{off: 5 * data.BlockSize, len: 100},
+			{off: 5*data.BlockSize + 100, len: 100, readAhead: minReadAhead},
+		},
+		"up to the maximum": {
+			{off: 0, len: 1, readAhead: minReadAhead},
+			{off: 1, len: 1, readAhead: 2 * minReadAhead},
+			{off: 2, len: 1, readAhead: 4 * minReadAhead},
+			{off: 3, len: 1, readAhead: 8 * minReadAhead},
+			{off: 4, len: 1, readAhead: 16 * minReadAhead},
+			{off: 5, len: 1, readAhead: maxReadAhead},
+			{off: 6, len: 1, readAhead: maxReadAhead},
+		},
+		"the end": {
+			{off: size - 10, len: 100},
+		},
+	}
+
+	for name, reads := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "sporkfs-range-reader")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+			d, err := data.NewLocalDriver(dir)
+			require.NoError(t, err)
+			file, err := d.Partial(1, 1, size)
+			require.NoError(t, err)
+
+			content := make([]byte, size)
+			rand.New(rand.NewSource(1)).Read(content)
+			fetcher := &rangeFetcher{content: content, fetched: make([]int, size)}
+			r := newRangeReader(context.Background(), 1, 1, file, fetcher)
+
+			for _, rd := range reads {
+				p := make([]byte, rd.len)
+				n, err := r.ReadAt(p, rd.off)
+				if rd.off+rd.len >= size {
+					require.Equal(t, io.EOF, err)
+				} else {
+					require.NoError(t, err)
+				}
+				require.Equal(t, content[rd.off:rd.off+int64(n)], p[:n])
+				require.Equal(t, rd.readAhead, r.readAhead)
+			}
+			require.NoError(t, r.Close())
+
+			for i, times := range fetcher.fetched {
+				if times > 1 {
+					t.Fatalf("byte %d was fetched %d times", i, times)
+				}
+			}
+		})
+	}
+}
+
+// streamFetcher streams ranges of content one block for every value sent on blocks, until the context is cancelled.
+type streamFetcher struct {
+	remote.Readerer
+	content []byte
+	blocks  chan struct{}
+}
+
+func (f *streamFetcher) RangeReader(ctx context.Context, _, _ uint64, off, length int64) (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		for rest := f.content[off : off+length]; len(rest) > 0; {
+			select {
+			case <-ctx.Done():
+				pw.CloseWithError(ctx.Err())
+				return
+			case <-f.blocks:
+			}
+			n := len(rest)
+			if n > data.BlockSize {
+				n = data.BlockSize
+			}
+			if _, err := pw.Write(rest[:n]); err != nil {
+				return
+			}
+			rest = rest[n:]
+		}
+		pw.Close()
+	}()
+	return pr, nil
+}
+
+func TestRangeReaderStreams(t *testing.T) {
+	const size = 4 * data.BlockSize
+	dir, err := ioutil.TempDir("", "sporkfs-range-reader")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	d, err := data.NewLocalDriver(dir)
+	require.NoError(t, err)
+	file, err := d.Partial(1, 1, size)
+	require.NoError(t, err)
+
+	content := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(content)
+	fetcher := &streamFetcher{content: content, blocks: make(chan struct{})}
+	r := newRangeReader(context.Background(), 1, 1, file, fetcher)
+
+	// the read returns once the first block has arrived while the read-ahead is still transferring
+	read := make(chan error)
+	p := make([]byte, 100)
+	go func() {
+		_, err := r.ReadAt(p, 0)
+		read <- err
+	}()
+	fetcher.blocks <- struct{}{}
+	require.NoError(t, <-read)
+	require.Equal(t, content[:100], p)
+	r.m.Lock()
+	require.True(t, r.prefetching)
+	r.m.Unlock()
+
+	// closing the reader stops the transfer
+	require.NoError(t, r.Close())
+}
+
+// failingFetcher fails the first fetch and serves ranges of content afterwards.
+type failingFetcher struct {
+	rangeFetcher
+	failed bool
+}
+
+func (f *failingFetcher) RangeReader(ctx context.Context, id, version uint64, off, length int64) (io.ReadCloser, error) {
+	if !f.failed {
+		f.failed = true
+		return nil, errors.New("unavailable")
+	}
+	return f.rangeFetcher.RangeReader(ctx, id, version, off, length)
+}
+
+func TestRangeReaderFetchFailed(t *testing.T) {
+	const size = 2 * data.BlockSize
+	dir, err := ioutil.TempDir("", "sporkfs-range-reader")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	d, err := data.NewLocalDriver(dir)
+	require.NoError(t, err)
+	file, err := d.Partial(1, 1, size)
+	require.NoError(t, err)
+
+	content := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(content)
+	fetcher := &failingFetcher{rangeFetcher: rangeFetcher{content: content, fetched: make([]int, size)}}
+	r := newRangeReader(context.Background(), 1, 1, file, fetcher)
+	defer r.Close()
+
+	// a random read doesn't read ahead, so the failed fetch is the read's own
+	p := make([]byte, 100)
+	_, err = r.ReadAt(p, data.BlockSize)
+	require.Error(t, err)
+
+	// the range was unclaimed, so the next read fetches it again
+	_, err = r.ReadAt(p, data.BlockSize)
+	require.NoError(t, err)
+	require.Equal(t, content[data.BlockSize:data.BlockSize+100], p)
+}
@@ -0,0 +1,186 @@
+package spork
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dimitarvdimitrov/sporkfs/store"
+	storedata "github.com/dimitarvdimitrov/sporkfs/store/data"
+	"github.com/dimitarvdimitrov/sporkfs/store/inventory"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
+)
+
+// Snapshots returns the snapshots of the directory from the oldest to the newest.
+func (s Spork) Snapshots(ctx context.Context, dir *store.File) ([]*store.Snapshot, error) {
+	if err := s.authorize(ctx, dir, store.PermList); err != nil {
+		return nil, err
+	}
+
+	dir.RLock()
+	defer dir.RUnlock()
+
+	return append([]*store.Snapshot(nil), dir.Snapshots...), nil
+}
+
+// CreateSnapshot takes a snapshot of the directory and all of its descendants. The snapshot is accessible under
+// store.SnapshotsDirName in the directory. The versions of files in it are kept until it's deleted.
+func (s Spork) CreateSnapshot(ctx context.Context, dir *store.File, name string) (err error) {
+	ctx, span := trace.Start(ctx, "spork.CreateSnapshot", trace.Id(dir.Id), trace.Name(name))
+	defer trace.End(span, &err)
+
+	if dir.Mode&store.ModeDirectory == 0 {
+		return store.ErrNotDirectory
+	}
+	if dir.ReadOnly {
+		return store.ErrReadOnly
+	}
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid snapshot name %q", name)
+	}
+	if err = s.authorize(ctx, dir, store.PermAdmin); err != nil {
+		return err
+	}
+
+	dir.Lock()
+	defer dir.Unlock()
+	span.AddEvent("acquired file lock")
+
+	for _, snap := range dir.Snapshots {
+		if snap.Name == name {
+			return store.ErrFileAlreadyExists
+		}
+	}
+
+	created := time.Now()
+	callback, err := s.raft.CreateSnapshot(ctx, dir.Id, name, created)
+	if err != nil {
+		return fmt.Errorf("couldn't vote snapshot creation in raft: %w", err)
+	}
+	defer callback()
+
+	s.createSnapshot(dir, name, created)
+	return nil
+}
+
+// DeleteSnapshot deletes the snapshot of the directory. Versions which were only kept for it are removed.
+func (s Spork) DeleteSnapshot(ctx context.Context, dir *store.File, name string) (err error) {
+	ctx, span := trace.Start(ctx, "spork.DeleteSnapshot", trace.Id(dir.Id), trace.Name(name))
+	defer trace.End(span, &err)
+
+	if err = s.authorize(ctx, dir, store.PermAdmin); err != nil {
+		return err
+	}
+
+	dir.Lock()
+	defer dir.Unlock()
+	span.AddEvent("acquired file lock")
+
+	found := false
+	for _, snap := range dir.Snapshots {
+		if snap.Name == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return store.ErrNoSuchFile
+	}
+
+	callback, err := s.raft.DeleteSnapshot(ctx, dir.Id, name)
+	if err != nil {
+		return fmt.Errorf("couldn't vote snapshot deletion in raft: %w", err)
+	}
+	defer callback()
+
+	s.deleteSnapshot(dir, name)
+	return nil
+}
+
+// createSnapshot adds the snapshot to the directory and pins its versions. dir needs to be locked.
+func (s Spork) createSnapshot(dir *store.File, name string, created time.Time) {
+	snap := store.NewSnapshot(dir, name, created)
+	dir.Snapshots = append(dir.Snapshots, snap)
+	for id, version := range snap.Versions() {
+		s.data.Pin(id, version)
+	}
+}
+
+// deleteSnapshot removes the snapshot from the directory and unpins its versions. dir needs to be locked.
+func (s Spork) deleteSnapshot(dir *store.File, name string) {
+	for i, snap := range dir.Snapshots {
+		if snap.Name != name {
+			continue
+		}
+		dir.Snapshots = append(dir.Snapshots[:i], dir.Snapshots[i+1:]...)
+		for id, version := range snap.Versions() {
+			s.data.Unpin(id, version)
+		}
+		return
+	}
+}
+
+// inventoryState is the inventory as a raft state. Pins aren't part of it, so the pins of the versions in
+// snapshots are rebuilt whenever the inventory is replaced.
+type inventoryState struct {
+	*inventory.Driver
+	data *storedata.PinningDriver
+}
+
+func (i inventoryState) SetState(r io.Reader) error {
+	if err := i.Driver.SetState(r); err != nil {
+		return err
+	}
+	i.data.SetPins(snapshotPins(i.Root()))
+	return nil
+}
+
+// snapshotPins counts how many snapshots of the directory and its descendants keep each version.
+func snapshotPins(dir *store.File) map[uint64]map[uint64]int {
+	pins := make(map[uint64]map[uint64]int)
+	var walk func(f *store.File)
+	walk = func(f *store.File) {
+		f.RLock()
+		snapshots, children := f.Snapshots, f.Children
+		f.RUnlock()
+
+		for _, snap := range snapshots {
+			for id, version := range snap.Versions() {
+				if pins[id] == nil {
+					pins[id] = make(map[uint64]int)
+				}
+				pins[id][version]++
+			}
+		}
+		for _, c := range children {
+			walk(c)
+		}
+	}
+	walk(dir)
+	return pins
+}
+
+// snapshotsDir returns the virtual directory which holds the snapshots of dir. dir needs to be at least read-locked.
+// It has an id of its own, so that changes to it can't end up changing dir.
+func snapshotsDir(dir *store.File) *store.File {
+	inode := store.SnapshotsDirInode(dir.Id)
+	d := &store.File{
+		RWMutex:  &sync.RWMutex{},
+		Id:       inode,
+		Inode:    inode,
+		Name:     store.SnapshotsDirName,
+		Mode:     store.ModeDirectory | 0555,
+		Size:     int64(len(dir.Snapshots)),
+		Atime:    dir.Atime,
+		Mtime:    dir.Mtime,
+		Parent:   dir,
+		ReadOnly: true,
+	}
+	for _, snap := range dir.Snapshots {
+		d.Children = append(d.Children, snap.Root)
+	}
+	return d
+}
@@ -0,0 +1,65 @@
+package spork
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dimitarvdimitrov/sporkfs/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotsReadOnly(t *testing.T) {
+	s, cleanup := newTestSpork(t)
+	defer cleanup()
+	ctx := context.Background()
+	dir := createTestFile(t, s, s.Root(), "dir", true)
+	f := createTestFile(t, s, dir, "f", false)
+	require.NoError(t, s.CreateSnapshot(ctx, dir, "snap"))
+
+	snapshots := lookupTestFile(t, s, "dir", store.SnapshotsDirName)
+	snapshot := lookupTestFile(t, s, "dir", store.SnapshotsDirName, "snap")
+	copied := lookupTestFile(t, s, "dir", store.SnapshotsDirName, "snap", "f")
+	require.NotEqual(t, dir.Id, snapshots.Id)
+	_, err := s.inventory.GetAny(snapshots.Id)
+	require.Equal(t, store.ErrNoSuchFile, err)
+
+	testCases := map[string]func(dir *store.File) error{
+		"set acl": func(dir *store.File) error {
+			return s.SetACL(ctx, dir, store.ACL{store.Everyone: store.PermRead})
+		},
+		"set quota": func(dir *store.File) error {
+			return s.SetQuota(ctx, dir, 1, 1)
+		},
+		"create file": func(dir *store.File) error {
+			_, err := s.CreateFile(ctx, dir, "new", store.ModeRegularFile)
+			return err
+		},
+		"create snapshot": func(dir *store.File) error {
+			return s.CreateSnapshot(ctx, dir, "other")
+		},
+		"rename into": func(dir *store.File) error {
+			return s.Rename(ctx, f, f.Parent, dir, "moved")
+		},
+		"copy into": func(dir *store.File) error {
+			_, err := s.Copy(ctx, f, dir, "copied")
+			return err
+		},
+	}
+
+	for name, change := range testCases {
+		t.Run(name, func(t *testing.T) {
+			for _, d := range []*store.File{snapshots, snapshot} {
+				require.Equal(t, store.ErrReadOnly, change(d), d.Name)
+			}
+		})
+	}
+	require.Equal(t, store.ErrReadOnly, s.Delete(ctx, copied))
+
+	// nothing changed in the directory
+	acl, inherited := effectiveACL(dir)
+	require.True(t, inherited)
+	require.Equal(t, store.ACL{store.Everyone: store.PermAll}, acl)
+	require.Nil(t, dir.Quota)
+	require.Equal(t, f.Id, lookupTestFile(t, s, "dir", "f").Id)
+	require.Len(t, dir.Snapshots, 1)
+}
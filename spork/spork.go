@@ -3,7 +3,6 @@ package spork
 import (
 	"context"
 	"fmt"
-	"io"
 	"math/rand"
 	"net"
 	"os"
@@ -12,23 +11,40 @@ import (
 
 	"github.com/dimitarvdimitrov/sporkfs/api"
 	proto "github.com/dimitarvdimitrov/sporkfs/api/pb"
+	"github.com/dimitarvdimitrov/sporkfs/auth"
+	"github.com/dimitarvdimitrov/sporkfs/crypt"
 	"github.com/dimitarvdimitrov/sporkfs/log"
+	"github.com/dimitarvdimitrov/sporkfs/mtls"
+	"github.com/dimitarvdimitrov/sporkfs/qos"
 	"github.com/dimitarvdimitrov/sporkfs/raft"
 	raftpb "github.com/dimitarvdimitrov/sporkfs/raft/pb"
 	"github.com/dimitarvdimitrov/sporkfs/store"
 	storedata "github.com/dimitarvdimitrov/sporkfs/store/data"
 	"github.com/dimitarvdimitrov/sporkfs/store/data/cache"
+	"github.com/dimitarvdimitrov/sporkfs/store/data/compressed"
+	"github.com/dimitarvdimitrov/sporkfs/store/history"
 	"github.com/dimitarvdimitrov/sporkfs/store/inventory"
 	"github.com/dimitarvdimitrov/sporkfs/store/remote"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 )
 
 type Spork struct {
-	inventory        inventory.Driver
-	data             storedata.Driver
-	cache            cache.Cache
+	// ctx is done once the node is stopping. Background work which outlives the calls that started it uses it.
+	ctx       context.Context
+	inventory inventory.Driver
+	// data keeps the versions which are in snapshots
+	data  *storedata.PinningDriver
+	cache cache.Cache
+	// history keeps the retained versions of superseded and deleted files
+	history *history.History
+	// trash is how long deleted files are kept in TrashDirName; 0 means they are deleted right away
+	trash time.Duration
+	// linearizable is whether all reads see the writes which completed on any node before them, instead of only
+	// the ones which this node has applied
+	linearizable     bool
 	invalid, deleted chan<- *store.File
 
 	peers   *raft.Peers
@@ -39,58 +55,136 @@ type Spork struct {
 	wg      *sync.WaitGroup
 }
 
-func New(ctx context.Context, cancel context.CancelFunc, cfg Config, invalid, deleted chan<- *store.File) (Spork, error) {
-	data, err := storedata.NewLocalDriver(cfg.DataDir + "/data")
+func New(ctx context.Context, cancel context.CancelFunc, cfg Config, invalid, deleted chan<- *store.File) (_ Spork, err error) {
+	var key *crypt.Key
+	if cfg.KeyFile != "" {
+		if key, err = crypt.LoadKey(cfg.KeyFile); err != nil {
+			return Spork{}, fmt.Errorf("init encryption: %s", err)
+		}
+		cfg.Config.Key = key
+	}
+	if cfg.Config.Credentials, err = mtls.Load(cfg.TLS, cfg.AllPeers); err != nil {
+		return Spork{}, fmt.Errorf("init tls: %s", err)
+	}
+
+	localData, err := newDataDriver(cfg.DataDir+"/data", cfg.StorageCompression, key)
 	if err != nil {
 		return Spork{}, fmt.Errorf("init data driver: %s", err)
 	}
-	cacheData, err := storedata.NewLocalDriver(cfg.DataDir + "/cache")
+	data := storedata.NewPinningDriver(localData)
+	cacheData, err := newDataDriver(cfg.DataDir+"/cache", cfg.StorageCompression, key)
 	if err != nil {
 		return Spork{}, fmt.Errorf("init data driver: %s", err)
 	}
-	c := cache.New(cacheData)
+	c := cache.New(cacheData, cfg.CacheSize, cfg.DataDir+"/cache.json")
 
 	inv, err := inventory.NewDriver()
 	if err != nil {
 		return Spork{}, fmt.Errorf("init inventory: %s", err)
 	}
 
-	r, commits, peers := raft.New(cfg.Config, inv)
-	fetcher, err := remote.NewFetcher(peers)
+	// the history is replicated with the inventory, so that all peers retain the same versions
+	hist := history.New(cfg.Retention)
+	r, commits, peers := raft.New(cfg.Config, inventoryState{Driver: &inv, data: data}, hist)
+	limiter := qos.NewLimiter(cfg.QoS)
+	fetcher, err := remote.NewFetcher(peers, limiter, cfg.Compression, cfg.Credentials)
 	if err != nil {
 		return Spork{}, fmt.Errorf("init fetcher: %s", err)
 	}
 
 	s := Spork{
-		inventory: inv,
-		data:      data,
-		cache:     c,
-		fetcher:   fetcher,
-		peers:     peers,
-		raft:      r,
-		commitC:   commits,
-		invalid:   invalid,
-		deleted:   deleted,
-		wg:        &sync.WaitGroup{},
-	}
-	startGrpcServer(ctx, cancel, cfg.Config.ThisPeer, data, c, r, s.wg)
-	s.wg.Add(1)
+		ctx:          ctx,
+		inventory:    inv,
+		data:         data,
+		cache:        c,
+		history:      hist,
+		trash:        cfg.Retention.Trash.Duration,
+		linearizable: cfg.LinearizableReads,
+		fetcher:      fetcher,
+		peers:        peers,
+		raft:         r,
+		commitC:      commits,
+		invalid:      invalid,
+		deleted:      deleted,
+		wg:           &sync.WaitGroup{},
+	}
+	startGrpcServer(ctx, cancel, cfg.Config.ThisPeer, cfg.Credentials, cfg.DataDir, data, c, r, limiter, s.wg)
+	if cfg.ClientAddr != "" {
+		if len(cfg.Auth.Tokens) != 0 && !cfg.TLS.Enabled() {
+			log.Warn("[spork] the client API rejects all tokens because [tls] isn't configured; they would be sent in plain text")
+		}
+		startClientServer(ctx, cancel, cfg.ClientAddr, cfg.Credentials, auth.New(cfg.Auth), s)
+	}
+	s.wg.Add(2)
 	go s.watchRaft()
+	go s.pruneCache(ctx)
+	if cfg.Retention.MaxAge.Duration > 0 {
+		s.wg.Add(1)
+		go s.expireVersions(ctx)
+	}
+	if s.trash > 0 {
+		s.wg.Add(1)
+		go s.purgeTrash(ctx)
+	}
 
 	return s, nil
 }
 
-func startGrpcServer(ctx context.Context, cancel context.CancelFunc, listenAddr string, data, cache storedata.Driver, raft *raft.Raft, wg *sync.WaitGroup) {
-	lis, err := net.Listen("tcp", listenAddr)
+// newDataDriver returns a driver which keeps files in dir, compressed with the algorithm and encrypted with the key
+// if they are set.
+func newDataDriver(dir, compression string, key *crypt.Key) (storedata.Driver, error) {
+	newLocal := func(dir string) (storedata.Driver, error) {
+		if key != nil {
+			return storedata.NewEncryptedLocalDriver(dir, key)
+		}
+		return storedata.NewLocalDriver(dir)
+	}
+
+	local, err := newLocal(dir)
 	if err != nil {
-		log.Fatal("failed to listen", zap.Error(err))
+		return nil, err
+	}
+	// compressed versions are kept separately, so that they stay readable after compression is disabled
+	compressedLocal, err := newLocal(dir + "-compressed")
+	if err != nil {
+		return nil, err
 	}
-	grpcServer := grpc.NewServer()
+	var scratch storedata.Driver
+	if compression != "" {
+		if scratch, err = newLocal(dir + "-scratch"); err != nil {
+			return nil, err
+		}
+	}
+	return compressed.New(local, compressedLocal, scratch, compression)
+}
+
+func startGrpcServer(ctx context.Context, cancel context.CancelFunc, listenAddr string, creds mtls.Credentials, dataDir string, data, cache storedata.Driver, raft *raft.Raft, limiter *qos.Limiter, wg *sync.WaitGroup) {
+	grpcServer := grpc.NewServer(append(creds.ServerOptions(), trace.ServerOption())...)
 
 	reflection.Register(grpcServer)
-	proto.RegisterFileServer(grpcServer, api.NewFileServer(data, cache))
+	proto.RegisterFileServer(grpcServer, api.NewFileServer(data, cache, limiter, dataDir))
 	raftpb.RegisterRaftServer(grpcServer, raft)
 
+	serveGrpc(ctx, cancel, listenAddr, grpcServer, wg)
+}
+
+// startClientServer serves the client API. Each call is authenticated and made on behalf of the authenticated user.
+func startClientServer(ctx context.Context, cancel context.CancelFunc, listenAddr string, creds mtls.Credentials, authenticator auth.Authenticator, s Spork) {
+	opts := append(creds.ClientOptions(), authenticator.ServerOptions()...)
+	grpcServer := grpc.NewServer(append(opts, trace.ServerOption())...)
+
+	reflection.Register(grpcServer)
+	proto.RegisterClientServer(grpcServer, api.NewClientServer(s))
+
+	serveGrpc(ctx, cancel, listenAddr, grpcServer, s.wg)
+}
+
+func serveGrpc(ctx context.Context, cancel context.CancelFunc, listenAddr string, grpcServer *grpc.Server, wg *sync.WaitGroup) {
+	lis, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		log.Fatal("failed to listen", zap.Error(err))
+	}
+
 	wg.Add(1)
 	go func() {
 		if err := grpcServer.Serve(lis); err != nil {
@@ -109,11 +203,53 @@ func startGrpcServer(ctx context.Context, cancel context.CancelFunc, listenAddr
 	}()
 }
 
+// pruneCache removes cached versions which were superseded while this node was down. It waits until the raft
+// log has been replayed so that the inventory knows about the latest versions.
+func (s Spork) pruneCache(ctx context.Context) {
+	defer s.wg.Done()
+
+	select {
+	case <-s.raft.Replayed():
+	case <-ctx.Done():
+		return
+	}
+
+	isCurrent := func(id, version uint64) bool {
+		f, err := s.inventory.GetAny(id)
+		if err != nil {
+			return false
+		}
+		f.RLock()
+		defer f.RUnlock()
+		return f.Version == version || s.data.Pinned(id, version) || s.history.Retained(id, version)
+	}
+	s.cache.Prune(isCurrent)
+
+	// transfers of superseded versions of local files will never be resumed
+	for id, versions := range s.data.PartialVersions() {
+		for _, version := range versions {
+			if !isCurrent(id, version) {
+				s.data.Remove(id, version)
+			}
+		}
+	}
+}
+
 func (s Spork) Root() *store.File {
 	return s.inventory.Root()
 }
 
-func (s Spork) Lookup(f *store.File, name string) (*store.File, error) {
+func (s Spork) Lookup(ctx context.Context, f *store.File, name string) (_ *store.File, err error) {
+	ctx, span := trace.Start(ctx, "spork.Lookup", trace.Id(f.Id), trace.Name(name))
+	defer trace.End(span, &err)
+
+	if err = s.authorize(ctx, f, store.PermList); err != nil {
+		return nil, err
+	}
+	if _, err = s.linearize(ctx, 0); err != nil {
+		return nil, err
+	}
+
 	f.RLock()
 	defer f.RUnlock()
 
@@ -122,15 +258,39 @@ func (s Spork) Lookup(f *store.File, name string) (*store.File, error) {
 			return c, nil
 		}
 	}
+	if name == store.SnapshotsDirName && f.Mode&store.ModeDirectory != 0 && !f.ReadOnly {
+		return snapshotsDir(f), nil
+	}
+	if name == history.DirName && f.Mode&store.ModeDirectory != 0 && !f.ReadOnly {
+		return s.versionsDir(f), nil
+	}
 	return nil, store.ErrNoSuchFile
 }
 
-func (s Spork) ReadWriter(f *store.File, flags int) (ReadWriteCloser, error) {
+func (s Spork) ReadWriter(ctx context.Context, f *store.File, flags int) (_ ReadWriteCloser, err error) {
+	ctx, span := trace.Start(ctx, "spork.ReadWriter", trace.Id(f.Id))
+	defer trace.End(span, &err)
+
+	if f.ReadOnly {
+		return nil, store.ErrReadOnly
+	}
+	perm := store.PermWrite
+	if flags&os.O_WRONLY == 0 {
+		perm |= store.PermRead
+	}
+	if err = s.authorize(ctx, f.Parent, perm); err != nil {
+		return nil, err
+	}
+	if ctx, err = s.linearize(ctx, flags); err != nil {
+		return nil, err
+	}
+
 	f.Lock()
 	defer f.Unlock()
+	span.AddEvent("acquired file lock")
 	log.Debug("opening file for read/write", log.Id(f.Id), log.Ver(f.Version))
 
-	driver, err := s.ensureFile(f)
+	driver, err := s.ensureFile(ctx, f)
 	if err != nil {
 		return nil, err
 	}
@@ -148,12 +308,14 @@ func (s Spork) ReadWriter(f *store.File, flags int) (ReadWriteCloser, error) {
 			r: r,
 		},
 		w: &writer{
+			ctx:             trace.Detach(ctx),
 			written:         flags&os.O_TRUNC != 0 || (flags&os.O_APPEND == 0 && flags&os.O_TRUNC == 0),
 			startingVersion: f.Version,
 			endingVersion:   nextVersion,
 			f:               f,
 			fileSizer:       driver,
 			fileRemover:     driver,
+			retirer:         s,
 			w:               w,
 			invalidate:      s.invalid,
 			changer:         s.raft,
@@ -163,16 +325,28 @@ func (s Spork) ReadWriter(f *store.File, flags int) (ReadWriteCloser, error) {
 	return rw, nil
 }
 
-func (s Spork) Read(f *store.File, flags int) (ReadCloser, error) {
-	f.RLock()
-	defer f.RUnlock()
+func (s Spork) Read(ctx context.Context, f *store.File, flags int) (_ ReadCloser, err error) {
+	ctx, span := trace.Start(ctx, "spork.Read", trace.Id(f.Id))
+	defer trace.End(span, &err)
 
-	driver, err := s.ensureFile(f)
-	if err != nil {
+	if err = s.authorize(ctx, f.Parent, store.PermRead); err != nil {
 		return nil, err
 	}
+	if ctx, err = s.linearize(ctx, flags); err != nil {
+		return nil, err
+	}
+
+	f.RLock()
+	defer f.RUnlock()
+	span.AddEvent("acquired file lock")
 
-	r, err := driver.Reader(f.Id, f.Version, flags)
+	var r storedata.Reader
+	if driver := s.driverFor(f.Id); f.Size > 0 && !driver.Contains(f.Id, f.Version) {
+		// the file is streamed from peers and only the parts of it which are actually read are fetched
+		r, err = s.rangeReader(ctx, f, driver)
+	} else {
+		r, err = s.localReader(ctx, f, flags)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -184,14 +358,36 @@ func (s Spork) Read(f *store.File, flags int) (ReadCloser, error) {
 	return reader, nil
 }
 
-// ensureFile makes sure the file is present locally and returns the driver from which the file can be read
-func (s Spork) ensureFile(f *store.File) (storedata.Driver, error) {
-	driver := s.data
-	if !s.peers.IsLocalFile(f.Id) {
-		driver = s.cache
+// driverFor returns the driver which should hold the file locally
+func (s Spork) driverFor(id uint64) storedata.Driver {
+	if s.peers.IsLocalFile(id) {
+		return s.data
+	}
+	return s.cache
+}
+
+func (s Spork) rangeReader(ctx context.Context, f *store.File, driver storedata.Driver) (storedata.Reader, error) {
+	partial, err := driver.Partial(f.Id, f.Version, f.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRangeReader(ctx, f.Id, f.Version, partial, s.fetcher), nil
+}
+
+func (s Spork) localReader(ctx context.Context, f *store.File, flags int) (storedata.Reader, error) {
+	driver, err := s.ensureFile(ctx, f)
+	if err != nil {
+		return nil, err
 	}
+	return driver.Reader(f.Id, f.Version, flags)
+}
 
-	err := s.maybeTransferRemoteFile(f.Id, f.Version, driver)
+// ensureFile makes sure the file is present locally and returns the driver from which the file can be read
+func (s Spork) ensureFile(ctx context.Context, f *store.File) (storedata.Driver, error) {
+	driver := s.driverFor(f.Id)
+
+	err := s.maybeTransferRemoteFile(ctx, f.Id, f.Version, f.Size, driver)
 	if err != nil {
 		return nil, err
 	}
@@ -199,35 +395,25 @@ func (s Spork) ensureFile(f *store.File) (storedata.Driver, error) {
 	return driver, nil
 }
 
-func (s Spork) maybeTransferRemoteFile(id, version uint64, dst storedata.Driver) error {
+func (s Spork) maybeTransferRemoteFile(ctx context.Context, id, version uint64, size int64, dst storedata.Driver) (err error) {
 	if dst.Contains(id, version) {
 		log.Debug("[spork] file already present in destination", log.Id(id), log.Ver(version))
 		return nil
 	}
+	ctx, span := trace.Start(ctx, "spork.transferRemoteFile", trace.Id(id), trace.Ver(version))
+	defer trace.End(span, &err)
+
 	log.Debug("[spork] transferring remote file", log.Id(id), log.Ver(version))
 	defer log.Debug("[spork] transferred remote file", log.Id(id), log.Ver(version))
 
-	r, err := s.fetcher.Reader(id, version)
-	if err != nil {
-		return fmt.Errorf("initing fetcher for id:%d, version:%d, err:%w", id, version, err)
-	}
-	defer r.Close()
-
-	w, err := dst.Writer(id, version, version, os.O_TRUNC)
+	err = s.transferRemoteFile(ctx, id, version, size, "", dst)
 	if err != nil {
-		return fmt.Errorf("writing file to destination id:%d, version:%d, err:%w", id, version, err)
-	}
-	defer w.Commit()
-
-	_, err = io.Copy(w, r)
-	if err != nil {
-		dst.Remove(id, version) // remove so that we can retry next time
 		return fmt.Errorf("error during stream transfer of id:%d, version:%d, err:%w", id, version, err)
 	}
 	return nil
 }
 
-func (s Spork) updateLocalFile(id, oldVersion, newVersion uint64, peerHint string, dst storedata.Driver) error {
+func (s Spork) updateLocalFile(ctx context.Context, id, oldVersion, newVersion uint64, size int64, peerHint string, dst storedata.Driver) (err error) {
 	log.Debug("transferring remote file", log.Id(id), log.Ver(newVersion), zap.Uint64("old_version", oldVersion))
 	if dst.Contains(id, newVersion) {
 		log.Debug("[spork] skipping transfer since file is already here",
@@ -237,50 +423,93 @@ func (s Spork) updateLocalFile(id, oldVersion, newVersion uint64, peerHint strin
 		)
 		return nil
 	}
+	ctx, span := trace.Start(ctx, "spork.updateLocalFile", trace.Id(id), trace.Ver(newVersion), trace.Peer(peerHint))
+	defer trace.End(span, &err)
 
-	r, err := s.fetcher.ReaderFromPeer(id, newVersion, peerHint)
-	if err != nil {
-		r, err = s.fetcher.Reader(id, newVersion)
+	return s.transferRemoteFile(ctx, id, newVersion, size, peerHint, dst)
+}
+
+// transferRemoteFile fetches the parts of the version which aren't present in dst yet. The parts are fetched
+// in stripes from all peers which have the file in parallel, preferably including peerHint.
+// What was written survives failures and restarts, so the next transfer of the same version resumes from there,
+// possibly from a different peer.
+func (s Spork) transferRemoteFile(ctx context.Context, id, version uint64, size int64, peerHint string, dst storedata.Driver) error {
+	return s.download(ctx, id, version, id, version, size, peerHint, dst)
+}
+
+// download writes the source version, fetched from the peers which hold it, as the version of the file in dst.
+func (s Spork) download(ctx context.Context, srcId, srcVersion, id, version uint64, size int64, peerHint string, dst storedata.Driver) error {
+	if size == 0 {
+		w, err := dst.Writer(id, version, version, os.O_TRUNC)
 		if err != nil {
 			return err
 		}
+		w.Commit()
+		return nil
 	}
-	defer r.Close()
 
-	w, err := dst.Writer(id, oldVersion, newVersion, os.O_WRONLY|os.O_TRUNC)
+	partial, err := dst.Partial(id, version, size)
 	if err != nil {
 		return err
 	}
-	defer w.Commit()
+	defer partial.Close()
 
-	_, err = io.Copy(w, r)
-	if err != nil {
-		return err
+	for {
+		if claimed := partial.Claim(0, size); len(claimed) > 0 {
+			err = s.fetcher.Download(ctx, srcId, srcVersion, claimed, partial, peerHint)
+			for _, rng := range claimed {
+				partial.Unclaim(rng) // only the blocks which weren't written are unclaimed
+			}
+			if err != nil {
+				return err
+			}
+		}
+		// others may be fetching some of the blocks, so we wait for them too
+		if partial.Wait(0, size) {
+			return nil
+		}
 	}
-	return nil
 }
 
-func (s Spork) Write(f *store.File, flags int) (WriteCloser, error) {
-	return s.ReadWriter(f, flags)
+func (s Spork) Write(ctx context.Context, f *store.File, flags int) (WriteCloser, error) {
+	return s.ReadWriter(ctx, f, flags)
+}
+
+func (s Spork) CreateFile(ctx context.Context, parent *store.File, name string, mode store.FileMode) (_ *store.File, err error) {
+	ctx, span := trace.Start(ctx, "spork.CreateFile", trace.Id(parent.Id), trace.Name(name))
+	defer trace.End(span, &err)
+
+	if parent.ReadOnly {
+		return nil, store.ErrReadOnly
+	}
+	if err = s.authorize(ctx, parent, store.PermWrite); err != nil {
+		return nil, err
+	}
+
+	return s.createFile(ctx, parent, s.newFile(name, mode))
 }
 
-func (s Spork) CreateFile(parent *store.File, name string, mode store.FileMode) (*store.File, error) {
+// createFile adds the new file f to the parent.
+func (s Spork) createFile(ctx context.Context, parent *store.File, f *store.File) (_ *store.File, err error) {
 	parent.Lock()
 	defer parent.Unlock()
+	trace.AddEvent(ctx, "acquired parent lock")
 
 	for _, c := range parent.Children {
-		if c.Name == name {
+		if c.Name == f.Name {
 			return nil, store.ErrFileAlreadyExists
 		}
 	}
+	if err = store.CheckQuota(parent, store.Usage{Inodes: 1}); err != nil {
+		return nil, err
+	}
 
-	f := s.newFile(name, mode)
 	f.Lock()
 	defer f.Unlock()
 
-	committed, callback := s.raft.Add(f.Id, parent.Id, f.Name, f.Mode)
-	if !committed {
-		return nil, fmt.Errorf("failed to add file in raft")
+	callback, err := s.raft.Add(ctx, f.Id, parent.Id, f.Name, f.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add file in raft: %w", err)
 	}
 	defer callback()
 
@@ -289,17 +518,28 @@ func (s Spork) CreateFile(parent *store.File, name string, mode store.FileMode)
 	return f, nil
 }
 
-func (s Spork) CreateLink(file, parent *store.File, linkName string) (*store.File, error) {
+func (s Spork) CreateLink(ctx context.Context, file, parent *store.File, linkName string) (_ *store.File, err error) {
+	ctx, span := trace.Start(ctx, "spork.CreateLink", trace.Id(file.Id), trace.Name(linkName))
+	defer trace.End(span, &err)
+
+	if file.ReadOnly || parent.ReadOnly {
+		return nil, store.ErrReadOnly
+	}
+
 	parent.Lock()
 	defer parent.Unlock()
 	file.Lock()
 	defer file.Unlock()
+	span.AddEvent("acquired file and parent locks")
 
 	for _, c := range parent.Children {
 		if c.Name == linkName {
 			return nil, store.ErrFileAlreadyExists
 		}
 	}
+	if err = store.CheckQuota(parent, store.Usage{Bytes: file.Size, Inodes: 1}); err != nil {
+		return nil, err
+	}
 
 	link := s.newFile(linkName, file.Mode)
 
@@ -311,9 +551,9 @@ func (s Spork) CreateLink(file, parent *store.File, linkName string) (*store.Fil
 	link.Mtime = file.Mtime
 	link.Size = file.Size
 
-	committed, callback := s.raft.Add(link.Id, parent.Id, link.Name, link.Mode)
-	if !committed {
-		return nil, fmt.Errorf("failed to add file in raft")
+	callback, err := s.raft.Add(ctx, link.Id, parent.Id, link.Name, link.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add file in raft: %w", err)
 	}
 	defer callback()
 
@@ -330,6 +570,24 @@ func (s Spork) add(file *store.File, parent *store.File) {
 	parent.Size = int64(len(parent.Children))
 }
 
+// replaceChild adds the file to the parent like add. We also need to make sure there isn't the same file locally
+// in case this is a race condition between changes from different nodes, so a child with the same name is
+// deleted first at the time. parent needs to be locked.
+func (s Spork) replaceChild(parent, file *store.File, at time.Time) {
+	for i, c := range parent.Children {
+		if c.Name == file.Name {
+			parent.Children = append(parent.Children[:i], parent.Children[i+1:]...)
+			parent.Size--
+			s.delete(c, at)
+			s.deleted <- c
+			log.Debug("[spork] replacing file with same name")
+			break
+		}
+	}
+
+	s.add(file, parent)
+}
+
 func (s Spork) newFile(name string, mode store.FileMode) *store.File {
 	now := time.Now()
 	return &store.File{
@@ -345,7 +603,24 @@ func (s Spork) newFile(name string, mode store.FileMode) *store.File {
 	}
 }
 
-func (s Spork) Rename(file, oldParent, newParent *store.File, newName string) error {
+func (s Spork) Rename(ctx context.Context, file, oldParent, newParent *store.File, newName string) (err error) {
+	ctx, span := trace.Start(ctx, "spork.Rename", trace.Id(file.Id), trace.Name(newName))
+	defer trace.End(span, &err)
+
+	if file.ReadOnly || newParent.ReadOnly {
+		return store.ErrReadOnly
+	}
+	if err = s.authorize(ctx, oldParent, store.PermWrite); err != nil {
+		return err
+	}
+	if err = s.authorize(ctx, newParent, store.PermWrite); err != nil {
+		return err
+	}
+
+	return s.move(ctx, file, oldParent, newParent, newName)
+}
+
+func (s Spork) move(ctx context.Context, file, oldParent, newParent *store.File, newName string) error {
 	oldParent.Lock()
 	defer oldParent.Unlock()
 
@@ -356,13 +631,43 @@ func (s Spork) Rename(file, oldParent, newParent *store.File, newName string) er
 		newParent.Lock()
 		defer newParent.Unlock()
 	}
+	trace.AddEvent(ctx, "acquired file and parent locks")
+
+	// like rename(2), the file atomically replaces the file which already has the new name
+	target := childNamed(newParent, newName)
+	if target != nil {
+		if target.Id == file.Id {
+			// it's the file itself or another hard link to it
+			return nil
+		}
+		if target.Id == oldParent.Id {
+			// the file's own directory; it's already locked and isn't empty anyway
+			return store.ErrDirectoryNotEmpty
+		}
+		target.Lock()
+		defer target.Unlock()
+		if err := checkReplace(file, target); err != nil {
+			return err
+		}
+	}
+	// files are moved to the trash regardless of quotas, so that deleting them always works
+	if oldParent.Id != newParent.Id && !inTrash(newParent) {
+		if err := checkMoveQuota(file, target, oldParent, newParent); err != nil {
+			return err
+		}
+	}
 
-	committed, callback := s.raft.Rename(file.Id, oldParent.Id, newParent.Id, file.Name, newName)
-	if !committed {
-		return fmt.Errorf("couldn't vote raft change")
+	now := time.Now()
+	callback, err := s.raft.Rename(ctx, file.Id, oldParent.Id, newParent.Id, file.Name, newName, now)
+	if err != nil {
+		return fmt.Errorf("couldn't vote raft change: %w", err)
 	}
 	defer callback()
 
+	if target != nil {
+		s.delete(target, now)
+	}
+
 	// we copy the file so that the rename below doesn't affect what the invalidator reads
 	oldFile := *file
 	s.invalid <- &oldFile
@@ -372,12 +677,56 @@ func (s Spork) Rename(file, oldParent, newParent *store.File, newName string) er
 	return nil
 }
 
+// childNamed returns the child of the directory with the name or nil if there is none. dir needs to be at least
+// read-locked.
+func childNamed(dir *store.File, name string) *store.File {
+	for _, c := range dir.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// checkReplace returns an error if the file can't replace the target in a rename, following rename(2).
+// target needs to be at least read-locked.
+func checkReplace(file, target *store.File) error {
+	fileIsDir := file.Mode&store.ModeDirectory != 0
+	targetIsDir := target.Mode&store.ModeDirectory != 0
+	switch {
+	case target.ReadOnly:
+		return store.ErrReadOnly
+	case fileIsDir && !targetIsDir:
+		return store.ErrNotDirectory
+	case !fileIsDir && targetIsDir:
+		return store.ErrIsDirectory
+	case len(target.Children) != 0 || len(target.Snapshots) != 0:
+		return store.ErrDirectoryNotEmpty
+	}
+	return nil
+}
+
+// checkMoveQuota returns store.ErrQuotaExceeded if moving the file to newParent, where it replaces target,
+// would exceed any of the quotas of newParent. target can be nil.
+func checkMoveQuota(file, target, oldParent, newParent *store.File) error {
+	if !store.HasQuota(newParent) {
+		return nil
+	}
+	u := store.UsageOf(file)
+	if target != nil {
+		replaced := store.UsageOf(target)
+		u.Bytes -= replaced.Bytes
+		u.Inodes -= replaced.Inodes
+	}
+	return store.CheckMove(oldParent, newParent, u)
+}
+
 func (s Spork) rename(file *store.File, newParent *store.File, oldParent *store.File, newName string) {
 	file.Name = newName
 
 	if oldParent.Id != newParent.Id {
 		for i, c := range oldParent.Children {
-			if c.Id == file.Id && c.Name == file.Name {
+			if c == file {
 				oldParent.Children = append(oldParent.Children[:i], oldParent.Children[i+1:]...)
 				oldParent.Size--
 				break
@@ -387,13 +736,33 @@ func (s Spork) rename(file *store.File, newParent *store.File, oldParent *store.
 		file.Parent = newParent
 		newParent.Children = append(newParent.Children, file)
 		newParent.Size++
+
+		// the usage only moves between the quotas which aren't shared by both parents; counting it means walking
+		// the whole tree, so it's skipped when there are no quotas
+		if store.HasQuota(oldParent) || store.HasQuota(newParent) {
+			u := store.UsageOf(file)
+			store.Charge(oldParent, store.Usage{Bytes: -u.Bytes, Inodes: -u.Inodes})
+			store.Charge(newParent, u)
+		}
 	}
 }
 
-func (s Spork) Delete(file *store.File) error {
-	if len(file.Children) != 0 {
+func (s Spork) Delete(ctx context.Context, file *store.File) (err error) {
+	ctx, span := trace.Start(ctx, "spork.Delete", trace.Id(file.Id), trace.Name(file.Name))
+	defer trace.End(span, &err)
+
+	if file.ReadOnly {
+		return store.ErrReadOnly
+	}
+	if len(file.Children) != 0 || len(file.Snapshots) != 0 {
 		return store.ErrDirectoryNotEmpty
 	}
+	if err = s.authorize(ctx, file.Parent, store.PermWrite); err != nil {
+		return err
+	}
+	if s.trash > 0 && !inTrash(file) {
+		return s.moveToTrash(ctx, file)
+	}
 
 	file.Lock()
 	defer file.Unlock()
@@ -401,6 +770,7 @@ func (s Spork) Delete(file *store.File) error {
 	parent := file.Parent
 	parent.Lock()
 	defer parent.Unlock()
+	span.AddEvent("acquired file and parent locks")
 
 	found := false
 	for _, c := range parent.Children {
@@ -414,28 +784,39 @@ func (s Spork) Delete(file *store.File) error {
 		return store.ErrNoSuchFile
 	}
 
-	committed, callback := s.raft.Delete(file.Id, parent.Id, file.Name)
-	if !committed {
-		return fmt.Errorf("couldn't vote removal in raft")
+	now := time.Now()
+	callback, err := s.raft.Delete(ctx, file.Id, parent.Id, file.Name, now)
+	if err != nil {
+		return fmt.Errorf("couldn't vote removal in raft: %w", err)
 	}
 	defer callback()
 
-	s.delete(file)
+	s.delete(file, now)
 
 	return nil
 }
 
-func (s Spork) delete(file *store.File) {
+func (s Spork) delete(file *store.File, at time.Time) {
+	s.removeVersions(s.unlink(file, at))
+}
+
+// unlink removes the file from the inventory at the time and returns the versions which aren't retained anymore
+// because of it.
+func (s Spork) unlink(file *store.File, at time.Time) (expired []history.Revision) {
+	if file.Mode&store.ModeDirectory != 0 {
+		expired = s.history.Drop(file.Id)
+	}
 	if !s.inventory.Remove(file) {
-		s.data.Remove(file.Id, file.Version)
-		s.cache.Remove(file.Id, file.Version)
+		expired = append(expired, s.retired(file, at)...)
 	}
+	return expired
 }
 
 func (s Spork) Close() {
 	log.Info("stopping spork...")
 	s.raft.Shutdown()
 	s.wg.Wait()
+	s.cache.Close()
 	close(s.invalid)
 	close(s.deleted)
 	log.Info("stopped spork")
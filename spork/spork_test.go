@@ -0,0 +1,195 @@
+package spork
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dimitarvdimitrov/sporkfs/raft"
+	raftpb "github.com/dimitarvdimitrov/sporkfs/raft/pb"
+	"github.com/dimitarvdimitrov/sporkfs/store"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestSpork starts a single node with its data in a temporary directory and waits until it's the leader.
+// configure can change the config before the node is started. It returns a function which stops the node and
+// removes the directory.
+func newTestSpork(t *testing.T, configure ...func(*Config)) (*Spork, func()) {
+	dir, err := ioutil.TempDir("", "sporkfs-spork")
+	require.NoError(t, err)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	require.NoError(t, lis.Close())
+
+	cfg := Config{
+		DataDir: dir,
+		Config: raft.Config{
+			AllPeers:   []string{addr},
+			ThisPeer:   addr,
+			Redundancy: 1,
+			DataDir:    dir,
+		},
+	}
+	for _, c := range configure {
+		c(&cfg)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	invalid, deleted := make(chan *store.File), make(chan *store.File)
+	go drain(invalid)
+	go drain(deleted)
+	s, err := New(ctx, cancel, cfg, invalid, deleted)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return s.raft.Linearize(ctx) == nil
+	}, 10*time.Second, 10*time.Millisecond)
+
+	return &s, func() {
+		cancel()
+		s.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func drain(c <-chan *store.File) {
+	for range c {
+	}
+}
+
+func createTestFile(t *testing.T, s *Spork, parent *store.File, name string, dir bool) *store.File {
+	mode := store.ModeRegularFile
+	if dir {
+		mode = store.ModeDirectory
+	}
+	f, err := s.CreateFile(context.Background(), parent, name, mode)
+	require.NoError(t, err)
+	return f
+}
+
+func lookupTestFile(t *testing.T, s *Spork, names ...string) *store.File {
+	f := s.Root()
+	for _, name := range names {
+		var err error
+		f, err = s.Lookup(context.Background(), f, name)
+		require.NoError(t, err)
+	}
+	return f
+}
+
+func TestRenameOverExisting(t *testing.T) {
+	testCases := map[string]struct {
+		srcDir, dstDir bool
+		// noDst is true if nothing has the new name
+		noDst bool
+		// dstChildren is how many files there are in the replaced directory
+		dstChildren int
+		err         error
+	}{
+		"file over file":         {},
+		"dir over empty dir":     {srcDir: true, dstDir: true},
+		"dir over non-empty dir": {srcDir: true, dstDir: true, dstChildren: 1, err: store.ErrDirectoryNotEmpty},
+		"file over dir":          {dstDir: true, err: store.ErrIsDirectory},
+		"dir over file":          {srcDir: true, err: store.ErrNotDirectory},
+		"file to a free name":    {noDst: true},
+		"dir to a free name":     {srcDir: true, noDst: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			s, cleanup := newTestSpork(t)
+			defer cleanup()
+			ctx := context.Background()
+			root := s.Root()
+
+			src := createTestFile(t, s, root, "src", tc.srcDir)
+			var dst *store.File
+			if !tc.noDst {
+				dst = createTestFile(t, s, root, "dst", tc.dstDir)
+				for i := 0; i < tc.dstChildren; i++ {
+					createTestFile(t, s, dst, fmt.Sprint(i), false)
+				}
+			}
+
+			err := s.Rename(ctx, src, root, root, "dst")
+			require.Equal(t, tc.err, err)
+			if err != nil {
+				require.Equal(t, src.Id, lookupTestFile(t, s, "src").Id)
+				require.Equal(t, dst.Id, lookupTestFile(t, s, "dst").Id)
+				return
+			}
+			require.Equal(t, src.Id, lookupTestFile(t, s, "dst").Id)
+			_, err = s.Lookup(ctx, root, "src")
+			require.Equal(t, store.ErrNoSuchFile, err)
+			if dst != nil {
+				_, err = s.inventory.GetAny(dst.Id)
+				require.Equal(t, store.ErrNoSuchFile, err)
+			}
+		})
+	}
+}
+
+func TestApplyRenameOverDirFilledAfterProposing(t *testing.T) {
+	s, cleanup := newTestSpork(t)
+	defer cleanup()
+	root := s.Root()
+	src := createTestFile(t, s, root, "src", true)
+	dst := createTestFile(t, s, root, "dst", true)
+
+	// the rename was proposed while dst was empty, but a file was created in it before it was applied
+	child := createTestFile(t, s, dst, "child", false)
+	unlock := s.lockFiles(src.Id, root.Id, dst.Id)
+	err := s.applyRename(&raftpb.Rename{Id: src.Id, OldParentId: root.Id, NewParentId: root.Id, OldName: "src", NewName: "dst"}, time.Now())
+	unlock()
+
+	require.True(t, errors.Is(err, store.ErrDirectoryNotEmpty), err)
+	require.Equal(t, src.Id, lookupTestFile(t, s, "src").Id)
+	require.Equal(t, child.Id, lookupTestFile(t, s, "dst", "child").Id)
+}
+
+func TestApplyCopyOverExisting(t *testing.T) {
+	testCases := map[string]struct {
+		dstDir bool
+		err    error
+	}{
+		"over a file":      {},
+		"over a directory": {dstDir: true, err: store.ErrIsDirectory},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			s, cleanup := newTestSpork(t)
+			defer cleanup()
+			root := s.Root()
+			src := createTestFile(t, s, root, "src", false)
+
+			// another peer created dst after the copy was proposed
+			dst := createTestFile(t, s, root, "dst", tc.dstDir)
+			req := &raftpb.Copy{
+				Id:            s.inventory.NewId(),
+				ParentId:      root.Id,
+				Name:          "dst",
+				Mode:          uint32(store.ModeRegularFile),
+				SourceId:      src.Id,
+				SourceVersion: src.Version,
+			}
+			unlock := s.lockFiles(root.Id, dst.Id)
+			err := s.applyCopy(req, time.Now())
+			unlock()
+
+			require.True(t, errors.Is(err, tc.err), err)
+			if err != nil {
+				require.Equal(t, dst.Id, lookupTestFile(t, s, "dst").Id)
+				return
+			}
+			require.Equal(t, req.Id, lookupTestFile(t, s, "dst").Id)
+			_, err = s.inventory.GetAny(dst.Id)
+			require.Equal(t, store.ErrNoSuchFile, err)
+		})
+	}
+}
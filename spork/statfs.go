@@ -0,0 +1,47 @@
+package spork
+
+import (
+	"context"
+
+	"github.com/dimitarvdimitrov/sporkfs/log"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// Stats are about the whole cluster. Bytes are what can be stored in files, i.e. the disk space of all peers divided
+// among the replicas of each file.
+type Stats struct {
+	TotalBytes, FreeBytes, AvailableBytes uint64
+	Files                                 uint64
+}
+
+// Statfs returns the capacity and usage of the cluster from the last disk usage which each peer reported. Peers
+// which have never responded aren't counted.
+func (s Spork) Statfs(ctx context.Context) (_ Stats, err error) {
+	ctx, span := trace.Start(ctx, "spork.Statfs")
+	defer trace.End(span, &err)
+
+	usage := s.fetcher.DiskUsage(ctx)
+	if len(usage) < s.peers.Len() {
+		log.Warn("[spork] not all peers reported disk usage", zap.Int("reported", len(usage)), zap.Int("peers", s.peers.Len()))
+	}
+	span.SetAttributes(attribute.Int("reported_peers", len(usage)))
+
+	var stats Stats
+	for _, u := range usage {
+		stats.TotalBytes += u.Total
+		stats.FreeBytes += u.Free
+		stats.AvailableBytes += u.Available
+	}
+	replicas := uint64(s.peers.Replicas())
+	if replicas == 0 {
+		replicas = 1
+	}
+	stats.TotalBytes /= replicas
+	stats.FreeBytes /= replicas
+	stats.AvailableBytes /= replicas
+	stats.Files = uint64(s.inventory.Count())
+
+	return stats, nil
+}
@@ -0,0 +1,156 @@
+package spork
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dimitarvdimitrov/sporkfs/log"
+	"github.com/dimitarvdimitrov/sporkfs/store"
+	"go.uber.org/zap"
+)
+
+// TrashDirName is the directory in the root where deleted files are moved when the trash is enabled. Deleting
+// files in it deletes them for good. The files in it lose the ACLs of the directories they were deleted from, so
+// only the users who administer the root can access it.
+const TrashDirName = ".trash"
+
+const (
+	// trashDirId is the id of the trash directory on all peers, so that peers which create it at the same time
+	// create the same directory; it's below inventory.ReservedIds, so no other file gets it
+	trashDirId uint64 = 1
+	// files in the trash are named "<trashTimeFormat> <name>", where the time is when they were deleted
+	trashTimeFormat    = "2006-01-02T15:04:05.000000000Z"
+	trashPurgeInterval = time.Minute
+	// trashPurgeGrace is how much longer than the primary peer of a file the other peers wait before purging it,
+	// so that files are still purged while the primary is down, but peers rarely purge the same file
+	trashPurgeGrace = 2 * trashPurgeInterval
+)
+
+// inTrash returns true if the file is the trash directory or is in it.
+func inTrash(f *store.File) bool {
+	for ; f.Parent != nil; f = f.Parent {
+		if f.Parent.Parent == nil && f.Name == TrashDirName {
+			return true
+		}
+	}
+	return false
+}
+
+// moveToTrash renames the file into the trash directory and prefixes its name with the current time.
+func (s Spork) moveToTrash(ctx context.Context, file *store.File) error {
+	trash, err := s.trashDir(ctx)
+	if err != nil {
+		return err
+	}
+	name := time.Now().UTC().Format(trashTimeFormat) + " " + file.Name
+	log.Debug("[spork] moving file to trash", log.Id(file.Id), log.Name(name))
+
+	return s.move(ctx, file, file.Parent, trash, name)
+}
+
+// trashDir returns the trash directory and creates it if it doesn't exist.
+func (s Spork) trashDir(ctx context.Context) (*store.File, error) {
+	root := s.Root()
+	if trash := findTrashDir(root); trash != nil {
+		return trash, nil
+	}
+
+	if err := s.checkTrashDirId(); err != nil {
+		return nil, err
+	}
+	trash := s.newFile(TrashDirName, store.ModeDirectory|0777)
+	trash.Id = trashDirId
+	trash, err := s.createFile(ctx, root, trash)
+	if err == store.ErrFileAlreadyExists {
+		// another file system call created it in the meantime
+		if trash = findTrashDir(root); trash != nil {
+			return trash, nil
+		}
+	}
+	return trash, err
+}
+
+// checkTrashDirId returns an error if a file other than the trash directory has its id. Ids weren't always reserved,
+// so a file which was created before may have it.
+func (s Spork) checkTrashDirId() error {
+	f, err := s.inventory.GetAny(trashDirId)
+	if err != nil {
+		return nil
+	}
+	if f.Parent != nil && f.Parent.Parent == nil && f.Name == TrashDirName && f.Mode&store.ModeDirectory != 0 {
+		return nil
+	}
+	return fmt.Errorf("file %q has the id %d of the trash directory", f.Name, trashDirId)
+}
+
+func findTrashDir(root *store.File) *store.File {
+	root.RLock()
+	defer root.RUnlock()
+
+	for _, c := range root.Children {
+		if c.Name == TrashDirName && c.Mode&store.ModeDirectory != 0 {
+			return c
+		}
+	}
+	return nil
+}
+
+// purgeTrash periodically deletes the files which have been in the trash for longer than s.trash. Each file is
+// purged by the first of the peers which hold it, or by any peer if that one hasn't done so a while later.
+func (s Spork) purgeTrash(ctx context.Context) {
+	defer s.wg.Done()
+
+	select {
+	case <-s.raft.Replayed():
+	case <-ctx.Done():
+		return
+	}
+	if err := s.checkTrashDirId(); err != nil {
+		log.Error("[spork] files can't be moved to the trash", zap.Error(err))
+	}
+
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.purgeExpired(ctx, now)
+		}
+	}
+}
+
+func (s Spork) purgeExpired(ctx context.Context, now time.Time) {
+	trash := findTrashDir(s.Root())
+	if trash == nil {
+		return
+	}
+
+	trash.RLock()
+	children := append([]*store.File(nil), trash.Children...)
+	trash.RUnlock()
+
+	for _, c := range children {
+		deleted, err := time.Parse(trashTimeFormat, strings.SplitN(c.Name, " ", 2)[0])
+		if err != nil || !purgeDue(now.Sub(deleted), s.trash, s.peers.IsPrimary(c.Id)) {
+			continue
+		}
+		log.Info("[spork] purging file from trash", log.Id(c.Id), log.Name(c.Name))
+		// another peer may have purged it in the meantime; the entry of whichever peer is later is rejected
+		if err = s.DeleteAll(ctx, c); err != nil && err != store.ErrNoSuchFile {
+			log.Warn("[spork] purging file from trash", log.Id(c.Id), log.Name(c.Name), zap.Error(err))
+		}
+	}
+}
+
+// purgeDue returns true if a file which has been in the trash for the duration needs to be purged by this peer.
+func purgeDue(elapsed, trash time.Duration, primary bool) bool {
+	if primary {
+		return elapsed >= trash
+	}
+	return elapsed >= trash+trashPurgeGrace
+}
@@ -0,0 +1,79 @@
+package spork
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	raftpb "github.com/dimitarvdimitrov/sporkfs/raft/pb"
+	"github.com/dimitarvdimitrov/sporkfs/store"
+	"github.com/dimitarvdimitrov/sporkfs/store/history"
+	"github.com/stretchr/testify/require"
+)
+
+func withTrash(cfg *Config) {
+	cfg.Retention.Trash = history.Duration{Duration: time.Hour}
+}
+
+func TestPurgeDue(t *testing.T) {
+	testCases := map[string]struct {
+		elapsed time.Duration
+		primary bool
+		due     bool
+	}{
+		"primary before expiry":           {elapsed: time.Hour - time.Second, primary: true},
+		"primary after expiry":            {elapsed: time.Hour, primary: true, due: true},
+		"other peer after expiry":         {elapsed: time.Hour},
+		"other peer after the grace":      {elapsed: time.Hour + trashPurgeGrace, due: true},
+		"other peer long after the grace": {elapsed: 10 * time.Hour, due: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.due, purgeDue(tc.elapsed, time.Hour, tc.primary))
+		})
+	}
+}
+
+func TestPurgeExpired(t *testing.T) {
+	s, cleanup := newTestSpork(t, withTrash)
+	defer cleanup()
+	f := createTestFile(t, s, s.Root(), "a", false)
+	require.NoError(t, s.Delete(context.Background(), f))
+	trash := lookupTestFile(t, s, TrashDirName)
+	require.Equal(t, trashDirId, trash.Id)
+	require.Len(t, trash.Children, 1)
+
+	s.purgeExpired(context.Background(), time.Now())
+	require.Len(t, trash.Children, 1)
+
+	s.purgeExpired(context.Background(), time.Now().Add(time.Hour))
+	require.Empty(t, trash.Children)
+	_, err := s.inventory.GetAny(f.Id)
+	require.Equal(t, store.ErrNoSuchFile, err)
+
+	// purging a file which another peer purged in the meantime is rejected without changing anything
+	require.NoError(t, s.Delete(context.Background(), createTestFile(t, s, s.Root(), "b", false)))
+	purged := trash.Children[0]
+	unlock := s.lockFiles(purged.Id, trash.Id)
+	require.NoError(t, s.applyDelete(&raftpb.Delete{Id: purged.Id, ParentId: trash.Id, Name: purged.Name, Recursive: true}, time.Now()))
+	unlock()
+	require.Equal(t, store.ErrNoSuchFile, s.DeleteAll(context.Background(), purged))
+}
+
+func TestTrashDirIdTaken(t *testing.T) {
+	s, cleanup := newTestSpork(t, withTrash)
+	defer cleanup()
+	root := s.Root()
+
+	// a file which was created before the id was reserved
+	unlock := s.lockFiles(root.Id)
+	require.NoError(t, s.applyAdd(&raftpb.Add{Id: trashDirId, ParentId: root.Id, Name: "old", Mode: uint32(store.ModeRegularFile)}, time.Now()))
+	unlock()
+	require.Error(t, s.checkTrashDirId())
+
+	f := createTestFile(t, s, root, "a", false)
+	require.Error(t, s.Delete(context.Background(), f))
+	require.Equal(t, f.Id, lookupTestFile(t, s, "a").Id)
+	require.Equal(t, trashDirId, lookupTestFile(t, s, "old").Id)
+}
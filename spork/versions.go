@@ -0,0 +1,251 @@
+package spork
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dimitarvdimitrov/sporkfs/log"
+	"github.com/dimitarvdimitrov/sporkfs/store"
+	"github.com/dimitarvdimitrov/sporkfs/store/history"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
+	"go.uber.org/zap"
+)
+
+const versionsExpiryInterval = time.Minute
+
+// Versions returns the retained versions of the file with the name in the directory from the newest to the oldest.
+// The file may have been deleted.
+func (s Spork) Versions(ctx context.Context, dir *store.File, name string) ([]history.Revision, error) {
+	if dir.Mode&store.ModeDirectory == 0 {
+		return nil, store.ErrNotDirectory
+	}
+	if err := s.authorize(ctx, dir, store.PermList); err != nil {
+		return nil, err
+	}
+	return s.history.List(dir.Id, name), nil
+}
+
+// RestoreVersion makes the retained version of the file with the name in the directory its current version.
+// If the file was deleted, it's created again. The version which is replaced is retained like any other.
+func (s Spork) RestoreVersion(ctx context.Context, dir *store.File, name string, version uint64) (err error) {
+	ctx, span := trace.Start(ctx, "spork.RestoreVersion", trace.Id(dir.Id), trace.Name(name), trace.Ver(version))
+	defer trace.End(span, &err)
+
+	if dir.Mode&store.ModeDirectory == 0 {
+		return store.ErrNotDirectory
+	}
+	if dir.ReadOnly {
+		return store.ErrReadOnly
+	}
+	if err = s.authorize(ctx, dir, store.PermWrite); err != nil {
+		return err
+	}
+
+	var rev history.Revision
+	found := false
+	for _, r := range s.history.List(dir.Id, name) {
+		if r.Version == version {
+			rev, found = r, true
+			break
+		}
+	}
+	if !found {
+		return store.ErrNoSuchFile
+	}
+
+	// the file may have been renamed or moved since, so it's locked by its id
+	unlock := s.lockFiles(dir.Id, rev.Id)
+	defer unlock()
+	span.AddEvent("acquired file locks")
+
+	var file *store.File
+	for _, c := range dir.Children {
+		if c.Name == name {
+			file = c
+			break
+		}
+	}
+	if file != nil && file.Id != rev.Id {
+		return store.ErrFileAlreadyExists
+	}
+	if file == nil {
+		file, _ = s.inventory.GetAny(rev.Id)
+	}
+	if file == nil {
+		if file, err = s.recreate(ctx, dir, rev); err != nil {
+			return err
+		}
+		file.Lock()
+		defer file.Unlock()
+	}
+
+	for _, link := range s.inventory.GetAll(file.Id) {
+		if err = store.CheckQuota(link.Parent, store.Usage{Bytes: rev.Size - file.Size}); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	callback, err := s.raft.Change(ctx, file.Id, rev.Version, 0, rev.Size, now)
+	if err != nil {
+		return fmt.Errorf("couldn't vote file change in raft: %w", err)
+	}
+	defer callback()
+
+	s.history.Forget(file.Id, rev.Version)
+	s.retire(file, now)
+
+	s.inventory.SetVersion(file.Id, rev.Version)
+	s.inventory.SetSize(file.Id, rev.Size)
+	for _, link := range s.inventory.GetAll(file.Id) {
+		link.Mtime, link.Atime = now, now
+		s.invalid <- link
+	}
+	return nil
+}
+
+// recreate adds an empty file with the id and name of the deleted file to the directory. dir needs to be locked.
+func (s Spork) recreate(ctx context.Context, dir *store.File, rev history.Revision) (*store.File, error) {
+	if err := store.CheckQuota(dir, store.Usage{Inodes: 1}); err != nil {
+		return nil, err
+	}
+
+	file := s.newFile(rev.Name, rev.Mode)
+	file.Id = rev.Id
+
+	callback, err := s.raft.Add(ctx, file.Id, dir.Id, file.Name, file.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add file in raft: %w", err)
+	}
+	defer callback()
+
+	s.add(file, dir)
+	s.invalid <- dir
+	return file, nil
+}
+
+// retire keeps the current version of the file in the history before it's superseded or the file is deleted
+// at the time. The versions which aren't retained anymore are removed. file needs to be locked.
+func (s Spork) retire(file *store.File, at time.Time) {
+	s.removeVersions(s.retired(file, at))
+}
+
+// retired is like retire, but returns the versions which aren't retained anymore instead of removing them.
+func (s Spork) retired(file *store.File, at time.Time) []history.Revision {
+	// the versions of hard links are kept in the directory of one of them, so that it's the same on all peers
+	parent := file.Parent
+	if first, err := s.inventory.GetAny(file.Id); err == nil {
+		parent = first.Parent
+	}
+
+	return s.history.Retire(parent.Id, history.Revision{
+		Id:      file.Id,
+		Name:    file.Name,
+		Mode:    file.Mode,
+		Version: file.Version,
+		Size:    file.Size,
+		Mtime:   file.Mtime,
+		Retired: at,
+	})
+}
+
+func (s Spork) removeVersions(revisions []history.Revision) {
+	for _, r := range revisions {
+		log.Debug("[spork] removing version", log.Id(r.Id), log.Ver(r.Version))
+		s.data.Remove(r.Id, r.Version)
+		s.cache.Remove(r.Id, r.Version)
+	}
+}
+
+// removeVersionsLater removes the versions in the background.
+func (s Spork) removeVersionsLater(revisions []history.Revision) {
+	if len(revisions) == 0 {
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.removeVersions(revisions)
+	}()
+}
+
+// expireVersions periodically removes the versions which are older than the retention policy allows. Retiring
+// a version only expires the other versions in the same directory, so versions in directories without
+// changes would otherwise be kept forever.
+func (s Spork) expireVersions(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(versionsExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			expired := s.history.Expire(now)
+			if len(expired) > 0 {
+				log.Info("[spork] expired versions", zap.Int("count", len(expired)))
+			}
+			s.removeVersions(expired)
+		}
+	}
+}
+
+// versionsDir returns the virtual directory which holds the retained versions of the files in dir. It has
+// a directory for each file name and the versions in it are named after their modification time and version.
+// dir needs to be at least read-locked.
+func (s Spork) versionsDir(dir *store.File) *store.File {
+	d := &store.File{
+		RWMutex:  &sync.RWMutex{},
+		Id:       dir.Id,
+		Inode:    store.VirtualInode(dir.Id, history.DirName),
+		Name:     history.DirName,
+		Mode:     store.ModeDirectory | 0555,
+		Atime:    dir.Atime,
+		Mtime:    dir.Mtime,
+		Parent:   dir,
+		ReadOnly: true,
+	}
+
+	dirId := strconv.FormatUint(dir.Id, 10)
+	byName := make(map[string]*store.File)
+	for _, r := range s.history.List(dir.Id, "") {
+		versions, ok := byName[r.Name]
+		if !ok {
+			versions = &store.File{
+				RWMutex:  &sync.RWMutex{},
+				Id:       r.Id,
+				Inode:    store.VirtualInode(dir.Id, history.DirName, r.Name),
+				Name:     r.Name,
+				Mode:     store.ModeDirectory | 0555,
+				Atime:    r.Retired,
+				Mtime:    r.Retired,
+				Parent:   d,
+				ReadOnly: true,
+			}
+			byName[r.Name] = versions
+			d.Children = append(d.Children, versions)
+		}
+
+		versions.Children = append(versions.Children, &store.File{
+			RWMutex:  &sync.RWMutex{},
+			Id:       r.Id,
+			Inode:    store.VirtualInode(r.Id, dirId, history.DirName, strconv.FormatUint(r.Version, 10)),
+			Name:     r.Mtime.UTC().Format("2006-01-02T15:04:05.000Z") + "-" + strconv.FormatUint(r.Version, 10),
+			Mode:     r.Mode &^ 0222,
+			Size:     r.Size,
+			Version:  r.Version,
+			Atime:    r.Mtime,
+			Mtime:    r.Mtime,
+			Parent:   versions,
+			ReadOnly: true,
+		})
+		versions.Size++
+	}
+	d.Size = int64(len(d.Children))
+	return d
+}
@@ -1,6 +1,7 @@
 package spork
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"github.com/dimitarvdimitrov/sporkfs/log"
 	"github.com/dimitarvdimitrov/sporkfs/store"
 	"github.com/dimitarvdimitrov/sporkfs/store/data"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
 	"go.uber.org/zap"
 )
 
@@ -21,6 +23,10 @@ type remover interface {
 	Remove(id, version uint64)
 }
 
+type retirer interface {
+	retire(f *store.File, at time.Time)
+}
+
 type linkSetter interface {
 	SetVersion(id, version uint64)
 	SetSize(id uint64, size int64)
@@ -41,11 +47,15 @@ type WriteCloser interface {
 type writer struct {
 	f *store.File
 
+	// ctx is the context in which the writer was opened. It's only used for tracing.
+	ctx context.Context
+
 	written                        bool
 	startingVersion, endingVersion uint64
 	invalidate                     chan<- *store.File
 	fileSizer                      sizer
 	fileRemover                    remover
+	retirer                        retirer
 	links                          linkSetter
 	changer                        raft.Committer
 	w                              data.Writer
@@ -89,9 +99,13 @@ func (w *writer) Write(p []byte) (n int, _ error) {
 	return w.w.Write(p)
 }
 
-func (w *writer) Close() error {
+func (w *writer) Close() (err error) {
+	ctx, span := trace.Start(w.ctx, "spork.writer.Close", trace.Id(w.f.Id))
+	defer trace.End(span, &err)
+
 	w.f.Lock()
 	defer w.f.Unlock()
+	span.AddEvent("acquired file lock")
 	log.Debug("closing handle for file", log.Id(w.f.Id))
 
 	if w.f.Version != w.startingVersion {
@@ -109,8 +123,15 @@ func (w *writer) Close() error {
 	newVersion := w.endingVersion
 	size := w.fileSizer.Size(w.f.Id, newVersion)
 
-	committed, callback := w.changer.Change(w.f.Id, newVersion, 0, size)
-	if !committed {
+	for _, link := range w.links.GetAll(w.f.Id) {
+		if err = store.CheckQuota(link.Parent, store.Usage{Bytes: size - w.f.Size}); err != nil {
+			w.fileRemover.Remove(w.f.Id, newVersion)
+			return err
+		}
+	}
+
+	callback, err := w.changer.Change(ctx, w.f.Id, newVersion, 0, size, changeTime)
+	if err != nil {
 		// we don't delete the version because this non-commitment might have been
 		// caused by a timing out in spork's raft loop;
 		// if there is another entry that changes the same file, say with index i
@@ -121,17 +142,18 @@ func (w *writer) Close() error {
 			log.Id(w.f.Id),
 			zap.Uint64("old_version", w.f.Version),
 			zap.Uint64("new_version", newVersion),
+			zap.Error(err),
 		)
-		return fmt.Errorf("couldn't vote file change in raft; changes discarded")
+		return fmt.Errorf("couldn't vote file change in raft; changes discarded: %w", err)
 	}
 	defer callback()
 
-	w.fileRemover.Remove(w.f.Id, w.f.Version)
+	w.retirer.retire(w.f, changeTime)
 
+	w.links.SetSize(w.f.Id, size)
 	for _, link := range w.links.GetAll(w.f.Id) {
 		link.Mtime, link.Atime = changeTime, changeTime
 		link.Version = newVersion
-		link.Size = size
 		if link != w.f {
 			w.invalidate <- link
 		}
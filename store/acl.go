@@ -0,0 +1,45 @@
+package store
+
+import "strings"
+
+type Permission uint32
+
+const (
+	// PermRead allows reading the contents of files in a directory
+	PermRead Permission = 1 << iota
+	// PermWrite allows changing files in a directory and creating, renaming and deleting them
+	PermWrite
+	// PermList allows looking up the files in a directory
+	PermList
+	// PermAdmin allows changing the ACL of a directory
+	PermAdmin
+
+	PermAll = PermRead | PermWrite | PermList | PermAdmin
+)
+
+func (p Permission) String() string {
+	b := strings.Builder{}
+	for _, perm := range []struct {
+		p Permission
+		c byte
+	}{{PermRead, 'r'}, {PermWrite, 'w'}, {PermList, 'l'}, {PermAdmin, 'a'}} {
+		if p&perm.p != 0 {
+			b.WriteByte(perm.c)
+		} else {
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+// Everyone is the user in an ACL whose permissions apply to all users.
+const Everyone = "*"
+
+// ACL maps users to their permissions in a directory and in its subdirectories which don't have ACLs of their own.
+// A nil ACL means that the directory inherits the ACL of its parent.
+type ACL map[string]Permission
+
+// Allows returns true if the user has all of the permissions.
+func (a ACL) Allows(user string, p Permission) bool {
+	return (a[user]|a[Everyone])&p == p
+}
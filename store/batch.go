@@ -0,0 +1,26 @@
+package store
+
+// OpKind is the kind of an operation in a batch.
+type OpKind int
+
+const (
+	// OpCreate creates an empty file or, if the mode is a directory, a directory at Path.
+	OpCreate OpKind = iota
+	// OpWrite replaces the content of the file at Path with Data.
+	OpWrite
+	// OpRename moves the file at Path to NewPath.
+	OpRename
+	// OpDelete deletes the file at Path.
+	OpDelete
+)
+
+// Op is an operation in a batch of operations which are applied atomically. Paths are absolute and later
+// operations see the files created, renamed and deleted by earlier ones. Descendants of directories which are
+// renamed in the batch are only found at their old paths though.
+type Op struct {
+	Kind    OpKind
+	Path    string
+	NewPath string
+	Mode    FileMode
+	Data    []byte
+}
@@ -1,52 +1,141 @@
 package cache
 
 import (
+	"container/list"
+	"expvar"
 	"sync"
 	"time"
 
+	"github.com/dimitarvdimitrov/sporkfs/log"
 	"github.com/dimitarvdimitrov/sporkfs/store/data"
+	"go.uber.org/zap"
 )
 
 const expiry = time.Minute * 5
 
+var (
+	sizeMetric         = expvar.NewInt("cache_size_bytes")
+	evictionsMetric    = expvar.NewInt("cache_evictions_total")
+	evictedBytesMetric = expvar.NewInt("cache_evicted_bytes_total")
+)
+
 type Cache interface {
 	data.Driver
 
-	// KeepAlive will reset the expiry time of the file. You don't have to
+	// KeepAlive will reset the expiry time of the file if it's cached. You don't have to
 	// call it manually, it will be called before all read/write methods of the cache except Remove.
 	KeepAlive(id, version uint64)
+
+	// Prune removes all versions for which isCurrent returns false, unless they are currently open.
+	Prune(isCurrent func(id, version uint64) bool)
+
+	// Close persists what is known about the cached files so that they can be reused after a restart.
+	Close()
+}
+
+type entry struct {
+	id, version uint64
+	size        int64
+	lastAccess  time.Time
+	// pins is the number of open readers and writers of this version. Pinned entries aren't evicted.
+	pins   int
+	expiry *time.Timer
+	lru    *list.Element
 }
 
 type cache struct {
 	*sync.Mutex
 
-	data  data.Driver
-	alive map[uint64]map[uint64]*time.Timer
+	data data.Driver
+	// maxSize is the byte budget of the cache. Zero means there is no limit.
+	maxSize int64
+	size    int64
+	alive   map[uint64]map[uint64]*entry
+	lru     *list.List // the front is the most recently used entry
+
+	metadataPath string
+	done         chan struct{}
+	wg           *sync.WaitGroup
 }
 
-func New(data data.Driver) *cache {
-	return &cache{
-		data:  data,
-		Mutex: &sync.Mutex{},
-		alive: make(map[uint64]map[uint64]*time.Timer),
+// New returns a cache which keeps its files in data. Cache metadata is persisted at metadataPath
+// and is used to restore the state of the cache after a restart.
+func New(data data.Driver, maxSize int64, metadataPath string) *cache {
+	c := &cache{
+		data:         data,
+		maxSize:      maxSize,
+		Mutex:        &sync.Mutex{},
+		alive:        make(map[uint64]map[uint64]*entry),
+		lru:          list.New(),
+		metadataPath: metadataPath,
+		done:         make(chan struct{}),
+		wg:           &sync.WaitGroup{},
 	}
+	c.restore()
+
+	c.wg.Add(1)
+	go c.persistPeriodically()
+	return c
 }
 
 func (c *cache) Reader(id, version uint64, flags int) (data.Reader, error) {
 	c.KeepAlive(id, version)
-	return c.data.Reader(id, version, flags)
+	r, err := c.data.Reader(id, version, flags)
+	if err != nil {
+		return nil, err
+	}
+	c.pin(id, version)
+	return &pinnedReader{Reader: r, unpin: c.unpinFunc(id, version)}, nil
 }
 
 func (c *cache) Writer(id, oldVersion, newVersion uint64, flags int) (data.Writer, error) {
 	c.KeepAlive(id, oldVersion)
 	c.KeepAlive(id, newVersion)
-	return c.data.Writer(id, oldVersion, newVersion, flags)
+	w, err := c.data.Writer(id, oldVersion, newVersion, flags)
+	if err != nil {
+		return nil, err
+	}
+	c.pin(id, newVersion)
+	return &pinnedWriter{
+		Writer:   w,
+		onCommit: c.committedFunc(id, newVersion),
+		unpin:    c.unpinFunc(id, newVersion),
+	}, nil
 }
 
 func (c *cache) Open(id, oldVersion, newVersion uint64, flags int) (data.Reader, data.Writer, error) {
 	c.KeepAlive(id, oldVersion)
 	c.KeepAlive(id, newVersion)
-	return c.data.Open(id, oldVersion, newVersion, flags)
+	r, w, err := c.data.Open(id, oldVersion, newVersion, flags)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the reader and the writer share the same file, so we keep the pin until both are done
+	c.pin(id, newVersion)
+	c.pin(id, newVersion)
+	reader := &pinnedReader{Reader: r, unpin: c.unpinFunc(id, newVersion)}
+	writer := &pinnedWriter{
+		Writer:   w,
+		onCommit: c.committedFunc(id, newVersion),
+		unpin:    c.unpinFunc(id, newVersion),
+	}
+	return reader, writer, nil
+}
+
+func (c *cache) Partial(id, version uint64, size int64) (data.PartialFile, error) {
+	c.KeepAlive(id, version)
+	p, err := c.data.Partial(id, version, size)
+	if err != nil {
+		return nil, err
+	}
+	c.pin(id, version)
+	return &pinnedPartial{
+		PartialFile: p,
+		onWrite:     c.grewFunc(id, version),
+		onComplete:  c.committedFunc(id, version),
+		unpin:       c.unpinFunc(id, version),
+	}, nil
 }
 
 func (c *cache) Contains(id, version uint64) bool {
@@ -60,43 +149,156 @@ func (c *cache) ContainsAny(id uint64) bool {
 }
 
 func (c *cache) Remove(id, version uint64) {
+	c.Lock()
+	c.forget(id, version)
+	c.Unlock()
+
 	c.data.Remove(id, version)
 }
 
+func (c *cache) Copy(srcId, srcVersion, id, version uint64) error {
+	c.KeepAlive(srcId, srcVersion)
+	c.KeepAlive(id, version)
+	if err := c.data.Copy(srcId, srcVersion, id, version); err != nil {
+		return err
+	}
+	c.committedFunc(id, version)()
+	return nil
+}
+
 func (c *cache) Size(id, version uint64) int64 {
 	c.KeepAlive(id, version)
 	return c.data.Size(id, version)
 }
 
+func (c *cache) Versions() map[uint64][]uint64 {
+	return c.data.Versions()
+}
+
+func (c *cache) PartialVersions() map[uint64][]uint64 {
+	return c.data.PartialVersions()
+}
+
+func (c *cache) Prune(isCurrent func(id, version uint64) bool) {
+	c.Lock()
+	candidates := make([]entry, 0, c.lru.Len())
+	for el := c.lru.Front(); el != nil; el = el.Next() {
+		candidates = append(candidates, *el.Value.(*entry))
+	}
+	c.Unlock()
+	for id, versions := range c.data.PartialVersions() {
+		for _, version := range versions {
+			candidates = append(candidates, entry{id: id, version: version})
+		}
+	}
+
+	// isCurrent may need to lock files, so we don't call it while holding the cache lock
+	var stale []entry
+	for _, e := range candidates {
+		if !isCurrent(e.id, e.version) {
+			stale = append(stale, e)
+		}
+	}
+
+	c.Lock()
+	removed := stale[:0]
+	for _, e := range stale {
+		current, ok := c.alive[e.id][e.version]
+		if ok && current.pins > 0 {
+			continue
+		}
+		c.forget(e.id, e.version)
+		removed = append(removed, e)
+	}
+	stale = removed
+	c.Unlock()
+
+	for _, e := range stale {
+		log.Debug("[cache] removing superseded version", log.Id(e.id), log.Ver(e.version))
+		c.data.Remove(e.id, e.version)
+	}
+	log.Info("[cache] pruned superseded versions", zap.Int("removed", len(stale)))
+}
+
+func (c *cache) Close() {
+	close(c.done)
+	c.wg.Wait()
+	c.persist()
+}
+
 func (c *cache) KeepAlive(id, version uint64) {
 	c.Lock()
 	defer c.Unlock()
 
-	if _, ok := c.alive[id]; !ok {
-		c.alive[id] = make(map[uint64]*time.Timer)
+	c.keepAlive(id, version)
+}
+
+// keepAlive resets the expiry of the version and returns its entry, or nil if the version isn't tracked.
+// It expects the cache to be locked.
+func (c *cache) keepAlive(id, version uint64) *entry {
+	e, ok := c.alive[id][version]
+	if !ok {
+		return nil
 	}
+	e.expiry.Stop()
+	c.lru.MoveToFront(e.lru)
+	e.lastAccess = time.Now()
+	e.expiry = time.AfterFunc(expiry, c.cleanFunc(id, version))
+	return e
+}
 
-	if t, ok := c.alive[id][version]; ok {
-		t.Stop()
+// track starts tracking the version or keeps it alive if it's already tracked. It expects the cache to be locked.
+func (c *cache) track(id, version uint64) *entry {
+	if e := c.keepAlive(id, version); e != nil {
+		return e
 	}
-	c.alive[id][version] = time.AfterFunc(expiry, c.cleanFunc(id, version))
+	if _, ok := c.alive[id]; !ok {
+		c.alive[id] = make(map[uint64]*entry)
+	}
+
+	e := &entry{id: id, version: version, lastAccess: time.Now()}
+	e.lru = c.lru.PushFront(e)
+	e.expiry = time.AfterFunc(expiry, c.cleanFunc(id, version))
+	c.alive[id][version] = e
+	return e
 }
 
 func (c *cache) cleanFunc(id, version uint64) func() {
 	return func() {
 		c.Lock()
-		defer c.Unlock()
-
-		if c.alive[id][version].Stop() {
-			c.alive[id][version] = time.AfterFunc(expiry, c.cleanFunc(id, version))
+		e, ok := c.alive[id][version]
+		if !ok {
+			c.Unlock()
 			return
 		}
 
-		c.Remove(id, version)
-		delete(c.alive[id], version)
-		if len(c.alive[id]) == 0 {
-			delete(c.alive, id)
+		if e.expiry.Stop() || e.pins > 0 {
+			e.expiry = time.AfterFunc(expiry, c.cleanFunc(id, version))
+			c.Unlock()
+			return
 		}
+
+		c.forget(id, version)
+		c.Unlock()
+
+		c.data.Remove(id, version)
+	}
+}
+
+// forget stops tracking the version and expects the cache to be locked.
+func (c *cache) forget(id, version uint64) {
+	e, ok := c.alive[id][version]
+	if !ok {
+		return
+	}
+	e.expiry.Stop()
+	c.lru.Remove(e.lru)
+	c.size -= e.size
+	sizeMetric.Add(-e.size)
+
+	delete(c.alive[id], version)
+	if len(c.alive[id]) == 0 {
+		delete(c.alive, id)
 	}
 }
 
@@ -112,3 +314,146 @@ func (c *cache) keepAliveAll(id uint64) {
 		c.KeepAlive(id, version)
 	}
 }
+
+func (c *cache) pin(id, version uint64) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.track(id, version).pins++
+}
+
+func (c *cache) unpinFunc(id, version uint64) func() {
+	return func() {
+		c.Lock()
+		if e, ok := c.alive[id][version]; ok && e.pins > 0 {
+			e.pins--
+		}
+		evicted := c.evict()
+		c.Unlock()
+
+		c.removeEvicted(evicted)
+	}
+}
+
+// committedFunc returns a function that updates the size of the version once it has been written.
+func (c *cache) committedFunc(id, version uint64) func() {
+	return func() {
+		size := c.data.Size(id, version)
+
+		c.Lock()
+		e := c.track(id, version)
+		c.size += size - e.size
+		sizeMetric.Add(size - e.size)
+		e.size = size
+		evicted := c.evict()
+		c.Unlock()
+
+		c.removeEvicted(evicted)
+	}
+}
+
+// grewFunc returns a function that charges the cache for the bytes written to a partial version, so that partly
+// fetched versions count towards the budget before they are complete.
+func (c *cache) grewFunc(id, version uint64) func(n int64) {
+	return func(n int64) {
+		c.Lock()
+		e := c.track(id, version)
+		c.size += n
+		sizeMetric.Add(n)
+		e.size += n
+		evicted := c.evict()
+		c.Unlock()
+
+		c.removeEvicted(evicted)
+	}
+}
+
+// evict forgets the least recently used unpinned entries until the cache fits in its budget. It returns
+// the evicted entries whose data needs to be removed. It expects the cache to be locked.
+func (c *cache) evict() (evicted []*entry) {
+	if c.maxSize <= 0 {
+		return nil
+	}
+
+	for el := c.lru.Back(); el != nil && c.size > c.maxSize; {
+		e := el.Value.(*entry)
+		el = el.Prev()
+		if e.pins > 0 || e.size == 0 {
+			continue
+		}
+		c.forget(e.id, e.version)
+		evicted = append(evicted, e)
+	}
+
+	if c.size > c.maxSize {
+		log.Warn("[cache] over budget but all remaining files are open",
+			zap.Int64("size", c.size),
+			zap.Int64("max_size", c.maxSize),
+		)
+	}
+	return
+}
+
+func (c *cache) removeEvicted(evicted []*entry) {
+	for _, e := range evicted {
+		log.Info("[cache] evicting file", log.Id(e.id), log.Ver(e.version), zap.Int64("size", e.size))
+		evictionsMetric.Add(1)
+		evictedBytesMetric.Add(e.size)
+		c.data.Remove(e.id, e.version)
+	}
+}
+
+type pinnedReader struct {
+	data.Reader
+	unpin func()
+	once  sync.Once
+}
+
+func (r *pinnedReader) Close() error {
+	err := r.Reader.Close()
+	r.once.Do(r.unpin)
+	return err
+}
+
+type pinnedWriter struct {
+	data.Writer
+	onCommit, unpin func()
+	once            sync.Once
+}
+
+func (w *pinnedWriter) Commit() {
+	w.Writer.Commit()
+	w.once.Do(func() {
+		w.onCommit()
+		w.unpin()
+	})
+}
+
+func (w *pinnedWriter) Cancel() {
+	w.Writer.Cancel()
+	w.once.Do(w.unpin)
+}
+
+type pinnedPartial struct {
+	data.PartialFile
+	onWrite            func(n int64)
+	onComplete, unpin  func()
+	completed, release sync.Once
+}
+
+func (p *pinnedPartial) WriteAt(b []byte, off int64) (int, error) {
+	n, err := p.PartialFile.WriteAt(b, off)
+	if n > 0 {
+		p.onWrite(int64(n))
+	}
+	if p.Complete() {
+		p.completed.Do(p.onComplete)
+	}
+	return n, err
+}
+
+func (p *pinnedPartial) Close() error {
+	err := p.PartialFile.Close()
+	p.release.Do(p.unpin)
+	return err
+}
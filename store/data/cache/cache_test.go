@@ -0,0 +1,275 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dimitarvdimitrov/sporkfs/store/data"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCache returns a cache in a temporary directory and a function which closes it and removes the directory.
+func newTestCache(t *testing.T, maxSize int64) (*cache, func()) {
+	dir, err := ioutil.TempDir("", "sporkfs-cache")
+	require.NoError(t, err)
+	d, err := data.NewLocalDriver(filepath.Join(dir, "data"))
+	require.NoError(t, err)
+
+	c := New(d, maxSize, filepath.Join(dir, "metadata.json"))
+	return c, func() {
+		c.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func writeVersion(t *testing.T, c *cache, id uint64, size int) {
+	w, err := c.Writer(id, 0, 1, os.O_TRUNC)
+	require.NoError(t, err)
+	_, err = w.Write(make([]byte, size))
+	require.NoError(t, err)
+	w.Commit()
+}
+
+// writeOpenVersion writes the version like writeVersion, but returns it open for reading.
+func writeOpenVersion(t *testing.T, c *cache, id uint64, size int) data.Reader {
+	r, w, err := c.Open(id, 0, 1, os.O_TRUNC)
+	require.NoError(t, err)
+	_, err = w.Write(make([]byte, size))
+	require.NoError(t, err)
+	w.Commit()
+	return r
+}
+
+func TestEviction(t *testing.T) {
+	type op struct {
+		// write writes a version of the file with size bytes, read opens it and open keeps it open until close;
+		// writeOpen writes it and keeps it open
+		write, writeOpen, read, open, close bool
+		id                                  uint64
+		size                                int
+	}
+	testCases := map[string]struct {
+		maxSize int64
+		ops     []op
+		// cached are the ids of the files which are still cached in the end
+		cached []uint64
+	}{
+		"within the budget": {
+			maxSize: 100,
+			ops:     []op{{write: true, id: 1, size: 50}, {write: true, id: 2, size: 50}},
+			cached:  []uint64{1, 2},
+		},
+		"unlimited": {
+			ops:    []op{{write: true, id: 1, size: 1000}, {write: true, id: 2, size: 1000}},
+			cached: []uint64{1, 2},
+		},
+		"least recently written": {
+			maxSize: 100,
+			ops:     []op{{write: true, id: 1, size: 50}, {write: true, id: 2, size: 50}, {write: true, id: 3, size: 50}},
+			cached:  []uint64{2, 3},
+		},
+		"least recently read": {
+			maxSize: 100,
+			ops: []op{
+				{write: true, id: 1, size: 50},
+				{write: true, id: 2, size: 50},
+				{read: true, id: 1},
+				{write: true, id: 3, size: 50},
+			},
+			cached: []uint64{1, 3},
+		},
+		"as many as needed": {
+			maxSize: 100,
+			ops: []op{
+				{write: true, id: 1, size: 30},
+				{write: true, id: 2, size: 30},
+				{write: true, id: 3, size: 30},
+				{write: true, id: 4, size: 60},
+			},
+			cached: []uint64{3, 4},
+		},
+		"open files": {
+			maxSize: 100,
+			ops: []op{
+				{write: true, id: 1, size: 50},
+				{open: true, id: 1},
+				{write: true, id: 2, size: 50},
+				{write: true, id: 3, size: 50},
+			},
+			cached: []uint64{1, 3},
+		},
+		"the only ones which aren't open": {
+			maxSize: 100,
+			ops: []op{
+				{write: true, id: 1, size: 80},
+				{open: true, id: 1},
+				{write: true, id: 2, size: 80},
+			},
+			cached: []uint64{1},
+		},
+		"over the budget while open": {
+			maxSize: 100,
+			ops: []op{
+				{writeOpen: true, id: 1, size: 80},
+				{writeOpen: true, id: 2, size: 80},
+			},
+			cached: []uint64{1, 2},
+		},
+		"once closed": {
+			maxSize: 100,
+			ops: []op{
+				{writeOpen: true, id: 1, size: 80},
+				{writeOpen: true, id: 2, size: 80},
+				{close: true, id: 1},
+			},
+			cached: []uint64{2},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c, cleanup := newTestCache(t, tc.maxSize)
+			defer cleanup()
+
+			open := make(map[uint64]data.Reader)
+			for _, o := range tc.ops {
+				switch {
+				case o.write:
+					writeVersion(t, c, o.id, o.size)
+				case o.writeOpen:
+					open[o.id] = writeOpenVersion(t, c, o.id, o.size)
+				case o.read, o.open:
+					r, err := c.Reader(o.id, 1, os.O_RDONLY)
+					require.NoError(t, err)
+					if o.open {
+						open[o.id] = r
+					} else {
+						require.NoError(t, r.Close())
+					}
+				case o.close:
+					require.NoError(t, open[o.id].Close())
+					delete(open, o.id)
+				}
+			}
+
+			var cached []uint64
+			for id := uint64(1); id <= 4; id++ {
+				if c.data.Contains(id, 1) {
+					cached = append(cached, id)
+				}
+			}
+			require.Equal(t, tc.cached, cached)
+			for _, r := range open {
+				require.NoError(t, r.Close())
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	c, cleanup := newTestCache(t, 0)
+	defer cleanup()
+	writeVersion(t, c, 1, 10)
+	writeVersion(t, c, 2, 10)
+	writeVersion(t, c, 3, 10)
+	r, err := c.Reader(3, 1, os.O_RDONLY)
+	require.NoError(t, err)
+
+	// only the first one is current, but the third one is open
+	c.Prune(func(id, version uint64) bool { return id == 1 })
+	require.True(t, c.Contains(1, 1))
+	require.False(t, c.Contains(2, 1))
+	require.True(t, c.Contains(3, 1))
+	require.NoError(t, r.Close())
+}
+
+func TestPartialVersions(t *testing.T) {
+	c, cleanup := newTestCache(t, 2*data.BlockSize)
+	defer cleanup()
+	writeVersion(t, c, 1, data.BlockSize)
+
+	// a partly read version counts towards the budget before it's complete
+	p, err := c.Partial(2, 1, 4*data.BlockSize)
+	require.NoError(t, err)
+	_, err = p.WriteAt(make([]byte, 2*data.BlockSize), 0)
+	require.NoError(t, err)
+	require.EqualValues(t, 2*data.BlockSize, c.size)
+	require.False(t, c.data.Contains(1, 1))
+
+	// and is evicted once it's closed
+	_, err = p.WriteAt(make([]byte, data.BlockSize), 2*data.BlockSize)
+	require.NoError(t, err)
+	require.NoError(t, p.Close())
+	require.EqualValues(t, 0, c.size)
+	require.Eventually(t, func() bool { return len(c.data.PartialVersions()) == 0 }, time.Second, time.Millisecond)
+
+	// versions which were only looked up aren't tracked
+	require.False(t, c.Contains(3, 1))
+	c.Lock()
+	require.Empty(t, c.alive)
+	c.Unlock()
+}
+
+func TestRestore(t *testing.T) {
+	testCases := map[string]struct {
+		// metadata replaces the persisted metadata if it isn't nil
+		metadata []byte
+		// lru is true if the order in which the versions were used survives the restart
+		lru bool
+	}{
+		"persisted":        {lru: true},
+		"missing metadata": {metadata: []byte{}},
+		"corrupt metadata": {metadata: []byte("{")},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "sporkfs-cache")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+			metadataPath := filepath.Join(dir, "metadata.json")
+			open := func(maxSize int64) *cache {
+				d, err := data.NewLocalDriver(filepath.Join(dir, "data"))
+				require.NoError(t, err)
+				return New(d, maxSize, metadataPath)
+			}
+
+			c := open(0)
+			for id, size := range map[uint64]int{1: 10, 2: 20, 3: 30} {
+				writeVersion(t, c, id, size)
+			}
+			// the order in which they were used: 2, 3, 1
+			for _, id := range []uint64{2, 3, 1} {
+				time.Sleep(time.Millisecond)
+				r, err := c.Reader(id, 1, os.O_RDONLY)
+				require.NoError(t, err)
+				require.NoError(t, r.Close())
+			}
+			c.Close()
+			switch {
+			case tc.metadata == nil:
+			case len(tc.metadata) == 0:
+				require.NoError(t, os.Remove(metadataPath))
+			default:
+				require.NoError(t, ioutil.WriteFile(metadataPath, tc.metadata, 0600))
+			}
+
+			c = open(60)
+			defer c.Close()
+			require.EqualValues(t, 60, c.size)
+			require.Len(t, c.alive, 3)
+
+			if tc.lru {
+				// the least recently used one is evicted first
+				writeVersion(t, c, 4, 10)
+				require.False(t, c.data.Contains(2, 1))
+				for _, id := range []uint64{1, 3, 4} {
+					require.True(t, c.data.Contains(id, 1), id)
+				}
+			}
+		})
+	}
+}
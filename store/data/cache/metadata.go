@@ -0,0 +1,170 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/dimitarvdimitrov/sporkfs/log"
+	"go.uber.org/zap"
+)
+
+const persistPeriod = time.Minute
+
+type metadata struct {
+	SavedAt time.Time       `json:"saved_at"`
+	Entries []entryMetadata `json:"entries"`
+}
+
+type entryMetadata struct {
+	Id         uint64    `json:"id"`
+	Version    uint64    `json:"version"`
+	Size       int64     `json:"size"`
+	LastAccess time.Time `json:"last_access"`
+}
+
+// restore starts tracking the versions that are already in the underlying driver. Versions which are in
+// the persisted metadata keep their last access time; the time while the node was down doesn't count towards
+// their expiry. Versions which aren't in the metadata are treated as if they were just accessed.
+func (c *cache) restore() {
+	persisted := make(map[uint64]map[uint64]entryMetadata)
+	meta, err := readMetadata(c.metadataPath)
+	if err != nil {
+		log.Warn("[cache] couldn't restore metadata; treating all cached files as new", zap.Error(err))
+	}
+	for _, e := range meta.Entries {
+		if persisted[e.Id] == nil {
+			persisted[e.Id] = make(map[uint64]entryMetadata)
+		}
+		persisted[e.Id][e.Version] = e
+	}
+
+	now := time.Now()
+	var restored []entryMetadata
+	for id, versions := range c.data.Versions() {
+		for _, version := range versions {
+			e, ok := persisted[id][version]
+			if !ok {
+				e = entryMetadata{
+					Id:         id,
+					Version:    version,
+					Size:       c.data.Size(id, version),
+					LastAccess: meta.SavedAt,
+				}
+			}
+			// shift the access time as if the node was never down
+			e.LastAccess = now.Add(e.LastAccess.Sub(meta.SavedAt))
+			restored = append(restored, e)
+		}
+	}
+
+	// partially fetched versions expire like the rest, unless they are read again and resumed
+	for id, versions := range c.data.PartialVersions() {
+		for _, version := range versions {
+			e, ok := persisted[id][version]
+			if !ok {
+				e = entryMetadata{Id: id, Version: version, LastAccess: meta.SavedAt}
+			}
+			e.LastAccess = now.Add(e.LastAccess.Sub(meta.SavedAt))
+			restored = append(restored, e)
+		}
+	}
+
+	// the least recently used entries go to the back of the list
+	sort.Slice(restored, func(i, j int) bool {
+		return restored[i].LastAccess.Before(restored[j].LastAccess)
+	})
+
+	c.Lock()
+	defer c.Unlock()
+	for _, m := range restored {
+		e := c.track(m.Id, m.Version)
+		e.lastAccess = m.LastAccess
+		e.size = m.Size
+		c.size += m.Size
+		sizeMetric.Add(m.Size)
+
+		e.expiry.Stop()
+		e.expiry = time.AfterFunc(expiry-now.Sub(m.LastAccess), c.cleanFunc(m.Id, m.Version))
+	}
+	log.Info("[cache] restored cached files", zap.Int("files", len(restored)), zap.Int64("size", c.size))
+}
+
+func (c *cache) persistPeriodically() {
+	defer c.wg.Done()
+
+	t := time.NewTicker(persistPeriod)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			c.persist()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *cache) persist() {
+	c.Lock()
+	meta := metadata{
+		SavedAt: time.Now(),
+		Entries: make([]entryMetadata, 0, c.lru.Len()),
+	}
+	for el := c.lru.Front(); el != nil; el = el.Next() {
+		e := el.Value.(*entry)
+		if e.size == 0 {
+			continue // it was only looked up or it's empty - either way there is nothing to restore
+		}
+		meta.Entries = append(meta.Entries, entryMetadata{
+			Id:         e.id,
+			Version:    e.version,
+			Size:       e.size,
+			LastAccess: e.lastAccess,
+		})
+	}
+	c.Unlock()
+
+	if err := writeMetadata(c.metadataPath, meta); err != nil {
+		log.Error("[cache] persisting metadata", zap.Error(err))
+	}
+}
+
+func readMetadata(path string) (metadata, error) {
+	meta := metadata{SavedAt: time.Now()}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return meta, nil
+		}
+		return meta, err
+	}
+	defer f.Close()
+
+	if err = json.NewDecoder(f).Decode(&meta); err != nil {
+		return metadata{SavedAt: time.Now()}, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return meta, nil
+}
+
+func writeMetadata(path string, meta metadata) error {
+	f, err := os.Create(path + ".new")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err = json.NewEncoder(f).Encode(meta); err != nil {
+		return fmt.Errorf("encoding cache metadata: %w", err)
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	_ = f.Close()
+
+	return os.Rename(f.Name(), path)
+}
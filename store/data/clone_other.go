@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package data
+
+import (
+	"errors"
+	"os"
+)
+
+func cloneFile(dst, src *os.File) error {
+	return errors.New("cloning files isn't supported")
+}
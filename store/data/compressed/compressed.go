@@ -0,0 +1,336 @@
+// Package compressed provides a data.Driver which keeps versions compressed on disk.
+package compressed
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/dimitarvdimitrov/sporkfs/log"
+	"github.com/dimitarvdimitrov/sporkfs/store/data"
+	"go.uber.org/zap"
+)
+
+const Gzip = "gzip"
+
+// driver keeps compressed versions in the frame format in their own underlying driver, so whether a version is
+// compressed never depends on its content. Versions are written and fetched in plain form to a scratch driver
+// first, since they may be written at random offsets. Once they are committed or completely fetched, they are
+// compressed in the background and removed from the scratch.
+//
+// Versions which were written while compression was disabled are kept in the plain driver and read as they are.
+type driver struct {
+	plain, compressed data.Driver
+	// scratch is nil if compression is disabled; versions are then written directly to the plain driver
+	scratch data.Driver
+
+	// m keeps versions from being removed while they are moved from the scratch
+	m *sync.Mutex
+}
+
+// New returns a driver which keeps versions compressed with algorithm in compressed. Versions being written are
+// kept in scratch. If algorithm is empty, new versions are stored in plain as they are, scratch isn't used and
+// versions which were compressed before can still be read.
+func New(plain, compressed, scratch data.Driver, algorithm string) (data.Driver, error) {
+	switch algorithm {
+	case "":
+		scratch = nil
+	case Gzip:
+	default:
+		return nil, fmt.Errorf("unknown compression algorithm %q", algorithm)
+	}
+
+	dr := driver{plain: plain, compressed: compressed, scratch: scratch, m: &sync.Mutex{}}
+	if scratch != nil {
+		// versions which were committed right before a restart may not have been compressed yet or may
+		// have been compressed only partially
+		for id, versions := range scratch.Versions() {
+			for _, version := range versions {
+				dr.compress(id, version)
+			}
+		}
+	}
+	return dr, nil
+}
+
+func (d driver) Contains(id, version uint64) bool {
+	return d.plain.Contains(id, version) || d.isCompressed(id, version) || d.inScratch(id, version)
+}
+
+func (d driver) ContainsAny(id uint64) bool {
+	return d.plain.ContainsAny(id) || d.compressed.ContainsAny(id) || (d.scratch != nil && d.scratch.ContainsAny(id))
+}
+
+// isCompressed returns true if the version is kept in the frame format. The zero version never is.
+func (d driver) isCompressed(id, version uint64) bool {
+	return version != 0 && d.compressed.Contains(id, version)
+}
+
+func (d driver) inScratch(id, version uint64) bool {
+	return d.scratch != nil && version != 0 && d.scratch.Contains(id, version)
+}
+
+func (d driver) Reader(id, version uint64, flags int) (data.Reader, error) {
+	if d.inScratch(id, version) {
+		if r, err := d.scratch.Reader(id, version, flags); err == nil {
+			return r, nil
+		}
+		// it was compressed in the meantime
+	}
+	if !d.isCompressed(id, version) {
+		return d.plain.Reader(id, version, flags)
+	}
+
+	r, err := d.compressed.Reader(id, version, flags)
+	if err != nil {
+		return nil, err
+	}
+	t, ends, err := readIndex(r, d.compressed.Size(id, version))
+	if err != nil {
+		_ = r.Close()
+		return nil, fmt.Errorf("reading compressed version %d-%d: %w", id, version, err)
+	}
+	return newFrameReader(r, t, ends), nil
+}
+
+func (d driver) Size(id, version uint64) int64 {
+	if d.inScratch(id, version) {
+		return d.scratch.Size(id, version)
+	}
+	if !d.isCompressed(id, version) {
+		return d.plain.Size(id, version)
+	}
+
+	r, err := d.compressed.Reader(id, version, os.O_RDONLY)
+	if err != nil {
+		return 0
+	}
+	defer r.Close()
+
+	t, _, err := readIndex(r, d.compressed.Size(id, version))
+	if err != nil {
+		log.Error("[data] reading size of compressed version", log.Id(id), log.Ver(version), zap.Error(err))
+		return 0
+	}
+	return int64(t.Size)
+}
+
+func (d driver) Remove(id, version uint64) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	d.plain.Remove(id, version)
+	d.compressed.Remove(id, version)
+	if d.scratch != nil {
+		d.scratch.Remove(id, version)
+	}
+}
+
+func (d driver) Copy(srcId, srcVersion, id, version uint64) error {
+	switch {
+	case d.inScratch(srcId, srcVersion):
+		if err := d.scratch.Copy(srcId, srcVersion, id, version); err != nil {
+			return err
+		}
+		go d.compress(id, version)
+		return nil
+	case d.isCompressed(srcId, srcVersion):
+		return d.compressed.Copy(srcId, srcVersion, id, version)
+	default:
+		return d.plain.Copy(srcId, srcVersion, id, version)
+	}
+}
+
+func (d driver) Versions() map[uint64][]uint64 {
+	versions := d.plain.Versions()
+	others := []map[uint64][]uint64{d.compressed.Versions()}
+	if d.scratch != nil {
+		others = append(others, d.scratch.Versions())
+	}
+
+	for _, other := range others {
+		for id, otherVersions := range other {
+			for _, version := range otherVersions {
+				if !containsVersion(versions[id], version) {
+					versions[id] = append(versions[id], version)
+				}
+			}
+		}
+	}
+	return versions
+}
+
+func containsVersion(versions []uint64, version uint64) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+func (d driver) PartialVersions() map[uint64][]uint64 {
+	versions := d.plain.PartialVersions()
+	if d.scratch == nil {
+		return versions
+	}
+	if versions == nil {
+		versions = make(map[uint64][]uint64)
+	}
+	for id, scratchVersions := range d.scratch.PartialVersions() {
+		versions[id] = append(versions[id], scratchVersions...)
+	}
+	return versions
+}
+
+func (d driver) Writer(id, oldVersion, newVersion uint64, flags int) (data.Writer, error) {
+	if d.scratch == nil && !d.isCompressed(id, oldVersion) {
+		return d.plain.Writer(id, oldVersion, newVersion, flags)
+	}
+	w, err := d.writable().Writer(id, newVersion, newVersion, scratchFlags(flags))
+	if err != nil {
+		return nil, err
+	}
+	if err = d.copyOldVersion(id, oldVersion, flags, w); err != nil {
+		w.Cancel()
+		return nil, err
+	}
+	if d.scratch == nil {
+		return w, nil
+	}
+	return &compressingWriter{Writer: w, compress: func() { go d.compress(id, newVersion) }}, nil
+}
+
+func (d driver) Open(id, oldVersion, newVersion uint64, flags int) (data.Reader, data.Writer, error) {
+	if d.scratch == nil && !d.isCompressed(id, oldVersion) {
+		return d.plain.Open(id, oldVersion, newVersion, flags)
+	}
+	// the reader keeps reading the plain file even after it's been compressed and removed from the scratch
+	r, w, err := d.writable().Open(id, newVersion, newVersion, scratchFlags(flags))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = d.copyOldVersion(id, oldVersion, flags, w); err != nil {
+		w.Cancel()
+		_ = r.Close()
+		return nil, nil, err
+	}
+	if d.scratch == nil {
+		return r, w, nil
+	}
+	return r, &compressingWriter{Writer: w, compress: func() { go d.compress(id, newVersion) }}, nil
+}
+
+// writable returns the driver to which new versions are written.
+func (d driver) writable() data.Driver {
+	if d.scratch != nil {
+		return d.scratch
+	}
+	return d.plain
+}
+
+func (d driver) Partial(id, version uint64, size int64) (data.PartialFile, error) {
+	if d.scratch == nil {
+		return d.plain.Partial(id, version, size)
+	}
+	p, err := d.scratch.Partial(id, version, size)
+	if err != nil {
+		return nil, err
+	}
+	return &compressingPartial{PartialFile: p, compress: func() { go d.compress(id, version) }}, nil
+}
+
+// scratchFlags returns the flags with which a new version is created in the scratch. Writers which don't truncate
+// the file start from the decompressed old version instead, which leaves them at the end of the file.
+func scratchFlags(flags int) int {
+	return flags&^os.O_APPEND | os.O_TRUNC
+}
+
+// copyOldVersion writes the plain content of the old version to w if the flags don't truncate the file.
+func (d driver) copyOldVersion(id, oldVersion uint64, flags int, w data.Writer) error {
+	if oldVersion == 0 || flags&os.O_TRUNC != 0 {
+		return nil
+	}
+	if flags&os.O_APPEND == 0 {
+		return nil // the local driver truncates files unless they are opened for appending
+	}
+
+	r, err := d.Reader(id, oldVersion, os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	size := d.Size(id, oldVersion)
+	buff := make([]byte, frameSize)
+	for off := int64(0); off < size; {
+		n, err := r.ReadAt(buff, off)
+		if n == 0 && err != nil {
+			return fmt.Errorf("copying old version: %w", err)
+		}
+		if _, err = w.Write(buff[:n]); err != nil {
+			return fmt.Errorf("copying old version: %w", err)
+		}
+		off += int64(n)
+	}
+	return nil
+}
+
+// compress moves the version from the scratch to the compressed driver. If that fails, the version stays
+// in the scratch and is read from there.
+func (d driver) compress(id, version uint64) {
+	r, err := d.scratch.Reader(id, version, os.O_RDONLY)
+	if err != nil {
+		log.Error("[data] opening version to compress", log.Id(id), log.Ver(version), zap.Error(err))
+		return
+	}
+	defer r.Close()
+
+	w, err := d.compressed.Writer(id, version, version, os.O_TRUNC)
+	if err != nil {
+		log.Error("[data] opening compressed version", log.Id(id), log.Ver(version), zap.Error(err))
+		return
+	}
+	if err = compress(w, r, d.scratch.Size(id, version)); err != nil {
+		log.Error("[data] compressing version", log.Id(id), log.Ver(version), zap.Error(err))
+		w.Cancel()
+		d.compressed.Remove(id, version)
+		return
+	}
+	w.Sync()
+	w.Commit()
+
+	d.m.Lock()
+	defer d.m.Unlock()
+	if !d.scratch.Contains(id, version) {
+		// it was removed while we were compressing it
+		d.compressed.Remove(id, version)
+		return
+	}
+	d.scratch.Remove(id, version)
+}
+
+type compressingWriter struct {
+	data.Writer
+	compress func()
+	once     sync.Once
+}
+
+func (w *compressingWriter) Commit() {
+	w.Writer.Commit()
+	w.once.Do(w.compress)
+}
+
+type compressingPartial struct {
+	data.PartialFile
+	compress func()
+	once     sync.Once
+}
+
+func (p *compressingPartial) WriteAt(b []byte, off int64) (int, error) {
+	n, err := p.PartialFile.WriteAt(b, off)
+	if p.Complete() {
+		p.once.Do(p.compress)
+	}
+	return n, err
+}
@@ -0,0 +1,122 @@
+package compressed
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dimitarvdimitrov/sporkfs/store/data"
+	"github.com/stretchr/testify/require"
+)
+
+type testDrivers struct {
+	plain, compressed, scratch data.Driver
+}
+
+// newTestDrivers returns drivers in a temporary directory and a function which removes it.
+func newTestDrivers(t *testing.T) (testDrivers, func()) {
+	dir, err := ioutil.TempDir("", "sporkfs-compressed")
+	require.NoError(t, err)
+	cleanup := func() { _ = os.RemoveAll(dir) }
+
+	var d testDrivers
+	d.plain, err = data.NewLocalDriver(dir + "/plain")
+	require.NoError(t, err)
+	d.compressed, err = data.NewLocalDriver(dir + "/compressed")
+	require.NoError(t, err)
+	d.scratch, err = data.NewLocalDriver(dir + "/scratch")
+	require.NoError(t, err)
+	return d, cleanup
+}
+
+func (d testDrivers) new(t *testing.T, algorithm string) data.Driver {
+	dr, err := New(d.plain, d.compressed, d.scratch, algorithm)
+	require.NoError(t, err)
+	return dr
+}
+
+func write(t *testing.T, d data.Driver, id, oldVersion, newVersion uint64, flags int, content []byte) {
+	w, err := d.Writer(id, oldVersion, newVersion, flags)
+	require.NoError(t, err)
+	_, err = w.Write(content)
+	require.NoError(t, err)
+	w.Sync()
+	w.Commit()
+}
+
+func read(t *testing.T, d data.Driver, id, version uint64) []byte {
+	r, err := d.Reader(id, version, os.O_RDONLY)
+	require.NoError(t, err)
+	defer r.Close()
+
+	content := make([]byte, d.Size(id, version))
+	_, err = r.ReadAt(content, 0)
+	require.NoError(t, err)
+	return content
+}
+
+func waitCompressed(t *testing.T, d testDrivers, id, version uint64) {
+	require.Eventually(t, func() bool {
+		return d.compressed.Contains(id, version) && !d.scratch.Contains(id, version)
+	}, time.Second, time.Millisecond)
+}
+
+func TestPlainFileWithTrailerIsReadAsItIs(t *testing.T) {
+	d, cleanup := newTestDrivers(t)
+	defer cleanup()
+
+	// a plain file which happens to be a valid compressed file
+	content := compressBytes(t, []byte("not what the user wrote"))
+	write(t, d.plain, 1, 0, 1, os.O_TRUNC, content)
+
+	for _, algorithm := range []string{"", Gzip} {
+		dr := d.new(t, algorithm)
+		require.Equal(t, content, read(t, dr, 1, 1))
+		require.EqualValues(t, len(content), dr.Size(1, 1))
+	}
+}
+
+func TestCompressedVersions(t *testing.T) {
+	d, cleanup := newTestDrivers(t)
+	defer cleanup()
+	dr := d.new(t, Gzip)
+
+	content := make([]byte, 3*frameSize)
+	write(t, dr, 1, 0, 1, os.O_TRUNC, content)
+	waitCompressed(t, d, 1, 1)
+	require.False(t, d.plain.Contains(1, 1))
+	require.Less(t, d.compressed.Size(1, 1), int64(len(content)))
+	require.Equal(t, content, read(t, dr, 1, 1))
+	require.EqualValues(t, len(content), dr.Size(1, 1))
+
+	// appending to a compressed version
+	write(t, dr, 1, 1, 2, os.O_APPEND, []byte("appended"))
+	waitCompressed(t, d, 1, 2)
+	require.Equal(t, append(content, "appended"...), read(t, dr, 1, 2))
+
+	// compressed versions stay readable and appendable after compression is disabled
+	dr = d.new(t, "")
+	require.Equal(t, content, read(t, dr, 1, 1))
+	write(t, dr, 1, 2, 3, os.O_APPEND, []byte(" again"))
+	require.True(t, d.plain.Contains(1, 3))
+	require.Equal(t, append(content, "appended again"...), read(t, dr, 1, 3))
+
+	dr.Remove(1, 1)
+	require.False(t, dr.Contains(1, 1))
+	require.False(t, d.compressed.Contains(1, 1))
+}
+
+func TestUncompressedVersionsAreCompressedAfterRestart(t *testing.T) {
+	d, cleanup := newTestDrivers(t)
+	defer cleanup()
+
+	content := []byte("committed right before a restart")
+	write(t, d.scratch, 1, 0, 1, os.O_TRUNC, content)
+	// a compressed copy which was left incomplete
+	write(t, d.compressed, 1, 0, 1, os.O_TRUNC, []byte("garbage"))
+
+	dr := d.new(t, Gzip)
+	require.False(t, d.scratch.Contains(1, 1))
+	require.Equal(t, content, read(t, dr, 1, 1))
+}
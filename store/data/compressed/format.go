@@ -0,0 +1,199 @@
+package compressed
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/dimitarvdimitrov/sporkfs/store/data"
+)
+
+// A compressed version is stored as a sequence of independently compressed frames followed by an index and
+// a trailer. Each frame holds frameSize bytes of the file, except the last one which may hold fewer.
+// The index has the offset in the stored file at which each frame ends. This lets readers decompress only
+// the frames which they read.
+//
+//	| frame 0 | frame 1 | ... | frame n-1 | index: n x uint64 | trailer |
+const (
+	frameSize   = data.BlockSize
+	trailerSize = 32
+	magic       = "sporkgz1"
+)
+
+var errCorrupt = errors.New("corrupt compressed file")
+
+type trailer struct {
+	Size        uint64
+	IndexOffset uint64
+	FrameSize   uint32
+	Frames      uint32
+	Magic       [8]byte
+}
+
+// compress writes the size bytes of src to dst in the frame format.
+func compress(dst io.Writer, src io.ReaderAt, size int64) error {
+	frames := int((size + frameSize - 1) / frameSize)
+	ends := make([]uint64, 0, frames)
+
+	var written uint64
+	plain := make([]byte, frameSize)
+	compressed := &bytes.Buffer{}
+	gz := gzip.NewWriter(compressed)
+
+	for off := int64(0); off < size; off += frameSize {
+		n, err := src.ReadAt(plain, off)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if int64(n) < frameSize && off+int64(n) < size {
+			return fmt.Errorf("short read at %d: %w", off, io.ErrUnexpectedEOF)
+		}
+
+		compressed.Reset()
+		gz.Reset(compressed)
+		if _, err = gz.Write(plain[:n]); err != nil {
+			return err
+		}
+		if err = gz.Close(); err != nil {
+			return err
+		}
+		if _, err = dst.Write(compressed.Bytes()); err != nil {
+			return err
+		}
+		written += uint64(compressed.Len())
+		ends = append(ends, written)
+	}
+
+	if err := binary.Write(dst, binary.BigEndian, ends); err != nil {
+		return err
+	}
+	t := trailer{
+		Size:        uint64(size),
+		IndexOffset: written,
+		FrameSize:   frameSize,
+		Frames:      uint32(len(ends)),
+	}
+	copy(t.Magic[:], magic)
+	return binary.Write(dst, binary.BigEndian, t)
+}
+
+// readIndex returns the trailer and the index of a stored file of the given size.
+func readIndex(r io.ReaderAt, storedSize int64) (trailer, []uint64, error) {
+	var t trailer
+	if storedSize < trailerSize {
+		return t, nil, fmt.Errorf("file of %d bytes is too short for a trailer: %w", storedSize, errCorrupt)
+	}
+	buff := make([]byte, trailerSize)
+	if _, err := r.ReadAt(buff, storedSize-trailerSize); err != nil {
+		return t, nil, fmt.Errorf("reading trailer: %w", err)
+	}
+	if err := binary.Read(bytes.NewReader(buff), binary.BigEndian, &t); err != nil {
+		return t, nil, fmt.Errorf("decoding trailer: %w", err)
+	}
+	if string(t.Magic[:]) != magic ||
+		t.FrameSize == 0 ||
+		uint64(t.Frames) != (t.Size+uint64(t.FrameSize)-1)/uint64(t.FrameSize) ||
+		t.IndexOffset+uint64(t.Frames)*8+trailerSize != uint64(storedSize) {
+		return t, nil, fmt.Errorf("invalid trailer: %w", errCorrupt)
+	}
+
+	ends := make([]uint64, t.Frames)
+	indexBuff := make([]byte, len(ends)*8)
+	if _, err := r.ReadAt(indexBuff, int64(t.IndexOffset)); err != nil {
+		return t, nil, fmt.Errorf("reading index: %w", err)
+	}
+	if err := binary.Read(bytes.NewReader(indexBuff), binary.BigEndian, ends); err != nil {
+		return t, nil, fmt.Errorf("decoding index: %w", err)
+	}
+	for i, end := range ends {
+		if end > t.IndexOffset || (i > 0 && end < ends[i-1]) {
+			return t, nil, fmt.Errorf("invalid end of frame %d: %w", i, errCorrupt)
+		}
+	}
+	return t, ends, nil
+}
+
+// frameReader reads the plain content of a file in the frame format. It keeps the last decompressed frame,
+// so sequential reads which are smaller than a frame decompress each frame only once.
+type frameReader struct {
+	data.Reader
+	t    trailer
+	ends []uint64
+
+	m           sync.Mutex
+	cachedFrame int
+	cached      []byte
+}
+
+func newFrameReader(r data.Reader, t trailer, ends []uint64) *frameReader {
+	return &frameReader{
+		Reader:      r,
+		t:           t,
+		ends:        ends,
+		cachedFrame: -1,
+	}
+}
+
+func (r *frameReader) ReadAt(p []byte, off int64) (int, error) {
+	size := int64(r.t.Size)
+	frameSize := int64(r.t.FrameSize)
+
+	n := 0
+	for n < len(p) && off < size {
+		frame := int(off / frameSize)
+		content, err := r.frame(frame)
+		if err != nil {
+			return n, err
+		}
+		inFrame := off - int64(frame)*frameSize
+		if inFrame >= int64(len(content)) {
+			return n, fmt.Errorf("frame %d is shorter than expected: %w", frame, io.ErrUnexpectedEOF)
+		}
+		copied := copy(p[n:], content[inFrame:])
+		n += copied
+		off += int64(copied)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (r *frameReader) Read(p []byte) (int, error) {
+	return r.ReadAt(p, 0)
+}
+
+func (r *frameReader) frame(i int) ([]byte, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if r.cachedFrame == i {
+		return r.cached, nil
+	}
+
+	start := uint64(0)
+	if i > 0 {
+		start = r.ends[i-1]
+	}
+	compressed := make([]byte, r.ends[i]-start)
+	if _, err := r.Reader.ReadAt(compressed, int64(start)); err != nil {
+		return nil, fmt.Errorf("reading frame %d: %w", i, err)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing frame %d: %w", i, err)
+	}
+	content, err := ioutil.ReadAll(gz)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing frame %d: %w", i, err)
+	}
+
+	r.cachedFrame, r.cached = i, content
+	return content, nil
+}
@@ -0,0 +1,112 @@
+package compressed
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// memReader is a data.Reader over a byte slice.
+type memReader struct {
+	*bytes.Reader
+}
+
+func (memReader) Close() error {
+	return nil
+}
+
+func compressBytes(t *testing.T, content []byte) []byte {
+	stored := &bytes.Buffer{}
+	require.NoError(t, compress(stored, bytes.NewReader(content), int64(len(content))))
+	return stored.Bytes()
+}
+
+func TestFrameFormat(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	testCases := map[string]int{
+		"empty":                      0,
+		"single byte":                1,
+		"shorter than a frame":       frameSize - 1,
+		"exactly a frame":            frameSize,
+		"several frames":             3 * frameSize,
+		"several frames and a short": 3*frameSize + 17,
+	}
+
+	for name, size := range testCases {
+		t.Run(name, func(t *testing.T) {
+			content := make([]byte, size)
+			random.Read(content[:size/2]) // the rest is zeros, which compress well
+			stored := compressBytes(t, content)
+
+			tr, ends, err := readIndex(bytes.NewReader(stored), int64(len(stored)))
+			require.NoError(t, err)
+			require.EqualValues(t, size, tr.Size)
+			require.Len(t, ends, (size+frameSize-1)/frameSize)
+
+			r := newFrameReader(memReader{bytes.NewReader(stored)}, tr, ends)
+			actual := make([]byte, size)
+			n, err := r.ReadAt(actual, 0)
+			require.Equal(t, size, n)
+			if size > 0 {
+				require.NoError(t, err)
+			}
+			require.Equal(t, content, actual)
+
+			for i := 0; i < 20 && size > 0; i++ {
+				off := random.Int63n(int64(size))
+				length := random.Int63n(int64(size)-off) + 1
+				actual := make([]byte, length)
+				n, err := r.ReadAt(actual, off)
+				require.NoError(t, err)
+				require.EqualValues(t, length, n)
+				require.Equal(t, content[off:off+length], actual)
+			}
+
+			// reading past the end
+			n, err = r.ReadAt(make([]byte, 10), int64(size))
+			require.Zero(t, n)
+			require.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestReadIndexRejectsInvalidFiles(t *testing.T) {
+	content := make([]byte, 2*frameSize+5)
+	rand.New(rand.NewSource(1)).Read(content)
+	valid := compressBytes(t, content)
+
+	testCases := map[string]func() []byte{
+		"plain content": func() []byte {
+			return content
+		},
+		"too short": func() []byte {
+			return valid[len(valid)-trailerSize+1:]
+		},
+		"truncated": func() []byte {
+			return valid[1:]
+		},
+		"wrong magic": func() []byte {
+			corrupt := append([]byte(nil), valid...)
+			corrupt[len(corrupt)-1] ^= 0xff
+			return corrupt
+		},
+		"frame ends after the index": func() []byte {
+			corrupt := append([]byte(nil), valid...)
+			indexOffset := len(corrupt) - trailerSize - 3*8
+			corrupt[indexOffset] = 0xff
+			return corrupt
+		},
+	}
+
+	for name, stored := range testCases {
+		t.Run(name, func(t *testing.T) {
+			stored := stored()
+			_, _, err := readIndex(bytes.NewReader(stored), int64(len(stored)))
+			require.Error(t, err)
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"sync"
 
+	"github.com/dimitarvdimitrov/sporkfs/crypt"
 	"github.com/dimitarvdimitrov/sporkfs/log"
 	"github.com/dimitarvdimitrov/sporkfs/store"
 	"go.uber.org/zap"
@@ -18,17 +19,35 @@ type localDriver struct {
 	// TODO get rid of the index and proxy all checking to the underlying FS, shouldn't be needed
 	// 	anyways since generateStorageLocation is now pretty straight forward
 	index index // the values in the index are the relative locations to the storageRoot
+
+	partialsM *sync.Mutex
+	partials  map[uint64]map[uint64]*partialFile
+
+	open fileOpener
 }
 
 func NewLocalDriver(location string) (*localDriver, error) {
+	return newLocalDriver(location, openPlain)
+}
+
+// NewEncryptedLocalDriver returns a driver which keeps the files encrypted with the key. Files can only
+// be read with the key with which they were written.
+func NewEncryptedLocalDriver(location string, key *crypt.Key) (*localDriver, error) {
+	return newLocalDriver(location, encryptedOpener(key))
+}
+
+func newLocalDriver(location string, open fileOpener) (*localDriver, error) {
 	if err := os.MkdirAll(location+"/", 0777); err != nil {
 		return nil, err
 	}
 
 	return &localDriver{
+		open:        open,
 		storageRoot: location + "/",
 		index:       buildIndex(location),
 		indexM:      &sync.RWMutex{},
+		partialsM:   &sync.Mutex{},
+		partials:    make(map[uint64]map[uint64]*partialFile),
 	}, nil
 }
 
@@ -47,7 +66,21 @@ func (d *localDriver) ContainsAny(id uint64) bool {
 	return len(d.index[id]) > 0
 }
 
+func (d *localDriver) Versions() map[uint64][]uint64 {
+	d.indexM.RLock()
+	defer d.indexM.RUnlock()
+
+	versions := make(map[uint64][]uint64, len(d.index))
+	for id, locations := range d.index {
+		for version := range locations {
+			versions[id] = append(versions[id], version)
+		}
+	}
+	return versions
+}
+
 func (d *localDriver) Remove(id, version uint64) {
+	d.removePartial(id, version)
 	if !d.Contains(id, version) {
 		return
 	}
@@ -65,6 +98,31 @@ func (d *localDriver) Remove(id, version uint64) {
 	}
 }
 
+func (d *localDriver) Copy(srcId, srcVersion, id, version uint64) error {
+	d.indexM.RLock()
+	srcLocation, exists := d.index[srcId][srcVersion]
+	d.indexM.RUnlock()
+	if !exists {
+		if srcVersion != 0 {
+			return store.ErrNoSuchFile
+		}
+		srcLocation = generateStorageLocation(srcId, srcVersion)
+	}
+
+	location := generateStorageLocation(id, version)
+	if err := duplicateFile(d.storageRoot+srcLocation, d.storageRoot+location); err != nil {
+		return err
+	}
+
+	d.indexM.Lock()
+	defer d.indexM.Unlock()
+	if d.index[id] == nil {
+		d.index[id] = make(map[uint64]string)
+	}
+	d.index[id][version] = location
+	return nil
+}
+
 func removeFromDisk(path string) {
 	err := os.Remove(path)
 	if err != nil {
@@ -86,7 +144,7 @@ func (d *localDriver) Reader(id, version uint64, flags int) (Reader, error) {
 		}
 	}
 
-	f, err := os.OpenFile(d.storageRoot+location, flags|os.O_CREATE, store.ModeRegularFile)
+	f, err := d.open(d.storageRoot+location, flags|os.O_CREATE, store.ModeRegularFile)
 	if err != nil {
 		return nil, fmt.Errorf("file id=%d was in index but not on disk: %w", id, err)
 	}
@@ -94,7 +152,7 @@ func (d *localDriver) Reader(id, version uint64, flags int) (Reader, error) {
 	return d.newSegReader(f), nil
 }
 
-func (d *localDriver) newSegReader(f *os.File) *segmentedReader {
+func (d *localDriver) newSegReader(f file) *segmentedReader {
 	return &segmentedReader{
 		f: f,
 		onClose: func() {
@@ -133,11 +191,11 @@ func (d *localDriver) Open(id, oldVersion, newVersion uint64, flags int) (Reader
 // file handle to the new duplicate with the provided flags. If the flags contains os.O_TRUNC
 // or the version is 0, there will be no copying - just a new empty file will be created.
 // It also returns the location of the file relative to the storage root.
-func (d *localDriver) handleForWriting(id, oldVersion, newVersion uint64, flags int) (*os.File, string, error) {
+func (d *localDriver) handleForWriting(id, oldVersion, newVersion uint64, flags int) (file, string, error) {
 	if oldVersion == 0 || flags&os.O_TRUNC != 0 {
 		newLocation := generateStorageLocation(id, newVersion)
 		newFilePath := d.storageRoot + newLocation
-		f, err := os.OpenFile(newFilePath, flags|os.O_CREATE, store.ModeRegularFile)
+		f, err := d.open(newFilePath, flags|os.O_CREATE, store.ModeRegularFile)
 		return f, newLocation, err
 	}
 
@@ -161,7 +219,7 @@ func (d *localDriver) handleForWriting(id, oldVersion, newVersion uint64, flags
 		)
 		return nil, "", err
 	}
-	f, err := os.OpenFile(newFilePath, flags, store.ModeRegularFile)
+	f, err := d.open(newFilePath, flags, store.ModeRegularFile)
 	return f, newLocation, err
 }
 
@@ -186,7 +244,7 @@ func (d *localDriver) Writer(id, oldVersion, newVersion uint64, flags int) (Writ
 	return segWriter, nil
 }
 
-func (d *localDriver) newSegWriter(id, oldVersion, newVersion uint64, file *os.File, newLocation string) *segmentedWriter {
+func (d *localDriver) newSegWriter(id, oldVersion, newVersion uint64, file file, newLocation string) *segmentedWriter {
 	onClose := func() {
 		_ = file.Close()
 		d.indexM.Lock()
@@ -210,7 +268,7 @@ func (d *localDriver) newSegWriter(id, oldVersion, newVersion uint64, file *os.F
 	}
 }
 
-func syncer(f *os.File) func() {
+func syncer(f file) func() {
 	return func() {
 		_ = f.Sync()
 	}
@@ -228,6 +286,9 @@ func duplicateFile(oldAbsolute, newAbsolute string) error {
 		return err
 	}
 	defer destination.Close()
+	if cloneFile(destination, source) == nil {
+		return nil
+	}
 	// we sacrifice some memory to make it faster
 	_, err = io.CopyBuffer(destination, source, make([]byte, 1024*1024))
 	return err
@@ -235,17 +296,16 @@ func duplicateFile(oldAbsolute, newAbsolute string) error {
 
 func (d *localDriver) Size(id, version uint64) int64 {
 	d.indexM.RLock()
-	f, err := os.Open(d.storageRoot + d.index[id][version])
+	f, err := d.open(d.storageRoot+d.index[id][version], os.O_RDONLY, store.ModeRegularFile)
 	d.indexM.RUnlock()
 	if err != nil {
 		return 0
 	}
 	defer f.Close()
 
-	info, err := f.Stat()
+	size, err := f.Size()
 	if err != nil {
 		return 0
 	}
-
-	return info.Size()
+	return size
 }
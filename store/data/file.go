@@ -0,0 +1,53 @@
+package data
+
+import (
+	"io"
+	"os"
+
+	"github.com/dimitarvdimitrov/sporkfs/crypt"
+)
+
+// file is what the driver needs from the files in which it keeps versions.
+type file interface {
+	io.ReaderAt
+	io.WriterAt
+	io.Writer
+	io.Closer
+	Sync() error
+	Truncate(size int64) error
+	// Size returns the size of the content of the file, which may differ from its size on disk.
+	Size() (int64, error)
+	Name() string
+}
+
+type fileOpener func(path string, flag int, perm os.FileMode) (file, error)
+
+type plainFile struct {
+	*os.File
+}
+
+func (f plainFile) Size() (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func openPlain(path string, flag int, perm os.FileMode) (file, error) {
+	f, err := os.OpenFile(path, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return plainFile{f}, nil
+}
+
+func encryptedOpener(key *crypt.Key) fileOpener {
+	return func(path string, flag int, perm os.FileMode) (file, error) {
+		f, err := key.OpenFile(path, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+}
@@ -29,14 +29,32 @@ func buildIndex(location string) (idx index) {
 		return
 	}
 
+	exists := make(map[string]bool, len(files))
 	for _, f := range files {
-		nameComponents := strings.SplitN(f.Name(), "-", -1)
-		if len(nameComponents) != 2 {
+		exists[f.Name()] = true
+	}
+
+	for _, f := range files {
+		switch {
+		case strings.HasSuffix(f.Name(), partialSuffix):
+			if !exists[f.Name()+stateSuffix] {
+				// there is no way to tell which parts of it were written
+				go removeFromDisk(location + "/" + f.Name())
+			}
+			continue
+		case strings.HasSuffix(f.Name(), newStateSuffix):
+			// the state was being written when the node stopped
+			go removeFromDisk(location + "/" + f.Name())
+			continue
+		case strings.HasSuffix(f.Name(), stateSuffix):
+			if !exists[strings.TrimSuffix(f.Name(), stateSuffix)] {
+				go removeFromDisk(location + "/" + f.Name())
+			}
 			continue
 		}
-		id, err1 := strconv.ParseUint(nameComponents[0], 10, 64)
-		version, err2 := strconv.ParseUint(nameComponents[1], 10, 64)
-		if err1 != nil || err2 != nil {
+
+		id, version, ok := parseStorageLocation(f.Name())
+		if !ok {
 			continue
 		}
 		if idx[id] == nil {
@@ -47,6 +65,16 @@ func buildIndex(location string) (idx index) {
 	return
 }
 
+func parseStorageLocation(location string) (id, version uint64, ok bool) {
+	nameComponents := strings.SplitN(location, "-", -1)
+	if len(nameComponents) != 2 {
+		return 0, 0, false
+	}
+	id, err1 := strconv.ParseUint(nameComponents[0], 10, 64)
+	version, err2 := strconv.ParseUint(nameComponents[1], 10, 64)
+	return id, version, err1 == nil && err2 == nil
+}
+
 func generateStorageLocation(id, version uint64) string {
 	return fmt.Sprintf("%d-%d", id, version)
 }
@@ -0,0 +1,503 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dimitarvdimitrov/sporkfs/log"
+	"github.com/dimitarvdimitrov/sporkfs/store"
+	"go.uber.org/zap"
+)
+
+// BlockSize is the granularity at which partial files keep track of which of their parts are present.
+const BlockSize = 1 << 18
+
+const (
+	partialSuffix = ".partial"
+	// the state file records which blocks of the partial file have been durably written
+	stateSuffix = ".state"
+	// the state is written to a temporary file first and then renamed over the state file
+	newStateSuffix = stateSuffix + ".new"
+	// checkpointBlocks is the number of blocks written between checkpoints of a partial file's state
+	checkpointBlocks = 16
+)
+
+// Range is a contiguous part of a file.
+type Range struct {
+	Off, Len int64
+}
+
+// PartialFile is a version of a file of which only some blocks are present locally. Blocks can be written in any
+// order, but each write needs to start at a block boundary and only blocks which are fully covered by a write
+// are considered present. The last block may be shorter than BlockSize.
+//
+// Partial files survive failed transfers and restarts. The blocks which were written before the last checkpoint
+// are still present the next time the version is opened, so the transfer can resume from there.
+//
+// Multiple readers can share a partial file. To avoid fetching the same block twice, a reader first claims
+// the blocks it is going to write. Other readers wait for claimed blocks instead of claiming them too.
+type PartialFile interface {
+	Reader
+	WriteAt(p []byte, off int64) (int, error)
+
+	// Claim returns the block-aligned ranges overlapping [off, off+length) which are neither present nor
+	// claimed and marks them as claimed by the caller. The caller needs to either write or Unclaim them.
+	Claim(off, length int64) []Range
+	// Unclaim marks the blocks overlapping the range as missing again, so that they can be claimed by others.
+	Unclaim(Range)
+	// Wait blocks until all blocks overlapping [off, off+length) are present. It returns false if any of those
+	// blocks is neither present nor claimed; such blocks need to be claimed first.
+	Wait(off, length int64) bool
+	Complete() bool
+	Size() int64
+}
+
+type blockState uint8
+
+const (
+	blockMissing blockState = iota
+	blockClaimed
+	blockPresent
+)
+
+type partialFile struct {
+	*sync.Mutex
+
+	id, version uint64
+	size        int64
+	f           file
+	blocks      []blockState
+	missing     int // number of blocks which aren't present
+	written     *sync.Cond
+	refs        int  // guarded by the driver's partialsM
+	removed     bool // guarded by the driver's partialsM
+	// released is closed once the last handle has been checkpointed and closed. Until then the partial file stays
+	// in the driver's partials, so that no one creates a new one for the same version in the meantime.
+	released chan struct{}
+
+	// blocks written since the last checkpoint; guarded by the partial file's lock
+	sinceCheckpoint int
+	checkpointM     *sync.Mutex
+
+	// onComplete is called once all blocks have been written. onRelease is called on every Close.
+	onComplete, onRelease func(*partialFile)
+}
+
+// Partial returns a handle to a partial copy of the version. All handles to the same version share their state.
+// Once all blocks have been written, the version becomes available via the rest of the driver's methods.
+// Incomplete versions are checkpointed when the last handle to them is closed and are resumed by the next call.
+func (d *localDriver) Partial(id, version uint64, size int64) (PartialFile, error) {
+	d.partialsM.Lock()
+	defer d.partialsM.Unlock()
+
+	for {
+		p, ok := d.partials[id][version]
+		if !ok {
+			break
+		}
+		if p.refs > 0 {
+			p.refs++
+			return p, nil
+		}
+		// the last handle is being closed; its checkpoint needs to finish before the file can be opened again
+		released := p.released
+		d.partialsM.Unlock()
+		<-released
+		d.partialsM.Lock()
+	}
+
+	path := d.storageRoot + generateStorageLocation(id, version) + partialSuffix
+	p, err := resumePartial(d.open, path, size)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn("[data] couldn't resume partial file; starting over", log.Id(id), log.Ver(version), zap.Error(err))
+		}
+		if p, err = newPartial(d.open, path, size); err != nil {
+			return nil, err
+		}
+	} else {
+		log.Debug("[data] resuming partial file", log.Id(id), log.Ver(version), zap.Int("missing_blocks", p.missing))
+	}
+	p.id, p.version = id, version
+	p.onComplete, p.onRelease = d.promotePartial, d.releasePartial
+	if p.missing == 0 {
+		// we stopped right before promoting it last time
+		d.promotePartial(p)
+	}
+
+	if d.partials[id] == nil {
+		d.partials[id] = make(map[uint64]*partialFile)
+	}
+	d.partials[id][version] = p
+	return p, nil
+}
+
+func newPartial(open fileOpener, path string, size int64) (*partialFile, error) {
+	f, err := open(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, store.ModeRegularFile)
+	if err != nil {
+		return nil, fmt.Errorf("creating partial file: %w", err)
+	}
+	if err = f.Truncate(size); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("allocating partial file: %w", err)
+	}
+	_ = os.Remove(path + stateSuffix)
+
+	blocks := int((size + BlockSize - 1) / BlockSize)
+	return newPartialFile(f, size, make([]blockState, blocks)), nil
+}
+
+// resumePartial opens a partial file left from a previous transfer. Only the blocks which were present at the
+// last checkpoint are considered present.
+func resumePartial(open fileOpener, path string, size int64) (*partialFile, error) {
+	state, err := readPartialState(path + stateSuffix)
+	if err != nil {
+		return nil, err
+	}
+	blocks := int((size + BlockSize - 1) / BlockSize)
+	if state.Size != size || len(state.Blocks) != blocks {
+		return nil, fmt.Errorf("partial file has size %d, expected %d", state.Size, size)
+	}
+
+	f, err := open(path, os.O_RDWR, store.ModeRegularFile)
+	if err != nil {
+		return nil, err
+	}
+	if actual, err := f.Size(); err != nil || actual != size {
+		_ = f.Close()
+		return nil, fmt.Errorf("partial file doesn't match its state")
+	}
+
+	states := make([]blockState, blocks)
+	for i, present := range state.Blocks {
+		if present != 0 {
+			states[i] = blockPresent
+		}
+	}
+	return newPartialFile(f, size, states), nil
+}
+
+func newPartialFile(f file, size int64, blocks []blockState) *partialFile {
+	p := &partialFile{
+		Mutex:       &sync.Mutex{},
+		size:        size,
+		f:           f,
+		blocks:      blocks,
+		refs:        1,
+		released:    make(chan struct{}),
+		checkpointM: &sync.Mutex{},
+	}
+	for _, b := range blocks {
+		if b != blockPresent {
+			p.missing++
+		}
+	}
+	p.written = sync.NewCond(p.Mutex)
+	return p
+}
+
+// PartialVersions returns the versions of which only some blocks are present, grouped by file id.
+func (d *localDriver) PartialVersions() map[uint64][]uint64 {
+	paths, err := filepath.Glob(d.storageRoot + "*" + partialSuffix)
+	if err != nil {
+		log.Error("[data] listing partial files", zap.Error(err))
+		return nil
+	}
+
+	versions := make(map[uint64][]uint64)
+	for _, path := range paths {
+		id, version, ok := parseStorageLocation(strings.TrimSuffix(filepath.Base(path), partialSuffix))
+		if ok {
+			versions[id] = append(versions[id], version)
+		}
+	}
+	return versions
+}
+
+// removePartial deletes what was written of the version. If the version is currently open, it's deleted
+// once the last handle to it is closed.
+func (d *localDriver) removePartial(id, version uint64) {
+	d.partialsM.Lock()
+	defer d.partialsM.Unlock()
+
+	if p, ok := d.partials[id][version]; ok {
+		p.removed = true
+		return
+	}
+	path := d.storageRoot + generateStorageLocation(id, version) + partialSuffix
+	if _, err := os.Stat(path); err == nil {
+		go removeFromDisk(path)
+		go removeFromDisk(path + stateSuffix)
+	}
+}
+
+// promotePartial makes the complete partial file available as a regular version.
+func (d *localDriver) promotePartial(p *partialFile) {
+	location := generateStorageLocation(p.id, p.version)
+	if err := p.f.Sync(); err != nil {
+		log.Error("[data] syncing completed partial file", log.Id(p.id), log.Ver(p.version), zap.Error(err))
+		return
+	}
+	if err := os.Rename(p.f.Name(), d.storageRoot+location); err != nil {
+		log.Error("[data] promoting completed partial file", log.Id(p.id), log.Ver(p.version), zap.Error(err))
+		return
+	}
+
+	d.indexM.Lock()
+	if d.index[p.id] == nil {
+		d.index[p.id] = make(map[uint64]string)
+	}
+	d.index[p.id][p.version] = location
+	d.indexM.Unlock()
+	_ = os.Remove(p.f.Name() + stateSuffix)
+	log.Debug("[data] partial file is complete", log.Id(p.id), log.Ver(p.version))
+}
+
+func (d *localDriver) releasePartial(p *partialFile) {
+	d.partialsM.Lock()
+	p.refs--
+	if p.refs > 0 {
+		d.partialsM.Unlock()
+		return
+	}
+	removed := p.removed
+	d.partialsM.Unlock()
+
+	if !removed {
+		// keep what was written so that the next transfer can resume from it
+		p.checkpoint()
+	}
+
+	p.Lock()
+	path := p.f.Name()
+	_ = p.f.Close()
+	complete := p.missing == 0
+	p.Unlock()
+
+	d.partialsM.Lock()
+	defer d.partialsM.Unlock()
+	delete(d.partials[p.id], p.version)
+	if len(d.partials[p.id]) == 0 {
+		delete(d.partials, p.id)
+	}
+	// it may have been removed while we were checkpointing it
+	if p.removed && !complete {
+		removeFromDisk(path)
+		removeFromDisk(path + stateSuffix)
+	}
+	close(p.released)
+}
+
+// checkpoint makes the blocks which are present so far durable and records them in the state file.
+func (p *partialFile) checkpoint() {
+	p.checkpointM.Lock()
+	defer p.checkpointM.Unlock()
+
+	p.Lock()
+	if p.missing == 0 {
+		p.Unlock()
+		return // it's been promoted and doesn't need a state anymore
+	}
+	state := partialState{Size: p.size, Blocks: make([]byte, len(p.blocks))}
+	for i, b := range p.blocks {
+		if b == blockPresent {
+			state.Blocks[i] = 1
+		}
+	}
+	p.sinceCheckpoint = 0
+	p.Unlock()
+
+	// the blocks need to be on disk before the state says they are
+	if err := p.f.Sync(); err != nil {
+		log.Error("[data] syncing partial file", log.Id(p.id), log.Ver(p.version), zap.Error(err))
+		return
+	}
+	statePath := p.f.Name() + stateSuffix
+	if err := writePartialState(p.f.Name(), state); err != nil {
+		log.Error("[data] persisting partial file state", log.Id(p.id), log.Ver(p.version), zap.Error(err))
+		return
+	}
+	if p.Complete() {
+		// it was promoted while we were writing the state
+		_ = os.Remove(statePath)
+	}
+}
+
+type partialState struct {
+	Size int64 `json:"size"`
+	// Blocks has one element per block; 1 means the block is present
+	Blocks []byte `json:"blocks"`
+}
+
+func readPartialState(path string) (partialState, error) {
+	var state partialState
+	f, err := os.Open(path)
+	if err != nil {
+		return state, err
+	}
+	defer f.Close()
+
+	if err = json.NewDecoder(f).Decode(&state); err != nil {
+		return state, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// writePartialState replaces the state of the partial file at partialPath.
+func writePartialState(partialPath string, state partialState) error {
+	f, err := os.Create(partialPath + newStateSuffix)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err = json.NewEncoder(f).Encode(state); err != nil {
+		return fmt.Errorf("encoding partial file state: %w", err)
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	_ = f.Close()
+
+	return os.Rename(f.Name(), partialPath+stateSuffix)
+}
+
+func (p *partialFile) Size() int64 {
+	return p.size
+}
+
+func (p *partialFile) Complete() bool {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.missing == 0
+}
+
+func (p *partialFile) ReadAt(b []byte, off int64) (int, error) {
+	return p.f.ReadAt(b, off)
+}
+
+func (p *partialFile) Read(b []byte) (int, error) {
+	return p.ReadAt(b, 0)
+}
+
+func (p *partialFile) WriteAt(b []byte, off int64) (int, error) {
+	if off%BlockSize != 0 {
+		return 0, fmt.Errorf("partial write at %d isn't aligned to a block", off)
+	}
+
+	n, err := p.f.WriteAt(b, off)
+
+	p.Lock()
+	wasMissing := p.missing
+	for block := off / BlockSize; block < int64(len(p.blocks)); block++ {
+		blockEnd := (block + 1) * BlockSize
+		if blockEnd > p.size {
+			blockEnd = p.size
+		}
+		if blockEnd > off+int64(n) {
+			break
+		}
+		if p.blocks[block] != blockPresent {
+			p.blocks[block] = blockPresent
+			p.missing--
+			p.sinceCheckpoint++
+		}
+	}
+	p.written.Broadcast()
+
+	if wasMissing > 0 && p.missing == 0 {
+		p.onComplete(p)
+	}
+	needsCheckpoint := p.missing > 0 && p.sinceCheckpoint >= checkpointBlocks
+	p.Unlock()
+
+	if needsCheckpoint {
+		p.checkpoint()
+	}
+	return n, err
+}
+
+func (p *partialFile) Claim(off, length int64) (claimed []Range) {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, block := range p.blockRange(off, length) {
+		if p.blocks[block] != blockMissing {
+			continue
+		}
+		p.blocks[block] = blockClaimed
+
+		blockOff := block * BlockSize
+		blockLen := int64(BlockSize)
+		if blockOff+blockLen > p.size {
+			blockLen = p.size - blockOff
+		}
+
+		if last := len(claimed) - 1; last >= 0 && claimed[last].Off+claimed[last].Len == blockOff {
+			claimed[last].Len += blockLen
+		} else {
+			claimed = append(claimed, Range{Off: blockOff, Len: blockLen})
+		}
+	}
+	return
+}
+
+func (p *partialFile) Unclaim(r Range) {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, block := range p.blockRange(r.Off, r.Len) {
+		if p.blocks[block] == blockClaimed {
+			p.blocks[block] = blockMissing
+		}
+	}
+	p.written.Broadcast()
+}
+
+func (p *partialFile) Wait(off, length int64) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	blocks := p.blockRange(off, length)
+	for {
+		allPresent := true
+		for _, block := range blocks {
+			switch p.blocks[block] {
+			case blockMissing:
+				return false
+			case blockClaimed:
+				allPresent = false
+			}
+		}
+		if allPresent {
+			return true
+		}
+		p.written.Wait()
+	}
+}
+
+// blockRange returns the indices of the blocks overlapping [off, off+length)
+func (p *partialFile) blockRange(off, length int64) (blocks []int64) {
+	if off+length > p.size {
+		length = p.size - off
+	}
+	if length <= 0 {
+		return nil
+	}
+
+	for block := off / BlockSize; block*BlockSize < off+length; block++ {
+		blocks = append(blocks, block)
+	}
+	return
+}
+
+// Close needs to be called once per call to Partial.
+func (p *partialFile) Close() error {
+	p.onRelease(p)
+	return nil
+}
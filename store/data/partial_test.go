@@ -0,0 +1,221 @@
+package data
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testPartialSize = 3*BlockSize + 100
+
+func newTestPartial(t *testing.T, d Driver) PartialFile {
+	p, err := d.Partial(1, 1, testPartialSize)
+	require.NoError(t, err)
+	return p
+}
+
+func TestPartialBlocks(t *testing.T) {
+	testCases := map[string]struct {
+		// written and claimed are done before claiming the range
+		written, claimed []Range
+		claim            Range
+		expected         []Range
+	}{
+		"nothing written": {
+			claim:    Range{Off: 0, Len: testPartialSize},
+			expected: []Range{{Off: 0, Len: testPartialSize}},
+		},
+		"within a block": {
+			claim:    Range{Off: BlockSize + 10, Len: 10},
+			expected: []Range{{Off: BlockSize, Len: BlockSize}},
+		},
+		"across blocks": {
+			claim:    Range{Off: BlockSize - 1, Len: 2},
+			expected: []Range{{Off: 0, Len: 2 * BlockSize}},
+		},
+		"the short last block": {
+			claim:    Range{Off: 3 * BlockSize, Len: BlockSize},
+			expected: []Range{{Off: 3 * BlockSize, Len: 100}},
+		},
+		"past the end": {
+			claim: Range{Off: testPartialSize, Len: 10},
+		},
+		"around written blocks": {
+			written:  []Range{{Off: BlockSize, Len: BlockSize}},
+			claim:    Range{Off: 0, Len: testPartialSize},
+			expected: []Range{{Off: 0, Len: BlockSize}, {Off: 2 * BlockSize, Len: BlockSize + 100}},
+		},
+		"partly written block": {
+			written:  []Range{{Off: 0, Len: BlockSize - 1}},
+			claim:    Range{Off: 0, Len: 1},
+			expected: []Range{{Off: 0, Len: BlockSize}},
+		},
+		"around claimed blocks": {
+			claimed:  []Range{{Off: 2 * BlockSize, Len: 1}},
+			claim:    Range{Off: BlockSize, Len: testPartialSize},
+			expected: []Range{{Off: BlockSize, Len: BlockSize}, {Off: 3 * BlockSize, Len: 100}},
+		},
+		"everything written": {
+			written: []Range{{Off: 0, Len: testPartialSize}},
+			claim:   Range{Off: 0, Len: testPartialSize},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			d, cleanup := newTestDriver(t)
+			defer cleanup()
+			p := newTestPartial(t, d)
+			defer p.Close()
+
+			for _, r := range tc.written {
+				_, err := p.WriteAt(make([]byte, r.Len), r.Off)
+				require.NoError(t, err)
+			}
+			for _, r := range tc.claimed {
+				p.Claim(r.Off, r.Len)
+			}
+			require.Equal(t, tc.expected, p.Claim(tc.claim.Off, tc.claim.Len))
+		})
+	}
+}
+
+func TestPartialWait(t *testing.T) {
+	d, cleanup := newTestDriver(t)
+	defer cleanup()
+	p := newTestPartial(t, d)
+	defer p.Close()
+
+	// missing blocks need to be claimed first
+	require.False(t, p.Wait(0, 1))
+
+	claimed := p.Claim(0, 2*BlockSize)
+	require.Equal(t, []Range{{Off: 0, Len: 2 * BlockSize}}, claimed)
+	done := make(chan bool)
+	go func() { done <- p.Wait(BlockSize, 1) }()
+
+	_, err := p.WriteAt(make([]byte, 2*BlockSize), 0)
+	require.NoError(t, err)
+	require.True(t, <-done)
+
+	// unclaimed blocks are missing again
+	p.Claim(2*BlockSize, 1)
+	go func() { done <- p.Wait(2*BlockSize, 1) }()
+	p.Unclaim(Range{Off: 2 * BlockSize, Len: BlockSize})
+	require.False(t, <-done)
+}
+
+func TestPartialCheckpoint(t *testing.T) {
+	d, cleanup := newTestDriver(t)
+	defer cleanup()
+	content := make([]byte, testPartialSize)
+	rand.New(rand.NewSource(1)).Read(content)
+
+	p := newTestPartial(t, d)
+	_, err := p.WriteAt(content[:BlockSize], 1)
+	require.Error(t, err, "unaligned writes are rejected")
+	_, err = p.WriteAt(content[BlockSize:2*BlockSize], BlockSize)
+	require.NoError(t, err)
+	require.NoError(t, p.Close())
+	require.Equal(t, map[uint64][]uint64{1: {1}}, d.PartialVersions())
+	require.False(t, d.Contains(1, 1))
+
+	// the written block survives closing the last handle
+	p = newTestPartial(t, d)
+	require.Equal(t, []Range{{Off: 0, Len: BlockSize}, {Off: 2 * BlockSize, Len: BlockSize + 100}}, p.Claim(0, testPartialSize))
+	_, err = p.WriteAt(content[:BlockSize], 0)
+	require.NoError(t, err)
+	_, err = p.WriteAt(content[2*BlockSize:], 2*BlockSize)
+	require.NoError(t, err)
+	require.True(t, p.Complete())
+	require.NoError(t, p.Close())
+
+	// once complete, it's a regular version
+	require.True(t, d.Contains(1, 1))
+	require.Empty(t, d.PartialVersions())
+	r, err := d.Reader(1, 1, os.O_RDONLY)
+	require.NoError(t, err)
+	defer r.Close()
+	actual := make([]byte, testPartialSize)
+	_, err = r.ReadAt(actual, 0)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(content, actual))
+}
+
+// slowSyncFile blocks in Sync until unblocked, after telling that it's syncing if no one was told yet.
+type slowSyncFile struct {
+	file
+	syncing chan<- struct{}
+	unblock <-chan struct{}
+}
+
+func (f slowSyncFile) Sync() error {
+	select {
+	case f.syncing <- struct{}{}:
+	default:
+	}
+	<-f.unblock
+	return f.file.Sync()
+}
+
+func TestPartialReopenedWhileClosing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sporkfs-data")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	syncing, unblock := make(chan struct{}, 1), make(chan struct{})
+	d, err := newLocalDriver(dir, func(path string, flag int, perm os.FileMode) (file, error) {
+		f, err := openPlain(path, flag, perm)
+		return slowSyncFile{file: f, syncing: syncing, unblock: unblock}, err
+	})
+	require.NoError(t, err)
+
+	content := make([]byte, BlockSize)
+	rand.New(rand.NewSource(1)).Read(content)
+	p := newTestPartial(t, d)
+	_, err = p.WriteAt(content, 0)
+	require.NoError(t, err)
+
+	// the last handle is closed and is being checkpointed when the version is opened again
+	closed := make(chan error)
+	go func() { closed <- p.Close() }()
+	<-syncing
+	reopened := make(chan PartialFile)
+	go func() { reopened <- newTestPartial(t, d) }()
+	select {
+	case <-reopened:
+		t.Fatal("opened before the checkpoint finished")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(unblock)
+	require.NoError(t, <-closed)
+
+	// the written block isn't lost to a new partial file
+	p = <-reopened
+	defer p.Close()
+	require.Nil(t, p.Claim(0, BlockSize))
+	actual := make([]byte, BlockSize)
+	_, err = p.ReadAt(actual, 0)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(content, actual))
+}
+
+func TestLeftoverPartialStates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sporkfs-data")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, generateStorageLocation(1, 1)+partialSuffix)
+	require.NoError(t, ioutil.WriteFile(path+newStateSuffix, []byte("{"), 0600))
+
+	_, err = NewLocalDriver(dir)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(path + newStateSuffix)
+		return os.IsNotExist(err)
+	}, time.Second, time.Millisecond)
+}
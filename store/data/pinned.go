@@ -0,0 +1,114 @@
+package data
+
+import "sync"
+
+// PinningDriver keeps pinned versions. Removing a pinned version is deferred until it's no longer pinned.
+type PinningDriver struct {
+	Driver
+
+	m sync.Mutex
+	// pins counts how many times each version is pinned
+	pins map[uint64]map[uint64]int
+	// removed are the pinned versions which would have been removed if they weren't pinned
+	removed map[uint64]map[uint64]bool
+}
+
+func NewPinningDriver(d Driver) *PinningDriver {
+	return &PinningDriver{
+		Driver:  d,
+		pins:    make(map[uint64]map[uint64]int),
+		removed: make(map[uint64]map[uint64]bool),
+	}
+}
+
+func (d *PinningDriver) Pin(id, version uint64) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	if d.pins[id] == nil {
+		d.pins[id] = make(map[uint64]int)
+	}
+	d.pins[id][version]++
+}
+
+// Unpin releases one pin of the version. If that was the last one and the version was removed while pinned,
+// it's removed now.
+func (d *PinningDriver) Unpin(id, version uint64) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	if d.pins[id][version] == 0 {
+		return
+	}
+	d.pins[id][version]--
+	if d.pins[id][version] > 0 {
+		return
+	}
+
+	delete(d.pins[id], version)
+	if len(d.pins[id]) == 0 {
+		delete(d.pins, id)
+	}
+	if d.removed[id][version] {
+		delete(d.removed[id], version)
+		if len(d.removed[id]) == 0 {
+			delete(d.removed, id)
+		}
+		d.Driver.Remove(id, version)
+	}
+}
+
+// SetPins replaces all pins with the counts of how many times each version is pinned. It's used when the pins
+// are rebuilt from the state which they come from. Versions which were removed while pinned and aren't pinned
+// anymore are removed now.
+func (d *PinningDriver) SetPins(pins map[uint64]map[uint64]int) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	d.pins = make(map[uint64]map[uint64]int, len(pins))
+	for id, versions := range pins {
+		for version, count := range versions {
+			if count <= 0 {
+				continue
+			}
+			if d.pins[id] == nil {
+				d.pins[id] = make(map[uint64]int)
+			}
+			d.pins[id][version] = count
+		}
+	}
+
+	for id, versions := range d.removed {
+		for version := range versions {
+			if d.pins[id][version] > 0 {
+				continue
+			}
+			delete(versions, version)
+			d.Driver.Remove(id, version)
+		}
+		if len(versions) == 0 {
+			delete(d.removed, id)
+		}
+	}
+}
+
+func (d *PinningDriver) Pinned(id, version uint64) bool {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	return d.pins[id][version] > 0
+}
+
+func (d *PinningDriver) Remove(id, version uint64) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	if d.pins[id][version] > 0 {
+		if d.removed[id] == nil {
+			d.removed[id] = make(map[uint64]bool)
+		}
+		d.removed[id][version] = true
+		return
+	}
+	d.Driver.Remove(id, version)
+}
@@ -0,0 +1,65 @@
+package data
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDriver returns a local driver in a temporary directory and a function which removes it.
+func newTestDriver(t *testing.T) (Driver, func()) {
+	dir, err := ioutil.TempDir("", "sporkfs-data")
+	require.NoError(t, err)
+	d, err := NewLocalDriver(dir)
+	require.NoError(t, err)
+	return d, func() { _ = os.RemoveAll(dir) }
+}
+
+func writeVersion(t *testing.T, d Driver, id, version uint64, content []byte) {
+	w, err := d.Writer(id, 0, version, os.O_TRUNC)
+	require.NoError(t, err)
+	_, err = w.Write(content)
+	require.NoError(t, err)
+	w.Commit()
+}
+
+func TestPinningDriver(t *testing.T) {
+	local, cleanup := newTestDriver(t)
+	defer cleanup()
+	d := NewPinningDriver(local)
+	for version := uint64(1); version <= 4; version++ {
+		writeVersion(t, d, 1, version, []byte("content"))
+	}
+
+	d.Pin(1, 1)
+	d.Pin(1, 1)
+	d.Pin(1, 2)
+	d.Pin(1, 3)
+
+	// removing pinned versions is deferred
+	d.Remove(1, 1)
+	d.Remove(1, 2)
+	d.Remove(1, 3)
+	d.Remove(1, 4)
+	require.True(t, d.Contains(1, 1))
+	require.True(t, d.Contains(1, 2))
+	require.True(t, d.Contains(1, 3))
+	require.False(t, d.Contains(1, 4))
+
+	// until the last pin is released
+	d.Unpin(1, 1)
+	require.True(t, d.Contains(1, 1))
+	d.Unpin(1, 1)
+	require.False(t, d.Contains(1, 1))
+	require.False(t, d.Pinned(1, 1))
+
+	// rebuilt pins release the versions which aren't pinned anymore
+	d.SetPins(map[uint64]map[uint64]int{1: {3: 1, 5: 2}})
+	require.False(t, d.Contains(1, 2))
+	require.True(t, d.Contains(1, 3))
+	require.True(t, d.Pinned(1, 5))
+	d.Unpin(1, 3)
+	require.False(t, d.Contains(1, 3))
+}
@@ -16,7 +16,16 @@ type Driver interface {
 	Open(id, oldVersion, newVersion uint64, flags int) (Reader, Writer, error)
 	Reader(id, version uint64, flags int) (Reader, error)
 	Remove(id, version uint64)
+	// Copy duplicates the version of the source file as the version of another file. It doesn't go through
+	// readers and writers and, where the file system supports it, the copy shares the blocks of the source.
+	Copy(srcId, srcVersion, id, version uint64) error
 	Size(id, version uint64) int64
+	// Partial returns a handle to a copy of the version which may only be partially present. See PartialFile.
+	Partial(id, version uint64, size int64) (PartialFile, error)
+	// Versions returns all versions of all files that the driver holds, grouped by file id.
+	Versions() map[uint64][]uint64
+	// PartialVersions returns the versions which are only partially present, grouped by file id.
+	PartialVersions() map[uint64][]uint64
 
 	// Write will return a Writer to the file and version with the flags.
 	// If the version is 0, a new empty file will be created and returned.
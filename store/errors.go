@@ -7,4 +7,9 @@ var (
 	ErrFileAlreadyExists = errors.New("[spork]: file exists")
 	ErrDirectoryNotEmpty = errors.New("[spork]: directory not empty")
 	ErrStaleHandle       = errors.New("[spork]: stale file handle")
+	ErrPermissionDenied  = errors.New("[spork]: permission denied")
+	ErrNotDirectory      = errors.New("[spork]: not a directory")
+	ErrIsDirectory       = errors.New("[spork]: is a directory")
+	ErrQuotaExceeded     = errors.New("[spork]: disk quota exceeded")
+	ErrReadOnly          = errors.New("[spork]: read-only file system")
 )
@@ -1,7 +1,9 @@
 package store
 
 import (
+	"encoding/binary"
 	"encoding/json"
+	"hash/fnv"
 	"io"
 	"os"
 	"sync"
@@ -25,11 +27,40 @@ type File struct {
 	Version uint64
 	Atime   time.Time
 	Mtime   time.Time
+	// ACL, Quota and Snapshots are only set on directories
+	ACL       ACL         `json:",omitempty"`
+	Quota     *Quota      `json:",omitempty"`
+	Snapshots []*Snapshot `json:",omitempty"`
+	// ReadOnly files are in snapshots
+	ReadOnly bool `json:",omitempty"`
+	// Inode is the inode number of virtual files and of the copies of files in snapshots. They keep the Id of the
+	// file which they show, but need their own inode number. It's 0 for all other files, whose inode number is the Id.
+	Inode uint64 `json:"-"`
 
 	Parent   *File
 	Children []*File
 }
 
+// Ino returns the inode number of the file.
+func (f *File) Ino() uint64 {
+	if f.Inode != 0 {
+		return f.Inode
+	}
+	return f.Id
+}
+
+// VirtualInode returns the inode number of a virtual file or a copy of the file with the id. The names tell apart
+// the different copies of the file, e.g. by the directory which holds them.
+func VirtualInode(id uint64, names ...string) uint64 {
+	h := fnv.New64a()
+	_ = binary.Write(h, binary.LittleEndian, id)
+	for _, n := range names {
+		_, _ = h.Write([]byte(n))
+		_, _ = h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
 type jsonFile File
 
 func (f *File) Serialize(w io.Writer) error {
@@ -59,5 +90,9 @@ func (f *File) UnmarshalJSON(b []byte) error {
 	for _, c := range f.Children {
 		c.Parent = f
 	}
+	for _, s := range f.Snapshots {
+		s.Root.Parent = f
+		s.setInodes(f.Id)
+	}
 	return err
 }
@@ -0,0 +1,178 @@
+package history
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dimitarvdimitrov/sporkfs/store"
+)
+
+// DirName is the name of the virtual directory through which the retained versions of the files in a directory
+// are accessed.
+const DirName = ".versions"
+
+// Config is the retention policy of superseded and deleted versions. A version is retained if it's one of the
+// latest Versions versions of its file or if it was retired less than MaxAge ago. It needs to be the same on all peers.
+type Config struct {
+	Versions int      `toml:"versions"`
+	MaxAge   Duration `toml:"max_age"` // e.g. "72h"
+	// Trash is how long deleted files are kept in the trash directory before they are purged. 0 disables the trash.
+	Trash Duration `toml:"trash"`
+}
+
+// Enabled returns true if superseded and deleted versions are retained.
+func (c Config) Enabled() bool {
+	return c.Versions > 0 || c.MaxAge.Duration > 0
+}
+
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) (err error) {
+	d.Duration, err = time.ParseDuration(string(text))
+	return err
+}
+
+// Revision is a version of a file which was superseded or whose file was deleted.
+type Revision struct {
+	Id      uint64
+	Name    string // the name of the file when the version was retired
+	Mode    store.FileMode
+	Version uint64
+	Size    int64
+	Mtime   time.Time // when the version was written
+	Retired time.Time
+}
+
+// History keeps the retained versions of files by the directory they were in.
+type History struct {
+	cfg Config
+
+	m    sync.Mutex
+	dirs map[uint64][]Revision // each from the oldest retired to the newest
+}
+
+func New(cfg Config) *History {
+	return &History{
+		cfg:  cfg,
+		dirs: make(map[uint64][]Revision),
+	}
+}
+
+func (h *History) MaxAge() time.Duration {
+	return h.cfg.MaxAge.Duration
+}
+
+// Retire records the revision in the history of the directory. It returns the revisions which aren't retained
+// anymore and whose data can be removed. With retention disabled that's the revision itself.
+// Empty versions aren't retained.
+func (h *History) Retire(dirId uint64, r Revision) (expired []Revision) {
+	if !h.cfg.Enabled() || r.Size == 0 {
+		return []Revision{r}
+	}
+
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	h.dirs[dirId] = append(h.dirs[dirId], r)
+	return h.expire(dirId, r.Retired)
+}
+
+// Expire removes the revisions which aren't retained anymore and returns them.
+func (h *History) Expire(now time.Time) (expired []Revision) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	for dirId := range h.dirs {
+		expired = append(expired, h.expire(dirId, now)...)
+	}
+	return expired
+}
+
+func (h *History) expire(dirId uint64, now time.Time) (expired []Revision) {
+	revisions := h.dirs[dirId]
+	newer := make(map[uint64]int) // how many newer revisions of each file there are
+
+	kept := make([]bool, len(revisions))
+	for i := len(revisions) - 1; i >= 0; i-- {
+		r := revisions[i]
+		kept[i] = newer[r.Id] < h.cfg.Versions || now.Sub(r.Retired) < h.cfg.MaxAge.Duration
+		newer[r.Id]++
+	}
+
+	retained := revisions[:0]
+	for i, r := range revisions {
+		if kept[i] {
+			retained = append(retained, r)
+		} else {
+			expired = append(expired, r)
+		}
+	}
+	h.set(dirId, retained)
+	return expired
+}
+
+// Forget removes the version from the history without expiring it, e.g. because it became current again.
+func (h *History) Forget(id, version uint64) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	for dirId, revisions := range h.dirs {
+		for i, r := range revisions {
+			if r.Id == id && r.Version == version {
+				h.set(dirId, append(revisions[:i], revisions[i+1:]...))
+				return
+			}
+		}
+	}
+}
+
+// Drop removes the history of the directory and returns it. It's used when the directory is deleted.
+func (h *History) Drop(dirId uint64) []Revision {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	revisions := h.dirs[dirId]
+	delete(h.dirs, dirId)
+	return revisions
+}
+
+// Retained returns true if the version is in the history of any directory.
+func (h *History) Retained(id, version uint64) bool {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	for _, revisions := range h.dirs {
+		for _, r := range revisions {
+			if r.Id == id && r.Version == version {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// List returns the retained versions of the file with the name in the directory from the newest to the oldest.
+// An empty name returns the versions of all files.
+func (h *History) List(dirId uint64, name string) []Revision {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	var revisions []Revision
+	all := h.dirs[dirId]
+	for i := len(all) - 1; i >= 0; i-- {
+		if name == "" || all[i].Name == name {
+			revisions = append(revisions, all[i])
+		}
+	}
+	return revisions
+}
+
+func (h *History) set(dirId uint64, revisions []Revision) {
+	if len(revisions) == 0 {
+		delete(h.dirs, dirId)
+		return
+	}
+	h.dirs[dirId] = revisions
+}
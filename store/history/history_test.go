@@ -0,0 +1,98 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetire(t *testing.T) {
+	start := time.Unix(1000, 0)
+	revision := func(id, version uint64, retired time.Duration) Revision {
+		return Revision{Id: id, Version: version, Size: 1, Retired: start.Add(retired)}
+	}
+
+	testCases := map[string]struct {
+		cfg      Config
+		retired  []Revision
+		expired  []uint64 // the versions which retiring the last revision expires
+		retained []uint64 // from the newest to the oldest
+	}{
+		"disabled": {
+			retired:  []Revision{revision(1, 1, 0)},
+			expired:  []uint64{1},
+			retained: nil,
+		},
+		"by count": {
+			cfg:      Config{Versions: 2},
+			retired:  []Revision{revision(1, 1, 0), revision(1, 2, 0), revision(1, 3, 0)},
+			expired:  []uint64{1},
+			retained: []uint64{3, 2},
+		},
+		"by count of each file": {
+			cfg:      Config{Versions: 1},
+			retired:  []Revision{revision(1, 1, 0), revision(2, 2, 0), revision(1, 3, 0)},
+			expired:  []uint64{1},
+			retained: []uint64{3, 2},
+		},
+		"by age": {
+			cfg:      Config{MaxAge: Duration{time.Hour}},
+			retired:  []Revision{revision(1, 1, 0), revision(1, 2, 30*time.Minute), revision(1, 3, time.Hour)},
+			expired:  []uint64{1},
+			retained: []uint64{3, 2},
+		},
+		"empty versions": {
+			cfg:      Config{Versions: 2},
+			retired:  []Revision{{Id: 1, Version: 1}},
+			expired:  []uint64{1},
+			retained: nil,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			h := New(tc.cfg)
+			var expired []Revision
+			for _, r := range tc.retired {
+				expired = h.Retire(1, r)
+			}
+
+			var expiredVersions, retainedVersions []uint64
+			for _, r := range expired {
+				expiredVersions = append(expiredVersions, r.Version)
+			}
+			for _, r := range h.List(1, "") {
+				retainedVersions = append(retainedVersions, r.Version)
+			}
+			require.Equal(t, tc.expired, expiredVersions)
+			require.Equal(t, tc.retained, retainedVersions)
+		})
+	}
+}
+
+func TestState(t *testing.T) {
+	cfg := Config{Versions: 1, MaxAge: Duration{time.Hour}}
+	retired := time.Unix(1000, 0)
+
+	h := New(cfg)
+	h.Retire(1, Revision{Id: 2, Name: "file", Version: 3, Size: 4, Mtime: retired.Add(-time.Minute), Retired: retired})
+	h.Retire(5, Revision{Id: 6, Name: "other", Version: 7, Size: 8, Retired: retired})
+	state, err := h.GetState()
+	require.NoError(t, err)
+
+	restored := New(cfg)
+	restored.Retire(9, Revision{Id: 10, Version: 11, Size: 12, Retired: retired})
+	require.NoError(t, restored.SetState(state))
+
+	for _, dirId := range []uint64{1, 5, 9} {
+		require.Equal(t, len(h.List(dirId, "")), len(restored.List(dirId, "")))
+		for i, r := range h.List(dirId, "") {
+			require.True(t, r.Retired.Equal(restored.List(dirId, "")[i].Retired))
+			require.True(t, r.Mtime.Equal(restored.List(dirId, "")[i].Mtime))
+		}
+	}
+	require.True(t, restored.Retained(2, 3))
+	require.False(t, restored.Retained(10, 11))
+	require.Equal(t, "file", restored.List(1, "")[0].Name)
+}
@@ -0,0 +1,37 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+func (h *History) Name() string {
+	return "history"
+}
+
+func (h *History) GetState() (io.Reader, error) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	buff := &bytes.Buffer{}
+	err := json.NewEncoder(buff).Encode(h.dirs)
+	if err != nil {
+		return nil, fmt.Errorf("serializing history: %w", err)
+	}
+	return buff, nil
+}
+
+func (h *History) SetState(r io.Reader) error {
+	dirs := make(map[uint64][]Revision)
+	err := json.NewDecoder(r).Decode(&dirs)
+	if err != nil {
+		return fmt.Errorf("setting history state: %w", err)
+	}
+
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.dirs = dirs
+	return nil
+}
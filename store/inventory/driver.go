@@ -8,6 +8,10 @@ import (
 	"github.com/dimitarvdimitrov/sporkfs/store"
 )
 
+// ReservedIds is how many of the lowest ids NewId never returns. They are for files which all peers need to give
+// the same id without agreeing on it first. The root has id 0.
+const ReservedIds = 16
+
 type Driver struct {
 	m sync.RWMutex
 
@@ -60,6 +64,14 @@ func (d Driver) GetAny(id uint64) (*store.File, error) {
 	return links[0], nil
 }
 
+// Count returns the number of files, where all hard links to a file count as one.
+func (d Driver) Count() int {
+	d.m.RLock()
+	defer d.m.RUnlock()
+
+	return len(d.catalog)
+}
+
 func (d Driver) GetAll(id uint64) []*store.File {
 	d.m.RLock()
 	defer d.m.RUnlock()
@@ -89,21 +101,47 @@ func (d Driver) SetVersion(id, version uint64) {
 	}
 }
 
-// SetVersion sets the version for all known links
+// SetSize sets the size for all known links and updates the quotas of their parents
 func (d Driver) SetSize(id uint64, size int64) {
 	d.m.RLock()
 	defer d.m.RUnlock()
 
 	for _, link := range d.catalog[id] {
+		store.Charge(link.Parent, store.Usage{Bytes: size - link.Size})
 		link.Size = size
 	}
 }
 
+// SetQuota sets the quota of the directory; maxBytes and maxInodes of 0 remove the quota
+func (d Driver) SetQuota(id uint64, maxBytes, maxInodes int64) {
+	d.m.RLock()
+	defer d.m.RUnlock()
+
+	for _, link := range d.catalog[id] {
+		if maxBytes == 0 && maxInodes == 0 {
+			link.Quota = nil
+		} else {
+			link.Quota = store.NewQuota(link, maxBytes, maxInodes)
+		}
+	}
+}
+
+// SetACL sets the ACL for all known links
+func (d Driver) SetACL(id uint64, acl store.ACL) {
+	d.m.RLock()
+	defer d.m.RUnlock()
+
+	for _, link := range d.catalog[id] {
+		link.ACL = acl
+	}
+}
+
 func (d Driver) Add(f *store.File) {
 	d.m.Lock()
 	defer d.m.Unlock()
 
 	d.catalog[f.Id] = append(d.catalog[f.Id], f)
+	store.Charge(f.Parent, store.UsageOf(f))
 }
 
 // Remove deletes the from the inventory and returns true if there are any more hard links to it
@@ -119,6 +157,8 @@ func (d Driver) Remove(f *store.File) bool {
 	}
 	if foundAt != -1 {
 		d.catalog[f.Id] = append(d.catalog[f.Id][:foundAt], d.catalog[f.Id][foundAt+1:]...)
+		u := store.UsageOf(f)
+		store.Charge(f.Parent, store.Usage{Bytes: -u.Bytes, Inodes: -u.Inodes})
 	}
 
 	for i, c := range f.Parent.Children {
@@ -144,7 +184,7 @@ func (d Driver) NewId() (id uint64) {
 	for {
 		id = rand.Uint64()
 
-		if _, exists := d.catalog[id]; !exists {
+		if _, exists := d.catalog[id]; !exists && id >= ReservedIds {
 			return
 		}
 	}
@@ -24,16 +24,28 @@ func (d Driver) GetState() (io.Reader, error) {
 	return buff, nil
 }
 
+// SetState replaces the files with the ones in the state. The root is replaced in place, so that all copies of the
+// driver see the new files.
 func (d Driver) SetState(r io.Reader) error {
 	d.m.Lock()
 	defer d.m.Unlock()
 
-	d.root = &store.File{}
-	err := d.root.Deserialize(r)
+	root := &store.File{}
+	err := root.Deserialize(r)
 	if err != nil {
 		return fmt.Errorf("setting inventory state: %w", err)
 	}
-	d.catalog = make(map[uint64][]*store.File)
+	*d.root = *root
+	for _, c := range d.root.Children {
+		c.Parent = d.root
+	}
+	for _, s := range d.root.Snapshots {
+		s.Root.Parent = d.root
+	}
+
+	for id := range d.catalog {
+		delete(d.catalog, id)
+	}
 	catalogFiles(d.root, d.catalog)
 	return nil
 }
@@ -0,0 +1,120 @@
+package store
+
+import "sync"
+
+// Usage is what the files in a directory take up. Hard links are counted once for each link.
+type Usage struct {
+	Bytes  int64
+	Inodes int64
+}
+
+// Quota limits the usage of a directory and all of its descendants. A limit of 0 means unlimited.
+type Quota struct {
+	m sync.Mutex
+
+	MaxBytes  int64
+	MaxInodes int64
+	// Used doesn't include the directory itself
+	Used Usage
+}
+
+// NewQuota returns a quota for dir with the usage of its current descendants.
+func NewQuota(dir *File, maxBytes, maxInodes int64) *Quota {
+	used := UsageOf(dir)
+	used.Inodes--
+	return &Quota{
+		MaxBytes:  maxBytes,
+		MaxInodes: maxInodes,
+		Used:      used,
+	}
+}
+
+// Usage returns the limits and the current usage.
+func (q *Quota) Usage() (maxBytes, maxInodes int64, used Usage) {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	return q.MaxBytes, q.MaxInodes, q.Used
+}
+
+func (q *Quota) charge(u Usage) {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	q.Used.Bytes += u.Bytes
+	q.Used.Inodes += u.Inodes
+}
+
+func (q *Quota) allows(u Usage) bool {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	if u.Bytes > 0 && q.MaxBytes > 0 && q.Used.Bytes+u.Bytes > q.MaxBytes {
+		return false
+	}
+	if u.Inodes > 0 && q.MaxInodes > 0 && q.Used.Inodes+u.Inodes > q.MaxInodes {
+		return false
+	}
+	return true
+}
+
+// UsageOf returns the usage of the file and all of its descendants.
+func UsageOf(f *File) Usage {
+	u := Usage{Inodes: 1}
+	if f.Mode&ModeDirectory == 0 {
+		u.Bytes = f.Size
+	}
+	for _, c := range f.Children {
+		cu := UsageOf(c)
+		u.Bytes += cu.Bytes
+		u.Inodes += cu.Inodes
+	}
+	return u
+}
+
+// HasQuota returns true if dir or any of its ancestors has a quota.
+func HasQuota(dir *File) bool {
+	for d := dir; d != nil; d = d.Parent {
+		if d.Quota != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Charge adds the usage to the quotas of dir and all of its ancestors. The usage can be negative.
+func Charge(dir *File, u Usage) {
+	for d := dir; d != nil; d = d.Parent {
+		if d.Quota != nil {
+			d.Quota.charge(u)
+		}
+	}
+}
+
+// CheckQuota returns ErrQuotaExceeded if adding the usage to dir would exceed the quota of dir or of
+// any of its ancestors.
+func CheckQuota(dir *File, u Usage) error {
+	for d := dir; d != nil; d = d.Parent {
+		if d.Quota != nil && !d.Quota.allows(u) {
+			return ErrQuotaExceeded
+		}
+	}
+	return nil
+}
+
+// CheckMove returns ErrQuotaExceeded if moving the usage from oldParent to newParent would exceed the quota of
+// newParent or of any of its ancestors. The quotas which both parents share aren't affected by the move.
+func CheckMove(oldParent, newParent *File, u Usage) error {
+	shared := make(map[*File]bool)
+	for d := oldParent; d != nil; d = d.Parent {
+		if d.Quota != nil {
+			shared[d] = true
+		}
+	}
+	for d := newParent; d != nil; d = d.Parent {
+		if d.Quota != nil && !shared[d] && !d.Quota.allows(u) {
+			return ErrQuotaExceeded
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDir(name string, parent *File, quota *Quota) *File {
+	dir := &File{Name: name, Mode: ModeDirectory, Quota: quota, Parent: parent}
+	if parent != nil {
+		parent.Children = append(parent.Children, dir)
+	}
+	return dir
+}
+
+func newTestFile(name string, parent *File, size int64) *File {
+	f := &File{Name: name, Mode: ModeRegularFile, Size: size, Parent: parent}
+	parent.Children = append(parent.Children, f)
+	return f
+}
+
+func TestUsageOf(t *testing.T) {
+	root := newTestDir("", nil, nil)
+	dir := newTestDir("dir", root, nil)
+	newTestFile("a", dir, 10)
+	newTestFile("b", newTestDir("sub", dir, nil), 5)
+
+	require.Equal(t, Usage{Bytes: 15, Inodes: 4}, UsageOf(dir))
+	require.Equal(t, Usage{Bytes: 15, Inodes: 5}, UsageOf(root))
+}
+
+func TestCheckQuota(t *testing.T) {
+	testCases := map[string]struct {
+		// the limits and the usage of the quota
+		maxBytes, maxInodes int64
+		used                Usage
+		usage               Usage
+		allowed             bool
+	}{
+		"unlimited":             {usage: Usage{Bytes: 1 << 40, Inodes: 1 << 20}, allowed: true},
+		"within bytes":          {maxBytes: 100, used: Usage{Bytes: 50}, usage: Usage{Bytes: 50}, allowed: true},
+		"over bytes":            {maxBytes: 100, used: Usage{Bytes: 50}, usage: Usage{Bytes: 51}},
+		"over inodes":           {maxInodes: 2, used: Usage{Inodes: 2}, usage: Usage{Inodes: 1}},
+		"freeing while over":    {maxBytes: 100, used: Usage{Bytes: 200}, usage: Usage{Bytes: -10}, allowed: true},
+		"inodes while over max": {maxBytes: 100, used: Usage{Bytes: 200}, usage: Usage{Inodes: 1}, allowed: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			root := newTestDir("", nil, nil)
+			quota := &Quota{MaxBytes: tc.maxBytes, MaxInodes: tc.maxInodes, Used: tc.used}
+			dir := newTestDir("limited", root, quota)
+			sub := newTestDir("sub", dir, nil)
+
+			err := CheckQuota(sub, tc.usage)
+			if tc.allowed {
+				require.NoError(t, err)
+			} else {
+				require.Equal(t, ErrQuotaExceeded, err)
+			}
+		})
+	}
+}
+
+func TestCheckMove(t *testing.T) {
+	// root (100 bytes) -> full (10 bytes, full) and free (unlimited) -> nested (10 bytes)
+	root := newTestDir("", nil, &Quota{MaxBytes: 100, Used: Usage{Bytes: 60}})
+	full := newTestDir("full", root, &Quota{MaxBytes: 10, Used: Usage{Bytes: 10}})
+	free := newTestDir("free", root, nil)
+	nested := newTestDir("nested", free, &Quota{MaxBytes: 10})
+
+	testCases := map[string]struct {
+		from, to *File
+		usage    Usage
+		allowed  bool
+	}{
+		"into a full quota":                 {from: free, to: full, usage: Usage{Bytes: 1}},
+		"out of a full quota":               {from: full, to: free, usage: Usage{Bytes: 10}, allowed: true},
+		"within the shared quota":           {from: full, to: free, usage: Usage{Bytes: 90}, allowed: true},
+		"into a nested quota":               {from: free, to: nested, usage: Usage{Bytes: 10}, allowed: true},
+		"over a nested quota":               {from: free, to: nested, usage: Usage{Bytes: 11}},
+		"replacing a bigger file in a full": {from: free, to: full, usage: Usage{Bytes: -5}, allowed: true},
+		"out of a nested quota":             {from: nested, to: free, usage: Usage{Bytes: 1000}, allowed: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := CheckMove(tc.from, tc.to, tc.usage)
+			if tc.allowed {
+				require.NoError(t, err)
+			} else {
+				require.Equal(t, ErrQuotaExceeded, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package remote
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dimitarvdimitrov/sporkfs/log"
+	"go.uber.org/zap"
+)
+
+const (
+	diskUsageTimeout = 2 * time.Second
+	// diskUsageTTL is how long the reported disk usage of a peer is used before the peer is asked again.
+	diskUsageTTL = 10 * time.Second
+)
+
+// DiskUsage is about the file system on which the data dir of a peer is. All values are in bytes.
+type DiskUsage struct {
+	Total, Free, Available uint64
+}
+
+// diskUsageCache keeps the last disk usage which each peer reported. Peers whose usage is older than diskUsageTTL
+// are asked again in the background, so callers only wait for peers which were never asked before. Peers which
+// stop responding keep their last reported usage.
+type diskUsageCache struct {
+	fetch func(ctx context.Context, peer string) (DiskUsage, error)
+
+	m       sync.Mutex
+	usage   map[string]DiskUsage
+	asked   map[string]time.Time     // when each peer last responded or failed to
+	pending map[string]chan struct{} // closed once the peer responds or fails to
+}
+
+func newDiskUsageCache(fetch func(ctx context.Context, peer string) (DiskUsage, error)) *diskUsageCache {
+	return &diskUsageCache{
+		fetch:   fetch,
+		usage:   make(map[string]DiskUsage),
+		asked:   make(map[string]time.Time),
+		pending: make(map[string]chan struct{}),
+	}
+}
+
+// get returns the usage of the peers which have ever reported it. It waits for the peers which haven't been
+// asked yet until they respond or ctx is done.
+func (c *diskUsageCache) get(ctx context.Context, peers []string) map[string]DiskUsage {
+	var wait []chan struct{}
+
+	c.m.Lock()
+	now := time.Now()
+	for _, peer := range peers {
+		asked, wasAsked := c.asked[peer]
+		done, isPending := c.pending[peer]
+		switch {
+		case isPending:
+		case wasAsked && now.Sub(asked) < diskUsageTTL:
+			continue
+		default:
+			done = make(chan struct{})
+			c.pending[peer] = done
+			go c.refresh(peer, done)
+		}
+		if !wasAsked {
+			wait = append(wait, done)
+		}
+	}
+	c.m.Unlock()
+
+	for _, done := range wait {
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+	usage := make(map[string]DiskUsage, len(peers))
+	for _, peer := range peers {
+		if u, ok := c.usage[peer]; ok {
+			usage[peer] = u
+		}
+	}
+	return usage
+}
+
+func (c *diskUsageCache) refresh(peer string, done chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), diskUsageTimeout)
+	defer cancel()
+	u, err := c.fetch(ctx, peer)
+
+	c.m.Lock()
+	defer c.m.Unlock()
+	defer close(done)
+
+	delete(c.pending, peer)
+	c.asked[peer] = time.Now()
+	if err != nil {
+		log.Warn("[remote] getting disk usage", zap.String("peer", peer), zap.Error(err))
+		return
+	}
+	c.usage[peer] = u
+}
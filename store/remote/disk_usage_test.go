@@ -0,0 +1,106 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDiskUsage answers with the usage of each peer, or fails for peers without one. Requests to blocked peers
+// wait until they are unblocked.
+type fakeDiskUsage struct {
+	m       sync.Mutex
+	usage   map[string]DiskUsage
+	calls   map[string]int
+	blocked chan struct{}
+}
+
+func (f *fakeDiskUsage) fetch(ctx context.Context, peer string) (DiskUsage, error) {
+	f.m.Lock()
+	f.calls[peer]++
+	u, ok := f.usage[peer]
+	blocked := f.blocked
+	f.m.Unlock()
+
+	if blocked != nil {
+		<-blocked
+	}
+	if !ok {
+		return DiskUsage{}, errors.New("peer is down")
+	}
+	return u, nil
+}
+
+func (f *fakeDiskUsage) callsTo(peer string) int {
+	f.m.Lock()
+	defer f.m.Unlock()
+	return f.calls[peer]
+}
+
+// expire makes the cached usage of all peers outdated.
+func expire(c *diskUsageCache) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	for peer := range c.asked {
+		c.asked[peer] = c.asked[peer].Add(-diskUsageTTL)
+	}
+}
+
+func TestDiskUsageCache(t *testing.T) {
+	ctx := context.Background()
+	peers := []string{"a", "b", "c"}
+	fake := &fakeDiskUsage{
+		usage: map[string]DiskUsage{"a": {Total: 1}, "b": {Total: 2}},
+		calls: make(map[string]int),
+	}
+	c := newDiskUsageCache(fake.fetch)
+
+	// the first call waits for all peers
+	require.Equal(t, map[string]DiskUsage{"a": {Total: 1}, "b": {Total: 2}}, c.get(ctx, peers))
+
+	// calls within the ttl don't ask the peers again, even the ones which failed
+	require.Equal(t, map[string]DiskUsage{"a": {Total: 1}, "b": {Total: 2}}, c.get(ctx, peers))
+	for _, peer := range peers {
+		require.Equal(t, 1, fake.callsTo(peer), peer)
+	}
+
+	// outdated usage is served while the peers are asked again in the background
+	fake.m.Lock()
+	fake.blocked = make(chan struct{})
+	fake.usage = map[string]DiskUsage{"a": {Total: 10}, "c": {Total: 30}}
+	fake.m.Unlock()
+	expire(c)
+	require.Equal(t, map[string]DiskUsage{"a": {Total: 1}, "b": {Total: 2}}, c.get(ctx, peers))
+	// requests which are still running aren't repeated
+	c.get(ctx, peers)
+	close(fake.blocked)
+	require.Eventually(t, func() bool {
+		c.m.Lock()
+		defer c.m.Unlock()
+		return len(c.pending) == 0
+	}, time.Second, time.Millisecond)
+	for _, peer := range peers {
+		require.Equal(t, 2, fake.callsTo(peer), peer)
+	}
+
+	// peers which stopped responding keep their last usage
+	require.Equal(t, map[string]DiskUsage{"a": {Total: 10}, "b": {Total: 2}, "c": {Total: 30}}, c.get(ctx, peers))
+}
+
+func TestDiskUsageCacheWaitsForNewPeersUntilCancelled(t *testing.T) {
+	fake := &fakeDiskUsage{
+		usage:   map[string]DiskUsage{"a": {Total: 1}},
+		calls:   make(map[string]int),
+		blocked: make(chan struct{}),
+	}
+	defer close(fake.blocked)
+	c := newDiskUsageCache(fake.fetch)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.Empty(t, c.get(ctx, []string{"a"}))
+}
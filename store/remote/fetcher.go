@@ -8,7 +8,11 @@ import (
 
 	"github.com/dimitarvdimitrov/sporkfs/api"
 	proto "github.com/dimitarvdimitrov/sporkfs/api/pb"
+	"github.com/dimitarvdimitrov/sporkfs/compression"
 	"github.com/dimitarvdimitrov/sporkfs/log"
+	"github.com/dimitarvdimitrov/sporkfs/mtls"
+	"github.com/dimitarvdimitrov/sporkfs/qos"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 )
@@ -16,37 +20,79 @@ import (
 const grpcBufferSize = 5 * api.ChunkSize
 
 type grpcFetcher struct {
-	client proto.FileClient
+	client      proto.FileClient
+	limiter     *qos.Limiter
+	compression compression.Config
 }
 
-func newGrpcFetcher(remoteUrl string) (grpcFetcher, error) {
+func newGrpcFetcher(remoteUrl string, limiter *qos.Limiter, compression compression.Config, creds mtls.Credentials) (grpcFetcher, error) {
 	conn, err := grpc.Dial(remoteUrl,
-		grpc.WithInsecure(),
+		creds.DialOption(),
 		grpc.WithReadBufferSize(grpcBufferSize),
+		trace.DialOption(),
 	)
 	if err != nil {
 		return grpcFetcher{}, err
 	}
 
 	return grpcFetcher{
-		client: proto.NewFileClient(conn),
+		client:      proto.NewFileClient(conn),
+		limiter:     limiter,
+		compression: compression,
 	}, nil
 }
 
-func (f grpcFetcher) Reader(id, version uint64) (io.ReadCloser, error) {
-	ctx, cancel := context.WithCancel(context.Background())
+func (f grpcFetcher) DiskUsage(ctx context.Context) (DiskUsage, error) {
+	reply, err := f.client.DiskUsage(ctx, &proto.DiskUsageRequest{})
+	if err != nil {
+		return DiskUsage{}, err
+	}
+	return DiskUsage{
+		Total:     reply.TotalBytes,
+		Free:      reply.FreeBytes,
+		Available: reply.AvailableBytes,
+	}, nil
+}
+
+// Reader streams the whole file. size is the size of the version and is only used to decide whether
+// to compress the transfer.
+func (f grpcFetcher) Reader(ctx context.Context, id, version uint64, size int64) (io.ReadCloser, error) {
+	return f.rangeReader(ctx, id, version, 0, 0, size)
+}
+
+// RangeReader streams length bytes of the file starting at off. A length of 0 means until the end of the file.
+// The transfer is limited according to the qos.Class of ctx.
+func (f grpcFetcher) RangeReader(ctx context.Context, id, version uint64, off, length int64) (io.ReadCloser, error) {
+	expectedSize := length
+	if length == 0 {
+		expectedSize = -1
+	}
+	return f.rangeReader(ctx, id, version, off, length, expectedSize)
+}
+
+// rangeReader streams the range of the file. expectedSize is how many bytes the range is expected to have;
+// it's negative if that isn't known.
+func (f grpcFetcher) rangeReader(ctx context.Context, id, version uint64, off, length, expectedSize int64) (io.ReadCloser, error) {
+	class := qos.ClassFrom(ctx)
+	// the stream outlives the request which started it, so we only keep the span
+	ctx, cancel := context.WithCancel(trace.Detach(ctx))
 
 	req := &proto.ReadRequest{
-		Id:      id,
-		Version: version,
+		Id:           id,
+		Version:      version,
+		Offset:       off,
+		Length:       length,
+		TrafficClass: int32(class),
 	}
 
 	var stream proto.File_ReadClient
 	var err error
 
+	opts := append(f.compression.CallOptions(expectedSize), grpc.WaitForReady(true))
+
 	connected := make(chan struct{})
 	go func() {
-		stream, err = f.client.Read(ctx, req, grpc.WaitForReady(true))
+		stream, err = f.client.Read(ctx, req, opts...)
 		close(connected)
 	}()
 
@@ -67,8 +113,11 @@ func (f grpcFetcher) Reader(id, version uint64) (io.ReadCloser, error) {
 
 	out, in := io.Pipe()
 	reader := grpcAsyncReader{
+		ctx:         ctx,
 		stream:      stream,
 		closeStream: cancel,
+		limiter:     f.limiter,
+		class:       class,
 		in:          in,
 		out:         out,
 		done:        make(chan struct{}),
@@ -79,8 +128,11 @@ func (f grpcFetcher) Reader(id, version uint64) (io.ReadCloser, error) {
 }
 
 type grpcAsyncReader struct {
+	ctx         context.Context
 	stream      proto.File_ReadClient
 	closeStream func()
+	limiter     *qos.Limiter
+	class       qos.Class
 	done        chan struct{}
 	in          *io.PipeWriter
 	out         *io.PipeReader
@@ -115,6 +167,11 @@ func (r grpcAsyncReader) run() {
 			return
 		}
 
+		if err = r.limiter.Wait(r.ctx, r.class, len(reply.Content)); err != nil {
+			_ = r.in.CloseWithError(err)
+			return
+		}
+
 		_, err = r.in.Write(reply.Content)
 		if err != nil {
 			log.Warn("couldn't receive file chunk from remote peer", zap.Error(err))
@@ -1,43 +1,99 @@
 package remote
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"sync"
 
+	"github.com/dimitarvdimitrov/sporkfs/compression"
+	"github.com/dimitarvdimitrov/sporkfs/mtls"
+	"github.com/dimitarvdimitrov/sporkfs/qos"
 	"github.com/dimitarvdimitrov/sporkfs/raft"
+	"github.com/dimitarvdimitrov/sporkfs/store/data"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Readerer interface {
-	Reader(id, version uint64) (io.ReadCloser, error)
-	ReaderFromPeer(id, version uint64, peer string) (io.ReadCloser, error)
+	// Reader returns a reader for the whole version, which is size bytes long.
+	Reader(ctx context.Context, id, version uint64, size int64) (io.ReadCloser, error)
+	ReaderFromPeer(ctx context.Context, id, version uint64, size int64, peer string) (io.ReadCloser, error)
+	// RangeReader returns a reader for length bytes of the file starting at off.
+	RangeReader(ctx context.Context, id, version uint64, off, length int64) (io.ReadCloser, error)
+	// Download writes the ranges of the file to dst, fetching different parts of them from different
+	// peers in parallel. The preferred peers are used in addition to the peers which should have the file.
+	Download(ctx context.Context, id, version uint64, ranges []data.Range, dst io.WriterAt, preferred ...string) error
+	// DiskUsage returns the last disk usage which each peer reported. It only waits for the peers which haven't
+	// been asked before; the others are asked again in the background when their usage is outdated.
+	DiskUsage(ctx context.Context) map[string]DiskUsage
 }
 
 type multiFetcher struct {
-	peers    *raft.Peers
-	fetchers map[string]grpcFetcher
+	peers     *raft.Peers
+	fetchers  map[string]grpcFetcher
+	diskUsage *diskUsageCache
 }
 
-func NewFetcher(peers *raft.Peers) (Readerer, error) {
+func NewFetcher(peers *raft.Peers, limiter *qos.Limiter, compression compression.Config, creds mtls.Credentials) (Readerer, error) {
 	peerConns := make(map[string]grpcFetcher, peers.Len())
 
 	err := peers.ForEach(func(peer string) error {
 		var err error
-		peerConns[peer], err = newGrpcFetcher(peer)
+		peerConns[peer], err = newGrpcFetcher(peer, limiter, compression, creds)
 		return err
 	})
 	if err != nil {
 		return multiFetcher{}, err
 	}
 
-	return multiFetcher{fetchers: peerConns, peers: peers}, nil
+	f := multiFetcher{fetchers: peerConns, peers: peers}
+	f.diskUsage = newDiskUsageCache(func(ctx context.Context, peer string) (DiskUsage, error) {
+		return f.fetchers[peer].DiskUsage(ctx)
+	})
+	return f, nil
+}
+
+func (f multiFetcher) DiskUsage(ctx context.Context) map[string]DiskUsage {
+	peers := make([]string, 0, len(f.fetchers))
+	for peer := range f.fetchers {
+		peers = append(peers, peer)
+	}
+	return f.diskUsage.get(ctx, peers)
+}
+
+func (f multiFetcher) ReaderFromPeer(ctx context.Context, id, version uint64, size int64, peer string) (_ io.ReadCloser, err error) {
+	ctx, span := trace.Start(ctx, "remote.ReaderFromPeer", trace.Id(id), trace.Ver(version), trace.Peer(peer))
+	defer trace.End(span, &err)
+
+	return f.fetchers[peer].Reader(ctx, id, version, size)
+}
+
+func (f multiFetcher) Reader(ctx context.Context, id, version uint64, size int64) (_ io.ReadCloser, err error) {
+	ctx, span := trace.Start(ctx, "remote.Reader", trace.Id(id), trace.Ver(version))
+	defer trace.End(span, &err)
+
+	return f.fromAnyPeer(id, version, func(fetcher grpcFetcher) (io.ReadCloser, error) {
+		return fetcher.Reader(ctx, id, version, size)
+	})
 }
 
-func (f multiFetcher) ReaderFromPeer(id, version uint64, peer string) (io.ReadCloser, error) {
-	return f.fetchers[peer].Reader(id, version)
+func (f multiFetcher) RangeReader(ctx context.Context, id, version uint64, off, length int64) (_ io.ReadCloser, err error) {
+	ctx, span := trace.Start(ctx, "remote.RangeReader",
+		trace.Id(id),
+		trace.Ver(version),
+		attribute.Int64("offset", off),
+		attribute.Int64("length", length),
+	)
+	defer trace.End(span, &err)
+
+	return f.fromAnyPeer(id, version, func(fetcher grpcFetcher) (io.ReadCloser, error) {
+		return fetcher.RangeReader(ctx, id, version, off, length)
+	})
 }
 
-func (f multiFetcher) Reader(id, version uint64) (io.ReadCloser, error) {
+// fromAnyPeer returns the reader of the first peer which has the file and successfully responds.
+func (f multiFetcher) fromAnyPeer(id, version uint64, open func(grpcFetcher) (io.ReadCloser, error)) (io.ReadCloser, error) {
 	peersWithFile := f.peers.PeersWithFile(id)
 	if len(peersWithFile) == 0 {
 		return nil, fmt.Errorf("couldn't find suitable peer for file %d-%d", id, version)
@@ -62,7 +118,7 @@ func (f multiFetcher) Reader(id, version uint64) (io.ReadCloser, error) {
 			default:
 			}
 
-			r, err := f.fetchers[p].Reader(id, version)
+			r, err := open(f.fetchers[p])
 			if err != nil {
 				return
 			}
@@ -0,0 +1,306 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dimitarvdimitrov/sporkfs/log"
+	"github.com/dimitarvdimitrov/sporkfs/store/data"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	// StripeSize is the size of the ranges into which striped downloads are split.
+	StripeSize = 1 << 22
+	// stallTimeout is how long a peer can go without sending any data before its stripe is given to someone else.
+	stallTimeout = time.Second * 5
+	// maxPeerFailures is the number of stripes a peer can fail before it's no longer used for the download.
+	maxPeerFailures = 2
+	// stripes are written in whole blocks, so that partial files can mark them as present
+	copyChunkSize = data.BlockSize
+)
+
+// rangeReaderer is what a striped download needs from each peer.
+type rangeReaderer interface {
+	RangeReader(ctx context.Context, id, version uint64, off, length int64) (io.ReadCloser, error)
+}
+
+type stripe struct {
+	off, len int64
+}
+
+// peerStats tracks how fast a peer has been sending data during a download.
+type peerStats struct {
+	bytes    int64
+	elapsed  time.Duration
+	failures int
+}
+
+func (s peerStats) throughput() float64 {
+	if s.elapsed == 0 {
+		return 0
+	}
+	return float64(s.bytes) / s.elapsed.Seconds()
+}
+
+// stripedDownload fetches different stripes of a file from different peers in parallel. Each peer takes the
+// next stripe from the queue as soon as it's done with its previous one, so faster peers end up sending more.
+// A stripe whose peer fails or stalls is put back into the queue from where the peer stopped.
+type stripedDownload struct {
+	ctx         context.Context
+	id, version uint64
+	dst         io.WriterAt
+
+	m         sync.Mutex
+	queue     []stripe
+	inFlight  int
+	stats     map[string]*peerStats
+	progress  chan struct{} // signals that a stripe has been completed or returned to the queue
+	remaining int64
+}
+
+// Download writes the ranges of the version to dst. It fetches stripes of the ranges in parallel from all the peers
+// which should have the file and from any of the preferred peers.
+func (f multiFetcher) Download(ctx context.Context, id, version uint64, ranges []data.Range, dst io.WriterAt, preferred ...string) (err error) {
+	size := int64(0)
+	for _, r := range ranges {
+		size += r.Len
+	}
+	ctx, span := trace.Start(ctx, "remote.Download", trace.Id(id), trace.Ver(version), attribute.Int64("size", size))
+	defer trace.End(span, &err)
+
+	peers := append(preferred, f.peers.PeersWithFile(id)...)
+	peers = dedup(peers)
+	if len(peers) == 0 {
+		return fmt.Errorf("couldn't find suitable peer for file %d-%d", id, version)
+	}
+
+	fetchers := make(map[string]rangeReaderer, len(peers))
+	for _, p := range peers {
+		if fetcher, ok := f.fetchers[p]; ok {
+			fetchers[p] = fetcher
+		}
+	}
+
+	d := newStripedDownload(ctx, id, version, ranges, dst)
+	d.run(fetchers)
+
+	for peer, stats := range d.stats {
+		log.Debug("[remote] peer throughput during striped download",
+			log.Id(id), log.Ver(version),
+			zap.String("peer", peer),
+			zap.Float64("bytes_per_sec", stats.throughput()),
+			zap.Int("failures", stats.failures),
+		)
+		span.SetAttributes(attribute.Float64("throughput."+peer, stats.throughput()))
+	}
+
+	if d.remaining > 0 {
+		return fmt.Errorf("all peers failed while downloading %d-%d; %d bytes remaining", id, version, d.remaining)
+	}
+	return nil
+}
+
+func newStripedDownload(ctx context.Context, id, version uint64, ranges []data.Range, dst io.WriterAt) *stripedDownload {
+	d := &stripedDownload{
+		ctx:      ctx,
+		id:       id,
+		version:  version,
+		dst:      dst,
+		stats:    make(map[string]*peerStats),
+		progress: make(chan struct{}, 1),
+	}
+	for _, r := range ranges {
+		d.remaining += r.Len
+		for off := r.Off; off < r.Off+r.Len; off += StripeSize {
+			length := int64(StripeSize)
+			if off+length > r.Off+r.Len {
+				length = r.Off + r.Len - off
+			}
+			d.queue = append(d.queue, stripe{off: off, len: length})
+		}
+	}
+	return d
+}
+
+// run fetches the stripes from the peers until they're all done or all peers have failed.
+func (d *stripedDownload) run(fetchers map[string]rangeReaderer) {
+	// the stats of all peers are created before any of them starts, since the map is written without the lock
+	for peer := range fetchers {
+		d.stats[peer] = &peerStats{}
+	}
+
+	var wg sync.WaitGroup
+	for peer, fetcher := range fetchers {
+		wg.Add(1)
+		go func(peer string, fetcher rangeReaderer) {
+			defer wg.Done()
+			d.work(peer, fetcher)
+		}(peer, fetcher)
+	}
+	wg.Wait()
+}
+
+// work fetches stripes from the peer until there are no more stripes left or the peer has failed too many times.
+func (d *stripedDownload) work(peer string, fetcher rangeReaderer) {
+	for {
+		s, ok := d.next(peer)
+		if !ok {
+			return
+		}
+
+		start := time.Now()
+		written, err := d.fetch(fetcher, s)
+		d.done(peer, s, written, time.Since(start), err)
+	}
+}
+
+// next blocks until there is a stripe for the peer to fetch. It returns false if the download is
+// finished or the peer shouldn't be used anymore.
+func (d *stripedDownload) next(peer string) (stripe, bool) {
+	for {
+		d.m.Lock()
+		if d.stats[peer].failures >= maxPeerFailures || d.remaining == 0 {
+			d.m.Unlock()
+			return stripe{}, false
+		}
+		if len(d.queue) > 0 {
+			s := d.queue[0]
+			d.queue = d.queue[1:]
+			d.inFlight++
+			d.m.Unlock()
+			return s, true
+		}
+		if d.inFlight == 0 {
+			// the queue is empty and no one will put anything back, so the remaining peers have all failed
+			d.m.Unlock()
+			return stripe{}, false
+		}
+		d.m.Unlock()
+
+		// wait for a stripe to be completed or to be put back in the queue
+		select {
+		case <-d.progress:
+			d.notify() // let the other waiting peers check as well
+		case <-d.ctx.Done():
+			return stripe{}, false
+		}
+	}
+}
+
+func (d *stripedDownload) done(peer string, s stripe, written int64, elapsed time.Duration, err error) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	defer d.notify()
+
+	d.inFlight--
+	stats := d.stats[peer]
+	stats.bytes += written
+	stats.elapsed += elapsed
+
+	if err != nil {
+		// stripes start at block boundaries and the destination only keeps whole blocks, so a block which was
+		// cut short needs to be fetched again from its start
+		written -= written % copyChunkSize
+
+		stats.failures++
+		log.Warn("[remote] peer failed to send stripe; reassigning it",
+			log.Id(d.id), log.Ver(d.version),
+			zap.String("peer", peer),
+			zap.Int64("offset", s.off+written),
+			zap.Error(err),
+		)
+		d.queue = append(d.queue, stripe{off: s.off + written, len: s.len - written})
+	}
+	d.remaining -= written
+}
+
+func (d *stripedDownload) notify() {
+	select {
+	case d.progress <- struct{}{}:
+	default:
+	}
+}
+
+// fetch copies the stripe from the peer to the destination. It returns how many bytes of the stripe were written
+// before any error. If the peer doesn't send anything for stallTimeout, the transfer is aborted.
+func (d *stripedDownload) fetch(fetcher rangeReaderer, s stripe) (written int64, err error) {
+	ctx, cancel := context.WithCancel(d.ctx)
+	defer cancel()
+
+	src, err := fetcher.RangeReader(ctx, d.id, d.version, s.off, s.len)
+	if err != nil {
+		return 0, err
+	}
+	var closeOnce sync.Once
+	closeSrc := func() { closeOnce.Do(func() { _ = src.Close() }) }
+	defer closeSrc()
+
+	var lastProgress atomic.Value
+	lastProgress.Store(time.Now())
+	stalled := make(chan struct{})
+	watchdogDone := make(chan struct{})
+	defer close(watchdogDone)
+
+	go func() {
+		t := time.NewTicker(stallTimeout / 5)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				if time.Since(lastProgress.Load().(time.Time)) > stallTimeout {
+					close(stalled)
+					closeSrc()
+					return
+				}
+			case <-watchdogDone:
+				return
+			}
+		}
+	}()
+
+	buff := make([]byte, copyChunkSize)
+	for written < s.len {
+		chunk := buff
+		if remaining := s.len - written; remaining < int64(len(chunk)) {
+			chunk = chunk[:remaining]
+		}
+
+		n, err := io.ReadFull(src, chunk)
+		if n > 0 {
+			if _, wErr := d.dst.WriteAt(chunk[:n], s.off+written); wErr != nil {
+				return written, wErr
+			}
+			written += int64(n)
+			lastProgress.Store(time.Now())
+		}
+		if err != nil {
+			select {
+			case <-stalled:
+				return written, fmt.Errorf("peer stalled for more than %s", stallTimeout)
+			default:
+			}
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func dedup(peers []string) []string {
+	seen := make(map[string]bool, len(peers))
+	result := peers[:0]
+	for _, p := range peers {
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		result = append(result, p)
+	}
+	return result
+}
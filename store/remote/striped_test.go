@@ -0,0 +1,143 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/dimitarvdimitrov/sporkfs/store/data"
+	"github.com/stretchr/testify/require"
+)
+
+var errBrokenStream = errors.New("stream broke")
+
+// fakePeer serves ranges of content. The first breakStreams streams it sends break after breakAt bytes.
+type fakePeer struct {
+	content      []byte
+	breakAt      int64
+	breakStreams int
+
+	m       sync.Mutex
+	streams int
+}
+
+func (p *fakePeer) RangeReader(_ context.Context, _, _ uint64, off, length int64) (io.ReadCloser, error) {
+	p.m.Lock()
+	p.streams++
+	broken := p.streams <= p.breakStreams
+	p.m.Unlock()
+
+	r := io.Reader(bytes.NewReader(p.content[off : off+length]))
+	if broken {
+		r = io.MultiReader(io.LimitReader(r, p.breakAt), errReader{errBrokenStream})
+	}
+	return ioutil.NopCloser(r), nil
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func TestStripedDownload(t *testing.T) {
+	size := int64(2*StripeSize + data.BlockSize/3)
+	content := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(content)
+
+	testCases := map[string]struct {
+		peers   []*fakePeer
+		ranges  []data.Range
+		success bool
+	}{
+		"healthy peers": {
+			peers: []*fakePeer{
+				{content: content},
+				{content: content},
+			},
+			ranges:  []data.Range{{Off: 0, Len: size}},
+			success: true,
+		},
+		"stream breaks in the middle of a block": {
+			peers: []*fakePeer{
+				{content: content, breakAt: data.BlockSize + data.BlockSize/2, breakStreams: 1},
+			},
+			ranges:  []data.Range{{Off: 0, Len: size}},
+			success: true,
+		},
+		"stream breaks in the middle of the last short block": {
+			peers: []*fakePeer{
+				{content: content, breakAt: data.BlockSize / 5, breakStreams: 1},
+			},
+			ranges:  []data.Range{{Off: 2 * StripeSize, Len: data.BlockSize / 3}},
+			success: true,
+		},
+		"one peer keeps breaking": {
+			peers: []*fakePeer{
+				{content: content, breakAt: 100, breakStreams: 1000},
+				{content: content},
+			},
+			ranges:  []data.Range{{Off: 0, Len: size}},
+			success: true,
+		},
+		"only ranges of the file": {
+			peers: []*fakePeer{
+				{content: content, breakAt: data.BlockSize*3 + 7, breakStreams: 1},
+			},
+			ranges:  []data.Range{{Off: data.BlockSize, Len: data.BlockSize * 5}, {Off: StripeSize, Len: StripeSize}},
+			success: true,
+		},
+		"all peers keep breaking": {
+			peers: []*fakePeer{
+				{content: content, breakAt: 100, breakStreams: 1000},
+				{content: content, breakAt: data.BlockSize + 1, breakStreams: 1000},
+			},
+			ranges:  []data.Range{{Off: 0, Len: size}},
+			success: false,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "sporkfs-striped")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			driver, err := data.NewLocalDriver(dir)
+			require.NoError(t, err)
+			dst, err := driver.Partial(1, 1, size)
+			require.NoError(t, err)
+			defer dst.Close()
+
+			peers := make(map[string]rangeReaderer, len(tc.peers))
+			for i, p := range tc.peers {
+				peers[string(rune('a'+i))] = p
+			}
+
+			d := newStripedDownload(context.Background(), 1, 1, tc.ranges, dst)
+			d.run(peers)
+
+			if !tc.success {
+				require.NotZero(t, d.remaining)
+				return
+			}
+			require.Zero(t, d.remaining)
+			for _, r := range tc.ranges {
+				require.True(t, dst.Wait(r.Off, r.Len), "range %v isn't present", r)
+
+				actual := make([]byte, r.Len)
+				_, err = dst.ReadAt(actual, r.Off)
+				require.NoError(t, err)
+				require.Equal(t, content[r.Off:r.Off+r.Len], actual)
+			}
+		})
+	}
+}
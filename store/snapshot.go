@@ -0,0 +1,100 @@
+package store
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SnapshotsDirName is the name of the virtual directory through which the snapshots of a directory are accessed.
+const SnapshotsDirName = ".snapshots"
+
+// Snapshot is a read-only copy of a directory and its descendants at a point in time. The files in it point to the
+// versions which were current when it was taken.
+type Snapshot struct {
+	Name    string
+	Created time.Time
+	// Root is the copy of the directory. It's named after the snapshot and its parent is the directory itself,
+	// so that it inherits the directory's ACL.
+	Root *File
+}
+
+// NewSnapshot copies dir and all of its descendants. dir needs to be locked.
+func NewSnapshot(dir *File, name string, created time.Time) *Snapshot {
+	root := copyFile(dir)
+	root.Name = name
+	root.Parent = dir
+	for _, c := range dir.Children {
+		copyTree(c, root)
+	}
+	s := &Snapshot{
+		Name:    name,
+		Created: created,
+		Root:    root,
+	}
+	s.setInodes(dir.Id)
+	return s
+}
+
+// SnapshotsDirInode returns the inode number of the SnapshotsDirName directory of the directory.
+func SnapshotsDirInode(dirId uint64) uint64 {
+	return VirtualInode(dirId, SnapshotsDirName)
+}
+
+// setInodes gives the files in the snapshot of the directory their own inode numbers. Hard links in the snapshot
+// share theirs.
+func (s *Snapshot) setInodes(dirId uint64) {
+	dir := strconv.FormatUint(dirId, 10)
+	var set func(f *File)
+	set = func(f *File) {
+		f.Inode = VirtualInode(f.Id, dir, SnapshotsDirName, s.Name)
+		for _, c := range f.Children {
+			set(c)
+		}
+	}
+	set(s.Root)
+}
+
+func copyTree(f, parent *File) {
+	f.RLock()
+	c := copyFile(f)
+	children := f.Children
+	f.RUnlock()
+
+	c.Parent = parent
+	parent.Children = append(parent.Children, c)
+	for _, child := range children {
+		copyTree(child, c)
+	}
+}
+
+func copyFile(f *File) *File {
+	return &File{
+		RWMutex:  &sync.RWMutex{},
+		Id:       f.Id,
+		Name:     f.Name,
+		Mode:     f.Mode,
+		Size:     f.Size,
+		Version:  f.Version,
+		Atime:    f.Atime,
+		Mtime:    f.Mtime,
+		ACL:      f.ACL,
+		ReadOnly: true,
+	}
+}
+
+// Versions returns the version of each file in the snapshot.
+func (s *Snapshot) Versions() map[uint64]uint64 {
+	versions := make(map[uint64]uint64)
+	var collect func(f *File)
+	collect = func(f *File) {
+		if f.Mode&ModeDirectory == 0 {
+			versions[f.Id] = f.Version
+		}
+		for _, c := range f.Children {
+			collect(c)
+		}
+	}
+	collect(s.Root)
+	return versions
+}
@@ -0,0 +1,51 @@
+package store
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotInodes(t *testing.T) {
+	root := newTestDir("", nil, nil)
+	root.RWMutex = &sync.RWMutex{}
+	dir := newTestDir("dir", root, nil)
+	dir.Id, dir.RWMutex = 1, &sync.RWMutex{}
+	file := newTestFile("file", dir, 10)
+	file.Id, file.RWMutex = 2, &sync.RWMutex{}
+	// a hard link to the file
+	link := newTestFile("link", dir, 10)
+	link.Id, link.RWMutex = 2, &sync.RWMutex{}
+
+	first, second := NewSnapshot(dir, "first", time.Now()), NewSnapshot(dir, "second", time.Now())
+	dir.Snapshots = []*Snapshot{first, second}
+
+	inodes := map[uint64]string{
+		dir.Ino():                 "dir",
+		file.Ino():                "file",
+		SnapshotsDirInode(dir.Id): SnapshotsDirName,
+	}
+	for _, snap := range dir.Snapshots {
+		for name, f := range map[string]*File{"root": snap.Root, "file": snap.Root.Children[0]} {
+			name = snap.Name + "/" + name
+			require.NotContains(t, inodes, f.Ino(), name)
+			inodes[f.Ino()] = name
+		}
+		// hard links in the snapshot are still hard links
+		require.Equal(t, snap.Root.Children[0].Ino(), snap.Root.Children[1].Ino())
+		require.Equal(t, file.Id, snap.Root.Children[0].Id)
+	}
+
+	// the inode numbers are the same after the snapshots are replicated
+	buf := &bytes.Buffer{}
+	require.NoError(t, root.Serialize(buf))
+	restored := &File{}
+	require.NoError(t, restored.Deserialize(buf))
+	for i, snap := range restored.Children[0].Snapshots {
+		require.Equal(t, dir.Snapshots[i].Root.Ino(), snap.Root.Ino())
+		require.Equal(t, dir.Snapshots[i].Root.Children[0].Ino(), snap.Root.Children[0].Ino())
+	}
+}
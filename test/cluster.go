@@ -14,6 +14,7 @@ import (
 
 	"github.com/dimitarvdimitrov/sporkfs/raft"
 	"github.com/dimitarvdimitrov/sporkfs/spork"
+	"github.com/dimitarvdimitrov/sporkfs/trace"
 )
 
 const basePort = 7340
@@ -81,6 +82,9 @@ func generateCfg(redundancy, numberOfNodes int) ([]spork.Config, error) {
 		configs[i] = spork.Config{
 			DataDir:    dataDir,
 			MountPoint: mountPoint,
+			Tracing: trace.Config{
+				File: dataDir + "/traces.json",
+			},
 			Config: raft.Config{
 				AllPeers:   peers,
 				ThisPeer:   peers[i],
@@ -0,0 +1,146 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dimitarvdimitrov/sporkfs/log"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+const tracerName = "github.com/dimitarvdimitrov/sporkfs"
+
+type Span = oteltrace.Span
+
+type Config struct {
+	// Endpoint is the host:port of an OTLP gRPC collector. Takes precedence over File.
+	Endpoint string `toml:"otlp_endpoint"`
+	// File is a path to which spans are written as JSON. Useful for tests and debugging.
+	File string `toml:"file"`
+}
+
+// Init sets up the global tracer provider according to the config. If neither an endpoint nor a file are
+// configured, tracing stays disabled and all spans are noops. The returned function flushes any pending
+// spans and needs to be called before exiting.
+func Init(cfg Config, thisPeer string) (func(), error) {
+	var (
+		exporter sdktrace.SpanExporter
+		closer   func() error
+		err      error
+	)
+
+	switch {
+	case cfg.Endpoint != "":
+		exporter, err = otlptracegrpc.New(context.Background(),
+			otlptracegrpc.WithEndpoint(cfg.Endpoint),
+			otlptracegrpc.WithInsecure(),
+		)
+		if err != nil {
+			return noop, fmt.Errorf("init otlp exporter: %w", err)
+		}
+	case cfg.File != "":
+		f, err := os.OpenFile(cfg.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+		if err != nil {
+			return noop, fmt.Errorf("opening trace file: %w", err)
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			_ = f.Close()
+			return noop, fmt.Errorf("init file exporter: %w", err)
+		}
+		closer = f.Close
+	default:
+		return noop, nil
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String("sporkfs"),
+			semconv.ServiceInstanceIDKey.String(thisPeer),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			log.Error("flushing traces", zap.Error(err))
+		}
+		if closer != nil {
+			_ = closer()
+		}
+	}, nil
+}
+
+// Start opens a new span as a child of whatever span is in ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, oteltrace.WithAttributes(attrs...))
+}
+
+// End records the error on the span, if there is one, and ends the span. It is meant to be deferred
+// with a pointer to a named error return value.
+func End(span Span, err *error) {
+	if err != nil && *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
+
+// AddEvent adds the event to the span in ctx.
+func AddEvent(ctx context.Context, name string) {
+	oteltrace.SpanFromContext(ctx).AddEvent(name)
+}
+
+// Detach returns a context that carries the span from ctx but not its deadline or cancellation. It's useful
+// for work that is started by a request but should outlive it.
+func Detach(ctx context.Context) context.Context {
+	return oteltrace.ContextWithSpan(context.Background(), oteltrace.SpanFromContext(ctx))
+}
+
+func Id(id uint64) attribute.KeyValue {
+	return attribute.Int64("id", int64(id))
+}
+
+func Ver(v uint64) attribute.KeyValue {
+	return attribute.Int64("version", int64(v))
+}
+
+func Name(n string) attribute.KeyValue {
+	return attribute.String("name", n)
+}
+
+func Peer(p string) attribute.KeyValue {
+	return attribute.String("peer", p)
+}
+
+// ServerOption instruments the streaming RPCs of a grpc server. Unary RPCs (raft's Step) aren't traced
+// since they are sent multiple times a second regardless of any file system activity.
+func ServerOption() grpc.ServerOption {
+	return grpc.StreamInterceptor(otelgrpc.StreamServerInterceptor())
+}
+
+// DialOption propagates the span context of streaming RPCs to the server.
+func DialOption() grpc.DialOption {
+	return grpc.WithStreamInterceptor(otelgrpc.StreamClientInterceptor())
+}
+
+func noop() {}
@@ -0,0 +1,94 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans makes the global tracer provider record the ended spans.
+func recordSpans() *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	return recorder
+}
+
+func TestEnd(t *testing.T) {
+	testCases := map[string]struct {
+		err    error
+		status codes.Code
+	}{
+		"without an error": {status: codes.Unset},
+		"with an error":    {err: errors.New("failed"), status: codes.Error},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			recorder := recordSpans()
+			ctx, span := Start(context.Background(), "parent", Id(1))
+			_, child := Start(ctx, "child", Name("file"))
+			err := tc.err
+			End(child, &err)
+			End(span, nil)
+
+			ended := recorder.Ended()
+			require.Len(t, ended, 2)
+			require.Equal(t, "child", ended[0].Name())
+			require.Equal(t, span.SpanContext().SpanID(), ended[0].Parent().SpanID())
+			require.Equal(t, tc.status, ended[0].Status().Code)
+			require.Equal(t, codes.Unset, ended[1].Status().Code)
+			if tc.err != nil {
+				require.Len(t, ended[0].Events(), 1, "the error is recorded as an event")
+			}
+		})
+	}
+}
+
+func TestDetach(t *testing.T) {
+	recordSpans()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	ctx, span := Start(ctx, "request")
+	defer span.End()
+	cancel()
+
+	detached := Detach(ctx)
+	require.NoError(t, detached.Err())
+	_, ok := detached.Deadline()
+	require.False(t, ok)
+	require.Equal(t, span.SpanContext(), oteltrace.SpanFromContext(detached).SpanContext())
+}
+
+func TestInit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sporkfs-trace")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer otel.SetTracerProvider(oteltrace.NewNoopTracerProvider())
+
+	// nothing is exported without an endpoint or a file
+	flush, err := Init(Config{}, "peer")
+	require.NoError(t, err)
+	flush()
+
+	path := filepath.Join(dir, "spans.json")
+	flush, err = Init(Config{File: path}, "peer")
+	require.NoError(t, err)
+	_, span := Start(context.Background(), "spork.Test")
+	span.End()
+	flush()
+
+	exported, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(exported), "spork.Test")
+	require.Contains(t, string(exported), "peer")
+}